@@ -0,0 +1,583 @@
+// Command aggregate merges the results of extract runs over many index
+// files, usually of several payers, into one dataset. Employer plans are
+// listed by more than one carrier index, so the same rate file turns up in
+// the results of each: aggregate writes every rate file once, by its
+// canonical location, with the EINs and descriptions of all results listing
+// it and the payers whose index did.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/logging"
+	"serif_interview/pkg/output"
+)
+
+var outputPath = ""
+var outputFormat = string(output.FormatJSON)
+var outputColumns = ""
+var outputHeader = true
+var groupBy = byLocation
+var isVerbose = false
+var logLevel = "info"
+var logFormat = logging.FormatText
+var inputFilenames []string
+
+const (
+	// byLocation writes one aggregateMatch per rate file.
+	byLocation = "location"
+	// byEIN writes one employer per EIN, with the payers and rate files
+	// listing it.
+	byEIN = "ein"
+)
+
+// defaultColumns are the csv columns of each -by, when -columns is not
+// given.
+var defaultColumns = map[string]string{
+	byLocation: "location,payers,eins,descriptions,planType,score,records",
+	byEIN:      "ein,payers,locations,descriptions",
+}
+
+// locationModes are the extract modes whose results list rate files.
+var locationModes = []string{string(extract.ModeHeuristics), string(extract.ModeAnalysis), "fileSets"}
+
+// datePrefixPattern is the date extract -drift-history strips from index
+// filenames to name their payer.
+var datePrefixPattern = regexp.MustCompile(`^[\d_-]+`)
+
+// out receives the aggregate, stdout unless -out is given.
+var out io.Writer = os.Stdout
+
+// results formats everything written to out.
+var results *output.Writer
+
+func main() {
+	if err := parseArgs(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			output.Logf(output.CodeUsage, "%v", err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	var outFile *output.File
+	if outputPath != "" {
+		f, err := output.CreateFile(outputPath)
+		if err != nil {
+			output.Logf(output.CodeOutputFailed, "%v", err)
+			os.Exit(1)
+		}
+		outFile = f
+		out = f
+	}
+
+	var err error
+	opts := output.Options{
+		Format:   output.Format(outputFormat),
+		NoHeader: !outputHeader,
+		Errors:   os.Stderr,
+	}
+	if outputColumns == "" {
+		outputColumns = defaultColumns[groupBy]
+	}
+	for _, column := range strings.Split(outputColumns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			opts.Columns = append(opts.Columns, column)
+		}
+	}
+	results, err = output.NewWriter(out, opts)
+	if err != nil {
+		output.Logf(output.CodeOutputFailed, "write output: %v", err)
+		if outFile != nil {
+			outFile.Abort()
+		}
+		os.Exit(1)
+	}
+
+	startTime := time.Now()
+	results.Meta("starttime", startTime.Format(time.DateTime))
+
+	exitCode := 0
+	if err := run(); err != nil {
+		output.Logf(output.CodeRunFailed, "%v", err)
+		results.Error(struct {
+			Code  output.Code `json:"code"`
+			Error string      `json:"error"`
+		}{Code: output.CodeRunFailed, Error: err.Error()})
+		exitCode = 1
+	}
+
+	results.Meta("endtime", time.Now().Format(time.DateTime))
+	results.Meta("duration", time.Since(startTime).String())
+
+	closeErr := results.Close()
+	if closeErr != nil {
+		output.Logf(output.CodeOutputFailed, "write output: %v", closeErr)
+		exitCode = 1
+	}
+
+	if outFile != nil {
+		if closeErr != nil {
+			outFile.Abort()
+		} else if err := outFile.Commit(); err != nil {
+			output.Logf(output.CodeOutputFailed, "%v", err)
+			exitCode = 1
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+func parseArgs(args []string) error {
+	fs := flag.NewFlagSet("aggregate", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "merge the results of extract runs over many index files into one deduplicated dataset")
+		fmt.Fprintln(w, "usage: aggregate [options] <results>...")
+		fmt.Fprintln(w, " <results> - json, object or ndjson output of extract in heuristics, analysis or fileSets mode, options may come before or after it")
+		fmt.Fprintln(w, " options:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&outputPath, "out", "", "write the aggregate to this file instead of stdout, replacing it once it is complete, gzip compressed when the name ends in .gz")
+	fs.StringVar(&outputPath, "o", "", "shorthand for -out")
+	fs.StringVar(&outputFormat, "format", outputFormat, "json for an array of records, object for one {meta, matches, errors} object, ndjson for one json object per line, csv for rows of -columns, parquet for a parquet file of -columns")
+	fs.StringVar(&groupBy, "by", groupBy, "location for one record per rate file with the payers, EINs and descriptions listing it, ein for one record per employer EIN with the payers and rate files listing it")
+	fs.StringVar(&outputColumns, "columns", "", "comma separated csv or parquet columns, json field names, defaults depend on -by")
+	fs.BoolVar(&outputHeader, "header", outputHeader, "write a csv header row")
+	fs.BoolVar(&isVerbose, "v", false, "log progress details to stderr, the same as -log-level=debug")
+	fs.StringVar(&logLevel, "log-level", logLevel, "lowest level logged to stderr, debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", logFormat, "text for key=value log lines, json for one json object per line")
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		inputFilenames = append(inputFilenames, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(inputFilenames) == 0 {
+		fs.Usage()
+		return errors.New("at least 1 results file expected")
+	}
+	if !slices.Contains(output.Formats, output.Format(outputFormat)) {
+		return fmt.Errorf("unknown format %q, expected one of %v", outputFormat, output.Formats)
+	}
+	if groupBy != byLocation && groupBy != byEIN {
+		return fmt.Errorf("unknown -by %q, expected %s or %s", groupBy, byLocation, byEIN)
+	}
+
+	return logging.Setup(os.Stderr, logging.Options{Level: logLevel, Verbose: isVerbose, Format: logFormat})
+}
+
+func run() error {
+	a := newAggregate()
+	for _, filename := range inputFilenames {
+		slog.Debug("reading", "file", filename)
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		matches, err := readResults(f, filename)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, m := range matches {
+			a.add(m)
+		}
+		slog.Debug("read", "file", filename, "matches", len(matches))
+	}
+
+	summary := a.summary(len(inputFilenames))
+	slog.Info("aggregated", "results", summary.Results, "matches", summary.Matches, "locations", summary.Locations, "sharedLocations", summary.SharedLocations, "payers", summary.Payers, "eins", summary.EINs)
+	results.Meta("aggregate", summary)
+
+	if groupBy == byEIN {
+		for _, e := range a.employers() {
+			if err := results.Match(e); err != nil {
+				output.Logf(output.CodeSerialize, "marshal employer: %v", err)
+			}
+		}
+		return nil
+	}
+	for _, m := range a.matches {
+		if err := results.Match(m); err != nil {
+			output.Logf(output.CodeSerialize, "marshal match: %v", err)
+		}
+	}
+	return nil
+}
+
+// source is where a match was found, the results file and, when it names
+// them, the index file and its payer.
+type source struct {
+	Payer   string `json:"payer"`
+	Index   string `json:"index,omitempty"`
+	Results string `json:"results"`
+}
+
+// sourcedMatch is a match of a results file with its source.
+type sourcedMatch struct {
+	extract.Match
+	source source
+}
+
+// aggregateMatch is a rate file listed in one or more results, with the
+// EINs, descriptions and payers of all of them. Score and aiMatch are the
+// strongest of the analysis results listing it.
+type aggregateMatch struct {
+	Location     string   `json:"location"`
+	Payers       []string `json:"payers"`
+	Sources      []source `json:"sources"`
+	Eins         []string `json:"eins,omitempty"`
+	Descriptions []string `json:"descriptions,omitempty"`
+	PlanType     string   `json:"planType,omitempty"`
+	Score        float64  `json:"score,omitempty"`
+	AIMatch      bool     `json:"aiMatch,omitempty"`
+	Records      int      `json:"records"`
+}
+
+// employer is an EIN with the payers and rate files listing a plan of it.
+type employer struct {
+	EIN          string   `json:"ein"`
+	Payers       []string `json:"payers"`
+	Locations    []string `json:"locations"`
+	Descriptions []string `json:"descriptions,omitempty"`
+}
+
+// aggregateSummary is the aggregate meta record.
+type aggregateSummary struct {
+	Results   int `json:"results"`
+	Matches   int `json:"matches"`
+	Locations int `json:"locations"`
+	// SharedLocations are the rate files more than one payer lists.
+	SharedLocations int `json:"sharedLocations"`
+	Payers          int `json:"payers"`
+	EINs            int `json:"eins"`
+}
+
+// aggregate merges matches by extract.CanonicalLocation, so rate file urls
+// signed anew by every index are one.
+type aggregate struct {
+	matches []*aggregateMatch
+	index   map[string]*aggregateMatch
+	added   int
+}
+
+func newAggregate() *aggregate {
+	return &aggregate{index: make(map[string]*aggregateMatch)}
+}
+
+func (a *aggregate) add(m sourcedMatch) {
+	a.added++
+	key := extract.CanonicalLocation(m.Location)
+	merged, ok := a.index[key]
+	if !ok {
+		merged = &aggregateMatch{Location: m.Location}
+		a.index[key] = merged
+		a.matches = append(a.matches, merged)
+	}
+	merged.Records += max(m.Records, 1)
+	if !slices.Contains(merged.Payers, m.source.Payer) {
+		merged.Payers = append(merged.Payers, m.source.Payer)
+	}
+	if !slices.Contains(merged.Sources, m.source) {
+		merged.Sources = append(merged.Sources, m.source)
+	}
+	// payers publish EINs with and without the hyphen
+	for _, ein := range m.Eins {
+		if ein = extract.NormalizeEIN(ein); ein != "" && !slices.Contains(merged.Eins, ein) {
+			merged.Eins = append(merged.Eins, ein)
+		}
+	}
+	descriptions := m.Descriptions
+	if len(descriptions) == 0 && m.Description != "" {
+		descriptions = []string{m.Description}
+	}
+	for _, description := range descriptions {
+		if !slices.Contains(merged.Descriptions, description) {
+			merged.Descriptions = append(merged.Descriptions, description)
+		}
+	}
+	if merged.PlanType == "" {
+		merged.PlanType = m.PlanType
+	}
+	merged.Score = max(merged.Score, m.Score)
+	merged.AIMatch = merged.AIMatch || m.AIMatch
+}
+
+// employers lists the EINs of the matches in order of first appearance.
+func (a *aggregate) employers() []*employer {
+	var employers []*employer
+	byEIN := make(map[string]*employer)
+	for _, m := range a.matches {
+		for _, ein := range m.Eins {
+			e, ok := byEIN[ein]
+			if !ok {
+				e = &employer{EIN: ein}
+				byEIN[ein] = e
+				employers = append(employers, e)
+			}
+			e.Locations = append(e.Locations, m.Location)
+			for _, payer := range m.Payers {
+				if !slices.Contains(e.Payers, payer) {
+					e.Payers = append(e.Payers, payer)
+				}
+			}
+			for _, description := range m.Descriptions {
+				if !slices.Contains(e.Descriptions, description) {
+					e.Descriptions = append(e.Descriptions, description)
+				}
+			}
+		}
+	}
+	return employers
+}
+
+func (a *aggregate) summary(resultFiles int) aggregateSummary {
+	s := aggregateSummary{Results: resultFiles, Matches: a.added, Locations: len(a.matches)}
+	payers := make(map[string]struct{})
+	eins := make(map[string]struct{})
+	for _, m := range a.matches {
+		if len(m.Payers) > 1 {
+			s.SharedLocations++
+		}
+		for _, payer := range m.Payers {
+			payers[payer] = struct{}{}
+		}
+		for _, ein := range m.Eins {
+			eins[ein] = struct{}{}
+		}
+	}
+	s.Payers = len(payers)
+	s.EINs = len(eins)
+	return s
+}
+
+// resultRecord is a record of a results file in the order written, a match
+// or the header of an index file.
+type resultRecord struct {
+	match *sourcedMatch
+	// header is set for the index meta record, and file as well for the
+	// header record of a -manifest run
+	header *extract.IndexHeader
+	file   string
+	payer  string
+}
+
+// readResults reads the matches of the json, object or ndjson output of an
+// extract run over one or more index files and attributes each to the payer
+// of its index file. A -manifest run names the index file of every match.
+// Otherwise analysis mode writes the matches of a file before its index
+// record and the other modes after it. Results without either are
+// attributed to their single input, or to the results file.
+func readResults(r io.Reader, filename string) ([]sourcedMatch, error) {
+	var records []resultRecord
+	var prov struct {
+		Mode   string `json:"mode"`
+		Inputs []struct {
+			File string `json:"file"`
+		} `json:"inputs"`
+	}
+	manifestPayers := make(map[string]string)
+
+	var add func(raw json.RawMessage) error
+	add = func(raw json.RawMessage) error {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			return nil
+		}
+		if raw[0] == '"' {
+			// heuristics mode writes bare locations
+			var location string
+			if err := json.Unmarshal(raw, &location); err != nil {
+				return err
+			}
+			records = append(records, resultRecord{match: &sourcedMatch{Match: extract.Match{Location: location}}})
+			return nil
+		}
+		var fields map[string]json.RawMessage
+		if raw[0] != '{' || json.Unmarshal(raw, &fields) != nil {
+			return nil
+		}
+
+		if len(fields) == 1 {
+			for key, value := range fields {
+				switch key {
+				case "match":
+					// a bare value of ndjson output
+					return add(value)
+				case "index":
+					var header extract.IndexHeader
+					if json.Unmarshal(value, &header) == nil {
+						records = append(records, resultRecord{header: &header})
+					}
+				case "provenance":
+					json.Unmarshal(value, &prov)
+				}
+			}
+			return nil
+		}
+		if matches, ok := fields["matches"]; ok {
+			// the object format
+			// only the last index header is kept in meta
+			var meta struct {
+				Index      *extract.IndexHeader `json:"index"`
+				Provenance json.RawMessage      `json:"provenance"`
+			}
+			if json.Unmarshal(fields["meta"], &meta) == nil {
+				if meta.Index != nil {
+					records = append(records, resultRecord{header: meta.Index})
+				}
+				json.Unmarshal(meta.Provenance, &prov)
+			}
+			var elements []json.RawMessage
+			if err := json.Unmarshal(matches, &elements); err != nil {
+				return fmt.Errorf("read matches: %w", err)
+			}
+			for _, element := range elements {
+				if err := add(element); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var file string
+		json.Unmarshal(fields["file"], &file)
+		if _, ok := fields["payer"]; ok && file != "" {
+			// the header of a file of a -manifest run
+			var header struct {
+				Payer string              `json:"payer"`
+				Index extract.IndexHeader `json:"index"`
+			}
+			if json.Unmarshal(raw, &header) == nil {
+				manifestPayers[file] = cmp.Or(header.Index.ReportingEntityName, header.Payer)
+			}
+			return nil
+		}
+		if _, ok := fields["location"]; ok {
+			var m extract.Match
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return fmt.Errorf("read match: %w", err)
+			}
+			records = append(records, resultRecord{match: &sourcedMatch{Match: m}, file: file})
+			return nil
+		}
+		if _, ok := fields["locations"]; ok {
+			// a fileSets mode network
+			var set extract.FileSet
+			if err := json.Unmarshal(raw, &set); err != nil {
+				return fmt.Errorf("read file set: %w", err)
+			}
+			for _, location := range set.Locations {
+				records = append(records, resultRecord{match: &sourcedMatch{Match: extract.Match{Location: location}}, file: file})
+			}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read results: %w", err)
+		}
+		var elements []json.RawMessage
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &elements); err != nil {
+				return nil, fmt.Errorf("read results: %w", err)
+			}
+		} else {
+			elements = []json.RawMessage{raw}
+		}
+		for _, element := range elements {
+			if err := add(element); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if prov.Mode != "" && !slices.Contains(locationModes, prov.Mode) {
+		return nil, fmt.Errorf("%s mode results list no rate files, expected one of %v", prov.Mode, locationModes)
+	}
+
+	fallback := source{Payer: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)), Results: filename}
+	if len(prov.Inputs) == 1 {
+		fallback.Index = prov.Inputs[0].File
+		fallback.Payer = payerOf(fallback.Index)
+	}
+
+	var matches []sourcedMatch
+	// the header the matches without a file belong to, by mode
+	var header *extract.IndexHeader
+	var waiting []int
+	for _, record := range records {
+		if record.header != nil {
+			header = record.header
+			if prov.Mode == string(extract.ModeAnalysis) {
+				for _, i := range waiting {
+					matches[i].source = headerSource(header, fallback)
+				}
+				waiting = waiting[:0]
+			}
+			continue
+		}
+		m := *record.match
+		switch {
+		case record.file != "":
+			m.source = source{Payer: cmp.Or(manifestPayers[record.file], payerOf(record.file)), Index: record.file, Results: filename}
+		case prov.Mode == string(extract.ModeAnalysis):
+			waiting = append(waiting, len(matches))
+			m.source = fallback
+		case header != nil:
+			m.source = headerSource(header, fallback)
+		default:
+			m.source = fallback
+		}
+		matches = append(matches, m)
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no rate file matches")
+	}
+	return matches, nil
+}
+
+// headerSource names the payer of header, keeping the index file of
+// fallback only when the results have a single input.
+func headerSource(header *extract.IndexHeader, fallback source) source {
+	s := fallback
+	if header.ReportingEntityName != "" {
+		s.Payer = header.ReportingEntityName
+	}
+	return s
+}
+
+// payerOf names the payer of an index file like extract -drift-history, by
+// its filename without the date prefix.
+func payerOf(file string) string {
+	if u, err := url.Parse(file); err == nil && u.Scheme != "" {
+		file = u.Path
+	}
+	return datePrefixPattern.ReplaceAllString(filepath.Base(file), "")
+}
@@ -0,0 +1,338 @@
+// Command demo runs the whole pipeline on synthetic data. It writes a small
+// index file and the rate files it references, serves them from a local
+// http server, extracts the index in every mode, from disk and streamed,
+// downloads the matched rate files and parses their rates. It needs no
+// network access or llm, and fails when a step does not produce the results
+// the synthetic data is built for, so it doubles as an integration test.
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/fhir"
+	"serif_interview/pkg/llm"
+	"serif_interview/pkg/output"
+	"serif_interview/pkg/rates"
+)
+
+var demoDir = ""
+var outputFormat = string(output.FormatNDJSON)
+
+var results *output.Writer
+
+func main() {
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "end-to-end demo on synthetic data")
+		fmt.Fprintln(w, "usage: demo [options]")
+		fmt.Fprintln(w, " options:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&demoDir, "dir", "", "write the synthetic files and downloads into this directory and keep them, a temporary directory otherwise")
+	fs.StringVar(&outputFormat, "format", outputFormat, "json, object or ndjson")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	var err error
+	results, err = output.NewWriter(os.Stdout, output.Options{Format: output.Format(outputFormat)})
+	if err != nil {
+		slog.Error(err.Error(), "code", output.CodeUsage)
+		os.Exit(2)
+	}
+
+	startTime := time.Now()
+	results.Meta("starttime", startTime.Format(time.DateTime))
+
+	exitCode := 0
+	if err := run(); err != nil {
+		slog.Error(err.Error(), "code", output.CodeRunFailed)
+		results.Error(struct {
+			Code  output.Code `json:"code"`
+			Error string      `json:"error"`
+		}{Code: output.CodeRunFailed, Error: err.Error()})
+		exitCode = 1
+	}
+
+	results.Meta("duration", time.Since(startTime).String())
+	if err := results.Close(); err != nil {
+		slog.Error("write output: "+err.Error(), "code", output.CodeOutputFailed)
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+func run() error {
+	dir := demoDir
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "demo-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	}
+	filesDir := filepath.Join(dir, "files")
+	if err := os.MkdirAll(filesDir, 0o755); err != nil {
+		return err
+	}
+	results.Meta("dir", dir)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(filesDir)))
+	defer server.Close()
+
+	indexFile, err := writeSyntheticData(filesDir, server.URL)
+	if err != nil {
+		return fmt.Errorf("write synthetic data: %w", err)
+	}
+
+	var failed []error
+	expect := func(step string, got int, want int) {
+		results.Meta(step, got)
+		if got != want {
+			failed = append(failed, fmt.Errorf("%s: got %d results, expected %d", step, got, want))
+		}
+	}
+
+	heuristics, err := extractIndex(extract.ModeHeuristics, indexFile)
+	if err != nil {
+		return err
+	}
+	for _, location := range heuristics.PpoPrices() {
+		step("heuristics", location)
+	}
+	expect("heuristics", len(heuristics.PpoPrices()), len(ppoShards))
+	for _, drugFile := range heuristics.DrugFiles() {
+		step("drugFiles", drugFile)
+	}
+	expect("drugFiles", len(heuristics.DrugFiles()), 1)
+
+	sets := heuristics.FileSets()
+	for _, set := range sets {
+		step("fileSets", set)
+		expect("missingShards", len(set.MissingShards), 0)
+	}
+	expect("fileSets", len(sets), 1)
+
+	plans, err := extractIndex(extract.ModeUniquePlans, indexFile)
+	if err != nil {
+		return err
+	}
+	for _, name := range plans.UniquePlans() {
+		step("uniquePlans", name)
+	}
+	// unique plans are the descriptions of rate files, the drug file left out
+	expect("uniquePlans", len(plans.UniquePlans()), len(syntheticPlans))
+
+	analysis, err := extractIndex(extract.ModeAnalysis, indexFile)
+	if err != nil {
+		return err
+	}
+	expect("analysis", analysis.Stats().Matches, len(ppoShards))
+
+	coverage, err := extractIndex(extract.ModeCoverage, indexFile)
+	if err != nil {
+		return err
+	}
+	resources := fhir.Resources(coverage.Header(), coverage.Coverage())
+	for _, resource := range resources {
+		step("fhir", resource)
+	}
+	if len(resources) == 0 {
+		failed = append(failed, errors.New("fhir: no resources"))
+	}
+
+	// the same index streamed from the server
+	streamed, err := extractIndex(extract.ModeHeuristics, server.URL+"/"+filepath.Base(indexFile))
+	if err != nil {
+		return err
+	}
+	expect("streamed", len(streamed.PpoPrices()), len(ppoShards))
+
+	downloader := download.New(download.Options{Dir: filepath.Join(dir, "downloads"), Workers: 2, Retries: 1})
+	downloads := downloader.DownloadAll(context.Background(), heuristics.PpoPrices())
+	downloaded := 0
+	for _, result := range downloads {
+		step("download", result)
+		if result.Error == "" {
+			downloaded++
+		}
+	}
+	expect("downloads", downloaded, len(ppoShards))
+
+	rateCount := 0
+	parser := rates.New(rates.Options{OnRate: func(rate rates.Rate) {
+		step("rate", rate)
+		rateCount++
+	}})
+	for _, result := range downloads {
+		if result.Error != "" {
+			continue
+		}
+		if err := parser.ParseFile(result.Path); err != nil {
+			return fmt.Errorf("parse rates of %s: %w", result.URL, err)
+		}
+	}
+	expect("rates", rateCount, len(ppoShards)*len(syntheticCodes))
+
+	return errors.Join(failed...)
+}
+
+// extractIndex parses filename in mode, analysis asking the none llm so the
+// demo runs without one.
+func extractIndex(mode extract.Mode, filename string) (*extract.Extractor, error) {
+	client, err := llm.New(llm.BackendNone, llm.Options{})
+	if err != nil {
+		return nil, err
+	}
+	var matches []extract.Match
+	extractor := extract.New(extract.Options{
+		Mode: mode,
+		LLM:  client,
+		OnMatch: func(match extract.Match) {
+			matches = append(matches, match)
+		},
+	})
+	if err := extractor.ParseFile(filename); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", mode, filename, err)
+	}
+	for _, match := range matches {
+		step(string(mode), match)
+	}
+	return extractor, nil
+}
+
+func step(name string, result any) {
+	results.Match(struct {
+		Step   string `json:"step"`
+		Result any    `json:"result"`
+	}{Step: name, Result: result})
+}
+
+// syntheticPlans are the reporting plans of the index, the first one uses the
+// sharded PPO network.
+var syntheticPlans = []string{
+	"excellus bcbs : blueppo",
+	"demo health : texas hmo",
+}
+
+// ppoShards are the rate files of an allow-listed plan carrying a New York
+// region plan code, split into two shards.
+var ppoShards = []string{
+	"2026-01_301_71A0_in-network-rates_1_of_2.json.gz",
+	"2026-01_301_71A0_in-network-rates_2_of_2.json.gz",
+}
+
+const otherRates = "2026-01_999_99Z9_in-network-rates.json.gz"
+
+var syntheticCodes = []string{"99213", "70450"}
+
+// writeSyntheticData writes the rate files and an index referencing them at
+// baseURL into dir, and returns the index filename.
+func writeSyntheticData(dir string, baseURL string) (string, error) {
+	for i, name := range append([]string{otherRates}, ppoShards...) {
+		var inNetwork []rates.Rate
+		for j, code := range syntheticCodes {
+			inNetwork = append(inNetwork, rates.Rate{
+				NegotiationArrangement: "ffs",
+				Name:                   "demo service " + code,
+				BillingCodeType:        "CPT",
+				BillingCodeTypeVersion: "2026",
+				BillingCode:            code,
+				Description:            "demo service " + code,
+				NegotiatedRates: []rates.NegotiatedRate{{
+					ProviderGroups: []rates.ProviderGroup{{
+						NPI: []rates.NPI{rates.NPI(fmt.Sprint(1234567890 + i))},
+						TIN: rates.TIN{Type: "ein", Value: "12-3456789"},
+					}},
+					NegotiatedPrices: []rates.NegotiatedPrice{{
+						NegotiatedType: "negotiated",
+						NegotiatedRate: float64(100 + 10*i + j),
+						ExpirationDate: "9999-12-31",
+						ServiceCode:    []string{"11"},
+						BillingClass:   "professional",
+					}},
+				}},
+			})
+		}
+		err := writeGzipJSON(filepath.Join(dir, name), map[string]any{
+			"reporting_entity_name": "Demo Health",
+			"reporting_entity_type": "health insurance issuer",
+			"last_updated_on":       "2026-01-01",
+			"version":               "1.0.0",
+			"in_network":            inNetwork,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var ppoFiles []map[string]string
+	for _, name := range ppoShards {
+		ppoFiles = append(ppoFiles, map[string]string{"description": syntheticPlans[0], "location": baseURL + "/" + name})
+	}
+	index := map[string]any{
+		"reporting_entity_name": "Demo Health",
+		"reporting_entity_type": "health insurance issuer",
+		"version":               "1.0.0",
+		"reporting_structure": []map[string]any{
+			{
+				"reporting_plans": []map[string]string{{
+					"plan_name":        syntheticPlans[0],
+					"plan_id_type":     "EIN",
+					"plan_id":          "12-3456789",
+					"plan_market_type": "group",
+				}},
+				"in_network_files": ppoFiles,
+			},
+			{
+				"reporting_plans": []map[string]string{{
+					"plan_name":        syntheticPlans[1],
+					"plan_id_type":     "EIN",
+					"plan_id":          "98-7654321",
+					"plan_market_type": "individual",
+				}},
+				"in_network_files": []map[string]string{
+					{"description": syntheticPlans[1], "location": baseURL + "/" + otherRates},
+					{"description": "prescription drug pricing", "location": baseURL + "/2026-01_demo_prescription-drugs.json.gz"},
+				},
+			},
+		},
+	}
+	indexFile := filepath.Join(dir, "2026-01_demo_index.json.gz")
+	return indexFile, writeGzipJSON(indexFile, index)
+}
+
+func writeGzipJSON(filename string, value any) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(f)
+	if err := json.NewEncoder(gw).Encode(value); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+const (
+	scopeRead  = "read"
+	scopeAdmin = "admin"
+)
+
+var adminAddr = ""
+var apiKeysPath = ""
+var apiKeyGrace = time.Hour
+
+// adminKeyEnv holds a bootstrap admin key that is always accepted, so the
+// first rotation does not need a key file.
+const adminKeyEnv = "EXTRACT_ADMIN_KEY"
+
+var errJobCancelled = errors.New("job cancelled by admin")
+
+// rules are the reloadable match settings, the -plans allow-list, the -deny
+// deny-list and the region codes of -state from -regions.
+var rulesMu sync.RWMutex
+var loadedPlans *extract.PlanList
+var loadedDeny *extract.PlanList
+var loadedRegions map[string]struct{}
+var loadedRulesVersion string
+
+func loadRules() error {
+	var plans *extract.PlanList
+	if plansPath != "" {
+		var err error
+		plans, err = extract.LoadPlanList(plansPath)
+		// -learn starts the files it adds to
+		if err != nil && !(learn && errors.Is(err, os.ErrNotExist)) {
+			return err
+		}
+	} else if profilePlans != nil {
+		var err error
+		plans, err = extract.ParsePlanList("profile "+profileName+" plans", profilePlans)
+		if err != nil {
+			return err
+		}
+	}
+
+	var deny *extract.PlanList
+	if denyPath != "" {
+		var err error
+		if deny, err = extract.LoadPlanList(denyPath); err != nil {
+			return err
+		}
+	} else if profileDeny != nil {
+		var err error
+		if deny, err = extract.ParsePlanList("profile "+profileName+" deny", profileDeny); err != nil {
+			return err
+		}
+	}
+
+	var registry map[string][]string
+	if regionsPath != "" {
+		var err error
+		registry, err = extract.LoadRegions(regionsPath)
+		if err != nil && !(learn && errors.Is(err, os.ErrNotExist)) {
+			return err
+		}
+	} else if profileRegions != nil {
+		var err error
+		registry, err = extract.ParseRegions("profile "+profileName+" regions", profileRegions)
+		if err != nil {
+			return err
+		}
+	}
+	regions, err := extract.RegionCodesForState(registry, state)
+	if err != nil {
+		if !extract.KnownState(state) {
+			return err
+		}
+		// no codes for the state, heuristics mode goes by its name
+		regions = map[string]struct{}{}
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	loadedPlans = plans
+	loadedDeny = deny
+	loadedRegions = regions
+	loadedRulesVersion = rulePackVersion(plans, deny, regions)
+	return nil
+}
+
+// applyRules copies the currently loaded rules into opts.
+func applyRules(opts *extract.Options) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	if loadedPlans != nil {
+		opts.PpoPlans = loadedPlans.Names
+		opts.PlanPatterns = loadedPlans.Patterns
+	}
+	if loadedDeny != nil {
+		opts.DenyPlans = loadedDeny.Names
+		opts.DenyPatterns = loadedDeny.Patterns
+	}
+	opts.RegionCodes = loadedRegions
+}
+
+// Job is one index file processed by the listener.
+type Job struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	Status   string `json:"status"`
+	Started  string `json:"started"`
+	Finished string `json:"finished,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+	// events are the records of a running job, replayed to new subscribers
+	events      []jobEvent
+	subscribers map[chan jobEvent]struct{}
+}
+
+// jobHistory is how many finished jobs stay listed.
+const jobHistory = 100
+
+var jobsMu sync.Mutex
+var jobs []*Job
+var nextJobID = 1
+
+func startJob(source string, cancel context.CancelFunc) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	job := &Job{
+		ID:      strconv.Itoa(nextJobID),
+		Source:  source,
+		Status:  "running",
+		Started: time.Now().Format(time.DateTime),
+		cancel:  cancel,
+	}
+	nextJobID++
+	jobs = append(jobs, job)
+	if len(jobs) > jobHistory {
+		jobs = jobs[len(jobs)-jobHistory:]
+	}
+	return job
+}
+
+func finishJob(job *Job, err error) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	job.Finished = time.Now().Format(time.DateTime)
+	switch {
+	case errors.Is(err, errJobCancelled):
+		job.Status = "cancelled"
+	case err != nil:
+		job.Status = "failed"
+		job.Error = err.Error()
+	default:
+		job.Status = "done"
+	}
+	job.cancel = nil
+	endJobEvents(job)
+}
+
+func listJobs() []Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	list := make([]Job, len(jobs))
+	for i, job := range jobs {
+		list[i] = job.snapshot()
+	}
+	return list
+}
+
+// snapshot copies the listed fields, jobsMu must be held.
+func (job *Job) snapshot() Job {
+	return Job{ID: job.ID, Source: job.Source, Status: job.Status, Started: job.Started, Finished: job.Finished, Error: job.Error}
+}
+
+func cancelJob(id string) bool {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	for _, job := range jobs {
+		if job.ID == id && job.cancel != nil {
+			job.cancel()
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is an entry of the -api-keys file. Only the sha256 of the key is
+// stored.
+type APIKey struct {
+	ID      string     `json:"id"`
+	Scope   string     `json:"scope"`
+	SHA256  string     `json:"sha256"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+var keysMu sync.Mutex
+var apiKeys []APIKey
+
+func loadAPIKeys() error {
+	if apiKeysPath == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(apiKeysPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read api keys: %w", err)
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if err := json.Unmarshal(content, &apiKeys); err != nil {
+		return fmt.Errorf("parse api keys %s: %w", apiKeysPath, err)
+	}
+	return nil
+}
+
+// authorized reports whether token grants scope, an admin key grants every
+// scope.
+func authorized(token string, scope string) bool {
+	if token == "" {
+		return false
+	}
+	if bootstrap := os.Getenv(adminKeyEnv); bootstrap != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(bootstrap)) == 1 {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	for _, key := range apiKeys {
+		if key.Expires != nil && now.After(*key.Expires) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(key.SHA256)) != 1 {
+			continue
+		}
+		return key.Scope == scopeAdmin || key.Scope == scope
+	}
+	return false
+}
+
+// rotateAPIKey issues a new key for scope and lets the previous keys of that
+// scope expire after -api-key-grace, so clients can switch over.
+func rotateAPIKey(scope string) (string, APIKey, error) {
+	if apiKeysPath == "" {
+		return "", APIKey{}, errors.New("key rotation requires -api-keys")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+	token := hex.EncodeToString(secret)
+	sum := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	expires := now.Add(apiKeyGrace)
+	for i := range apiKeys {
+		if apiKeys[i].Scope == scope && (apiKeys[i].Expires == nil || apiKeys[i].Expires.After(expires)) {
+			apiKeys[i].Expires = &expires
+		}
+	}
+	key := APIKey{
+		ID:      hex.EncodeToString(sum[:4]),
+		Scope:   scope,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Created: now,
+	}
+	apiKeys = append(apiKeys, key)
+
+	content, err := json.MarshalIndent(apiKeys, "", "  ")
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	tmp := apiKeysPath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return "", APIKey{}, fmt.Errorf("write api keys %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, apiKeysPath); err != nil {
+		return "", APIKey{}, fmt.Errorf("replace api keys %s: %w", apiKeysPath, err)
+	}
+
+	return token, key, nil
+}
+
+// adminHandler serves the admin API. Listing jobs needs a read or admin key,
+// everything that changes state needs an admin key.
+func adminHandler(cache *extract.LLMCache) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("GET /admin/jobs", requireScope(scopeRead, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, listJobs())
+	}))
+	mux.Handle("GET /admin/jobs/{id}/events", requireScope(scopeRead, streamJobEvents))
+	mux.Handle("POST /admin/jobs/{id}/cancel", requireScope(scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		if !cancelJob(r.PathValue("id")) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no running job " + r.PathValue("id")})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling"})
+	}))
+	mux.Handle("POST /admin/rules/reload", requireScope(scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		if err := loadRules(); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded", "plans": plansPath, "deny": denyPath, "regions": regionsPath, "state": state})
+	}))
+	mux.Handle("POST /admin/cache/flush", requireScope(scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		flushed := 0
+		if cache != nil {
+			flushed = cache.Prune(time.Now(), 0)
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"flushed": flushed})
+	}))
+	mux.Handle("POST /admin/keys/rotate", requireScope(scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		scope := r.URL.Query().Get("scope")
+		if scope != scopeRead && scope != scopeAdmin {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "scope must be read or admin"})
+			return
+		}
+		token, key, err := rotateAPIKey(scope)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Key string `json:"key"`
+			APIKey
+		}{Key: token, APIKey: key})
+	}))
+
+	return mux
+}
+
+func requireScope(scope string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !authorized(token, scope) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": scope + " scope required"})
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// startAdmin binds -admin-addr and serves the admin API in the background
+// until ctx ends.
+func startAdmin(ctx context.Context, cache *extract.LLMCache) error {
+	if err := loadAPIKeys(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", adminAddr)
+	if err != nil {
+		return fmt.Errorf("admin api: %w", err)
+	}
+
+	server := &http.Server{Handler: adminHandler(cache)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			output.Logf(output.CodeAdminAPI, "admin api: %v", err)
+		}
+	}()
+
+	slog.Debug("admin api listening", "addr", ln.Addr().String())
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useAPIKeys serves the admin API with keys as the -api-keys file, a key of
+// each scope the test names by its token.
+func useAPIKeys(t *testing.T, keys map[string]APIKey) *httptest.Server {
+	t.Helper()
+	oldPath, oldKeys, oldGrace := apiKeysPath, apiKeys, apiKeyGrace
+	t.Cleanup(func() { apiKeysPath, apiKeys, apiKeyGrace = oldPath, oldKeys, oldGrace })
+	t.Setenv(adminKeyEnv, "")
+
+	apiKeysPath = filepath.Join(t.TempDir(), "keys.json")
+	apiKeys = nil
+	for token, key := range keys {
+		sum := sha256.Sum256([]byte(token))
+		key.SHA256 = hex.EncodeToString(sum[:])
+		apiKeys = append(apiKeys, key)
+	}
+	server := httptest.NewServer(adminHandler(nil))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// adminStatus sends method to path of the admin API with token as the bearer
+// key, returning the status and decoding the body into result.
+func adminStatus(t *testing.T, server *httptest.Server, method, path, token string, result any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAdminScopes(t *testing.T) {
+	server := useAPIKeys(t, map[string]APIKey{
+		"read-token":  {ID: "r", Scope: scopeRead},
+		"admin-token": {ID: "a", Scope: scopeAdmin},
+	})
+
+	for _, tt := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/admin/jobs", "read-token", http.StatusOK},
+		{"GET", "/admin/jobs", "admin-token", http.StatusOK},
+		{"GET", "/admin/jobs", "", http.StatusForbidden},
+		{"GET", "/admin/jobs", "other-token", http.StatusForbidden},
+		{"POST", "/admin/cache/flush", "read-token", http.StatusForbidden},
+		{"POST", "/admin/jobs/1/cancel", "read-token", http.StatusForbidden},
+		{"POST", "/admin/rules/reload", "read-token", http.StatusForbidden},
+		{"POST", "/admin/keys/rotate?scope=admin", "read-token", http.StatusForbidden},
+		{"POST", "/admin/cache/flush", "admin-token", http.StatusOK},
+		{"POST", "/admin/jobs/1/cancel", "admin-token", http.StatusNotFound},
+		{"POST", "/admin/keys/rotate?scope=owner", "admin-token", http.StatusBadRequest},
+	} {
+		if got := adminStatus(t, server, tt.method, tt.path, tt.token, nil); got != tt.want {
+			t.Errorf("%s %s with %q: status %d, want %d", tt.method, tt.path, tt.token, got, tt.want)
+		}
+	}
+	if len(apiKeys) != 2 {
+		t.Errorf("%d keys after refused rotations, want 2", len(apiKeys))
+	}
+}
+
+func TestAdminExpiredKey(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	valid := time.Now().Add(time.Hour)
+	server := useAPIKeys(t, map[string]APIKey{
+		"expired-token": {ID: "e", Scope: scopeAdmin, Expires: &expired},
+		"valid-token":   {ID: "v", Scope: scopeAdmin, Expires: &valid},
+	})
+
+	if got := adminStatus(t, server, "GET", "/admin/jobs", "expired-token", nil); got != http.StatusForbidden {
+		t.Errorf("expired key: status %d, want %d", got, http.StatusForbidden)
+	}
+	if got := adminStatus(t, server, "GET", "/admin/jobs", "valid-token", nil); got != http.StatusOK {
+		t.Errorf("key expiring later: status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestAdminKeyRotation(t *testing.T) {
+	server := useAPIKeys(t, map[string]APIKey{
+		"old-admin": {ID: "a", Scope: scopeAdmin},
+		"old-read":  {ID: "r", Scope: scopeRead},
+	})
+	apiKeyGrace = 300 * time.Millisecond
+
+	var rotated struct {
+		Key string `json:"key"`
+		APIKey
+	}
+	if got := adminStatus(t, server, "POST", "/admin/keys/rotate?scope=admin", "old-admin", &rotated); got != http.StatusOK {
+		t.Fatalf("rotate: status %d", got)
+	}
+	sum := sha256.Sum256([]byte(rotated.Key))
+	if rotated.Key == "" || rotated.Scope != scopeAdmin || rotated.SHA256 != hex.EncodeToString(sum[:]) || rotated.Expires != nil {
+		t.Fatalf("rotated key %+v", rotated)
+	}
+
+	// both admin keys work during the grace period, the file keeps only hashes
+	for _, token := range []string{"old-admin", rotated.Key} {
+		if got := adminStatus(t, server, "POST", "/admin/cache/flush", token, nil); got != http.StatusOK {
+			t.Errorf("admin key during the grace period: status %d", got)
+		}
+	}
+	apiKeys = nil
+	if err := loadAPIKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(apiKeys) != 3 {
+		t.Fatalf("%d stored keys, want 3", len(apiKeys))
+	}
+	for _, key := range apiKeys {
+		if (key.Scope == scopeAdmin && key.ID == "a") != (key.Expires != nil) {
+			t.Errorf("stored key %+v, want only the old admin key expiring", key)
+		}
+	}
+
+	time.Sleep(apiKeyGrace + 100*time.Millisecond)
+	if got := adminStatus(t, server, "POST", "/admin/cache/flush", "old-admin", nil); got != http.StatusForbidden {
+		t.Errorf("old admin key after the grace period: status %d, want %d", got, http.StatusForbidden)
+	}
+	if got := adminStatus(t, server, "POST", "/admin/cache/flush", rotated.Key, nil); got != http.StatusOK {
+		t.Errorf("rotated key after the grace period: status %d", got)
+	}
+	if got := adminStatus(t, server, "GET", "/admin/jobs", "old-read", nil); got != http.StatusOK {
+		t.Errorf("read key after rotating the admin keys: status %d", got)
+	}
+}
+
+func TestAdminBootstrapKey(t *testing.T) {
+	server := useAPIKeys(t, nil)
+	t.Setenv(adminKeyEnv, "bootstrap-token")
+
+	if got := adminStatus(t, server, "POST", "/admin/cache/flush", "bootstrap-token", nil); got != http.StatusOK {
+		t.Errorf("bootstrap key: status %d", got)
+	}
+	if got := adminStatus(t, server, "POST", "/admin/cache/flush", "bootstrap-toke", nil); got != http.StatusForbidden {
+		t.Errorf("prefix of the bootstrap key: status %d, want %d", got, http.StatusForbidden)
+	}
+
+	// the first rotation needs no key file
+	var rotated struct {
+		Key string `json:"key"`
+	}
+	if got := adminStatus(t, server, "POST", "/admin/keys/rotate?scope=read", "bootstrap-token", &rotated); got != http.StatusOK {
+		t.Fatalf("rotate with the bootstrap key: status %d", got)
+	}
+	if got := adminStatus(t, server, "GET", "/admin/jobs", rotated.Key, nil); got != http.StatusOK {
+		t.Errorf("issued read key: status %d", got)
+	}
+
+	t.Setenv(adminKeyEnv, "")
+	if got := adminStatus(t, server, "POST", "/admin/cache/flush", "bootstrap-token", nil); got != http.StatusForbidden {
+		t.Errorf("bootstrap key once unset: status %d, want %d", got, http.StatusForbidden)
+	}
+
+	t.Setenv(adminKeyEnv, "bootstrap-token")
+	apiKeysPath = ""
+	if got := adminStatus(t, server, "POST", "/admin/keys/rotate?scope=read", "bootstrap-token", nil); got != http.StatusInternalServerError {
+		t.Errorf("rotate without -api-keys: status %d, want %d", got, http.StatusInternalServerError)
+	}
+}
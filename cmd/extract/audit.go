@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"serif_interview/pkg/extract"
+)
+
+var auditDir = ""
+var auditKeyPath = ""
+
+// auditKey signs the audit documents, nil when -audit-dir is not given.
+var auditKey ed25519.PrivateKey
+
+// runStartTime is when the run began, recorded in audit documents.
+var runStartTime time.Time
+
+// AuditDocument bundles the evidence of one analysis match for attaching to
+// regulatory or client deliverables.
+type AuditDocument struct {
+	ID        string              `json:"id"`
+	CreatedAt string              `json:"createdAt"`
+	RunStart  string              `json:"runStart"`
+	IndexFile string              `json:"indexFile"`
+	Header    extract.IndexHeader `json:"header"`
+	Match     extract.Match       `json:"match"`
+	Evidence  *extract.Evidence   `json:"evidence"`
+	LLM       auditLLM            `json:"llm"`
+	RulePack  auditRulePack       `json:"rulePack"`
+}
+
+type auditLLM struct {
+	Backend string `json:"backend"`
+	Model   string `json:"model,omitempty"`
+}
+
+type auditRulePack struct {
+	Version string `json:"version"`
+	Plans   string `json:"plans,omitempty"`
+	Deny    string `json:"deny,omitempty"`
+	Regions string `json:"regions,omitempty"`
+	State   string `json:"state"`
+}
+
+// signedAudit is the file layout. The signature covers the bytes of document
+// exactly as written.
+type signedAudit struct {
+	Document  json.RawMessage `json:"document"`
+	Signature auditSignature  `json:"signature"`
+}
+
+type auditSignature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+	Value     string `json:"value"`
+}
+
+// loadAuditKey reads the PKCS#8 PEM ed25519 private key of -audit-key, as
+// written by openssl genpkey -algorithm ed25519.
+func loadAuditKey() error {
+	if auditDir == "" {
+		return nil
+	}
+	if auditKeyPath == "" {
+		return errors.New("-audit-dir requires -audit-key")
+	}
+
+	content, err := os.ReadFile(auditKeyPath)
+	if err != nil {
+		return fmt.Errorf("read audit key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return fmt.Errorf("audit key %s is not PEM encoded", auditKeyPath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse audit key %s: %w", auditKeyPath, err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return fmt.Errorf("audit key %s is not an ed25519 key", auditKeyPath)
+	}
+
+	if err := os.MkdirAll(auditDir, 0o755); err != nil {
+		return fmt.Errorf("create audit dir: %w", err)
+	}
+	auditKey = ed
+	return nil
+}
+
+// writeAudits writes one signed document per match of filename into
+// -audit-dir, named after the payer and the document digest.
+func writeAudits(filename string, header extract.IndexHeader, matches []extract.Match) error {
+	if auditKey == nil {
+		return nil
+	}
+
+	rulesMu.RLock()
+	rulePack := auditRulePack{Version: loadedRulesVersion, Plans: plansPath, Deny: denyPath, Regions: regionsPath, State: state}
+	rulesMu.RUnlock()
+
+	for _, match := range matches {
+		doc := AuditDocument{
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			RunStart:  runStartTime.UTC().Format(time.RFC3339),
+			IndexFile: filename,
+			Header:    header,
+			Match:     match,
+			Evidence:  match.Evidence,
+			LLM:       auditLLM{Backend: llmBackend, Model: llmModel},
+			RulePack:  rulePack,
+		}
+		// the id is the digest of the document without it
+		content, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("marshal audit of %s: %w", match.Location, err)
+		}
+		sum := sha256.Sum256(content)
+		doc.ID = hex.EncodeToString(sum[:8])
+		if content, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("marshal audit of %s: %w", match.Location, err)
+		}
+
+		signed, err := json.Marshal(signedAudit{
+			Document: content,
+			Signature: auditSignature{
+				Algorithm: "ed25519",
+				PublicKey: base64.StdEncoding.EncodeToString(auditKey.Public().(ed25519.PublicKey)),
+				Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(auditKey, content)),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("marshal audit of %s: %w", match.Location, err)
+		}
+
+		name := filepath.Join(auditDir, payerKey(filename)+"-"+doc.ID+".json")
+		if err := os.WriteFile(name, append(signed, '\n'), 0o644); err != nil {
+			return fmt.Errorf("write audit: %w", err)
+		}
+	}
+	return nil
+}
+
+// rulePackVersion digests the effective plan names, patterns, deny-list and
+// region codes, so an audit names the exact rules a match was decided with.
+func rulePackVersion(plans *extract.PlanList, deny *extract.PlanList, regions map[string]struct{}) string {
+	names := extract.DefaultPpoPlans
+	var patterns []string
+	if plans != nil {
+		names = plans.Names
+		for _, re := range plans.Patterns {
+			patterns = append(patterns, re.String())
+		}
+	}
+
+	h := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		fmt.Fprintf(h, "plan %s\n", name)
+	}
+	for _, pattern := range patterns {
+		fmt.Fprintf(h, "pattern %s\n", pattern)
+	}
+	if deny != nil {
+		for _, name := range slices.Sorted(maps.Keys(deny.Names)) {
+			fmt.Fprintf(h, "deny %s\n", name)
+		}
+		for _, re := range deny.Patterns {
+			fmt.Fprintf(h, "deny pattern %s\n", re.String())
+		}
+	}
+	for _, code := range slices.Sorted(maps.Keys(regions)) {
+		fmt.Fprintf(h, "region %s\n", code)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
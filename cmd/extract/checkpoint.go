@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+var checkpointPath = ""
+var checkpointInterval = time.Minute
+var resumePath = ""
+
+// checkpointArgs checks that checkpoints are only used on a single index file.
+func checkpointArgs() error {
+	if checkpointPath == "" && resumePath == "" {
+		return nil
+	}
+	if sqsQueueURL != "" || manifestPath != "" {
+		return errors.New("-checkpoint and -resume take a single filename, not -manifest or -listen-sqs")
+	}
+	if checkpointPath == "" {
+		// keep checkpointing where the resumed run left off
+		checkpointPath = resumePath
+	}
+	return nil
+}
+
+// applyCheckpoints sets opts up to write checkpoints of filename to
+// -checkpoint and to resume from the -resume checkpoint.
+func applyCheckpoints(opts *extract.Options, filename string) error {
+	if resumePath != "" {
+		content, err := os.ReadFile(resumePath)
+		if err != nil {
+			return fmt.Errorf("read checkpoint: %w", err)
+		}
+		var cp extract.Checkpoint
+		if err := json.Unmarshal(content, &cp); err != nil {
+			return fmt.Errorf("parse checkpoint %s: %w", resumePath, err)
+		}
+		if cp.File != filename {
+			return fmt.Errorf("checkpoint %s is for %s, not %s", resumePath, cp.File, filename)
+		}
+		slog.Info("resuming", "file", filename, "records", cp.Records, "checkpoint", cp.CreatedAt.Format(time.DateTime))
+		opts.Resume = &cp
+		opts.GzipIndex = true
+	}
+
+	if checkpointPath != "" {
+		opts.CheckpointInterval = checkpointInterval
+		opts.OnCheckpoint = func(cp extract.Checkpoint) {
+			if err := writeCheckpoint(cp); err != nil {
+				output.Logf(output.CodeCheckpoint, "%v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeCheckpoint replaces -checkpoint, a partially written checkpoint would
+// lose the previous one.
+func writeCheckpoint(cp extract.Checkpoint) error {
+	content, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("serialize checkpoint: %w", err)
+	}
+	tmp := checkpointPath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("write checkpoint %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, checkpointPath); err != nil {
+		return fmt.Errorf("replace checkpoint %s: %w", checkpointPath, err)
+	}
+	slog.Debug("checkpoint", "records", cp.Records, "bytesRead", cp.BytesRead)
+	return nil
+}
+
+// removeCheckpoint deletes the checkpoint of a finished file, so a later run
+// cannot resume from it by mistake.
+func removeCheckpoint() {
+	if checkpointPath == "" {
+		return
+	}
+	if err := os.Remove(checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		output.Logf(output.CodeCheckpoint, "remove checkpoint: %v", err)
+	}
+}
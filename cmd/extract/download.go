@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+var downloadDir = ""
+var downloadWorkers = 4
+var downloadRetries = 3
+var downloadPerHost = 2
+var downloadRate = 0.0
+var downloadMaxRetryAfter = 10 * time.Minute
+var downloadManifestPath = ""
+var downloadRevalidate = false
+
+var errDownloadsFailed = errors.New("downloads failed")
+
+var matchedLocationsMu sync.Mutex
+var matchedLocations []string
+
+// queuedFiles are the canonical locations of matchedLocations, a file listed
+// by several index files under differently signed urls is downloaded once.
+var queuedFiles = extract.NewDedupStore()
+
+func queueDownloads(locations []string) {
+	if downloadDir == "" && !probeOnly {
+		return
+	}
+	matchedLocationsMu.Lock()
+	defer matchedLocationsMu.Unlock()
+	for _, location := range locations {
+		if queuedFiles.Add(extract.CanonicalLocation(location)) {
+			matchedLocations = append(matchedLocations, location)
+		}
+	}
+}
+
+// newDownloader is the downloader of -download and -probe.
+func newDownloader() *download.Downloader {
+	retries := downloadRetries
+	if retries == 0 {
+		retries = -1
+	}
+	return download.New(download.Options{
+		Dir:      downloadDir,
+		Workers:  downloadWorkers,
+		Retries:  retries,
+		MaxBytes: maxDownloadMB << 20,
+		Limits:   download.Limits{MaxRatio: maxExpansionRatio},
+		PerHost:  downloadPerHost,
+		Rate:     downloadRate,
+
+		MaxRetryAfter: downloadMaxRetryAfter,
+		Revalidate:    downloadRevalidate,
+	})
+}
+
+// downloadMatches fetches every matched location into downloadDir and prints
+// one result record per file. With -download-manifest the files an earlier
+// run left unfinished are fetched as well.
+func downloadMatches(ctx context.Context) (err error) {
+	if downloadDir == "" || (len(matchedLocations) == 0 && downloadManifestPath == "") {
+		return nil
+	}
+
+	downloader := newDownloader()
+	if downloadManifestPath != "" {
+		manifest, err := downloader.OpenManifest(downloadManifestPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, manifest.Close())
+		}()
+		queueDownloads(manifest.Pending())
+		if len(matchedLocations) == 0 {
+			return nil
+		}
+	}
+
+	slog.Debug("downloading", "files", len(matchedLocations), "dir", downloadDir)
+	downloads := downloader.DownloadAll(ctx, matchedLocations)
+	manifestErr := downloader.WriteDirManifest(downloads)
+
+	failed := 0
+	for _, result := range downloads {
+		if result.Error != "" {
+			failed++
+		}
+		if err := results.Match(struct {
+			Download download.Result `json:"download"`
+		}{Download: result}); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing download result")
+		}
+	}
+
+	if failed > 0 {
+		return errors.Join(fmt.Errorf("%d of %d: %w", failed, len(downloads), errDownloadsFailed), manifestErr)
+	}
+	return manifestErr
+}
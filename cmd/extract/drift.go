@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"serif_interview/pkg/blob"
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+const exitCodeIndexDrift = 5
+
+var errIndexDrift = errors.New("index version or reporting entity changed since the previous run")
+
+var driftHistoryPath = ""
+var maxIndexAgeDays = 0
+var driftWebhookURL = ""
+var driftPayer = ""
+
+// DriftRecord is one run's entry in the drift history file.
+type DriftRecord struct {
+	RunTime  string              `json:"runTime"`
+	Filename string              `json:"filename"`
+	Header   extract.IndexHeader `json:"header"`
+}
+
+// DriftAlert describes a header change between the previous and current run.
+type DriftAlert struct {
+	Code     output.Code `json:"code"`
+	Warning  string      `json:"warning"`
+	Payer    string      `json:"payer"`
+	Previous DriftRecord `json:"previous"`
+	Current  DriftRecord `json:"current"`
+}
+
+var datePrefixPattern = regexp.MustCompile(`^[\d_-]+`)
+
+// payerKey identifies the payer in the history file. Monthly index files are
+// usually named like 2026-01-01_anthem_index.json.gz, so without an explicit
+// -payer the date prefix is dropped to line consecutive months up.
+func payerKey(filename string) string {
+	if driftPayer != "" {
+		return driftPayer
+	}
+	if download.IsURL(filename) || blob.IsURI(filename) {
+		if u, err := url.Parse(filename); err == nil {
+			filename = u.Path
+		}
+	}
+	return datePrefixPattern.ReplaceAllString(filepath.Base(filename), "")
+}
+
+// driftFields is the part of a header that counts as drift, a new
+// last_updated_on is just the next monthly drop.
+func driftFields(header extract.IndexHeader) extract.IndexHeader {
+	header.LastUpdatedOn = ""
+	return header
+}
+
+// checkIndexDrift appends this run's header to the history file and reports
+// errIndexDrift with the alert when it differs from the payer's previous run.
+// The alert is written to the results, postDriftAlert sends it on.
+func checkIndexDrift(out *output.Writer, filename string, header extract.IndexHeader) (*DriftAlert, error) {
+	history, err := readDriftHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	payer := payerKey(filename)
+	current := DriftRecord{
+		RunTime:  time.Now().Format(time.DateTime),
+		Filename: filename,
+		Header:   header,
+	}
+
+	var alert *DriftAlert
+	if records := history[payer]; len(records) > 0 {
+		previous := records[len(records)-1]
+		if driftFields(previous.Header) != driftFields(current.Header) {
+			alert = &DriftAlert{
+				Code:     output.CodeIndexDrift,
+				Warning:  "index version or reporting entity changed since the previous run",
+				Payer:    payer,
+				Previous: previous,
+				Current:  current,
+			}
+		}
+	}
+	history[payer] = append(history[payer], current)
+
+	if err := writeDriftHistory(history); err != nil {
+		return nil, err
+	}
+
+	if alert == nil {
+		return nil, nil
+	}
+
+	if err := out.Error(alert); err != nil {
+		output.Logf(output.CodeSerialize, "Error during serializing drift alert")
+	}
+
+	return alert, errIndexDrift
+}
+
+// postDriftAlert sends alert to -drift-webhook, if both are set. It is called
+// without outMu held, the post can take until its timeout.
+func postDriftAlert(alert *DriftAlert) {
+	if alert == nil || driftWebhookURL == "" {
+		return
+	}
+	jsonStr, err := json.Marshal(alert)
+	if err != nil {
+		output.Logf(output.CodeSerialize, "Error during serializing drift alert")
+		return
+	}
+	if err := postJSON(driftWebhookURL, jsonStr); err != nil {
+		output.Logf(output.CodeWebhook, "drift webhook: %v", err)
+	}
+}
+
+// checkIndexAge warns when the index was last updated more than
+// -max-index-age days ago, a payer that stopped publishing new drops. Dates
+// are the CMS yyyy-mm-dd, a longer timestamp is cut to its date.
+func checkIndexAge(out *output.Writer, filename string, header extract.IndexHeader) {
+	if maxIndexAgeDays <= 0 || header.LastUpdatedOn == "" {
+		return
+	}
+	date := header.LastUpdatedOn
+	if len(date) > len(time.DateOnly) {
+		date = date[:len(time.DateOnly)]
+	}
+	updated, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		slog.Debug("unreadable last_updated_on", "file", filename, "lastUpdatedOn", header.LastUpdatedOn)
+		return
+	}
+	age := int(time.Since(updated).Hours() / 24)
+	if age <= maxIndexAgeDays {
+		return
+	}
+
+	warning := struct {
+		Code          output.Code `json:"code"`
+		Warning       string      `json:"warning"`
+		File          string      `json:"file"`
+		LastUpdatedOn string      `json:"lastUpdatedOn"`
+		AgeDays       int         `json:"ageDays"`
+	}{
+		Code:          output.CodeStaleIndex,
+		Warning:       fmt.Sprintf("index was last updated %d days ago, more than %d", age, maxIndexAgeDays),
+		File:          filename,
+		LastUpdatedOn: header.LastUpdatedOn,
+		AgeDays:       age,
+	}
+	if err := out.Error(warning); err != nil {
+		output.Logf(output.CodeSerialize, "Error during serializing stale index warning")
+	}
+}
+
+// readDriftHistory returns the records per payer, oldest first.
+func readDriftHistory() (map[string][]DriftRecord, error) {
+	history := make(map[string][]DriftRecord)
+
+	content, err := os.ReadFile(driftHistoryPath)
+	if err == nil {
+		if err := json.Unmarshal(content, &history); err != nil {
+			return nil, fmt.Errorf("parse drift history %s: %w", driftHistoryPath, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read drift history %s: %w", driftHistoryPath, err)
+	}
+
+	return history, nil
+}
+
+func writeDriftHistory(history map[string][]DriftRecord) error {
+	content, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serialize drift history: %w", err)
+	}
+	tmp := driftHistoryPath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("write drift history %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, driftHistoryPath); err != nil {
+		return fmt.Errorf("replace drift history %s: %w", driftHistoryPath, err)
+	}
+	return nil
+}
+
+func postJSON(url string, body []byte) error {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+func TestIndexDrift(t *testing.T) {
+	oldHistory, oldWebhook := driftHistoryPath, driftWebhookURL
+	t.Cleanup(func() { driftHistoryPath, driftWebhookURL = oldHistory, oldWebhook })
+
+	var posted []DriftAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert DriftAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("decode drift alert: %v", err)
+		}
+		posted = append(posted, alert)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	out, err := output.NewWriter(&buf, output.Options{Format: output.FormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	driftHistoryPath = filepath.Join(t.TempDir(), "drift-history.json")
+	driftWebhookURL = server.URL
+
+	january := extract.IndexHeader{ReportingEntityName: "Excellus", Version: "1.0.0", LastUpdatedOn: "2026-01-01"}
+	february := january
+	february.LastUpdatedOn = "2026-02-01"
+	march := february
+	march.Version = "2.0.0"
+	for _, tt := range []struct {
+		filename string
+		header   extract.IndexHeader
+		drift    bool
+	}{
+		{"2026-01-01_excellus_index.json", january, false},
+		{"2026-02-01_excellus_index.json", february, false},
+		{"2026-03-01_excellus_index.json", march, true},
+	} {
+		alert, err := checkIndexDrift(out, tt.filename, tt.header)
+		if tt.drift != (alert != nil) || tt.drift != errors.Is(err, errIndexDrift) {
+			t.Errorf("%s: alert %+v, %v, want drift %v", tt.filename, alert, err, tt.drift)
+		}
+		// the check only records the alert, it is posted separately
+		if len(posted) != 0 {
+			t.Fatalf("%s: alert posted while checking", tt.filename)
+		}
+		postDriftAlert(alert)
+	}
+
+	if len(posted) != 1 || posted[0].Payer != "excellus_index.json" || posted[0].Previous.Header.Version != "1.0.0" || posted[0].Current.Header.Version != "2.0.0" {
+		t.Errorf("posted alerts %+v, want the version change", posted)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"code":"`+string(output.CodeIndexDrift)+`"`)) {
+		t.Errorf("drift alert missing from the results: %s", buf.Bytes())
+	}
+	history, err := readDriftHistory()
+	if err != nil || len(history["excellus_index.json"]) != 3 {
+		t.Errorf("history %+v, %v, want the three runs", history, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"serif_interview/pkg/embed"
+	"serif_interview/pkg/llm"
+
+	_ "modernc.org/sqlite"
+)
+
+var embeddingsPath = ""
+var embeddingModel = ""
+var embeddingThreshold = 0.0
+var reindex = false
+
+// embeddings keeps the description embeddings of -embeddings across runs,
+// nil when not given.
+var embeddings *embed.Store
+var embeddingsDB *sql.DB
+
+// openEmbeddings opens the -embeddings store of the -llm backend and, with
+// -reindex, embeds every description it holds again.
+func openEmbeddings(ctx context.Context) error {
+	if embeddingsPath == "" {
+		return nil
+	}
+	embedder, err := llm.NewEmbedder(llmBackend, llm.Options{Model: embeddingModel, OllamaURL: ollamaURL})
+	if err != nil {
+		return err
+	}
+	model := embeddingModel
+	if model == "" {
+		model = llm.DefaultEmbeddingModels[llmBackend]
+	}
+
+	db, err := sql.Open("sqlite", embeddingsPath+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		return fmt.Errorf("open sqlite %s: %w", embeddingsPath, err)
+	}
+	db.SetMaxOpenConns(1)
+	// the models of both backends share a store, each under its own name
+	store, err := embed.Open(db, embedder, embed.Options{Model: llmBackend + "/" + model, Timeout: llmTimeout})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("embeddings %s: %w", embeddingsPath, err)
+	}
+
+	if reindex {
+		n, err := store.Reindex(ctx)
+		if err != nil {
+			db.Close()
+			return fmt.Errorf("reindex %s: %w", embeddingsPath, err)
+		}
+		slog.Info("reindexed embeddings", "path", embeddingsPath, "model", model, "descriptions", n)
+	}
+	embeddings, embeddingsDB = store, db
+	return nil
+}
+
+func closeEmbeddings() error {
+	if embeddingsDB == nil {
+		return nil
+	}
+	return embeddingsDB.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/llm"
+	"serif_interview/pkg/output"
+)
+
+// evalPath is the labeled set of -eval.
+var evalPath = ""
+
+// evalColumns are the csv columns of -eval's disagreements.
+const evalColumns = "description,location,label,wrong,score,aiMatch,aiConfidence,aiRationale,heuristicMatch,regionCodeMatch"
+
+// evaluate classifies the labeled descriptions of -eval instead of reading
+// an index, writing a record per description a classifier got wrong and the
+// precision, recall and F1 of each classifier as evaluation metadata.
+func evaluate(ctx context.Context, opts extract.Options) error {
+	f, err := os.Open(evalPath)
+	if err != nil {
+		return fmt.Errorf("-eval: %w", err)
+	}
+	labels, err := extract.ReadLabels(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("-eval %s: %w", evalPath, err)
+	}
+
+	if llmBackend == llm.BackendNone {
+		// leaves the llm classifier out rather than evaluating no answers
+		opts.LLM = nil
+	}
+	slog.Debug("evaluating", "labels", len(labels))
+	evaluation, disagreements := extract.New(opts).Evaluate(ctx, labels)
+	for _, d := range disagreements {
+		if err := results.Match(d); err != nil {
+			output.Logf(output.CodeSerialize, "marshal disagreement: %v", err)
+		}
+	}
+	for _, c := range evaluation.Classifiers {
+		slog.Info("evaluated", "classifier", c.Classifier, "precision", c.Precision, "recall", c.Recall, "f1", c.F1,
+			"truePositives", c.TruePositives, "falsePositives", c.FalsePositives, "falseNegatives", c.FalseNegatives)
+	}
+	results.Meta("evaluation", evaluation)
+	return ctx.Err()
+}
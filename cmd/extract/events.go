@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is disconnected, it resumes with Last-Event-ID on reconnect.
+const subscriberBuffer = 256
+
+// jobEvent is one match or error record of a job, id is its position in the
+// job's stream.
+type jobEvent struct {
+	id     int
+	kind   string
+	record json.RawMessage
+}
+
+// publishJobEvent appends a record to the job's stream and hands it to the
+// subscribers.
+func publishJobEvent(job *Job, kind string, record json.RawMessage) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	event := jobEvent{id: len(job.events), kind: kind, record: record}
+	job.events = append(job.events, event)
+	for ch := range job.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// too slow, the client reconnects from its last event
+			delete(job.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// endJobEvents disconnects the subscribers of a finished job and drops its
+// stream. jobsMu must be held.
+func endJobEvents(job *Job) {
+	for ch := range job.subscribers {
+		close(ch)
+	}
+	job.subscribers = nil
+	job.events = nil
+}
+
+// subscribeJob returns the events of a running job from position from on and
+// a channel for the ones that follow. The channel is nil when the job has
+// already finished.
+func subscribeJob(id string, from int) (*Job, []jobEvent, chan jobEvent) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	for _, job := range jobs {
+		if job.ID != id {
+			continue
+		}
+		if job.cancel == nil {
+			return job, nil, nil
+		}
+		replay := append([]jobEvent(nil), job.events[min(from, len(job.events)):]...)
+		ch := make(chan jobEvent, subscriberBuffer)
+		if job.subscribers == nil {
+			job.subscribers = make(map[chan jobEvent]struct{})
+		}
+		job.subscribers[ch] = struct{}{}
+		return job, replay, ch
+	}
+	return nil, nil, nil
+}
+
+func unsubscribeJob(job *Job, ch chan jobEvent) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	if _, ok := job.subscribers[ch]; ok {
+		delete(job.subscribers, ch)
+		close(ch)
+	}
+}
+
+// streamJobEvents serves the matches and errors of a job as Server-Sent
+// Events while it runs, followed by a done event with the final job status.
+func streamJobEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	from := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		from = last + 1
+	}
+	job, replay, ch := subscribeJob(r.PathValue("id"), from)
+	if job == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no job " + r.PathValue("id")})
+		return
+	}
+	if ch != nil {
+		defer unsubscribeJob(job, ch)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range replay {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.kind, event.record)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for ch != nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event, open := <-ch:
+			if !open {
+				ch = nil
+				break
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.kind, event.record)
+		}
+		flusher.Flush()
+	}
+
+	jobsMu.Lock()
+	status := job.snapshot()
+	jobsMu.Unlock()
+	if status.Status == "running" {
+		// disconnected for falling behind, not finished
+		return
+	}
+	data, _ := json.Marshal(status)
+	fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+	flusher.Flush()
+}
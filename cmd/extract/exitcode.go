@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/llm"
+)
+
+// Exit statuses, together with exitCodeIndexDrift, exitCodeSchemaInvalid and
+// exitCodeInterrupted. They never change meaning, so shell pipelines and
+// schedulers can branch on them.
+const (
+	exitCodeOK              = 0
+	exitCodeFailed          = 1
+	exitCodeNoMatches       = 2
+	exitCodeParseError      = 3
+	exitCodeLLMUnavailable  = 4
+	exitCodeDownloadsFailed = 7
+	exitCodeSizeLimit       = 8
+	exitCodeUsage           = 64
+)
+
+// outcomes name the exit statuses in the runSummary record.
+var outcomes = map[int]string{
+	exitCodeOK:              "ok",
+	exitCodeFailed:          "failed",
+	exitCodeNoMatches:       "noMatches",
+	exitCodeParseError:      "parseError",
+	exitCodeLLMUnavailable:  "llmUnavailable",
+	exitCodeIndexDrift:      "indexDrift",
+	exitCodeSchemaInvalid:   "schemaInvalid",
+	exitCodeDownloadsFailed: "downloadsFailed",
+	exitCodeSizeLimit:       "sizeLimit",
+	exitCodeInterrupted:     "interrupted",
+}
+
+// llmUnavailable is set when the llm of a mode relying on it did not answer
+// and the run carried on without it.
+var llmUnavailable atomic.Bool
+
+// requiresLLM is true for runs whose results lack something without the llm.
+func requiresLLM() bool {
+	return llmBackend != llm.BackendNone && (mode == modeAnalysis || planTypeLLM)
+}
+
+// exitCodeFor maps the error of a run to its exit status. A run without an
+// error exits exitCodeLLMUnavailable when it went on without its llm and
+// exitCodeNoMatches when it parsed index files but matched nothing.
+func exitCodeFor(err error) int {
+	var limitErr *download.LimitError
+	var guardErr *extract.LimitError
+	var parseErr *extract.ParseError
+	switch {
+	case err == nil && llmUnavailable.Load():
+		return exitCodeLLMUnavailable
+	case err == nil:
+		if totals := runTotals(); totals.Files > 0 && mode != modeStats && totals.Matches == 0 {
+			return exitCodeNoMatches
+		}
+		return exitCodeOK
+	case errors.Is(err, context.Canceled):
+		return exitCodeInterrupted
+	case errors.Is(err, errIndexDrift):
+		return exitCodeIndexDrift
+	case errors.Is(err, errSchemaInvalid):
+		return exitCodeSchemaInvalid
+	case errors.As(err, &limitErr), errors.As(err, &guardErr):
+		return exitCodeSizeLimit
+	case errors.As(err, &parseErr):
+		return exitCodeParseError
+	case errors.Is(err, errDownloadsFailed):
+		return exitCodeDownloadsFailed
+	}
+	return exitCodeFailed
+}
+
+// runSummary is the last record before the footer of every run, the exit
+// status and the counts of the whole run.
+type runSummary struct {
+	ExitCode    int    `json:"exitCode"`
+	Outcome     string `json:"outcome"`
+	Files       int    `json:"files"`
+	FailedFiles int    `json:"failedFiles"`
+	Records     int    `json:"records"`
+	Matches     int    `json:"matches"`
+	UniquePlans int    `json:"uniquePlans"`
+	Errors      int    `json:"errors"`
+	Skipped     int    `json:"skipped"`
+	Denied      int    `json:"denied"`
+	Duration    string `json:"duration"`
+}
+
+// writeRunSummary writes the runSummary record and logs it, csv and parquet
+// output drop meta records.
+func writeRunSummary(exitCode int, duration time.Duration) {
+	summary := runTotals()
+	summary.ExitCode, summary.Outcome = exitCode, outcomes[exitCode]
+	summary.Duration = duration.Round(time.Millisecond).String()
+	results.Meta("runSummary", summary)
+	slog.Info("run finished", "exitCode", summary.ExitCode, "outcome", summary.Outcome, "files", summary.Files, "failedFiles", summary.FailedFiles, "records", summary.Records, "matches", summary.Matches, "errors", summary.Errors)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"serif_interview/pkg/blob"
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/gzindex"
+	"serif_interview/pkg/output"
+)
+
+// gzindexColumns are the csv columns of -build-gzindex's records.
+const gzindexColumns = "file,index,points,compressedBytes,uncompressedBytes"
+
+// gzipIndexRecord describes the seek index written for a file.
+type gzipIndexRecord struct {
+	File              string `json:"file"`
+	Index             string `json:"index"`
+	Points            int    `json:"points"`
+	CompressedBytes   int64  `json:"compressedBytes"`
+	UncompressedBytes int64  `json:"uncompressedBytes"`
+}
+
+// buildGzipIndexes writes a seek index next to each gzip input instead of
+// extracting it, so resumes of the file seek to their checkpoint.
+func buildGzipIndexes(ctx context.Context, inputs []string) error {
+	var errs []error
+	for _, filename := range inputs {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		record, err := buildGzipIndex(filename)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filename, err))
+			continue
+		}
+		slog.Info("gzip index written", "file", filename, "index", record.Index, "points", record.Points)
+		if err := results.Match(record); err != nil {
+			output.Logf(output.CodeSerialize, "marshal gzip index record: %v", err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func buildGzipIndex(filename string) (gzipIndexRecord, error) {
+	if download.IsURL(filename) || blob.IsURI(filename) {
+		return gzipIndexRecord{}, errors.New("-build-gzindex needs a local file, download it first")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return gzipIndexRecord{}, err
+	}
+	defer f.Close()
+	ix, err := gzindex.Build(f, gzindexSpanMB<<20)
+	if err != nil {
+		return gzipIndexRecord{}, fmt.Errorf("build gzip index: %w", err)
+	}
+	path := gzindex.Path(filename)
+	if err := ix.Save(path); err != nil {
+		return gzipIndexRecord{}, fmt.Errorf("write gzip index: %w", err)
+	}
+	return gzipIndexRecord{
+		File:              filename,
+		Index:             path,
+		Points:            len(ix.Points),
+		CompressedBytes:   ix.CompressedSize,
+		UncompressedBytes: ix.UncompressedSize,
+	}, nil
+}
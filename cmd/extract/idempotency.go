@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// forceReprocess extracts every announced object even when identical input
+// was already extracted.
+var forceReprocess = false
+var jobIndexPath = ""
+
+// completed maps the hash of an extracted input to the location of its
+// results, persisted in -job-index when given.
+var completedMu sync.Mutex
+var completed map[string]string
+
+func loadJobIndex() error {
+	completedMu.Lock()
+	defer completedMu.Unlock()
+
+	completed = make(map[string]string)
+	if jobIndexPath == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(jobIndexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read job index: %w", err)
+	}
+	if err := json.Unmarshal(content, &completed); err != nil {
+		return fmt.Errorf("parse job index %s: %w", jobIndexPath, err)
+	}
+	return nil
+}
+
+// inputHash identifies an extraction by the object, its content digest, the
+// S3 ETag or a sha256 of the file, and the settings that shape the results.
+func inputHash(bucket string, key string, digest string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{bucket, key, digest, mode, outputFormat, outputColumns}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func fileDigest(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// completedResults returns the results of an earlier extraction of the same
+// input, as long as they were not removed by retention since.
+func completedResults(ctx context.Context, client *s3.Client, hash string) (string, bool) {
+	completedMu.Lock()
+	location, ok := completed[hash]
+	completedMu.Unlock()
+	if !ok {
+		return "", false
+	}
+
+	key := strings.TrimPrefix(location, "s3://"+resultsBucket+"/")
+	_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(resultsBucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		completedMu.Lock()
+		delete(completed, hash)
+		completedMu.Unlock()
+	}
+	if err != nil {
+		return "", false
+	}
+	return location, true
+}
+
+func recordCompleted(hash string, location string) error {
+	completedMu.Lock()
+	defer completedMu.Unlock()
+
+	completed[hash] = location
+	if jobIndexPath == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(completed, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := jobIndexPath + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return fmt.Errorf("write job index %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, jobIndexPath); err != nil {
+		return fmt.Errorf("replace job index %s: %w", jobIndexPath, err)
+	}
+	return nil
+}
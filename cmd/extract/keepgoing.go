@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+var keepGoing = false
+
+// skippedRecords counts the records -keep-going skipped per file of the run.
+var skippedMu sync.Mutex
+var skippedRecords = make(map[string]int)
+
+// printRecordErrors writes an error record for every reporting_structure
+// record of filename that was skipped.
+func printRecordErrors(out *output.Writer, filename string, extractor *extract.Extractor) {
+	skipped := extractor.Stats().Skipped
+	if skipped == 0 {
+		return
+	}
+	skippedMu.Lock()
+	skippedRecords[filename] += skipped
+	skippedMu.Unlock()
+
+	for _, recordErr := range extractor.RecordErrors() {
+		out.Error(struct {
+			Code    output.Code `json:"code"`
+			Warning string      `json:"warning"`
+			File    string      `json:"file"`
+			Path    string      `json:"path"`
+			Offset  int64       `json:"offset"`
+			Message string      `json:"message"`
+		}{
+			Code:    output.CodeSkippedRecord,
+			Warning: "reporting_structure record skipped",
+			File:    filename,
+			Path:    recordErr.Path,
+			Offset:  recordErr.Offset,
+			Message: recordErr.Message,
+		})
+	}
+	output.Logf(output.CodeSkippedRecord, "%s: skipped %d reporting_structure records that could not be read", filename, skipped)
+}
+
+// printSkippedSummary ends a -keep-going run that skipped records with their
+// count per file.
+func printSkippedSummary() {
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	if len(skippedRecords) == 0 {
+		return
+	}
+
+	total := 0
+	for _, skipped := range skippedRecords {
+		total += skipped
+	}
+	results.Error(struct {
+		Code    output.Code    `json:"code"`
+		Warning string         `json:"warning"`
+		Skipped int            `json:"skipped"`
+		Files   map[string]int `json:"files"`
+	}{
+		Code:    output.CodeSkippedRecord,
+		Warning: fmt.Sprintf("%d reporting_structure records were skipped, their results are missing", total),
+		Skipped: total,
+		Files:   maps.Clone(skippedRecords),
+	})
+}
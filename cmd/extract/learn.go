@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/extract"
+)
+
+var learn = false
+
+// learned are the plan descriptions and plan codes confirmed so far, for
+// -learn.
+var learned struct {
+	sync.Mutex
+	plans   []extract.Learned
+	regions []extract.Learned
+}
+
+// noteLearned keeps the analysis matches of filename that both the llm and
+// the keywords confirm, their descriptions for -plans and, when not a region
+// code of -state yet, their plan codes for -regions.
+func noteLearned(filename string, matches []extract.Match) {
+	if !learn {
+		return
+	}
+	learned.Lock()
+	defer learned.Unlock()
+	for _, m := range matches {
+		if !m.AIMatch || !m.HeuristicMatch {
+			continue
+		}
+		comment := fmt.Sprintf("learned %s from %s, llm confidence %.2f, score %.2f", time.Now().Format(time.DateOnly), filepath.Base(filename), m.AIConfidence, m.Score)
+		learned.plans = append(learned.plans, extract.Learned{Value: m.Description, Comment: comment})
+		if m.RegionCodeMatch {
+			continue
+		}
+		if code, err := extract.ExtractPlanCode(m.Location); err == nil {
+			learned.regions = append(learned.regions, extract.Learned{Value: code, Comment: comment})
+		}
+	}
+}
+
+// writeLearned adds what the run confirmed to the -plans and -regions files,
+// entries they already have being left alone.
+func writeLearned() error {
+	if !learn {
+		return nil
+	}
+	learned.Lock()
+	defer learned.Unlock()
+	if plansPath != "" {
+		added, err := extract.AddLearnedPlans(plansPath, learned.plans)
+		if err != nil {
+			return fmt.Errorf("-learn: %w", err)
+		}
+		slog.Info("learned plans", "file", plansPath, "added", added, "confirmed", len(learned.plans))
+	}
+	if regionsPath != "" {
+		added, err := extract.AddLearnedRegions(regionsPath, state, learned.regions)
+		if err != nil {
+			return fmt.Errorf("-learn: %w", err)
+		}
+		slog.Info("learned region codes", "file", regionsPath, "state", state, "added", added, "confirmed", len(learned.regions))
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+var sqsQueueURL = ""
+var resultsBucket = ""
+var resultsPrefix = "results/"
+
+// s3Event is the part of an S3 event notification the listener needs.
+type s3Event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				ETag string `json:"eTag"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+	// Event is only set on the s3:TestEvent sent when notifications are
+	// first configured.
+	Event string `json:"Event"`
+}
+
+// listen extracts every index file announced by S3 object-created events on
+// the -listen-sqs queue until ctx ends. Each file gets its own output
+// document, uploaded next to the others under -results-prefix in
+// -results-bucket. Messages are only deleted once their results are stored,
+// failed ones become visible again for a retry or the queue's dead letter
+// queue. Objects whose identical content was already extracted get the
+// earlier results unless -force is given or the message has a "force"
+// attribute of "true".
+func listen(ctx context.Context, opts extract.Options) error {
+	if err := loadJobIndex(); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("load aws config: %w", err)
+	}
+	sqsClient := sqs.NewFromConfig(cfg)
+	s3Client := s3.NewFromConfig(cfg)
+
+	slog.Debug("listening", "queue", sqsQueueURL)
+	var lastRetention time.Time
+	for {
+		if time.Since(lastRetention) >= retentionInterval {
+			if err := enforceRetention(ctx, s3Client, opts.LLMCache); err != nil {
+				results.Error(struct {
+					Code    output.Code `json:"code"`
+					Warning string      `json:"warning"`
+				}{Code: output.CodeRetention, Warning: err.Error()})
+			}
+			lastRetention = time.Now()
+		}
+
+		resp, err := sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(sqsQueueURL),
+			MaxNumberOfMessages:   1,
+			WaitTimeSeconds:       20,
+			MessageAttributeNames: []string{"force"},
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("receive from %s: %w", sqsQueueURL, err)
+		}
+
+		for _, msg := range resp.Messages {
+			force := forceReprocess
+			if attr, ok := msg.MessageAttributes["force"]; ok {
+				force = force || aws.ToString(attr.StringValue) == "true"
+			}
+			if err := handleEvent(ctx, s3Client, aws.ToString(msg.Body), force, opts); err != nil {
+				results.Error(struct {
+					Code      output.Code `json:"code"`
+					Warning   string      `json:"warning"`
+					MessageID string      `json:"messageId"`
+				}{Code: output.CodeMessageRetry, Warning: err.Error(), MessageID: aws.ToString(msg.MessageId)})
+				// cancelled jobs are dropped, anything else is retried
+				if !errors.Is(err, errJobCancelled) {
+					continue
+				}
+			}
+
+			_, err := sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(sqsQueueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				return fmt.Errorf("delete message %s: %w", aws.ToString(msg.MessageId), err)
+			}
+		}
+	}
+}
+
+func handleEvent(ctx context.Context, client *s3.Client, body string, force bool, opts extract.Options) error {
+	var event s3Event
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return fmt.Errorf("decode s3 event: %w", err)
+	}
+	if event.Event == "s3:TestEvent" {
+		return nil
+	}
+
+	var errs []error
+	for _, record := range event.Records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			continue
+		}
+		// keys are url encoded in event notifications, spaces as +
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("decode object key %q: %w", record.S3.Object.Key, err))
+			continue
+		}
+		if err := extractObject(ctx, client, record.S3.Bucket.Name, key, record.S3.Object.ETag, force, opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// extractObject downloads one index file, extracts it into a separate output
+// document and uploads that to the results bucket. Unless force is set, the
+// results of an earlier extraction of the same content are reported instead,
+// looked up by etag before downloading and by the file digest after.
+func extractObject(ctx context.Context, client *s3.Client, bucket string, key string, etag string, force bool, opts extract.Options) (err error) {
+	source := "s3://" + bucket + "/" + key
+	if etag != "" && !force {
+		if location, ok := completedResults(ctx, client, inputHash(bucket, key, etag)); ok {
+			reportResults(source, location, true)
+			return nil
+		}
+	}
+	slog.Debug("extracting", "source", source)
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	job := startJob(source, cancel)
+	defer func() {
+		if jobCtx.Err() != nil && ctx.Err() == nil {
+			err = fmt.Errorf("%s: %w", source, errJobCancelled)
+		}
+		finishJob(job, err)
+	}()
+	ctx = jobCtx
+	applyRules(&opts)
+
+	dir, err := os.MkdirTemp("", "extract-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// keep the object name, the payer key and compression are derived from it
+	filename := filepath.Join(dir, path.Base(key))
+	if err := getObject(ctx, client, bucket, key, filename); err != nil {
+		return fmt.Errorf("download %s: %w", source, err)
+	}
+	digest, err := fileDigest(filename)
+	if err != nil {
+		return fmt.Errorf("hash %s: %w", source, err)
+	}
+	if !force {
+		if location, ok := completedResults(ctx, client, inputHash(bucket, key, digest)); ok {
+			reportResults(source, location, true)
+			return nil
+		}
+	}
+
+	// the file's output document, the run's results only get its location
+	var buf bytes.Buffer
+	outOpts := outputOptions()
+	outOpts.Observe = func(kind string, record json.RawMessage) {
+		publishJobEvent(job, kind, record)
+	}
+	out, err := output.NewWriter(&buf, outOpts)
+	if err != nil {
+		return err
+	}
+	out.Meta("source", source)
+	extractErr := processFile(ctx, out, filename, opts, extract.NewDedupStore())
+	if extractErr != nil {
+		out.Error(newFailure(errorCode(extractErr, output.CodeFileFailed), extractErr))
+	}
+	closeErr := out.Close()
+	if extractErr != nil && !errors.Is(extractErr, errIndexDrift) {
+		return extractErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	ext := ".json"
+	switch output.Format(outputFormat) {
+	case output.FormatNDJSON:
+		ext = ".ndjson"
+	case output.FormatCSV:
+		ext = ".csv"
+	case output.FormatParquet:
+		ext = ".parquet"
+	}
+	base := key
+	for _, suffix := range []string{".gz", ".zst", ".bz2"} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	resultKey := resultsPrefix + strings.TrimSuffix(base, ".json") + ext
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(resultsBucket),
+		Key:         aws.String(resultKey),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("upload results of %s: %w", source, err)
+	}
+
+	location := "s3://" + resultsBucket + "/" + resultKey
+	for _, d := range []string{etag, digest} {
+		if d == "" {
+			continue
+		}
+		if err := recordCompleted(inputHash(bucket, key, d), location); err != nil {
+			results.Error(struct {
+				Code    output.Code `json:"code"`
+				Warning string      `json:"warning"`
+			}{Code: output.CodeResultIndex, Warning: err.Error()})
+		}
+	}
+
+	reportResults(source, location, false)
+	return nil
+}
+
+func reportResults(source string, location string, cached bool) {
+	results.Match(struct {
+		Source  string `json:"source"`
+		Results string `json:"results"`
+		Cached  bool   `json:"cached,omitempty"`
+	}{Source: source, Results: location, Cached: cached})
+}
+
+func getObject(ctx context.Context, client *s3.Client, bucket string, key string, filename string) error {
+	resp, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	maxBytes := maxDownloadMB << 20
+	if maxBytes > 0 && aws.ToInt64(resp.ContentLength) > maxBytes {
+		return &download.LimitError{Reason: fmt.Sprintf("%d bytes are larger than %d", aws.ToInt64(resp.ContentLength), maxBytes)}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	var body io.Reader = resp.Body
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	written, err := io.Copy(f, body)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if maxBytes > 0 && written > maxBytes {
+		f.Close()
+		return &download.LimitError{Reason: fmt.Sprintf("object passed %d bytes", maxBytes)}
+	}
+	return f.Close()
+}
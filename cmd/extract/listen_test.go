@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+// fakeS3 keeps objects by bucket and key, answering the path style requests
+// the SDK sends to an IP endpoint.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		prefix := r.URL.Query().Get("prefix")
+		var keys []string
+		for name := range f.objects {
+			if k, ok := strings.CutPrefix(name, bucket+"/"); ok && strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var contents strings.Builder
+		for _, k := range keys {
+			fmt.Fprintf(&contents, "<Contents><Key>%s</Key><LastModified>2026-01-01T00:00:00.000Z</LastModified><Size>%d</Size></Contents>", k, len(f.objects[bucket+"/"+k]))
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>%s</ListBucketResult>`, bucket, prefix, len(keys), contents.String())
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		content, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case r.Method == http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+			body = decodeAWSChunked(body)
+		}
+		f.objects[bucket+"/"+key] = body
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+r.URL.String(), http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) object(name string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[name]
+}
+
+// decodeAWSChunked strips the chunk sizes and checksum trailer the SDK wraps
+// uploads in.
+func decodeAWSChunked(body []byte) []byte {
+	var decoded []byte
+	r := bufio.NewReader(bytes.NewReader(body))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return decoded
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 {
+			return decoded
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return decoded
+		}
+		decoded = append(decoded, chunk[:size]...)
+	}
+}
+
+type sqsMessage struct {
+	MessageId     string
+	ReceiptHandle string
+	Body          string
+}
+
+// fakeSQS hands out its messages one per receive, then none, and records the
+// receipt handles deleted.
+type fakeSQS struct {
+	mu       sync.Mutex
+	messages []sqsMessage
+	received int
+	deleted  []string
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		QueueUrl      string
+		ReceiptHandle string
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch target := r.Header.Get("X-Amz-Target"); target {
+	case "AmazonSQS.ReceiveMessage":
+		f.received++
+		messages := []sqsMessage{}
+		if len(f.messages) > 0 {
+			messages, f.messages = f.messages[:1], f.messages[1:]
+		} else {
+			// a short long poll
+			time.Sleep(10 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Messages": messages})
+	case "AmazonSQS.DeleteMessage":
+		f.deleted = append(f.deleted, req.ReceiptHandle)
+		io.WriteString(w, "{}")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"com.amazonaws.sqs#InvalidAction","message":"unexpected %s"}`, target)
+	}
+}
+
+func (f *fakeSQS) drained() ([]string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...), len(f.messages) == 0 && f.received > 4
+}
+
+func TestListen(t *testing.T) {
+	s3Server := &fakeS3{objects: map[string][]byte{
+		"indexes/2026-01-01_excellus_index.json": []byte(`{"reporting_entity_name":"Excellus","reporting_structure":[{"reporting_plans":[],"in_network_files":[
+			{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"}]}]}`),
+	}}
+	created := `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"indexes"},"object":{"key":"2026-01-01_excellus_index.json","eTag":"e1"}}}]}`
+	sqsServer := &fakeSQS{messages: []sqsMessage{
+		{MessageId: "m1", ReceiptHandle: "r1", Body: created},
+		// the same object again is answered from the first results
+		{MessageId: "m2", ReceiptHandle: "r2", Body: created},
+		// an unreadable event stays on the queue for a retry
+		{MessageId: "m3", ReceiptHandle: "r3", Body: "not an event"},
+		{MessageId: "m4", ReceiptHandle: "r4", Body: `{"Event":"s3:TestEvent"}`},
+	}}
+	s3HTTP := httptest.NewServer(s3Server)
+	defer s3HTTP.Close()
+	sqsHTTP := httptest.NewServer(sqsServer)
+	defer sqsHTTP.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL_S3", s3HTTP.URL)
+	t.Setenv("AWS_ENDPOINT_URL_SQS", sqsHTTP.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	oldResults, oldQueue, oldBucket, oldMode := results, sqsQueueURL, resultsBucket, mode
+	t.Cleanup(func() { results, sqsQueueURL, resultsBucket, mode = oldResults, oldQueue, oldBucket, oldMode })
+	var runBuf bytes.Buffer
+	var err error
+	results, err = output.NewWriter(&runBuf, output.Options{Format: output.FormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqsQueueURL = sqsHTTP.URL + "/123456789012/index-events"
+	resultsBucket = "results"
+	mode = modeUniquePlans
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- listen(ctx, extract.Options{Mode: extract.ModeUniquePlans}) }()
+
+	deadline := time.After(30 * time.Second)
+	for {
+		if _, ok := sqsServer.drained(); ok {
+			break
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("listen returned early: %v", err)
+		case <-deadline:
+			t.Fatal("queue not drained")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := results.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted, _ := sqsServer.drained(); strings.Join(deleted, ",") != "r1,r2,r4" {
+		t.Errorf("deleted %v, want every message but the unreadable one", deleted)
+	}
+
+	// the file's results are uploaded as a document of their own
+	document := s3Server.object("results/results/2026-01-01_excellus_index.json")
+	var records []map[string]any
+	if err := json.Unmarshal(document, &records); err != nil {
+		t.Fatalf("uploaded results %q: %v", document, err)
+	}
+	if !bytes.Contains(document, []byte(`"source":"s3://indexes/2026-01-01_excellus_index.json"`)) || !bytes.Contains(document, []byte("excellus bcbs : blueppo")) {
+		t.Errorf("uploaded results %s, want the source and its plan", document)
+	}
+
+	// the run's results only point at them
+	run := runBuf.String()
+	if strings.Contains(run, "excellus bcbs : blueppo") {
+		t.Errorf("the file's results leaked into the run's results:\n%s", run)
+	}
+	location := `"results":"s3://results/results/2026-01-01_excellus_index.json"`
+	if strings.Count(run, location) != 2 || strings.Count(run, `"cached":true`) != 1 {
+		t.Errorf("run results %s, want the location extracted then cached", run)
+	}
+	if !strings.Contains(run, `"messageId":"m3"`) || !strings.Contains(run, string(output.CodeMessageRetry)) {
+		t.Errorf("run results %s, want a retry warning for the unreadable event", run)
+	}
+}
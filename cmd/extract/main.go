@@ -1,688 +1,644 @@
 package main
 
 import (
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"net/url"
+	"log/slog"
 	"os"
-	"path"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/fhir"
+	"serif_interview/pkg/llm"
+	"serif_interview/pkg/output"
 )
 
 func main() {
-	fmt.Println("[")
-	startTime := time.Now()
-	fmt.Printf("{ \"starttime\": \"%s\"},", startTime.Format(time.DateTime))
-	fmt.Println()
+	if err := parseArgs(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			output.Logf(output.CodeUsage, "%v", err)
+		}
+		os.Exit(exitCodeForArgs(err))
+	}
 
-	exitCode := 0
-	if err := run(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		exitCode = 1
+	var outFile *output.File
+	if outputPath != "" {
+		f, err := output.CreateFile(outputPath)
+		if err != nil {
+			output.Logf(output.CodeOutputFailed, "%v", err)
+			os.Exit(exitCodeFailed)
+		}
+		outFile = f
+		out = f
 	}
 
-	fmt.Printf("{ \"endtime\": \"%s\" },", time.Now().Format(time.DateTime))
-	fmt.Println()
-	fmt.Printf("{ \"duration\": \"%s\" },", time.Since(startTime))
-	fmt.Println()
+	startTime := time.Now()
+	outOpts := outputOptions()
+	sinks, err := openSinks(startTime)
+	if err == nil {
+		outOpts.Sinks, outOpts.SinkFailed = sinks, sinkFailed
+		results, err = output.NewWriter(out, outOpts)
+	}
+	if err != nil {
+		output.Logf(output.CodeOutputFailed, "write output: %v", err)
+		if outFile != nil {
+			outFile.Abort()
+		}
+		os.Exit(exitCodeFailed)
+	}
 
-	fmt.Println("]")
+	runStartTime = startTime
+	results.Meta("starttime", startTime.Format(time.DateTime))
 
-	os.Exit(exitCode)
-}
+	// the first SIGINT or SIGTERM stops the parse between records so the
+	// results so far and the footer are still written, a second one kills
+	// the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		slog.Warn("interrupted, finishing the output, interrupt again to exit immediately")
+	}()
 
-var isUniquePlansMode = false
-var isAnalysisMode = false
-var isHeuristicsMode = false
-
-func printUsage() error {
-	fmt.Println("ney york ppo price extractor - ")
-	fmt.Println(" <filename> - must be path to filename in .json.gz format")
-	fmt.Println(" <mode flag> - optional, defaults to -heuristics")
-	fmt.Println("             -uniquePlans - extract all unique plan names")
-	fmt.Println("             -heuristics  - extract ppo price urls based on heuristics")
-	fmt.Println("             -analysis - extract data analysis json for exploration")
-	fmt.Println(" no other arguments are allowed")
-	return fmt.Errorf("exactly 1 argument expected")
-}
-func run() error {
-	if len(os.Args) < 2 {
-		return printUsage()
-	}
-	if len(os.Args) >= 3 {
-		if os.Args[2] == "-uniquePlans" {
-			isUniquePlansMode = true
-		} else if os.Args[2] == "-analysis" {
-			isAnalysisMode = true
-		} else if os.Args[2] == "-heuristics" {
-			isHeuristicsMode = true
-		} else {
-			return printUsage()
-		}
-	} else {
-		isHeuristicsMode = true
+	err = run(ctx)
+	if errors.Is(err, errIndexDrift) {
+		output.Logf(output.CodeIndexDrift, "%v", err)
+	} else if errors.Is(err, errSchemaInvalid) {
+		output.Logf(output.CodeSchemaViolation, "%v", err)
+	} else if err != nil {
+		code := errorCode(err, output.CodeRunFailed)
+		output.Logf(code, "%v", err)
+		results.Error(newFailure(code, err))
 	}
+	exitCode := exitCodeFor(err)
+	notifyCompletion(err, time.Since(startTime))
+	setCallbackSummary(err, time.Since(startTime))
+	writeRunSummary(exitCode, time.Since(startTime))
 
-	llama, err := ollama.New(ollama.WithModel("llama3"))
-	if err != nil {
-		return fmt.Errorf("open gollama failed %w", err)
+	results.Meta("endtime", time.Now().Format(time.DateTime))
+	results.Meta("duration", time.Since(startTime).String())
+
+	closeErr := results.Close()
+	if closeErr != nil {
+		output.Logf(output.CodeOutputFailed, "write output: %v", closeErr)
+		exitCode = exitCodeFailed
 	}
 
-	ctx := context.Background()
-	var helloPrompt []llms.MessageContent
-	helloPrompt = append(helloPrompt, llms.TextParts(llms.ChatMessageTypeSystem, "Say hello, indicating you are an ollama LLM and any other relevant niceities, and assert that you are working correctly and want to help out finding relevant new york ppo price information."))
+	if outFile != nil {
+		// a failed run still wrote a complete document ending in its error,
+		// only an incomplete one is discarded
+		if closeErr != nil {
+			outFile.Abort()
+		} else if err := outFile.Commit(); err != nil {
+			output.Logf(output.CodeOutputFailed, "%v", err)
+			exitCode = exitCodeFailed
+		}
+	}
 
-	res, err := llama.GenerateContent(ctx, helloPrompt)
-	if err != nil {
-		if isAnalysisMode {
-			fmt.Println("{ \"warning\": \"Ollama llm is not working. Instal ollama and run ollama pull llama3 if youd like the help of llm analysis. This analysis will continue without ollama.\" },")
-			println("Cancel this application now if you do not want to proceed ... sleeping 5")
-			time.Sleep(5 * time.Second)
+	os.Exit(exitCode)
+}
+
+// exitCodeInterrupted is the exit status of a run stopped by SIGINT or
+// SIGTERM, as shells report for a process killed by SIGINT.
+const exitCodeInterrupted = 130
+
+func run(ctx context.Context) (err error) {
+	// -llm=none leaves the extractor without a client, so nothing is asked
+	// or counted as a failed call
+	var client extract.LLMClient
+	if llmBackend != llm.BackendNone {
+		// the client is opened by its first call, not before a run needs it
+		lazy, err := llm.NewLazy(llmBackend, llm.Options{
+			Model:       llmModel,
+			OllamaURL:   ollamaURL,
+			Temperature: llmTemperature,
+			NumCtx:      llmNumCtx,
+		})
+		if err != nil {
+			return err
 		}
-	} else {
-		msg := res.Choices[0].Content
-		jsonMsg, err := json.Marshal(msg)
-		var jsonStr string
+		client = llm.WithPolicy(lazy, llm.Policy{
+			Timeout:     llmTimeout,
+			Retries:     llmRetries,
+			MaxFailures: llmMaxFailures,
+			OnOpen: func(err error) {
+				llmUnavailable.Store(requiresLLM())
+				output.Logf(output.CodeLLMCircuitOpen, "the %s llm failed %d calls in a row, continuing without it: %v", llmBackend, llmMaxFailures, err)
+			},
+			OnClose: func() {
+				slog.Info("llm answering again", "llm", llmBackend)
+			},
+		})
+	}
+
+	// runs relying on the llm check it answers before the first file, the
+	// greeting is only sent when asked for
+	if llmBackend != llm.BackendNone && (requiresLLM() || llmGreeting) {
+		prompt := "Reply with the single word OK."
+		if llmGreeting {
+			prompt = "Say hello, indicating you are an " + llmBackend + " LLM and any other relevant niceities, and assert that you are working correctly and want to help out finding relevant new york ppo price information."
+		}
+		res, err := client.Generate(ctx, prompt, "")
 		if err != nil {
-			jsonStr = "Error extracting ollama response"
-		} else {
-			jsonStr = string(jsonMsg)
+			llmUnavailable.Store(requiresLLM())
+			if mode == modeAnalysis {
+				results.Error(struct {
+					Code    output.Code `json:"code"`
+					Warning string      `json:"warning"`
+				}{Code: output.CodeLLMUnavailable, Warning: fmt.Sprintf("The %s llm is not working: %v. Start it, or pass -llm=none, if youd like the help of llm analysis. This analysis will continue without it.", llmBackend, err)})
+				output.Logf(output.CodeLLMUnavailable, "the %s llm is not working: %v, cancel now if you do not want to proceed without it ... sleeping 5", llmBackend, err)
+				time.Sleep(5 * time.Second)
+			}
+		} else if llmGreeting {
+			slog.Info("llm greeting", "llm", llmBackend, "reply", res)
 		}
-
-		fmt.Printf("{ \"audit\": %s },", jsonStr)
-		fmt.Println()
 	}
 
-	filename := os.Args[1]
-	filestream, err := os.Open(filename)
+	quarantine, err := openQuarantine()
 	if err != nil {
-		return fmt.Errorf("open file stream: %s - %w", filename, err)
+		return err
 	}
-
-	gr, err := gzip.NewReader(filestream)
-	if err != nil {
-		return fmt.Errorf("open gzip stream: %w", err)
+	if quarantine != nil {
+		defer quarantine.Close()
 	}
-	defer gr.Close()
 
-	dec := json.NewDecoder(gr)
-	err = parseIndexFile(dec, llama)
-	if err != nil {
+	if err := loadAuditKey(); err != nil {
 		return err
 	}
 
-	if isUniquePlansMode {
-		printUniquePlans()
+	if err := openSQLite(); err != nil {
+		return err
 	}
-	if isHeuristicsMode {
-		printPpoPrices()
+	defer func() {
+		if dbErr := closeSQLite(err); dbErr != nil {
+			err = errors.Join(err, dbErr)
+		}
+	}()
+	if err := openEmbeddings(ctx); err != nil {
+		return err
 	}
-
-	return nil
-}
-
-// parseIndexFile walks the JSON stream and collects
-// allowed_amount_file.location values for records that list the target plan name.
-func parseIndexFile(dec *json.Decoder, llama *ollama.LLM) error {
-	tok, err := dec.Token()
-	if err != nil {
-		return fmt.Errorf("read root token: %w", err)
+	defer func() {
+		if embedErr := closeEmbeddings(); embedErr != nil {
+			err = errors.Join(err, embedErr)
+		}
+	}()
+	if err := openPostgres(ctx); err != nil {
+		return err
 	}
-	if d, ok := tok.(json.Delim); !ok || d != '{' {
-		return errors.New("expected root object")
+	defer func() {
+		if pgErr := closePostgres(context.Background()); pgErr != nil {
+			err = errors.Join(err, pgErr)
+		}
+	}()
+
+	opts := extract.Options{
+		Mode:            extractMode(),
+		LLM:             client,
+		LLMCache:        extract.NewLLMCache(),
+		LLMBatchSize:    llmBatchSize,
+		LLMWorkers:      llmWorkers,
+		PlanTypeLLM:     planTypeLLM,
+		MaxDepth:        maxJSONDepth,
+		MaxStringLength: maxJSONStringLength,
+		Limits:          download.Limits{MaxBytes: maxDecompressedMB << 20, MaxRatio: maxExpansionRatio},
+		Workers:         recordWorkers,
+		MaxMatches:      maxMatches,
+		EINs:            eins,
+		PlanFilter:      planFilterPattern,
+		FuzzyThreshold:  fuzzyThreshold,
+		Weights:         weights,
+		MinScore:        minScore,
+		RawMatches:      rawMatches,
+		Carrier:         carrier,
+		DetectCarrier:   carrierName == "auto",
+		Keywords:        keywords,
+		Prompts:         llmPrompts,
+		KeepGoing:       keepGoing,
+		SourcePaths:     sourcePaths,
+		URLPatterns:     urlPatterns,
+
+		MaxDescriptionLength: maxDescriptionLength,
+		MaxSkippedFieldSize:  maxSkippedFieldMB << 20,
+		Embeddings:           embeddings,
+		EmbeddingThreshold:   embeddingThreshold,
+	}
+	if quarantine != nil {
+		opts.Quarantine = &lockedWriter{w: quarantine}
+	}
+	if err := loadRules(); err != nil {
+		return err
 	}
+	applyRules(&opts)
+	stopMetrics, err := startMetrics(&opts)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
 
-	for dec.More() {
-		keyTok, err := dec.Token()
-		if err != nil {
-			return fmt.Errorf("read root key: %w", err)
-		}
-		key, ok := keyTok.(string)
-		if !ok {
-			return errors.New("unexpected non-string key at root")
-		}
-
-		if key != "reporting_structure" {
-			var discard json.RawMessage
-			if err := dec.Decode(&discard); err != nil {
-				return fmt.Errorf("skip field %q: %w", key, err)
-			}
-			continue
+	if sqsQueueURL != "" {
+		if err := writeProvenance(nil); err != nil {
+			return err
 		}
+		return serve(ctx, opts)
+	}
 
-		err = parseReportingStructure(dec, llama)
-		if err != nil {
+	if evalPath != "" {
+		if err := writeProvenance(nil); err != nil {
 			return err
 		}
+		return evaluate(ctx, opts)
 	}
 
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close root object: %w", err)
+	if inputFilename == "" && manifestPath == "" {
+		// -download-manifest without a filename only resumes its downloads
+		if err := writeProvenance(nil); err != nil {
+			return err
+		}
+		return downloadMatches(ctx)
 	}
 
-	return nil
-}
-
-func parseReportingStructure(dec *json.Decoder, llama *ollama.LLM) error {
-	tok, err := dec.Token()
+	inputs, err := inputFiles()
 	if err != nil {
-		return fmt.Errorf("read reporting_structure value: %w", err)
+		return err
 	}
-	if d, ok := tok.(json.Delim); !ok || d != '[' {
-		return errors.New("reporting_structure is not an array")
+	if err := writeProvenance(inputs); err != nil {
+		return err
 	}
-
-	for dec.More() {
-		tok, err := dec.Token()
-		if err != nil {
-			return fmt.Errorf("read reporting_structure element: %w", err)
-		}
-		if d, ok := tok.(json.Delim); !ok || d != '{' {
-			return errors.New("expected object in reporting_structure array")
-		}
-
-		err = scanReportingRecord(dec, llama)
-		if err != nil {
-			return err
-		}
+	if validateOnly {
+		return validateFiles(ctx, inputs)
 	}
-
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close reporting_structure array: %w", err)
+	if buildGzindex {
+		return buildGzipIndexes(ctx, inputs)
 	}
-
-	return nil
-}
-
-func scanReportingRecord(dec *json.Decoder, llama *ollama.LLM) error {
-	var eins []string
-
-	for dec.More() {
-		keyTok, err := dec.Token()
-		if err != nil {
-			return fmt.Errorf("read reporting_structure key: %w", err)
-		}
-		key, ok := keyTok.(string)
-		if !ok {
-			return errors.New("unexpected non-string key in reporting_structure element")
-		}
-
-		switch key {
-		case "in_network_files":
-			if isUniquePlansMode {
-				err := getUniquePlans(dec, llama, eins)
-				if err != nil {
-					return err
-				}
-			} else if isAnalysisMode {
-				err := checkInNetworkFiles(dec, llama, eins)
-				if err != nil {
-					return err
-				}
-			} else if isHeuristicsMode {
-				err := getPpoPricesByHeuristics(dec)
-				if err != nil {
-					return err
-				}
-			} else {
-				return errors.New("Unknown mode for reporting record")
-			}
-		case "reporting_plans":
-			if isAnalysisMode {
-				eins_2, err := processReportingPlan(dec)
-				if err != nil {
-					return err
-				}
-				eins = eins_2
-				break
-			}
-			fallthrough
-		default:
-			var discard json.RawMessage
-			if err := dec.Decode(&discard); err != nil {
-				return fmt.Errorf("skip field %q: %w", key, err)
-			}
-		}
+	if err := loadURLHistory(); err != nil {
+		return err
 	}
 
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close reporting_structure element: %w", err)
+	err = processFiles(ctx, inputs, opts)
+	printSkippedSummary()
+	if summaryErr := writeSummary(); summaryErr != nil {
+		err = errors.Join(err, summaryErr)
 	}
-
-	return nil
-}
-
-func processReportingPlan(dec *json.Decoder) ([]string, error) {
-	tok, err := dec.Token()
-	if err != nil {
-		return nil, fmt.Errorf("read reporting_plans value: %w", err)
+	if ctx.Err() != nil {
+		// the rate files of an interrupted run are left for a complete one
+		return err
+	}
+	if learnErr := writeLearned(); learnErr != nil {
+		err = errors.Join(err, learnErr)
+	}
+	if probeOnly {
+		return errors.Join(err, probeMatches(ctx))
 	}
-	if d, ok := tok.(json.Delim); !ok || d != '[' {
-		return nil, errors.New("reporting_plans is not an array")
+	if downloadErr := downloadMatches(ctx); downloadErr != nil {
+		err = errors.Join(err, downloadErr)
 	}
 
-	eins := make(map[string]struct{})
+	return err
+}
 
-	for dec.More() {
-		var reportingPlan struct {
-			Type string `json:"plan_id_type"`
-			Id   string `json:"plan_id"`
+// processFile parses one index file and prints its results to out as a
+// contiguous block. Results already printed for another file of the run are
+// skipped. When ctx is done mid-file the results of the records read so far
+// are printed before its error is returned, and the checkpoint is kept.
+func processFile(ctx context.Context, out *output.Writer, filename string, opts extract.Options, dedup *extract.DedupStore) error {
+	file := ""
+	if manifestPath != "" {
+		file = filename
+	}
+	// matches are only held for the stores needing them after the file
+	keepMatches := resultsDB != nil || warehouse != nil || auditKey != nil || learn
+	var matches []extract.Match
+	opts.OnMatch = func(match extract.Match) {
+		printMatch(out, file, match)
+		if keepMatches {
+			matches = append(matches, match)
+		}
+	}
+	if err := applyCheckpoints(&opts, filename); err != nil {
+		return err
+	}
+	extractor := extract.New(opts)
+
+	slog.Debug("reading", "file", filename)
+	stopProgress := func() {}
+	if showProgress {
+		stopProgress = reportProgress(extractor)
+	}
+	err := extractor.ParseFileContext(ctx, filename)
+	stopProgress()
+	if c := extractor.Carrier(); c != nil {
+		slog.Debug("carrier", "file", filename, "carrier", c.Name)
+	}
+	recordSummary(filename, extractor, err != nil)
+	interrupted := err != nil && ctx.Err() != nil
+	// -keep-going writes what was read before a file broke off
+	stopped := err != nil && !interrupted && keepGoing
+	if err != nil && !interrupted && !stopped {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if interrupted {
+		// partial results are printed but not stored as the file's results
+		err = fmt.Errorf("%s: interrupted after %d records: %w", filename, extractor.Progress().Records, err)
+	} else if stopped {
+		removeCheckpoint()
+		err = fmt.Errorf("%s: stopped after %d records: %w", filename, extractor.Stats().Records, err)
+	} else {
+		removeCheckpoint()
+		if err := storeFileResults(filename, extractor, matches); err != nil {
+			return err
 		}
-		if err := dec.Decode(&reportingPlan); err != nil {
-			return nil, fmt.Errorf("decode reporting plan: %w", err)
+		if err := loadFileResults(ctx, filename, extractor, matches); err != nil {
+			return err
 		}
-
-		if strings.ToLower(reportingPlan.Type) == "ein" {
-			eins[reportingPlan.Id] = struct{}{}
+		if err := writeAudits(filename, extractor.Header(), matches); err != nil {
+			return err
 		}
+		noteLearned(filename, matches)
 	}
 
-	if _, err := dec.Token(); err != nil {
-		return nil, fmt.Errorf("close reporting_plans element: %w", err)
-	}
+	// the drift alert is found with the output locked and posted once it is
+	// released, so a slow webhook does not hold up the other files
+	var alert *DriftAlert
+	defer func() { postDriftAlert(alert) }()
+	outMu.Lock()
+	defer outMu.Unlock()
 
-	result := make([]string, 0, len(eins))
-	for k := range eins {
-		result = append(result, k)
+	if manifestPath != "" {
+		// the header keeps the matches of one file together in both formats
+		out.Match(struct {
+			File  string              `json:"file"`
+			Payer string              `json:"payer"`
+			Index extract.IndexHeader `json:"index"`
+		}{File: filename, Payer: payerKey(filename), Index: extractor.Header()})
+	} else {
+		out.Meta("index", extractor.Header())
+	}
+	checkIndexAge(out, filename, extractor.Header())
+
+	switch mode {
+	case modeUniquePlans:
+		printUniquePlans(out, extractor, dedup)
+	case modeHeuristics:
+		printPpoPrices(out, extractor, dedup)
+		printShardGaps(out, extractor)
+		queueDownloads(extractor.PpoPrices())
+	case modeFileSets:
+		printFileSets(out, extractor, dedup)
+		printShardGaps(out, extractor)
+		queueDownloads(extractor.PpoPrices())
+	case modeFHIR:
+		printFHIR(out, extractor, dedup)
+		printShardGaps(out, extractor)
+		queueDownloads(extractor.PpoPrices())
+	case modeStats:
+		printIndexStats(out, filename, extractor)
+	}
+	printFuzzyMatches(out, extractor)
+	printEmbeddingMatches(out, extractor)
+	printURLPatterns(out, extractor)
+	printDrugFiles(out, extractor)
+	printQuarantineSummary(out, extractor)
+	printRecordErrors(out, filename, extractor)
+
+	if interrupted || stopped {
+		return err
+	}
+	if driftHistoryPath != "" {
+		alert, err = checkIndexDrift(out, filename, extractor.Header())
+		return err
 	}
 
-	return result, nil
+	return nil
 }
 
-var ppoPlansMap = map[string]struct{}{
-	"regence bs idaho : par providers":                                                           struct{}{},
-	"bcbs kansas city : preferred care blue":                                                     struct{}{},
-	"bs california : blue high performance":                                                      struct{}{},
-	"bcbs tennessee, inc. : network c":                                                           struct{}{},
-	"hcsc: bcbs texas : blue high performance":                                                   struct{}{},
-	"arkansas bcbs : true blue ppo":                                                              struct{}{},
-	"bcbs massachusetts : blue care elect":                                                       struct{}{},
-	"carefirst bcbs : par network":                                                               struct{}{},
-	"bcbs michigan : par providers":                                                              struct{}{},
-	"bcbs south carolina : blue high performance":                                                struct{}{},
-	"bcbs louisiana : blue hpn":                                                                  struct{}{},
-	"premera bc : heritage prime":                                                                struct{}{},
-	"highmark bs : highmark bs network":                                                          struct{}{},
-	"bcbs north carolina : comprehensive major medical network (cmmn)":                           struct{}{},
-	"regence blueshield : regence-67e0":                                                          struct{}{},
-	"florida blue: bcbs florida : pps":                                                           struct{}{},
-	"hcsc: bcbs new mexico : new mexico bluecard ppo":                                            struct{}{},
-	"bcbs alabama : blue high performance":                                                       struct{}{},
-	"bcbs michigan : blue high performance":                                                      struct{}{},
-	"bcbs south carolina : preferred blue":                                                       struct{}{},
-	"highmark bs northeastern ny : highmark blue shield of northeastern new york - ppo":          struct{}{},
-	"highmark bcbs delaware : blue choice":                                                       struct{}{},
-	"premera bc : prudentbuyer washington":                                                       struct{}{},
-	"hcsc: bcbs illinois : blue high performance":                                                struct{}{},
-	"highmark bcbs : highmark bcbs network":                                                      struct{}{},
-	"bcbs kansas : blue choice":                                                                  struct{}{},
-	"independence bc : par network":                                                              struct{}{},
-	"hcsc: bcbs montana : par network":                                                           struct{}{},
-	"premera bc : heritage signature":                                                            struct{}{},
-	"bcbs massachusetts : blue high performance":                                                 struct{}{},
-	"carefirst bcbs : blue precision":                                                            struct{}{},
-	"bcbs mississippi : par network":                                                             struct{}{},
-	"florida blue: bcbs florida : networkblue":                                                   struct{}{},
-	"independence bc : personal choice":                                                          struct{}{},
-	"regence blueshield : bluecard ppo":                                                          struct{}{},
-	"bcbs arizona : blue preferred":                                                              struct{}{},
-	"bcbs alabama : par network":                                                                 struct{}{},
-	"bcbs vermont : new england health plans (nehp)":                                             struct{}{},
-	"bcbs hawaii : preferred provider network":                                                   struct{}{},
-	"florida blue: triple-s (pr) : bluecard ppo":                                                 struct{}{},
-	"bcbs kansas : traditional providers":                                                        struct{}{},
-	"horizon bcbs new jersey, inc. : select hospitals/par physicians":                            struct{}{},
-	"bcbs north carolina : blue high performance":                                                struct{}{},
-	"hcsc: bcbs illinois : participating provider option":                                        struct{}{},
-	"hcsc: bcbs montana : ppo network":                                                           struct{}{},
-	"highmark bs northeastern ny : highmark blue shield of northeastern new york traditional":    struct{}{},
-	"independence bc : personal choice limited":                                                  struct{}{},
-	"highmark bs : community blue premier":                                                       struct{}{},
-	"bcbs kansas city : participating network":                                                   struct{}{},
-	"premera bc : traditional":                                                                   struct{}{},
-	"regence bcbs utah : preferred blue option":                                                  struct{}{},
-	"health service coalition of nevada (hsc) rates":                                             struct{}{},
-	"bcbs wyoming : wyoming total choice":                                                        struct{}{},
-	"regence bs idaho : blue shield preferred providers":                                         struct{}{},
-	"regence bcbs oregon : oregon high performance":                                              struct{}{},
-	"capital bc : capital blue cross ppo":                                                        struct{}{},
-	"bcbs wyoming : par network":                                                                 struct{}{},
-	"arkansas bcbs : ppp network":                                                                struct{}{},
-	"excellus bcbs : blueppo":                                                                    struct{}{},
-	"bc idaho : participating provider network":                                                  struct{}{},
-	"highmark bcbs western ny : highmark blue cross blue shield of western new york - hpn":       struct{}{},
-	"bcbs kansas city : blueselect plus":                                                         struct{}{},
-	"hcsc: bcbs texas : participating providers":                                                 struct{}{},
-	"bcbs north carolina : preferred provider network (ppn)":                                     struct{}{},
-	"highmark bcbs wv : west virginia par providers":                                             struct{}{},
-	"bs california : ppo network":                                                                struct{}{},
-	"bcbs kansas city : blue high performance":                                                   struct{}{},
-	"bcbs vermont : bcbsvt par providers":                                                        struct{}{},
-	"carefirst bcbs : blueessential":                                                             struct{}{},
-	"capital bc : blue high performance":                                                         struct{}{},
-	"bcbs north carolina : blue value (lcst)":                                                    struct{}{},
-	"bc idaho : preferred blue":                                                                  struct{}{},
-	"highmark bcbs wv : super blue plus":                                                         struct{}{},
-	"premera bc : heritage":                                                                      struct{}{},
-	"bcbs tennessee, inc. : network p":                                                           struct{}{},
-	"bcbs alabama : preferred care":                                                              struct{}{},
-	"wellmark bcbs iowa : alliance select":                                                       struct{}{},
-	"highmark bcbs western ny : highmark blue cross blue shield of western new york-traditional": struct{}{},
-	"premera bc : blue high performance state-wide":                                              struct{}{},
-	"carefirst bcbs : alternate network":                                                         struct{}{},
-	"bcbs wyoming : blue select":                                                                 struct{}{},
-	"bcbs arizona : alliance":                                                                    struct{}{},
-	"hcsc: bcbs illinois : blue choice options":                                                  struct{}{},
-	"in-network negotiated rates files":                                                          struct{}{},
-	"hcsc: bcbs oklahoma : bluechoice ppo":                                                       struct{}{},
-	"hcsc: bcbs illinois : bcbs of illinois par providers":                                       struct{}{},
-	"bcbs north dakota : preferred blue ppo":                                                     struct{}{},
-	"bcbs louisiana : preferred care":                                                            struct{}{},
-	"hcsc: bcbs new mexico : new mexico par network":                                             struct{}{},
-	"florida blue: triple-s (pr) : participating providers":                                      struct{}{},
-	"regence blueshield : blue high performance":                                                 struct{}{},
-	"capital bc : capital blue cross traditional":                                                struct{}{},
-	"florida blue: bcbs florida : ppc / ppo network":                                             struct{}{},
-	"bcbs tennessee, inc. : network s":                                                           struct{}{},
-	"highmark bcbs : community blue":                                                             struct{}{},
-	"bcbs michigan : trust":                                                                      struct{}{},
-	"dental vision":                                                                              struct{}{},
-	"hcsc: bcbs oklahoma : blue traditional":                                                     struct{}{},
-	"bcbs hawaii : participating provider network":                                               struct{}{},
-	"bcbs massachusetts : par providers":                                                         struct{}{},
-	"bcbs minnesota : high value":                                                                struct{}{},
-	"highmark bs : pa national performance blue":                                                 struct{}{},
-	"bcbs nebraska : blueprint health":                                                           struct{}{},
-	"carefirst bcbs : select preferred provider":                                                 struct{}{},
-	"highmark bcbs western ny : highmark bluecross blueshield of western new york-ppo":           struct{}{},
-	"bcbs nebraska : network blue":                                                               struct{}{},
-	"bcbs minnesota : aware":                                                                     struct{}{},
-	"bcbs kansas city : preferred care ppo":                                                      struct{}{},
-	"florida blue: triple-s (vi) : usvi-62a0":                                                    struct{}{},
-	"highmark bcbs delaware : blue classic":                                                      struct{}{},
-	"hcsc: bcbs oklahoma : blue preferred":                                                       struct{}{},
-}
-var regionCodes = map[string]struct{}{
-	"301_71a0": {},
-	"302_42b0": {},
-	"254_39b0": {},
-	"800_72a0": {},
+// errorCode classifies an error that failed a file or the run, fallback when
+// it has no more specific code.
+func errorCode(err error, fallback output.Code) output.Code {
+	var limitErr *download.LimitError
+	var guardErr *extract.LimitError
+	switch {
+	case errors.As(err, &limitErr), errors.As(err, &guardErr):
+		return output.CodeSizeLimit
+	case errors.Is(err, errDownloadsFailed):
+		return output.CodeDownloadFailed
+	case errors.Is(err, errIndexDrift):
+		return output.CodeIndexDrift
+	case errors.Is(err, context.Canceled):
+		return output.CodeInterrupted
+	}
+	return fallback
 }
 
-var uniquePpoPrices = make(map[string]struct{})
+// failure is the error record of a failed file or run. An index file that
+// failed to parse adds the JSON pointer of the record it failed in and about
+// where in the decompressed file, unless several files failed.
+type failure struct {
+	Code   output.Code `json:"code"`
+	Error  string      `json:"error"`
+	Path   string      `json:"path,omitempty"`
+	Offset int64       `json:"offset,omitempty"`
+}
 
-func getPpoPricesByHeuristics(dec *json.Decoder) error {
-	tok, err := dec.Token()
-	if err != nil {
-		return fmt.Errorf("read in_network_files value: %w", err)
-	}
-	if d, ok := tok.(json.Delim); !ok || d != '[' {
-		return errors.New("in_network_files is not an array")
+func newFailure(code output.Code, err error) failure {
+	f := failure{Code: code, Error: err.Error()}
+	if parseErrs := parseErrors(err); len(parseErrs) == 1 {
+		f.Path, f.Offset = parseErrs[0].Path, parseErrs[0].Offset
 	}
+	return f
+}
 
-	for dec.More() {
-		var inNetworkFile struct {
-			Description string `json:"description"`
-			Location    string `json:"location"`
-		}
-		if err := dec.Decode(&inNetworkFile); err != nil {
-			return fmt.Errorf("decode plan: %w", err)
+// parseErrors are the ParseErrors err wraps, one per failed file.
+func parseErrors(err error) []*extract.ParseError {
+	switch err := err.(type) {
+	case *extract.ParseError:
+		return []*extract.ParseError{err}
+	case interface{ Unwrap() []error }:
+		var parseErrs []*extract.ParseError
+		for _, err := range err.Unwrap() {
+			parseErrs = append(parseErrs, parseErrors(err)...)
 		}
+		return parseErrs
+	case interface{ Unwrap() error }:
+		return parseErrors(err.Unwrap())
+	}
+	return nil
+}
 
-		lowerDesc := strings.ToLower(inNetworkFile.Description)
-
-		planMatch := false
-		regionCodeMatch := false
+// sourcedLocation is a heuristics match with -source-paths.
+type sourcedLocation struct {
+	Location string `json:"location"`
+	extract.Source
+}
 
-		if _, exists := ppoPlansMap[lowerDesc]; exists {
-			planMatch = true
-		} else {
+func printPpoPrices(out *output.Writer, extractor *extract.Extractor, dedup *extract.DedupStore) {
+	for _, k := range extractor.PpoPrices() {
+		if !dedup.Add(extract.CanonicalLocation(k)) {
 			continue
 		}
-
-		planCode, err := ExtractPlanCode(inNetworkFile.Location)
-		if err == nil {
-			if _, exists := regionCodes[strings.ToLower(planCode)]; exists {
-				regionCodeMatch = true
-			}
+		var match any = k
+		if sourcePaths {
+			source, _ := extractor.Source(k)
+			match = sourcedLocation{Location: k, Source: source}
 		}
-
-		if planMatch && regionCodeMatch {
-			uniquePpoPrices[inNetworkFile.Location] = struct{}{}
+		if err := out.Match(match); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing ppo prices")
 		}
 	}
-
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close reporting_plans array: %w", err)
-	}
-
-	return nil
 }
 
-func printPpoPrices() {
-	for k := range uniquePpoPrices {
-		jsonStr, err := json.Marshal(k)
-		if err != nil {
-			println("Error during serializing ppo prices")
-		} else {
-			fmt.Printf("%s,", jsonStr)
-			fmt.Println()
+func printFileSets(out *output.Writer, extractor *extract.Extractor, dedup *extract.DedupStore) {
+	for _, set := range extractor.FileSets() {
+		if !dedup.Add(set.Network) {
+			continue
+		}
+		if err := out.Match(set); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing file set")
 		}
 	}
 }
 
-var plansFound map[string]struct{} = make(map[string]struct{})
-
-func getUniquePlans(dec *json.Decoder, llama *ollama.LLM, eins []string) error {
-	tok, err := dec.Token()
-	if err != nil {
-		return fmt.Errorf("read in_network_files value: %w", err)
-	}
-	if d, ok := tok.(json.Delim); !ok || d != '[' {
-		return errors.New("in_network_files is not an array")
-	}
-
-	for dec.More() {
-		var inNetworkFile struct {
-			Description string `json:"description"`
-		}
-		if err := dec.Decode(&inNetworkFile); err != nil {
-			return fmt.Errorf("decode plan: %w", err)
-		}
-
-		lowerDesc := strings.ToLower(inNetworkFile.Description)
-		if lowerDesc == "In-Network Negotiated Rates Files" {
+// printFHIR writes one record per resource, like the ndjson files of FHIR
+// bulk data exports. Resources shared by several index files are written once.
+func printFHIR(out *output.Writer, extractor *extract.Extractor, dedup *extract.DedupStore) {
+	for _, resource := range fhir.Resources(extractor.Header(), extractor.Coverage()) {
+		if !dedup.Add(resource.Reference()) {
 			continue
 		}
-		plansFound[lowerDesc] = struct{}{}
-	}
-
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close reporting_plans array: %w", err)
+		if err := out.Match(resource); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing fhir resource")
+		}
 	}
-
-	return nil
 }
-func checkInNetworkFiles(dec *json.Decoder, llama *ollama.LLM, eins []string) error {
-	tok, err := dec.Token()
-	if err != nil {
-		return fmt.Errorf("read in_network_files value: %w", err)
-	}
-	if d, ok := tok.(json.Delim); !ok || d != '[' {
-		return errors.New("in_network_files is not an array")
-	}
-
-	ctx := context.Background()
-	var isNewYorkPrompt []llms.MessageContent
-	isNewYorkPrompt = append(isNewYorkPrompt, llms.TextParts(llms.ChatMessageTypeSystem, `
-	Does the given insurance plan descriptive name operate in New York? 
-	Your answer should be true for yes, false for no.
-	`))
-	var isPpoPrompt []llms.MessageContent
-	isPpoPrompt = append(isPpoPrompt, llms.TextParts(llms.ChatMessageTypeSystem, `
-	Should the given insurance plan descriptive name be considered a PPO plan? 
-	Your answer should be true for yes, false for no.
-	`))
-
-	targetNy := "ny"
-	targetNewYork := "new york"
-	targetPpo := "ppo"
-	targetPreferred := "preferred"
-
-	regionCodes := map[string]struct{}{
-		"301_71A0": {},
-		"302_42B0": {},
-		"254_39B0": {},
-		"800_72A0": {},
-	}
-	for dec.More() {
-		var inNetworkFile struct {
-			Description string `json:"description"`
-			Location    string `json:"location"`
-		}
-		if err := dec.Decode(&inNetworkFile); err != nil {
-			return fmt.Errorf("decode plan: %w", err)
-		}
-
-		lowerDesc := strings.ToLower(inNetworkFile.Description)
-
-		planMatch := false
-		aiMatch := false
-		regionCodeMatch := false
-		naiveMatch := false
 
-		if strings.Contains(lowerDesc, targetNy) || strings.Contains(lowerDesc, targetNewYork) {
-			if strings.Contains(lowerDesc, targetPpo) || strings.Contains(lowerDesc, targetPreferred) {
-				planMatch = true
-				naiveMatch = true
-			}
+// printShardGaps warns about matched networks whose shards are not all
+// present in the index, since rates from a partial set are biased.
+func printShardGaps(out *output.Writer, extractor *extract.Extractor) {
+	for _, set := range extractor.FileSets() {
+		if len(set.MissingShards) == 0 {
+			continue
 		}
 
-		planCode, err := ExtractPlanCode(inNetworkFile.Location)
-		if err == nil {
-			if _, exists := regionCodes[strings.ToLower(planCode)]; exists {
-				regionCodeMatch = true
-				planMatch = true
-			}
+		warning := struct {
+			Code           output.Code `json:"code"`
+			Warning        string      `json:"warning"`
+			Network        string      `json:"network"`
+			ExpectedShards int         `json:"expectedShards"`
+			MissingShards  []int       `json:"missingShards"`
+		}{
+			Code:           output.CodeShardGap,
+			Warning:        "sharded file set is incomplete in the index",
+			Network:        set.Network,
+			ExpectedShards: set.ExpectedShards,
+			MissingShards:  set.MissingShards,
 		}
-
-		isNewYorkLlm, err := doLlmQuery(ctx, inNetworkFile, llama, isNewYorkPrompt)
-		if err == nil && isNewYorkLlm {
-			isPpoLlm, err := doLlmQuery(ctx, inNetworkFile, llama, isPpoPrompt)
-			if err == nil && isPpoLlm {
-				planMatch = true
-				aiMatch = true
-			}
+		if err := out.Error(warning); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing shard gap warning")
 		}
-
-		if planMatch {
-			printMatch(inNetworkFile.Description, inNetworkFile.Location, eins, aiMatch, naiveMatch, regionCodeMatch)
-		}
-	}
-
-	if _, err := dec.Token(); err != nil {
-		return fmt.Errorf("close reporting_plans array: %w", err)
 	}
-
-	return nil
 }
 
-func doLlmQuery(ctx context.Context, inNetworkFile struct {
-	Description string "json:\"description\""
-	Location    string "json:\"location\""
-}, llama *ollama.LLM, prompt []llms.MessageContent) (bool, error) {
-	prompt = append(prompt, llms.TextParts(llms.ChatMessageTypeHuman, inNetworkFile.Description))
-	aiResponse, err := llama.GenerateContent(ctx, prompt)
-	prompt = prompt[:len(prompt)-1]
-
-	if err != nil {
-		return false, err
+// printFuzzyMatches lists the descriptions -fuzzy allow-listed, with the name
+// each resembles and how closely, for review.
+func printFuzzyMatches(out *output.Writer, extractor *extract.Extractor) {
+	if matches := extractor.FuzzyMatches(); len(matches) > 0 {
+		out.Meta("fuzzyMatches", matches)
 	}
+}
 
-	if strings.ToLower(aiResponse.Choices[0].Content) == "true" {
-		return true, nil
+// printEmbeddingMatches lists the descriptions -embed-threshold
+// allow-listed, with the name each is closest to and how closely.
+func printEmbeddingMatches(out *output.Writer, extractor *extract.Extractor) {
+	if matches := extractor.EmbeddingMatches(); len(matches) > 0 {
+		out.Meta("embeddingMatches", matches)
 	}
-
-	return false, nil
 }
 
-func printUniquePlans() {
-	for k := range plansFound {
-		jsonStr, err := json.Marshal(k)
-		if err != nil {
-			println("Error during serializing unique plan name")
-		} else {
-			fmt.Printf("%s,", jsonStr)
-			fmt.Println()
-		}
+// printURLPatterns writes the location clusters of -url-patterns, those
+// without a plan code scheme are the naming conventions ExtractPlanCode
+// does not know yet.
+func printURLPatterns(out *output.Writer, extractor *extract.Extractor) {
+	if clusters := extractor.URLClusters(); len(clusters) > 0 {
+		out.Meta("urlPatterns", clusters)
 	}
 }
-func printMatch(description string, location string, eins []string, aiMatch bool, heuristicMatch bool, regionCodeMatch bool) {
-	match := struct {
-		Description     string   `json:"description"`
-		Location        string   `json:"location"`
-		Eins            []string `json:"eins"`
-		AIMatch         bool     `json:"aiMatch"`
-		HeuristicMatch  bool     `json:"heuristicMatch"`
-		RegionCodeMatch bool     `json:"regionCodeMatch"`
-	}{
-		Description:     description,
-		Location:        location,
-		Eins:            eins,
-		AIMatch:         aiMatch,
-		HeuristicMatch:  heuristicMatch,
-		RegionCodeMatch: regionCodeMatch,
-	}
 
-	out, err := json.Marshal(match)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "marshal match: %v\n", err)
+// printDrugFiles warns about prescription drug files in the index, they are
+// never matched as rate files but hold the pharmacy pricing of the plans.
+func printDrugFiles(out *output.Writer, extractor *extract.Extractor) {
+	drugFiles := extractor.DrugFiles()
+	if len(drugFiles) == 0 {
 		return
 	}
 
-	fmt.Printf("%s,", out)
-	fmt.Println()
+	warning := struct {
+		Code      output.Code           `json:"code"`
+		Warning   string                `json:"warning"`
+		DrugFiles []extract.NetworkFile `json:"drugFiles"`
+	}{
+		Code:      output.CodeDrugFiles,
+		Warning:   fmt.Sprintf("%d prescription drug files are not rate files, parse them with the rates command", len(drugFiles)),
+		DrugFiles: drugFiles,
+	}
+	if err := out.Error(warning); err != nil {
+		output.Logf(output.CodeSerialize, "Error during serializing drug file warning")
+	}
 }
 
-func ExtractPlanCode(rawURL string) (string, error) {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return "", err
+// printUniquePlans prints the unique plans of a file, or adds them to the
+// planTally of a manifest run.
+func printUniquePlans(out *output.Writer, extractor *extract.Extractor, dedup *extract.DedupStore) {
+	if planTally != nil {
+		planTally.Add(extractor.PlanSummaries())
+		return
 	}
-
-	filename := path.Base(u.Path)
-	if filename == "" || filename == "/" {
-		return "", errors.New("no filename found in URL path")
+	var summaries []extract.PlanSummary
+	for _, summary := range extractor.PlanSummaries() {
+		if dedup.Add(summary.Plan) {
+			summaries = append(summaries, summary)
+		}
 	}
+	printPlanSummaries(out, summaries)
+}
 
-	// Find underscore positions
-	first := strings.Index(filename, "_")
-	if first == -1 {
-		return "", errors.New("filename does not contain underscores")
+func printPlanSummaries(out *output.Writer, summaries []extract.PlanSummary) {
+	for _, summary := range summaries {
+		if err := out.Match(summary); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing unique plan name")
+		}
 	}
+}
 
-	second := strings.Index(filename[first+1:], "_")
-	if second == -1 {
-		return "", errors.New("filename does not contain enough underscores")
+// printIndexStats prints the stats mode counts of filename.
+func printIndexStats(out *output.Writer, filename string, extractor *extract.Extractor) {
+	record := struct {
+		File string `json:"file"`
+		extract.IndexStats
+	}{File: filename, IndexStats: extractor.IndexStats()}
+	if err := out.Match(record); err != nil {
+		output.Logf(output.CodeSerialize, "marshal index stats: %v", err)
 	}
-	second += first + 1
+}
 
-	third := strings.Index(filename[second+1:], "_")
-	if third == -1 {
-		return "", errors.New("filename does not contain enough underscores")
+// printMatch prints an analysis match, tagged with its index file in
+// manifest runs.
+func printMatch(out *output.Writer, file string, match extract.Match) {
+	var record any = match
+	if file != "" {
+		record = struct {
+			File string `json:"file"`
+			extract.Match
+		}{File: file, Match: match}
 	}
-	third += second + 1
 
-	if third <= first+1 {
-		return "", errors.New("invalid underscore positions in filename")
-	}
+	outMu.Lock()
+	defer outMu.Unlock()
 
-	return filename[first+1 : third], nil
+	if err := out.Match(record); err != nil {
+		output.Logf(output.CodeSerialize, "marshal match: %v", err)
+	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"serif_interview/pkg/extract"
+)
+
+var manifestPath = ""
+var fileWorkers = 1
+var recordWorkers = 1
+
+// outMu keeps the output of concurrently processed files from interleaving.
+var outMu sync.Mutex
+
+// inputFiles returns the index files of this run, the manifest entries when
+// -manifest is given and the single filename argument otherwise.
+func inputFiles() ([]string, error) {
+	if manifestPath == "" {
+		return []string{inputFilename}, nil
+	}
+
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %s - %w", manifestPath, err)
+	}
+	defer f.Close()
+
+	return readManifest(f)
+}
+
+// readManifest reads one index file path per line, skipping blank lines and
+// lines starting with #.
+func readManifest(r io.Reader) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("manifest lists no index files")
+	}
+
+	return files, nil
+}
+
+// planTally adds up the unique plans of the files of a manifest run, they
+// are printed once every file is read.
+var planTally *extract.PlanTally
+
+// processFiles runs processFile for every input with at most fileWorkers
+// files in flight. A failing file does not stop the others, all errors are
+// returned together. Once ctx is done no further files are started.
+func processFiles(ctx context.Context, inputs []string, opts extract.Options) error {
+	dedup := extract.NewDedupStore()
+	if mode == modeUniquePlans && len(inputs) > 1 {
+		planTally = extract.NewPlanTally()
+		defer func() { planTally = nil }()
+	}
+	sem := make(chan struct{}, max(fileWorkers, 1))
+
+	var wg sync.WaitGroup
+	var errsMu sync.Mutex
+	var errs []error
+	started := 0
+
+	for _, filename := range inputs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			errsMu.Lock()
+			errs = append(errs, fmt.Errorf("%d of %d files not started: %w", len(inputs)-started, len(inputs), ctx.Err()))
+			errsMu.Unlock()
+			break
+		}
+		started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := processFile(ctx, results, filename, opts, dedup); err != nil {
+				errsMu.Lock()
+				errs = append(errs, err)
+				errsMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if planTally != nil {
+		printPlanSummaries(results, planTally.Summaries())
+	}
+
+	return errors.Join(errs...)
+}
+
+// lockedWriter serialises writes from concurrently running extractors.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/metrics"
+	"serif_interview/pkg/output"
+)
+
+var metricsAddr = ""
+
+// startMetrics serves /metrics on -metrics-addr and has opts report to it,
+// until the returned stop is called.
+func startMetrics(opts *extract.Options) (stop func(), err error) {
+	if metricsAddr == "" {
+		return func() {}, nil
+	}
+	ln, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+
+	m := metrics.New()
+	opts.Observer = m
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			output.Logf(output.CodeMetrics, "metrics: %v", err)
+		}
+	}()
+
+	slog.Debug("metrics listening", "addr", ln.Addr().String())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"serif_interview/pkg/output"
+)
+
+var slackWebhookURL = ""
+var teamsWebhookURL = ""
+
+// notifyCompletion posts the run outcome and per payer summary to the
+// configured Slack and Teams incoming webhooks. Failures to notify are only
+// reported on stderr, they do not change the exit code.
+func notifyCompletion(runErr error, duration time.Duration) {
+	if slackWebhookURL == "" && teamsWebhookURL == "" {
+		return
+	}
+
+	title := fmt.Sprintf("extract %s finished in %s", mode, duration.Round(time.Second))
+	if runErr != nil {
+		title = fmt.Sprintf("extract %s failed after %s", mode, duration.Round(time.Second))
+	}
+
+	var details strings.Builder
+	if manifestPath != "" {
+		fmt.Fprintf(&details, "manifest: %s\n", manifestPath)
+	} else {
+		fmt.Fprintf(&details, "file: %s\n", inputFilename)
+	}
+	if runErr != nil {
+		fmt.Fprintf(&details, "error: %s\n", runErr)
+	}
+	table := summaryTable()
+
+	if slackWebhookURL != "" {
+		if err := postJSON(slackWebhookURL, slackMessage(title, details.String(), table, runErr != nil)); err != nil {
+			output.Logf(output.CodeWebhook, "slack notification: %v", err)
+		}
+	}
+	if teamsWebhookURL != "" {
+		if err := postJSON(teamsWebhookURL, teamsMessage(title, details.String(), table, runErr != nil)); err != nil {
+			output.Logf(output.CodeWebhook, "teams notification: %v", err)
+		}
+	}
+}
+
+// summaryTable renders the per payer summary as aligned plain text, neither
+// Slack nor Teams messages support markdown tables.
+func summaryTable() string {
+	rows := summaryRows()
+	if len(rows) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(summaryColumns, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(summaryCells(row), "\t"))
+	}
+	tw.Flush()
+
+	return buf.String()
+}
+
+func slackMessage(title string, details string, table string, failed bool) []byte {
+	icon := ":white_check_mark:"
+	if failed {
+		icon = ":x:"
+	}
+
+	blocks := []any{
+		map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("%s *%s*\n%s", icon, title, details)},
+		},
+	}
+	if table != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "```" + table + "```"},
+		})
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"text":   title,
+		"blocks": blocks,
+	})
+	return body
+}
+
+// teamsMessage builds an adaptive card, accepted by both Teams workflow
+// webhooks and the older connector webhooks.
+func teamsMessage(title string, details string, table string, failed bool) []byte {
+	color := "Good"
+	if failed {
+		color = "Attention"
+	}
+
+	cardBody := []any{
+		map[string]any{"type": "TextBlock", "text": title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+		map[string]any{"type": "TextBlock", "text": details, "wrap": true},
+	}
+	if table != "" {
+		cardBody = append(cardBody, map[string]any{"type": "TextBlock", "text": table, "fontType": "Monospace", "wrap": true})
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"type": "message",
+		"attachments": []any{
+			map[string]any{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    cardBody,
+				},
+			},
+		},
+	})
+	return body
+}
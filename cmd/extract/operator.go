@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/leader"
+)
+
+var configPath = ""
+var stateDir = ""
+var leaderElect = false
+var leaseName = "extract"
+
+// applyConfigFile sets every flag named in the json object at configPath,
+// e.g. a ConfigMap mounted into the pod. Flags given on the command line take
+// precedence over the file.
+func applyConfigFile(fs *flag.FlagSet) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("parse config %s: %w", configPath, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("config %s: unknown setting %q", configPath, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(values[name])); err != nil {
+			return fmt.Errorf("config %s: %s: %w", configPath, name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyStateDir keeps the history, quarantine and api key files of long running
+// deployments on one persistent volume unless they are set individually.
+func applyStateDir() {
+	if stateDir == "" {
+		return
+	}
+	if driftHistoryPath == "" {
+		driftHistoryPath = filepath.Join(stateDir, "drift-history.json")
+	}
+	if urlHistoryPath == "" {
+		urlHistoryPath = filepath.Join(stateDir, "url-history.json")
+	}
+	if quarantinePath == "" {
+		quarantinePath = filepath.Join(stateDir, "quarantine.jsonl")
+	}
+	if apiKeysPath == "" {
+		apiKeysPath = filepath.Join(stateDir, "api-keys.json")
+	}
+	if jobIndexPath == "" {
+		jobIndexPath = filepath.Join(stateDir, "job-index.json")
+	}
+}
+
+// serve runs the listener until ctx is done on SIGINT or SIGTERM, with
+// -leader-elect only on the replica holding the lease so a replicated
+// Deployment processes every event once.
+func serve(ctx context.Context, opts extract.Options) error {
+	if adminAddr != "" {
+		if err := startAdmin(ctx, opts.LLMCache); err != nil {
+			return err
+		}
+	}
+
+	if !leaderElect {
+		return listen(ctx, opts)
+	}
+
+	elector, err := leader.NewInCluster(leader.Options{Name: leaseName})
+	if err != nil {
+		return err
+	}
+	slog.Debug("waiting for lease", "lease", leaseName, "identity", elector.Identity())
+	err = elector.Run(ctx, func(ctx context.Context) error {
+		slog.Debug("acquired lease", "lease", leaseName)
+		return listen(ctx, opts)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
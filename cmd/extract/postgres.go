@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/postgres"
+)
+
+var postgresURL = ""
+var postgresBatch = postgres.DefaultBatchSize
+
+// warehouse loads the matches of every file into -out-pg, nil when not
+// given.
+var warehouse *postgres.Sink
+
+func openPostgres(ctx context.Context) error {
+	if postgresURL == "" {
+		return nil
+	}
+	sink, err := postgres.Open(ctx, postgresURL, postgres.Options{BatchSize: postgresBatch})
+	if err != nil {
+		return err
+	}
+	warehouse = sink
+	return nil
+}
+
+// closePostgres copies the matches still buffered.
+func closePostgres(ctx context.Context) error {
+	if warehouse == nil {
+		return nil
+	}
+	return warehouse.Close(ctx)
+}
+
+// loadFileResults buffers the matches of one index file for the warehouse.
+func loadFileResults(ctx context.Context, filename string, extractor *extract.Extractor, matches []extract.Match) error {
+	if warehouse == nil {
+		return nil
+	}
+
+	payer := payerKey(filename)
+	switch mode {
+	case modeHeuristics, modeFHIR:
+		for _, location := range extractor.PpoPrices() {
+			err := warehouse.AddMatch(ctx, postgres.Match{Payer: payer, Location: location, File: filename, Mode: mode, HeuristicMatch: true})
+			if err != nil {
+				return err
+			}
+		}
+	case modeFileSets:
+		for _, set := range extractor.FileSets() {
+			for _, location := range set.Locations {
+				err := warehouse.AddMatch(ctx, postgres.Match{Payer: payer, Location: location, File: filename, Mode: mode,
+					Network: set.Network, PlanCode: set.PlanCode, HeuristicMatch: true})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case modeAnalysis:
+		for _, match := range matches {
+			err := warehouse.AddMatch(ctx, postgres.Match{
+				Payer:           payer,
+				Location:        match.Location,
+				File:            filename,
+				Mode:            mode,
+				Description:     match.Description,
+				EINs:            match.Eins,
+				AIMatch:         match.AIMatch,
+				HeuristicMatch:  match.HeuristicMatch,
+				RegionCodeMatch: match.RegionCodeMatch,
+				Score:           match.Score,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// a listener runs until stopped, its files are loaded as they complete
+	if sqsQueueURL != "" {
+		return warehouse.Flush(ctx)
+	}
+	return nil
+}
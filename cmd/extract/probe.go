@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/output"
+)
+
+var probeOnly = false
+
+// probeTotals sums up a -probe run, what downloading the matches would take.
+type probeTotals struct {
+	Files       int    `json:"files"`
+	Reachable   int    `json:"reachable"`
+	Dead        int    `json:"dead"`
+	UnknownSize int    `json:"unknownSize"`
+	Bytes       int64  `json:"bytes"`
+	Size        string `json:"size"`
+}
+
+// probeMatches sends a HEAD request to every matched location and prints one
+// probe record per file and the totals.
+func probeMatches(ctx context.Context) error {
+	if len(matchedLocations) == 0 {
+		return nil
+	}
+
+	slog.Debug("probing", "files", len(matchedLocations))
+	probes := newDownloader().ProbeAll(ctx, matchedLocations)
+
+	totals := probeTotals{Files: len(probes)}
+	for _, probe := range probes {
+		switch {
+		case probe.Error != "":
+			totals.Dead++
+			output.Logf(output.CodeDeadLink, "%s", probe.Error)
+		case probe.ContentLength < 0:
+			totals.Reachable++
+			totals.UnknownSize++
+		default:
+			totals.Reachable++
+			totals.Bytes += probe.ContentLength
+		}
+		if err := results.Match(struct {
+			Probe download.ProbeResult `json:"probe"`
+		}{Probe: probe}); err != nil {
+			output.Logf(output.CodeSerialize, "Error during serializing probe result")
+		}
+	}
+	totals.Size = formatBytes(totals.Bytes)
+	results.Meta("probeTotals", totals)
+	return ctx.Err()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var profileName = ""
+var profileFile = ""
+
+// profileFiles are looked up in the working directory when -profile-file is
+// not given.
+var profileFiles = []string{"extract.yaml", "extract.yml", "extract.toml"}
+
+// profilePlans, profileDeny and profileRegions are the plan list, deny-list
+// and regions a profile gives inline rather than as file paths, as yaml.
+var profilePlans []byte
+var profileDeny []byte
+var profileRegions []byte
+
+// profilesFile is the layout of a profile file, each profile setting flags
+// by name:
+//
+//	profiles:
+//	  ny-ppo:
+//	    mode: analysis
+//	    state: NY
+//	    plans: plans/ny.yaml
+//	    llm: ollama
+//	    format: csv
+//
+// or in TOML
+//
+//	[profiles.ny-ppo]
+//	mode = "analysis"
+//	regions = ["301_71A0", "302_42B0"]
+type profilesFile struct {
+	Profiles map[string]map[string]any `yaml:"profiles" toml:"profiles"`
+}
+
+// applyProfile sets every flag the -profile profile names that is not set on
+// the command line. Plan, deny and region file paths are relative to the
+// profile file, and each may be given inline instead.
+func applyProfile(fs *flag.FlagSet) error {
+	path, err := findProfileFile()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read profiles: %w", err)
+	}
+
+	var file profilesFile
+	if strings.HasSuffix(path, ".toml") {
+		err = toml.Unmarshal(content, &file)
+	} else {
+		err = yaml.Unmarshal(content, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("parse profiles %s: %w", path, err)
+	}
+	profile, ok := file.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profiles %s: no profile %q, expected one of %v", path, profileName, slices.Sorted(maps.Keys(file.Profiles)))
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for _, name := range slices.Sorted(maps.Keys(profile)) {
+		if fs.Lookup(name) == nil || name == "profile" || name == "profile-file" {
+			return fmt.Errorf("profile %s: unknown setting %q", profileName, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		value := profile[name]
+		if name == "plans" || name == "deny" || name == "regions" {
+			if relative, ok := value.(string); ok {
+				if !filepath.IsAbs(relative) {
+					value = filepath.Join(filepath.Dir(path), relative)
+				}
+			} else {
+				inline, err := yaml.Marshal(value)
+				if err != nil {
+					return fmt.Errorf("profile %s: %s: %w", profileName, name, err)
+				}
+				switch name {
+				case "plans":
+					profilePlans = inline
+				case "deny":
+					profileDeny = inline
+				default:
+					profileRegions = inline
+				}
+				continue
+			}
+		}
+		if list, ok := value.([]any); ok {
+			// lists of comma separated flags such as ein
+			values := make([]string, len(list))
+			for i, v := range list {
+				values[i] = fmt.Sprint(v)
+			}
+			value = strings.Join(values, ",")
+		}
+		if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("profile %s: %s: %w", profileName, name, err)
+		}
+	}
+
+	return nil
+}
+
+// findProfileFile returns -profile-file, or the first of profileFiles in the
+// working directory.
+func findProfileFile() (string, error) {
+	if profileFile != "" {
+		return profileFile, nil
+	}
+	for _, name := range profileFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", errors.New("-profile needs -profile-file or one of " + strings.Join(profileFiles, ", ") + " in the working directory")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"serif_interview/pkg/extract"
+)
+
+var showProgress = false
+
+const progressInterval = 5 * time.Second
+
+// reportProgress prints how far extractor has read to stderr every
+// progressInterval until the returned stop is called.
+func reportProgress(extractor *extract.Extractor) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		// nested table of contents files restart the byte count
+		var file string
+		var fileStart time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			progress := extractor.Progress()
+			if progress.File != file {
+				file, fileStart = progress.File, time.Now().Add(-progressInterval)
+			}
+			slog.Info("progress", progressAttrs(progress, time.Since(start), time.Since(fileStart))...)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// progressAttrs estimates completion from the compressed bytes read so far,
+// records vary too much in size to count towards it.
+func progressAttrs(progress extract.Progress, elapsed time.Duration, fileElapsed time.Duration) []any {
+	attrs := []any{"file", filepath.Base(progress.File), "read", formatBytes(progress.BytesRead)}
+	if progress.TotalBytes > 0 {
+		attrs = append(attrs, "size", formatBytes(progress.TotalBytes), "percent", fmt.Sprintf("%.1f", 100*float64(progress.BytesRead)/float64(progress.TotalBytes)))
+	}
+	attrs = append(attrs, "records", progress.Records, "recordsPerSecond", int64(float64(progress.Records)/elapsed.Seconds()))
+
+	if progress.TotalBytes > 0 && progress.BytesRead > 0 {
+		rate := float64(progress.BytesRead) / fileElapsed.Seconds()
+		remaining := time.Duration(float64(progress.TotalBytes-progress.BytesRead) / rate * float64(time.Second))
+		attrs = append(attrs, "eta", time.Now().Add(remaining).Format(time.TimeOnly), "remaining", remaining.Round(time.Second).String())
+	}
+	return attrs
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
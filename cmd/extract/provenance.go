@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+
+	"serif_interview/pkg/download"
+)
+
+// version and commit name the build, set with
+// -ldflags="-X main.version=v1.2.0 -X main.commit=abc123". Builds inside the
+// git checkout fall back to the module version and vcs revision Go embeds.
+var version = ""
+var commit = ""
+
+// resultFlags are the flags that shape the results of a file, the ones the
+// config fingerprint covers. Output paths, logging and checkpoints do not.
+var resultFlags = []string{
+	"plans", "deny", "state", "plan-type", "regions", "ein", "plan-filter", "min-score", "signal-weights", "raw-matches", "fuzzy", "embed-model", "embed-threshold", "carrier", "keep-going", "source-paths", "url-patterns",
+	"llm", "llm-model", "llm-batch", "plan-type-llm", "max-depth", "max-string-length", "max-decompressed-mb", "max-ratio",
+	"max-description-length", "max-skipped-field-mb", "max-matches",
+	"format", "columns", "header",
+}
+
+// resultSettings are the resultFlags values after parsing, defaults included.
+var resultSettings map[string]string
+
+func recordResultSettings(fs *flag.FlagSet) {
+	resultSettings = make(map[string]string, len(resultFlags))
+	for _, name := range resultFlags {
+		if f := fs.Lookup(name); f != nil {
+			resultSettings[name] = f.Value.String()
+		}
+	}
+}
+
+// provenance is the record written before any results, naming the build,
+// host, inputs and settings a result set was produced with.
+type provenance struct {
+	Tool      string            `json:"tool"`
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit,omitempty"`
+	Modified  bool              `json:"modified,omitempty"`
+	GoVersion string            `json:"goVersion"`
+	Hostname  string            `json:"hostname"`
+	Mode      string            `json:"mode"`
+	Config    string            `json:"config"`
+	Rules     string            `json:"rules"`
+	Inputs    []provenanceInput `json:"inputs"`
+}
+
+// provenanceInput is an index file of the run. Urls are streamed once while
+// parsing, so only local files have a size and digest.
+type provenanceInput struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// newProvenance describes the run over inputs, hashing the local ones.
+func newProvenance(inputs []string) (provenance, error) {
+	p := provenance{
+		Tool:      "extract",
+		Version:   version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		Mode:      mode,
+		Config:    configFingerprint(),
+		Rules:     loadedRulesVersion,
+		Inputs:    make([]provenanceInput, 0, len(inputs)),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if p.Version == "" {
+			p.Version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if p.Commit == "" {
+					p.Commit = setting.Value
+				}
+			case "vcs.modified":
+				p.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		p.Hostname = hostname
+	}
+
+	for _, filename := range inputs {
+		input := provenanceInput{File: filename}
+		// files that cannot be opened fail when they are processed
+		if info, err := os.Stat(filename); err == nil && !download.IsURL(filename) {
+			input.Size = info.Size()
+			if input.SHA256, err = fileDigest(filename); err != nil {
+				return p, fmt.Errorf("hash %s: %w", filename, err)
+			}
+		}
+		p.Inputs = append(p.Inputs, input)
+	}
+	return p, nil
+}
+
+// writeProvenance writes the provenance record of the run over inputs.
+func writeProvenance(inputs []string) error {
+	p, err := newProvenance(inputs)
+	if err != nil {
+		return err
+	}
+	return results.Meta("provenance", p)
+}
+
+// configFingerprint digests the mode and resultSettings, runs with the same
+// fingerprint and rules produce the same results from the same input.
+func configFingerprint() string {
+	names := make([]string, 0, len(resultSettings))
+	for name := range resultSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "mode=%s\n", mode)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\n", name, resultSettings[name])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
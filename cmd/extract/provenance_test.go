@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+// TestResultFlags checks that the config fingerprint covers flags that exist,
+// a renamed flag would silently drop out of it.
+func TestResultFlags(t *testing.T) {
+	fs, _, _ := newFlagSet()
+	for _, name := range resultFlags {
+		if fs.Lookup(name) == nil {
+			t.Errorf("result flag -%s is not defined", name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+var quarantinePath = ""
+
+func openQuarantine() (*os.File, error) {
+	if quarantinePath == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(quarantinePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open quarantine file: %s - %w", quarantinePath, err)
+	}
+
+	return f, nil
+}
+
+func printQuarantineSummary(out *output.Writer, extractor *extract.Extractor) {
+	if extractor.Quarantined() == 0 {
+		return
+	}
+
+	out.Error(struct {
+		Code       output.Code `json:"code"`
+		Warning    string      `json:"warning"`
+		Quarantine string      `json:"quarantine"`
+	}{
+		Code:       output.CodeQuarantine,
+		Warning:    fmt.Sprintf("%d values with unexpected types were written to the quarantine file", extractor.Quarantined()),
+		Quarantine: quarantinePath,
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"serif_interview/pkg/extract"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var retainAge time.Duration
+var retainCount = 0
+var retainCacheEntries = 100000
+var retentionInterval = time.Hour
+
+// StoreStats is the size of everything a long running listener keeps,
+// written as the "store" meta record after each retention sweep.
+type StoreStats struct {
+	ResultObjects     int    `json:"resultObjects"`
+	ResultBytes       int64  `json:"resultBytes"`
+	ResultsDeleted    int    `json:"resultsDeleted"`
+	DriftRecords      int    `json:"driftRecords"`
+	DriftBytes        int64  `json:"driftBytes"`
+	DriftPruned       int    `json:"driftPruned"`
+	URLHistoryBytes   int64  `json:"urlHistoryBytes"`
+	QuarantineBytes   int64  `json:"quarantineBytes"`
+	LLMCacheEntries   int    `json:"llmCacheEntries"`
+	LLMCachePruned    int    `json:"llmCachePruned"`
+	RetentionDuration string `json:"retentionDuration"`
+}
+
+// enforceRetention deletes uploaded results, drift history records and
+// cached llm answers beyond -retain-age and -retain-count, then reports the
+// remaining store size.
+func enforceRetention(ctx context.Context, client *s3.Client, cache *extract.LLMCache) error {
+	start := time.Now()
+	var cutoff time.Time
+	if retainAge > 0 {
+		cutoff = start.Add(-retainAge)
+	}
+
+	var stats StoreStats
+	if err := pruneResults(ctx, client, cutoff, &stats); err != nil {
+		return err
+	}
+	if driftHistoryPath != "" {
+		if err := pruneDriftHistory(cutoff, &stats); err != nil {
+			return err
+		}
+		stats.DriftBytes = fileSize(driftHistoryPath)
+	}
+	if cache != nil {
+		stats.LLMCachePruned = cache.Prune(cutoff, retainCacheEntries)
+		stats.LLMCacheEntries = cache.Len()
+	}
+	stats.URLHistoryBytes = fileSize(urlHistoryPath)
+	stats.QuarantineBytes = fileSize(quarantinePath)
+	stats.RetentionDuration = time.Since(start).String()
+
+	results.Meta("store", stats)
+	return nil
+}
+
+// pruneResults keeps the newest -retain-count result documents below
+// -results-prefix that are not older than cutoff.
+func pruneResults(ctx context.Context, client *s3.Client, cutoff time.Time, stats *StoreStats) error {
+	var objects []types.Object
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(resultsBucket),
+		Prefix: aws.String(resultsPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list results in %s: %w", resultsBucket, err)
+		}
+		objects = append(objects, page.Contents...)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return aws.ToTime(objects[i].LastModified).After(aws.ToTime(objects[j].LastModified))
+	})
+
+	var expired []types.ObjectIdentifier
+	for i, object := range objects {
+		tooMany := retainCount > 0 && i >= retainCount
+		tooOld := !cutoff.IsZero() && aws.ToTime(object.LastModified).Before(cutoff)
+		if tooMany || tooOld {
+			expired = append(expired, types.ObjectIdentifier{Key: object.Key})
+			continue
+		}
+		stats.ResultObjects++
+		stats.ResultBytes += aws.ToInt64(object.Size)
+	}
+
+	// DeleteObjects takes at most 1000 keys per request
+	for len(expired) > 0 {
+		batch := expired[:min(len(expired), 1000)]
+		expired = expired[len(batch):]
+
+		resp, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(resultsBucket),
+			Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("delete expired results in %s: %w", resultsBucket, err)
+		}
+		stats.ResultsDeleted += len(batch) - len(resp.Errors)
+	}
+
+	return nil
+}
+
+// pruneDriftHistory drops records before cutoff and beyond -retain-count per
+// payer. The latest record of a payer is always kept, the next run compares
+// against it.
+func pruneDriftHistory(cutoff time.Time, stats *StoreStats) error {
+	history, err := readDriftHistory()
+	if err != nil {
+		return err
+	}
+
+	pruned := 0
+	for payer, records := range history {
+		keep := records
+		if retainCount > 0 && len(keep) > retainCount {
+			keep = keep[len(keep)-retainCount:]
+		}
+		if !cutoff.IsZero() {
+			for len(keep) > 1 {
+				runTime, err := time.ParseInLocation(time.DateTime, keep[0].RunTime, time.Local)
+				if err == nil && !runTime.Before(cutoff) {
+					break
+				}
+				keep = keep[1:]
+			}
+		}
+		pruned += len(records) - len(keep)
+		history[payer] = keep
+		stats.DriftRecords += len(keep)
+	}
+	stats.DriftPruned = pruned
+
+	if pruned == 0 {
+		return nil
+	}
+	return writeDriftHistory(history)
+}
+
+func fileSize(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
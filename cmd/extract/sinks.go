@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"serif_interview/pkg/output"
+)
+
+var outWebhookURL = ""
+var outWebhookBatch = output.DefaultWebhookBatch
+var recordsSQLitePath = ""
+var callbackURL = ""
+var callbackBatch = output.DefaultWebhookBatch
+var webhookRetries = 3
+
+// webhookSecretEnv holds the key signing -webhook and -out-webhook requests,
+// kept out of the command line and the provenance record.
+const webhookSecretEnv = "EXTRACT_WEBHOOK_SECRET"
+
+// callback posts the matches and the run summary to -webhook, nil when not
+// given.
+var callback *output.Callback
+
+// openSinks opens the outputs receiving every record besides -out, a
+// failure of one later only drops it.
+func openSinks(startTime time.Time) ([]output.Sink, error) {
+	run := startTime.UTC().Format(time.RFC3339Nano)
+	webhook := output.WebhookOptions{Secret: os.Getenv(webhookSecretEnv), Retries: webhookRetries}
+
+	var sinks []output.Sink
+	if outWebhookURL != "" {
+		opts := webhook
+		opts.Batch, opts.Delivery = outWebhookBatch, run+"/records/"
+		sinks = append(sinks, output.NewWebhookSink(outWebhookURL, opts))
+	}
+	if callbackURL != "" {
+		opts := webhook
+		opts.Batch = callbackBatch
+		callback = output.NewCallback(callbackURL, run, opts)
+		sinks = append(sinks, callback)
+	}
+	if recordsSQLitePath != "" {
+		db, err := sql.Open("sqlite", recordsSQLitePath+"?_pragma=busy_timeout(10000)")
+		if err != nil {
+			return nil, fmt.Errorf("open sqlite %s: %w", recordsSQLitePath, err)
+		}
+		db.SetMaxOpenConns(1)
+		sink, err := output.NewSQLiteSink(db, run)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", recordsSQLitePath, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func sinkFailed(err error) {
+	output.Logf(output.CodeSinkFailed, "%v, no further records are sent to it", err)
+}
+
+// callbackSummary is the summary -webhook receives after the last matches.
+type callbackSummary struct {
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Duration string         `json:"duration"`
+	Payers   []PayerSummary `json:"payers"`
+}
+
+// setCallbackSummary hands the run outcome to -webhook, posted when the
+// results are closed.
+func setCallbackSummary(runErr error, duration time.Duration) {
+	if callback == nil {
+		return
+	}
+	summary := callbackSummary{Status: "succeeded", Duration: duration.Round(time.Millisecond).String(), Payers: summaryRows()}
+	if runErr != nil {
+		summary.Status, summary.Error = "failed", runErr.Error()
+	}
+	callback.SetSummary(summary)
+}
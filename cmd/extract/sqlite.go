@@ -0,0 +1,193 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/extract"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqlitePath = ""
+
+// resultsDB accumulates the results of every run in -out-sqlite, nil when
+// not given.
+var resultsDB *sql.DB
+var runID int64
+
+// sqliteMu serializes file transactions, sqlite has a single writer.
+var sqliteMu sync.Mutex
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY,
+	started_at TEXT NOT NULL,
+	finished_at TEXT,
+	mode TEXT NOT NULL,
+	input TEXT NOT NULL,
+	error TEXT
+);
+CREATE TABLE IF NOT EXISTS plans (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	file TEXT NOT NULL,
+	payer TEXT NOT NULL,
+	name TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS matches (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	file TEXT NOT NULL,
+	payer TEXT NOT NULL,
+	location TEXT NOT NULL,
+	network TEXT,
+	plan_code TEXT,
+	description TEXT,
+	ai_match INTEGER,
+	heuristic_match INTEGER,
+	region_code_match INTEGER
+);
+CREATE TABLE IF NOT EXISTS eins (
+	match_id INTEGER NOT NULL REFERENCES matches(id),
+	ein TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS matches_run ON matches(run_id);
+CREATE INDEX IF NOT EXISTS matches_location ON matches(location);
+CREATE INDEX IF NOT EXISTS plans_run ON plans(run_id);
+CREATE INDEX IF NOT EXISTS eins_match ON eins(match_id);
+CREATE INDEX IF NOT EXISTS eins_ein ON eins(ein);
+`
+
+// openSQLite creates the tables when needed and registers this run.
+func openSQLite() error {
+	if sqlitePath == "" {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", sqlitePath+"?_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return fmt.Errorf("open sqlite %s: %w", sqlitePath, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return fmt.Errorf("create sqlite tables in %s: %w", sqlitePath, err)
+	}
+
+	input := inputFilename
+	if manifestPath != "" {
+		input = manifestPath
+	}
+	res, err := db.Exec(`INSERT INTO runs (started_at, mode, input) VALUES (?, ?, ?)`,
+		time.Now().Format(time.DateTime), mode, input)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("insert run into %s: %w", sqlitePath, err)
+	}
+	runID, err = res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	resultsDB = db
+	return nil
+}
+
+// closeSQLite records how the run ended.
+func closeSQLite(runErr error) error {
+	if resultsDB == nil {
+		return nil
+	}
+
+	var errText sql.NullString
+	if runErr != nil {
+		errText = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := resultsDB.Exec(`UPDATE runs SET finished_at = ?, error = ? WHERE id = ?`,
+		time.Now().Format(time.DateTime), errText, runID)
+	if closeErr := resultsDB.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("finish run in %s: %w", sqlitePath, err)
+	}
+	return nil
+}
+
+// storeFileResults inserts everything one index file produced in a single
+// transaction, so a failed file leaves no partial rows.
+func storeFileResults(filename string, extractor *extract.Extractor, matches []extract.Match) error {
+	if resultsDB == nil {
+		return nil
+	}
+
+	sqliteMu.Lock()
+	defer sqliteMu.Unlock()
+
+	tx, err := resultsDB.Begin()
+	if err != nil {
+		return fmt.Errorf("begin sqlite transaction: %w", err)
+	}
+	if err := insertFileResults(tx, filename, extractor, matches); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store %s in %s: %w", filename, sqlitePath, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s to %s: %w", filename, sqlitePath, err)
+	}
+	return nil
+}
+
+func insertFileResults(tx *sql.Tx, filename string, extractor *extract.Extractor, matches []extract.Match) error {
+	payer := payerKey(filename)
+
+	switch mode {
+	case modeUniquePlans:
+		for _, name := range extractor.UniquePlans() {
+			if _, err := tx.Exec(`INSERT INTO plans (run_id, file, payer, name) VALUES (?, ?, ?, ?)`,
+				runID, filename, payer, name); err != nil {
+				return err
+			}
+		}
+	case modeHeuristics, modeFHIR:
+		for _, location := range extractor.PpoPrices() {
+			if _, err := tx.Exec(`INSERT INTO matches (run_id, file, payer, location) VALUES (?, ?, ?, ?)`,
+				runID, filename, payer, location); err != nil {
+				return err
+			}
+		}
+	case modeFileSets:
+		for _, set := range extractor.FileSets() {
+			for _, location := range set.Locations {
+				if _, err := tx.Exec(`INSERT INTO matches (run_id, file, payer, location, network, plan_code) VALUES (?, ?, ?, ?, ?, ?)`,
+					runID, filename, payer, location, set.Network, set.PlanCode); err != nil {
+					return err
+				}
+			}
+		}
+	case modeAnalysis:
+		for _, match := range matches {
+			res, err := tx.Exec(`INSERT INTO matches (run_id, file, payer, location, description, ai_match, heuristic_match, region_code_match) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				runID, filename, payer, match.Location, match.Description, match.AIMatch, match.HeuristicMatch, match.RegionCodeMatch)
+			if err != nil {
+				return err
+			}
+			matchID, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for _, ein := range match.Eins {
+				if _, err := tx.Exec(`INSERT INTO eins (match_id, ein) VALUES (?, ?)`, matchID, ein); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
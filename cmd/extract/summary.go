@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"serif_interview/pkg/extract"
+)
+
+var summaryPath = ""
+var urlHistoryPath = ""
+
+// PayerSummary is one row of the per payer summary matrix.
+type PayerSummary struct {
+	Payer       string `json:"payer"`
+	Records     int    `json:"records"`
+	Matches     int    `json:"matches"`
+	UniquePlans int    `json:"uniquePlans"`
+	NewURLs     int    `json:"newUrls"`
+	Errors      int    `json:"errors"`
+	Skipped     int    `json:"skipped"`
+	Denied      int    `json:"denied"`
+}
+
+var summariesMu sync.Mutex
+var summaries = make(map[string]*PayerSummary)
+
+// filesDone and filesFailed count the index files of the run.
+var filesDone, filesFailed int
+
+// previousURLs holds the matched urls of the last run per payer, loaded from
+// -url-history, and currentURLs collects this run's for saving back.
+var previousURLs = make(map[string][]string)
+var currentURLs = make(map[string]map[string]struct{})
+
+func loadURLHistory() error {
+	if urlHistoryPath == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(urlHistoryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read url history %s: %w", urlHistoryPath, err)
+	}
+	if err := json.Unmarshal(content, &previousURLs); err != nil {
+		return fmt.Errorf("parse url history %s: %w", urlHistoryPath, err)
+	}
+
+	return nil
+}
+
+// stripQuery drops the signature query string, which changes every month
+// even when the file does not.
+func stripQuery(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// recordSummary adds one processed file to its payer's row. extractor may be
+// nil when the file could not be opened.
+func recordSummary(filename string, extractor *extract.Extractor, failed bool) {
+	payer := payerKey(filename)
+
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+
+	row, exists := summaries[payer]
+	if !exists {
+		row = &PayerSummary{Payer: payer}
+		summaries[payer] = row
+	}
+	filesDone++
+	if failed {
+		row.Errors++
+		filesFailed++
+	}
+	if extractor == nil {
+		return
+	}
+
+	stats := extractor.Stats()
+	row.Records += stats.Records
+	row.Matches += stats.Matches
+	row.UniquePlans += stats.Descriptions
+	row.Errors += stats.Quarantined
+	row.Skipped += stats.Skipped
+	row.Denied += stats.Denied
+
+	previous := make(map[string]struct{})
+	for _, location := range previousURLs[payer] {
+		previous[location] = struct{}{}
+	}
+	current, exists := currentURLs[payer]
+	if !exists {
+		current = make(map[string]struct{})
+		currentURLs[payer] = current
+	}
+	for _, location := range extractor.PpoPrices() {
+		location = stripQuery(location)
+		if _, seen := current[location]; seen {
+			continue
+		}
+		current[location] = struct{}{}
+		if _, known := previous[location]; !known {
+			row.NewURLs++
+		}
+	}
+}
+
+// summaryRows are the rows of the matrix sorted by payer.
+func summaryRows() []PayerSummary {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	rows := make([]PayerSummary, 0, len(summaries))
+	for _, row := range summaries {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Payer < rows[j].Payer })
+	return rows
+}
+
+// runTotals adds up the rows of all payers.
+func runTotals() runSummary {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	totals := runSummary{Files: filesDone, FailedFiles: filesFailed}
+	for _, row := range summaries {
+		totals.Records += row.Records
+		totals.Matches += row.Matches
+		totals.UniquePlans += row.UniquePlans
+		totals.Errors += row.Errors
+		totals.Skipped += row.Skipped
+		totals.Denied += row.Denied
+	}
+	return totals
+}
+
+// writeSummary writes the matrix to -summary as markdown for .md files and
+// csv otherwise, and saves this run's urls to -url-history.
+func writeSummary() error {
+	if urlHistoryPath != "" {
+		history := make(map[string][]string)
+		for payer, locations := range previousURLs {
+			history[payer] = locations
+		}
+		for payer, locations := range currentURLs {
+			list := make([]string, 0, len(locations))
+			for location := range locations {
+				list = append(list, location)
+			}
+			sort.Strings(list)
+			history[payer] = list
+		}
+
+		content, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			return fmt.Errorf("serialize url history: %w", err)
+		}
+		if err := os.WriteFile(urlHistoryPath, content, 0o644); err != nil {
+			return fmt.Errorf("write url history %s: %w", urlHistoryPath, err)
+		}
+	}
+
+	if summaryPath == "" {
+		return nil
+	}
+
+	rows := summaryRows()
+	f, err := os.Create(summaryPath)
+	if err != nil {
+		return fmt.Errorf("create summary %s: %w", summaryPath, err)
+	}
+
+	if strings.EqualFold(filepath.Ext(summaryPath), ".md") {
+		err = writeSummaryMarkdown(f, rows)
+	} else {
+		err = writeSummaryCSV(f, rows)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write summary %s: %w", summaryPath, err)
+	}
+
+	return nil
+}
+
+var summaryColumns = []string{"payer", "records", "matches", "unique plans", "new urls", "errors", "skipped", "denied"}
+
+func summaryCells(row PayerSummary) []string {
+	return []string{
+		row.Payer,
+		strconv.Itoa(row.Records),
+		strconv.Itoa(row.Matches),
+		strconv.Itoa(row.UniquePlans),
+		strconv.Itoa(row.NewURLs),
+		strconv.Itoa(row.Errors),
+		strconv.Itoa(row.Skipped),
+		strconv.Itoa(row.Denied),
+	}
+}
+
+func writeSummaryCSV(w io.Writer, rows []PayerSummary) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(summaryColumns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cw.Write(summaryCells(row)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSummaryMarkdown(w io.Writer, rows []PayerSummary) error {
+	if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(summaryColumns, " | ")); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(summaryColumns))); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := summaryCells(row)
+		// payer names come from filenames, keep them from breaking the table
+		cells[0] = strings.ReplaceAll(cells[0], "|", `\|`)
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+const exitCodeSchemaInvalid = 6
+
+var errSchemaInvalid = errors.New("index files violate the CMS table of contents schema")
+
+// validateFiles checks inputs against the schema instead of extracting them,
+// writing a record per violation and the reports as validation metadata.
+func validateFiles(ctx context.Context, inputs []string) error {
+	var reports []*extract.ValidationReport
+	var errs []error
+	invalid := 0
+	for _, filename := range inputs {
+		report, err := extract.ValidateFile(ctx, filename, extract.ValidateOptions{
+			Limits: download.Limits{MaxBytes: maxDecompressedMB << 20, MaxRatio: maxExpansionRatio},
+			OnViolation: func(v extract.SchemaViolation) {
+				if err := results.Match(struct {
+					File string `json:"file"`
+					extract.SchemaViolation
+				}{File: filename, SchemaViolation: v}); err != nil {
+					output.Logf(output.CodeSerialize, "marshal schema violation: %v", err)
+				}
+			},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filename, err))
+			continue
+		}
+		reports = append(reports, report)
+		if !report.Valid {
+			invalid++
+			output.Logf(output.CodeSchemaViolation, "%s: %d schema violations in %d records", filename, report.Violations, report.Records)
+		}
+	}
+	results.Meta("validation", reports)
+
+	if invalid > 0 {
+		errs = append(errs, fmt.Errorf("%w: %d of %d", errSchemaInvalid, invalid, len(inputs)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"serif_interview/pkg/hospital"
+	"serif_interview/pkg/nppes"
+	"serif_interview/pkg/rates"
+
+	_ "modernc.org/sqlite"
+)
+
+var nppesPath = ""
+
+// registry adds the providers of their npis to the records, nil without
+// -nppes.
+var registry *nppes.Registry
+
+// nppesErr is the first failed lookup, records are written from callbacks
+// that cannot fail the parse.
+var nppesErr error
+
+// lookedUp are the distinct npis of the run, true for those -nppes has.
+var lookedUp = make(map[string]bool)
+
+// sqliteExtensions name the -nppes files read as sqlite databases.
+var sqliteExtensions = []string{".db", ".sqlite", ".sqlite3"}
+
+// openNPPES opens the -nppes snapshot, a sqlite database by its extension
+// and an npidata_pfile csv otherwise.
+func openNPPES() (closeNPPES func() error, err error) {
+	if nppesPath == "" {
+		return func() error { return nil }, nil
+	}
+	slog.Debug("reading nppes", "file", nppesPath)
+	if !slices.Contains(sqliteExtensions, strings.ToLower(filepath.Ext(nppesPath))) {
+		registry, err = nppes.LoadCSV(nppesPath)
+		return func() error { return nil }, err
+	}
+
+	db, err := sql.Open("sqlite", "file:"+nppesPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("open nppes database: %w", err)
+	}
+	registry, err = nppes.NewSQLite(db, "")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", nppesPath, err)
+	}
+	return func() error {
+		registry.Close()
+		return db.Close()
+	}, nil
+}
+
+// providersOf looks up the npis of a record, in the order they are listed,
+// none without -nppes.
+func providersOf(value any) []nppes.Provider {
+	if registry == nil {
+		return nil
+	}
+	var providers []nppes.Provider
+	for _, npi := range recordNPIs(value) {
+		p, found, err := registry.Lookup(npi)
+		if err != nil {
+			if nppesErr == nil {
+				nppesErr = err
+			}
+			return providers
+		}
+		lookedUp[npi] = found
+		if found {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// recordNPIs are the distinct npis of the provider groups of a record,
+// those of the provider_references the cms and flat schemas refer to are
+// in the providerReference records.
+func recordNPIs(value any) []string {
+	var npis []string
+	add := func(npi string) {
+		if npi = strings.TrimSpace(npi); npi != "" && !slices.Contains(npis, npi) {
+			npis = append(npis, npi)
+		}
+	}
+	addGroups := func(groups []rates.ProviderGroup) {
+		for _, group := range groups {
+			for _, npi := range group.NPI {
+				add(string(npi))
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case rates.Rate:
+		for _, negotiatedRate := range v.NegotiatedRates {
+			addGroups(negotiatedRate.ProviderGroups)
+		}
+	case rates.ProviderReference:
+		addGroups(v.ProviderGroups)
+	case rates.FlatPrice:
+		addGroups(v.ProviderGroups)
+	case rates.ConsumerPrice:
+		for _, provider := range v.Providers {
+			for _, npi := range provider.NPIs {
+				add(npi)
+			}
+		}
+	case rates.PriceSpan:
+		for _, npi := range v.Provider.NPIs {
+			add(npi)
+		}
+	case hospital.Comparison:
+		add(v.NPI)
+	}
+	return npis
+}
+
+// nppesSummary is the nppes meta record, how many of the npis of the run
+// the snapshot has.
+type nppesSummary struct {
+	File  string `json:"file"`
+	NPIs  int    `json:"npis"`
+	Found int    `json:"found"`
+}
+
+func printNPPESSummary() {
+	if registry == nil {
+		return
+	}
+	summary := nppesSummary{File: nppesPath, NPIs: len(lookedUp)}
+	for _, found := range lookedUp {
+		if found {
+			summary.Found++
+		}
+	}
+	slog.Debug("nppes lookups", "npis", summary.NPIs, "found", summary.Found)
+	results.Meta("nppes", summary)
+}
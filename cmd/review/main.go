@@ -0,0 +1,245 @@
+// Command review walks through the borderline matches of an analysis mode
+// run, those the llm and the keywords disagree on, and asks a human to
+// accept or reject each with a keystroke. Decisions are written as a labels
+// file for extract -eval, and accepted descriptions and their plan codes can
+// be added to the plan allow-list and region codes of extract -plans and
+// -regions.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/output"
+)
+
+var labelsPath = "labels.csv"
+var plansPath = ""
+var regionsPath = ""
+var state = "NY"
+var reviewAll = false
+
+func main() {
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "accept or reject the borderline matches of an analysis run")
+		fmt.Fprintln(w, "usage: review [options] analysis.json")
+		fmt.Fprintln(w, " keys: a or y accepts, r or n rejects, s skips, u undoes the last decision, q quits")
+		fmt.Fprintln(w, " options:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&labelsPath, "labels", labelsPath, "csv labels file the decisions are added to, in the layout of extract -eval, matches it already labels are not asked about again")
+	fs.StringVar(&plansPath, "plans", "", "plan allow-list of extract -plans the accepted descriptions are added to, created when missing")
+	fs.StringVar(&regionsPath, "regions", "", "region codes file of extract -regions the plan codes of the accepted locations are added to, under -state, created when missing")
+	fs.StringVar(&state, "state", state, "state abbreviation the -regions codes are added for")
+	fs.BoolVar(&reviewAll, "all", false, "review every match, not only those where aiMatch and heuristicMatch disagree")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(fs.Arg(0)); err != nil {
+		slog.Error(err.Error(), "code", output.CodeRunFailed)
+		os.Exit(1)
+	}
+}
+
+func run(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	matches, err := readMatches(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	labels, err := readLabelsFile(labelsPath)
+	if err != nil {
+		return err
+	}
+	labeled := make(map[string]struct{}, len(labels))
+	for _, label := range labels {
+		labeled[labelKey(label.Description, label.Location)] = struct{}{}
+	}
+
+	var candidates []extract.Match
+	for _, m := range matches {
+		if _, ok := labeled[labelKey(m.Description, m.Location)]; ok {
+			continue
+		}
+		if reviewAll || m.AIMatch != m.HeuristicMatch {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) == 0 {
+		fmt.Printf("no matches to review in %d, %d already labeled\n", len(matches), len(labeled))
+		return nil
+	}
+
+	r := &reviewer{candidates: candidates, decisions: make([]*bool, len(candidates))}
+	r.save = func() error {
+		if err := writeLabelsFile(labelsPath, append(labels, r.labels()...)); err != nil {
+			return err
+		}
+		return learnAccepted(filename, r.accepted())
+	}
+	if err := r.run(os.Stdin, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("%d accepted, %d rejected, %d not decided, labels written to %s\n", len(r.accepted()), len(r.labels())-len(r.accepted()), len(candidates)-len(r.labels()), labelsPath)
+	return nil
+}
+
+func labelKey(description string, location string) string {
+	return description + "\x00" + location
+}
+
+// readMatches reads the matches of the json, object or ndjson output of
+// extract, records without an aiMatch field being meta records or matches
+// of other modes.
+func readMatches(r io.Reader) ([]extract.Match, error) {
+	dec := json.NewDecoder(r)
+	var matches []extract.Match
+	add := func(raw json.RawMessage) error {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(raw, &fields) != nil {
+			return nil
+		}
+		if records, ok := fields["matches"]; ok {
+			// the object format
+			var elements []json.RawMessage
+			if err := json.Unmarshal(records, &elements); err != nil {
+				return fmt.Errorf("read matches: %w", err)
+			}
+			for _, element := range elements {
+				var m extract.Match
+				if json.Unmarshal(element, &m) == nil && hasField(element, "aiMatch") {
+					matches = append(matches, m)
+				}
+			}
+			return nil
+		}
+		if _, ok := fields["aiMatch"]; !ok {
+			return nil
+		}
+		var m extract.Match
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return fmt.Errorf("read match: %w", err)
+		}
+		matches = append(matches, m)
+		return nil
+	}
+
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read matches: %w", err)
+		}
+		var elements []json.RawMessage
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			if err := json.Unmarshal(raw, &elements); err != nil {
+				return nil, fmt.Errorf("read matches: %w", err)
+			}
+		} else {
+			elements = []json.RawMessage{raw}
+		}
+		for _, element := range elements {
+			if err := add(element); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no analysis matches")
+	}
+	return matches, nil
+}
+
+func hasField(raw json.RawMessage, name string) bool {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return false
+	}
+	_, ok := fields[name]
+	return ok
+}
+
+// readLabelsFile reads the labels of path, none when it does not exist.
+func readLabelsFile(path string) ([]extract.Label, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	labels, err := extract.ReadLabels(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return labels, nil
+}
+
+// writeLabelsFile replaces path with labels, through a temporary file so an
+// interrupted write keeps the previous decisions.
+func writeLabelsFile(path string, labels []extract.Label) error {
+	var b bytes.Buffer
+	if err := extract.WriteLabels(&b, labels); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b.Bytes())
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.WriteFile(path+".tmp", content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// learnAccepted adds the descriptions of the accepted matches to -plans
+// and their plan codes to -regions, entries the files already have being
+// left alone. Matches undone since an earlier save are not removed again,
+// the files being the user's to edit.
+func learnAccepted(filename string, accepted []extract.Match) error {
+	comment := fmt.Sprintf("accepted in review %s of %s", time.Now().Format(time.DateOnly), filepath.Base(filename))
+	var plans, codes []extract.Learned
+	for _, m := range accepted {
+		plans = append(plans, extract.Learned{Value: m.Description, Comment: comment})
+		if code, err := extract.ExtractPlanCode(m.Location); err == nil && !m.RegionCodeMatch {
+			codes = append(codes, extract.Learned{Value: code, Comment: comment})
+		}
+	}
+	if plansPath != "" {
+		if _, err := extract.AddLearnedPlans(plansPath, plans); err != nil {
+			return err
+		}
+	}
+	if regionsPath != "" {
+		if _, err := extract.AddLearnedRegions(regionsPath, state, codes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"serif_interview/pkg/extract"
+)
+
+// reviewer steps through the candidates, decisions[i] being nil until the
+// candidate is accepted or rejected.
+type reviewer struct {
+	candidates []extract.Match
+	decisions  []*bool
+	// history are the candidates decided or skipped, in order, for undo
+	history []int
+	// save writes the decisions so far
+	save func() error
+	// terminal is set when keys are read one at a time and the screen is
+	// cleared between matches
+	terminal bool
+}
+
+// run asks about each candidate until all are decided or skipped, or q or
+// the end of in. Keys are read one at a time from a terminal, and as lines
+// otherwise.
+func (r *reviewer) run(in *os.File, out io.Writer) error {
+	if restore, err := rawKeys(in); err == nil {
+		r.terminal = true
+		defer restore()
+		// cbreak mode leaves ctrl-c to the default handler, which would
+		// leave the terminal without echo
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupted)
+		go func() {
+			if _, ok := <-interrupted; ok {
+				restore()
+				os.Exit(130)
+			}
+		}()
+	}
+
+	keys := bufio.NewReader(in)
+	for i := 0; i < len(r.candidates); {
+		r.show(out, i)
+		key, err := readKey(keys)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch key {
+		case 'a', 'y', 'r', 'n':
+			accepted := key == 'a' || key == 'y'
+			r.decisions[i] = &accepted
+		case 's':
+		case 'u', 'b':
+			if len(r.history) > 0 {
+				i = r.history[len(r.history)-1]
+				r.history = r.history[:len(r.history)-1]
+				r.decisions[i] = nil
+				if err := r.save(); err != nil {
+					return err
+				}
+			}
+			continue
+		case 'q':
+			return nil
+		default:
+			continue
+		}
+		r.history = append(r.history, i)
+		if err := r.save(); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+// readKey returns the next key pressed, skipping whitespace so line input
+// works too.
+func readKey(keys *bufio.Reader) (rune, error) {
+	for {
+		key, _, err := keys.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if key != ' ' && key != '\n' && key != '\r' && key != '\t' {
+			return key, nil
+		}
+	}
+}
+
+// rawKeys puts the terminal of in into cbreak mode without echo with stty,
+// failing for input that is not a terminal or systems without stty.
+func rawKeys(in *os.File) (restore func(), err error) {
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return nil, errors.New("not a terminal")
+	}
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = in
+		state, err := cmd.Output()
+		return strings.TrimSpace(string(state)), err
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("cbreak", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+// show prints candidate i with its signals and llm answers.
+func (r *reviewer) show(out io.Writer, i int) {
+	if r.terminal {
+		fmt.Fprint(out, "\x1b[H\x1b[2J")
+	}
+	accepted, rejected := 0, 0
+	for _, decision := range r.decisions {
+		if decision != nil && *decision {
+			accepted++
+		} else if decision != nil {
+			rejected++
+		}
+	}
+	m := r.candidates[i]
+	fmt.Fprintf(out, "match %d of %d, %d accepted, %d rejected\n\n", i+1, len(r.candidates), accepted, rejected)
+	fmt.Fprintf(out, "  description  %s\n", m.Description)
+	for _, description := range m.Descriptions {
+		if description != m.Description {
+			fmt.Fprintf(out, "               %s\n", description)
+		}
+	}
+	fmt.Fprintf(out, "  location     %s\n", m.Location)
+	if m.PlanType != "" {
+		fmt.Fprintf(out, "  plan type    %s\n", m.PlanType)
+	}
+	fmt.Fprintf(out, "  score        %.2f\n", m.Score)
+	fmt.Fprintf(out, "  llm          %s, confidence %.2f\n", yesNo(m.AIMatch), m.AIConfidence)
+	if m.AIRationale != "" {
+		fmt.Fprintf(out, "               %s\n", m.AIRationale)
+	}
+	fmt.Fprintf(out, "  keywords     %s\n", yesNo(m.HeuristicMatch))
+	fmt.Fprintf(out, "  region code  %s\n", yesNo(m.RegionCodeMatch))
+	if len(m.Signals) > 0 {
+		fmt.Fprintf(out, "\n  signals\n")
+		for _, s := range m.Signals {
+			fmt.Fprintf(out, "    %-11s %.2f x %.2f = %.2f\n", s.Signal, s.Weight, s.Value, s.Score)
+		}
+	}
+	if decision := r.decisions[i]; decision != nil {
+		fmt.Fprintf(out, "\n  decided      %s\n", map[bool]string{true: "accepted", false: "rejected"}[*decision])
+	}
+	fmt.Fprint(out, "\n[a]ccept  [r]eject  [s]kip  [u]ndo  [q]uit\n")
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// labels are the decisions as labels, in the order of the candidates.
+func (r *reviewer) labels() []extract.Label {
+	var labels []extract.Label
+	for i, decision := range r.decisions {
+		if decision != nil {
+			labels = append(labels, extract.Label{Description: r.candidates[i].Description, Location: r.candidates[i].Location, Match: *decision})
+		}
+	}
+	return labels
+}
+
+// accepted are the accepted candidates.
+func (r *reviewer) accepted() []extract.Match {
+	var matches []extract.Match
+	for i, decision := range r.decisions {
+		if decision != nil && *decision {
+			matches = append(matches, r.candidates[i])
+		}
+	}
+	return matches
+}
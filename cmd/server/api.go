@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/metrics"
+	"serif_interview/pkg/output"
+)
+
+// apiHandler serves the api, and the metrics without a key so scrapers need
+// none.
+func apiHandler(jobs *jobQueue, observer *metrics.Metrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /extract", func(w http.ResponseWriter, r *http.Request) { handleExtract(w, r, jobs) })
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) { writeJSON(w, http.StatusOK, jobs.list()) })
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) { handleJob(w, r, jobs) })
+	mux.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) { handleCancel(w, r, jobs) })
+	mux.HandleFunc("GET /jobs/{id}/results", func(w http.ResponseWriter, r *http.Request) { handleResults(w, r, jobs) })
+	root := http.NewServeMux()
+	root.Handle("GET /metrics", observer.Handler())
+	root.Handle("/", requireKey(apiKey(), mux))
+	return root
+}
+
+func apiKey() string {
+	return os.Getenv(apiKeyEnv)
+}
+
+// requireKey refuses requests without "Authorization: Bearer <key>", unless
+// key is empty.
+func requireKey(key string, next http.Handler) http.Handler {
+	if key == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleExtract queues a job for the index file of the request, a json
+// {"url", "mode"} body, a multipart form with a "file" part and an optional
+// "mode" field, or any other body as the file itself with ?mode=.
+func handleExtract(w http.ResponseWriter, r *http.Request, jobs *jobQueue) {
+	if maxUploadMB > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadMB<<20)
+	}
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	job := &Job{ID: id, Mode: r.URL.Query().Get("mode")}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var body struct {
+			URL  string `json:"url"`
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("decode request: %v", err))
+			return
+		}
+		if !download.IsURL(body.URL) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("url %q is not an http or https url", body.URL))
+			return
+		}
+		job.Source, job.input = body.URL, body.URL
+		if body.Mode != "" {
+			job.Mode = body.Mode
+		}
+	case "multipart/form-data":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("read file part: %v", err))
+			return
+		}
+		defer file.Close()
+		if mode := r.FormValue("mode"); mode != "" {
+			job.Mode = mode
+		}
+		job.Source = filepath.Base(header.Filename)
+		if status, err := saveUpload(job, file); err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+	default:
+		job.Source = "upload"
+		if status, err := saveUpload(job, r.Body); err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+	}
+	if r.MultipartForm != nil {
+		r.MultipartForm.RemoveAll()
+	}
+
+	if job.Mode == "" {
+		job.Mode = defaultMode
+	}
+	if !slices.Contains(modeNames, job.Mode) {
+		removeUpload(job)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown mode %q, expected one of %v", job.Mode, modeNames))
+		return
+	}
+
+	if err := jobs.submit(job); err != nil {
+		removeUpload(job)
+		status := http.StatusInternalServerError
+		if errors.Is(err, errQueueFull) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	snapshot, _ := jobs.get(job.ID)
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// saveUpload stores body as the job's input in the data dir. The extractor
+// sniffs the compression, so the upload keeps no extension.
+func saveUpload(job *Job, body io.Reader) (int, error) {
+	f, err := os.CreateTemp(dataDir, job.ID+"-*.upload")
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("store upload: %w", err)
+	}
+	job.input, job.upload = f.Name(), true
+
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeUpload(job)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("upload is larger than %d MB", maxUploadMB)
+		}
+		return http.StatusBadRequest, fmt.Errorf("read upload: %w", err)
+	}
+	return 0, nil
+}
+
+func removeUpload(job *Job) {
+	if job.upload {
+		os.Remove(job.input)
+	}
+}
+
+func handleJob(w http.ResponseWriter, r *http.Request, jobs *jobQueue) {
+	job, ok := jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancel stops a queued or running job, 409 when it has already
+// finished.
+func handleCancel(w http.ResponseWriter, r *http.Request, jobs *jobQueue) {
+	job, err := jobs.cancelJob(r.PathValue("id"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		writeError(w, http.StatusNotFound, "no such job")
+	case errors.Is(err, errJobFinished):
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", job.Status))
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// handleResults streams the results file of a job as it grows, ending the
+// response once the job has finished and every record was sent.
+func handleResults(w http.ResponseWriter, r *http.Request, jobs *jobQueue) {
+	job, ok := jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	f, err := os.Open(jobPath(job.ID, ".ndjson"))
+	if err != nil {
+		writeError(w, http.StatusGone, "results of the job were removed")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	for {
+		// the snapshot is taken before copying, records written after it
+		// wake the loop again
+		if job, ok = jobs.get(job.ID); !ok {
+			return
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !job.finished.IsZero() {
+			return
+		}
+		select {
+		case <-job.changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		output.Logf(output.CodeSerialize, "write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/metrics"
+)
+
+const testIndex = `{"reporting_entity_name":"Excellus","reporting_structure":[{"reporting_plans":[],"in_network_files":[
+	{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"},
+	{"description":"excellus dental","location":"https://example.com/2026-01_800_72A0_in-network-rates.json.gz"}]}]}`
+
+// useDataDir points the server at an empty data dir for the test.
+func useDataDir(t *testing.T) {
+	t.Helper()
+	oldDir, oldSize, oldInterval := dataDir, queueSize, checkpointInterval
+	t.Cleanup(func() { dataDir, queueSize, checkpointInterval = oldDir, oldSize, oldInterval })
+	t.Setenv(apiKeyEnv, "")
+	dataDir = t.TempDir()
+}
+
+// serveJobs serves the api of jobs, its workers running until the test ends.
+func serveJobs(t *testing.T, jobs *jobQueue, workers int) *httptest.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs.start(ctx, workers)
+	server := httptest.NewServer(apiHandler(jobs, metrics.New()))
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		jobs.wait()
+	})
+	return server
+}
+
+// call sends body to path, returning the status and decoding the response
+// into result.
+func call(t *testing.T, server *httptest.Server, method, path, body string, result any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitJob polls job id until it has finished.
+func waitJob(t *testing.T, server *httptest.Server, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		var job Job
+		if got := call(t, server, "GET", "/jobs/"+id, "", &job); got != http.StatusOK {
+			t.Fatalf("GET /jobs/%s: status %d", id, got)
+		}
+		if job.Finished != "" {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %+v did not finish", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// streamResults streams the records of job id.
+func streamResults(t *testing.T, server *httptest.Server, id string) []string {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/jobs/" + id + "/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("results of %s: status %d, %s", id, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var records []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		records = append(records, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestExtractJob(t *testing.T) {
+	useDataDir(t)
+	server := serveJobs(t, newJobQueue(extract.Options{}), 1)
+
+	var job Job
+	if got := call(t, server, "POST", "/extract?mode=uniquePlans", testIndex, &job); got != http.StatusAccepted {
+		t.Fatalf("POST /extract: status %d", got)
+	}
+	if job.ID == "" || job.Source != "upload" || job.Mode != modeUniquePlans {
+		t.Fatalf("submitted job %+v", job)
+	}
+
+	job = waitJob(t, server, job.ID)
+	if job.Status != statusDone || job.Records != 2 {
+		t.Errorf("finished job %+v, want done with both plans", job)
+	}
+	records := streamResults(t, server, job.ID)
+	if len(records) != 2 || !strings.Contains(records[0]+records[1], "excellus bcbs : blueppo") {
+		t.Errorf("results %q, want both plans", records)
+	}
+	// the upload is removed with the job done
+	if uploads, _ := filepath.Glob(filepath.Join(dataDir, "*.upload")); len(uploads) != 0 {
+		t.Errorf("uploads %v left after the job", uploads)
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/extract?mode=unknown", testIndex, http.StatusBadRequest},
+		{"/jobs/unknown", "", http.StatusNotFound},
+		{"/jobs/unknown/results", "", http.StatusNotFound},
+	} {
+		method := "POST"
+		if tt.body == "" {
+			method = "GET"
+		}
+		if got := call(t, server, method, tt.path, tt.body, nil); got != tt.want {
+			t.Errorf("%s %s: status %d, want %d", method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	useDataDir(t)
+	// without workers the job stays queued
+	server := serveJobs(t, newJobQueue(extract.Options{}), 0)
+
+	var job Job
+	if got := call(t, server, "POST", "/extract", testIndex, &job); got != http.StatusAccepted {
+		t.Fatalf("POST /extract: status %d", got)
+	}
+	if got := call(t, server, "POST", "/jobs/"+job.ID+"/cancel", "", &job); got != http.StatusAccepted || job.Status != statusCanceled {
+		t.Fatalf("cancel: status %d, job %+v", got, job)
+	}
+	// the stream of a canceled job ends without records
+	if records := streamResults(t, server, job.ID); len(records) != 0 {
+		t.Errorf("results %q of a canceled job", records)
+	}
+	if got := call(t, server, "POST", "/jobs/"+job.ID+"/cancel", "", nil); got != http.StatusConflict {
+		t.Errorf("cancel again: status %d, want %d", got, http.StatusConflict)
+	}
+	if got := call(t, server, "POST", "/jobs/unknown/cancel", "", nil); got != http.StatusNotFound {
+		t.Errorf("cancel unknown job: status %d, want %d", got, http.StatusNotFound)
+	}
+	if uploads, _ := filepath.Glob(filepath.Join(dataDir, "*.upload")); len(uploads) != 0 {
+		t.Errorf("uploads %v left after canceling", uploads)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	useDataDir(t)
+	queueSize = 1
+	jobs := newJobQueue(extract.Options{})
+	server := serveJobs(t, jobs, 0)
+
+	if got := call(t, server, "POST", "/extract", testIndex, nil); got != http.StatusAccepted {
+		t.Fatalf("first job: status %d", got)
+	}
+	if got := call(t, server, "POST", "/extract", testIndex, nil); got != http.StatusServiceUnavailable {
+		t.Errorf("job beyond -queue: status %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	if err := jobs.submit(&Job{ID: "direct"}); err != errQueueFull {
+		t.Errorf("submit to a full queue: %v, want %v", err, errQueueFull)
+	}
+	// the refused upload is not kept
+	if uploads, _ := filepath.Glob(filepath.Join(dataDir, "*.upload")); len(uploads) != 1 {
+		t.Errorf("uploads %v, want only the queued job's", uploads)
+	}
+}
+
+func TestRestoreJobs(t *testing.T) {
+	useDataDir(t)
+	checkpointInterval = 0
+	input := filepath.Join(dataDir, "index.json")
+	if err := os.WriteFile(input, []byte(testIndex), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []jobState{
+		{Job: Job{ID: "later", Mode: modeUniquePlans, Status: statusQueued, Submitted: "2026-01-01 10:00:01"}, Input: input},
+		{Job: Job{ID: "running", Mode: modeUniquePlans, Status: statusRunning, Submitted: "2026-01-01 10:00:00", Started: "2026-01-01 10:00:00", Records: 1}, Input: input},
+		{Job: Job{ID: "done", Mode: modeUniquePlans, Status: statusDone, Submitted: "2025-12-31 10:00:00", Finished: "2025-12-31 10:00:01"}, Input: input},
+	} {
+		content, err := json.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(jobPath(state.ID, ".job.json"), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(jobPath(state.ID, ".ndjson"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs := newJobQueue(extract.Options{})
+	if err := jobs.restore(); err != nil {
+		t.Fatal(err)
+	}
+	// the interrupted job runs again first, the finished one stays finished
+	if len(jobs.queued) != 2 || jobs.queued[0].ID != "running" || jobs.queued[1].ID != "later" {
+		t.Fatalf("queued %v, want the running job then the queued one", jobs.queued)
+	}
+	if job, _ := jobs.get("running"); job.Status != statusQueued || job.Started != "" {
+		t.Errorf("restored running job %+v, want it queued again", job)
+	}
+	if job, _ := jobs.get("done"); job.Status != statusDone || job.finished.IsZero() {
+		t.Errorf("restored finished job %+v", job)
+	}
+
+	server := serveJobs(t, jobs, 1)
+	for _, id := range []string{"running", "later"} {
+		if job := waitJob(t, server, id); job.Status != statusDone || job.Records != 2 {
+			t.Errorf("restored job %+v, want done with both plans", job)
+		}
+		if records := streamResults(t, server, id); len(records) != 2 {
+			t.Errorf("results %q of restored job %s", records, id)
+		}
+	}
+	// the ttl has long passed for the finished job
+	jobs.prune()
+	if _, ok := jobs.get("done"); ok {
+		t.Error("finished job kept past -job-ttl")
+	}
+	if _, err := os.Stat(jobPath("done", ".job.json")); !os.IsNotExist(err) {
+		t.Errorf("job file of the pruned job: %v", err)
+	}
+}
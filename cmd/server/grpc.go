@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/extractpb"
+	"serif_interview/pkg/output"
+	"serif_interview/pkg/rates"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var protoModes = map[extractpb.Mode]string{
+	extractpb.Mode_MODE_HEURISTICS:   modeHeuristics,
+	extractpb.Mode_MODE_UNIQUE_PLANS: modeUniquePlans,
+	extractpb.Mode_MODE_ANALYSIS:     modeAnalysis,
+	extractpb.Mode_MODE_FILE_SETS:    modeFileSets,
+	extractpb.Mode_MODE_FHIR:         modeFHIR,
+	extractpb.Mode_MODE_STATS:        modeStats,
+}
+
+// extractorService implements the Extractor gRPC service. Each call parses
+// its file itself rather than through the job queue, and sends records as
+// they are produced, so a slow client slows the parse instead of records
+// piling up in memory.
+type extractorService struct {
+	extractpb.UnimplementedExtractorServer
+	opts extract.Options
+}
+
+// serveGRPC serves the Extractor service on -grpc-addr until ctx is done.
+func serveGRPC(ctx context.Context, opts extract.Options) (func(), error) {
+	ln, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen grpc: %w", err)
+	}
+	var serverOpts []grpc.ServerOption
+	if key := apiKey(); key != "" {
+		serverOpts = append(serverOpts, grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !validKey(key, metadata.ValueFromIncomingContext(ss.Context(), "authorization")) {
+				return status.Error(codes.Unauthenticated, "missing or wrong bearer token")
+			}
+			return handler(srv, ss)
+		}))
+	}
+	server := grpc.NewServer(serverOpts...)
+	extractpb.RegisterExtractorServer(server, &extractorService{opts: opts})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(ln); err != nil {
+			output.Logf(output.CodeRunFailed, "grpc: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		// running streams are given the same grace period as http requests
+		timer := time.AfterFunc(5*time.Second, server.Stop)
+		defer timer.Stop()
+		server.GracefulStop()
+	}()
+	return func() { <-done }, nil
+}
+
+func validKey(key string, values []string) bool {
+	for _, value := range values {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *extractorService) ExtractIndex(req *extractpb.ExtractIndexRequest, stream grpc.ServerStreamingServer[extractpb.IndexRecord]) error {
+	if !download.IsURL(req.Location) {
+		return status.Errorf(codes.InvalidArgument, "location %q is not an http or https url", req.Location)
+	}
+	mode := defaultMode
+	if req.Mode != extractpb.Mode_MODE_UNSPECIFIED {
+		var ok bool
+		if mode, ok = protoModes[req.Mode]; !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown mode %v", req.Mode)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendErr error
+	send := func(record any) {
+		if sendErr != nil {
+			return
+		}
+		var message *extractpb.IndexRecord
+		if message, sendErr = indexRecord(record); sendErr == nil {
+			sendErr = stream.Send(message)
+		}
+		if sendErr != nil {
+			cancel()
+		}
+	}
+
+	opts := s.opts
+	opts.Mode = extractMode(mode)
+	opts.OnMatch = func(m extract.Match) { send(m) }
+	extractor := extract.New(opts)
+	if err := extractor.ParseFileContext(ctx, req.Location); err != nil {
+		return callError(stream.Context(), sendErr, err)
+	}
+	modeResults(extractor, mode, send)
+	return sendErr
+}
+
+func (s *extractorService) StreamMatches(req *extractpb.StreamMatchesRequest, stream grpc.ServerStreamingServer[extractpb.Match]) error {
+	if !download.IsURL(req.Location) {
+		return status.Errorf(codes.InvalidArgument, "location %q is not an http or https url", req.Location)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendErr error
+
+	opts := s.opts
+	opts.Mode = extract.ModeAnalysis
+	opts.RawMatches = true
+	opts.OnMatch = func(m extract.Match) {
+		if sendErr != nil {
+			return
+		}
+		if sendErr = stream.Send(matchMessage(m)); sendErr != nil {
+			cancel()
+		}
+	}
+	if err := extract.New(opts).ParseFileContext(ctx, req.Location); err != nil {
+		return callError(stream.Context(), sendErr, err)
+	}
+	return sendErr
+}
+
+func (s *extractorService) ExtractRates(req *extractpb.ExtractRatesRequest, stream grpc.ServerStreamingServer[extractpb.NegotiatedPrice]) error {
+	if !download.IsURL(req.Location) {
+		return status.Errorf(codes.InvalidArgument, "location %q is not an http or https url", req.Location)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendErr error
+
+	parser := rates.New(rates.Options{
+		Codes:         req.Codes,
+		Providers:     req.Providers,
+		ResolveRemote: req.ResolveProviders,
+		Limits:        s.opts.Limits,
+		OnUnresolved: func(location string, err error) {
+			output.Logf(output.CodeProviderFile, "provider reference file %s: %v", location, err)
+		},
+		OnRate: func(rate rates.Rate) {
+			for _, price := range rates.FlatPrices(rate) {
+				if sendErr != nil {
+					return
+				}
+				if sendErr = stream.Send(priceMessage(price)); sendErr != nil {
+					cancel()
+				}
+			}
+		},
+	})
+	if err := parser.ParseFileContext(ctx, req.Location); err != nil {
+		return callError(stream.Context(), sendErr, err)
+	}
+	return sendErr
+}
+
+// callError is the status a call ends with when its parse failed, the send
+// error or the client's cancellation when those stopped it.
+func callError(ctx context.Context, sendErr error, err error) error {
+	if sendErr != nil {
+		return sendErr
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	var limitErr *download.LimitError
+	if errors.As(err, &limitErr) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// indexRecord wraps a record of modeResults or a match in an IndexRecord.
+func indexRecord(record any) (*extractpb.IndexRecord, error) {
+	switch r := record.(type) {
+	case string:
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_Location{Location: r}}, nil
+	case extract.Match:
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_Match{Match: matchMessage(r)}}, nil
+	case extract.PlanSummary:
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_Plan{Plan: &extractpb.PlanSummary{
+			Plan:      r.Plan,
+			Count:     int64(r.Count),
+			PlanCodes: r.PlanCodes,
+			Examples:  r.Examples,
+		}}}, nil
+	case extract.FileSet:
+		missing := make([]int32, len(r.MissingShards))
+		for i, shard := range r.MissingShards {
+			missing[i] = int32(shard)
+		}
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_FileSet{FileSet: &extractpb.FileSet{
+			Network:        r.Network,
+			PlanCode:       r.PlanCode,
+			ShardCount:     int32(r.ShardCount),
+			ExpectedShards: int32(r.ExpectedShards),
+			MissingShards:  missing,
+			Locations:      r.Locations,
+		}}}, nil
+	case extract.IndexStats:
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_Stats{Stats: &extractpb.IndexStats{
+			Records:        int64(r.Records),
+			InNetworkFiles: int64(r.InNetworkFiles),
+			Descriptions:   int64(r.Descriptions),
+			PlanCodes:      int64(r.PlanCodes),
+			ReportingPlans: int64(r.ReportingPlans),
+			Eins:           int64(r.EINs),
+			RecordsWithEin: int64(r.RecordsWithEIN),
+			EinCoverage:    r.EINCoverage,
+			BytesRead:      r.BytesRead,
+		}}}, nil
+	default:
+		// fhir resources
+		content, err := json.Marshal(r)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "serialize record: %v", err)
+		}
+		return &extractpb.IndexRecord{Record: &extractpb.IndexRecord_FhirResource{FhirResource: content}}, nil
+	}
+}
+
+func matchMessage(m extract.Match) *extractpb.Match {
+	signals := make([]*extractpb.SignalScore, len(m.Signals))
+	for i, signal := range m.Signals {
+		signals[i] = &extractpb.SignalScore{Signal: string(signal.Signal), Weight: signal.Weight, Value: signal.Value, Score: signal.Score}
+	}
+	return &extractpb.Match{
+		Description:     m.Description,
+		Location:        m.Location,
+		Eins:            m.Eins,
+		AiMatch:         m.AIMatch,
+		AiConfidence:    m.AIConfidence,
+		HeuristicMatch:  m.HeuristicMatch,
+		RegionCodeMatch: m.RegionCodeMatch,
+		Score:           m.Score,
+		Signals:         signals,
+		Records:         int64(m.Records),
+		Descriptions:    m.Descriptions,
+	}
+}
+
+func priceMessage(price rates.FlatPrice) *extractpb.NegotiatedPrice {
+	references := make([]string, len(price.ProviderReferences))
+	for i, reference := range price.ProviderReferences {
+		references[i] = reference.String()
+	}
+	groups := make([]*extractpb.ProviderGroup, len(price.ProviderGroups))
+	for i, group := range price.ProviderGroups {
+		npis := make([]string, len(group.NPI))
+		for j, npi := range group.NPI {
+			npis[j] = string(npi)
+		}
+		groups[i] = &extractpb.ProviderGroup{Npi: npis, Tin: &extractpb.Tin{Type: group.TIN.Type, Value: group.TIN.Value}}
+	}
+	return &extractpb.NegotiatedPrice{
+		BillingCode:        price.BillingCode,
+		BillingCodeType:    price.BillingCodeType,
+		NegotiatedRate:     price.NegotiatedRate,
+		NegotiatedType:     price.NegotiatedType,
+		BillingClass:       price.BillingClass,
+		ExpirationDate:     price.ExpirationDate,
+		ProviderReferences: references,
+		ProviderGroups:     groups,
+	}
+}
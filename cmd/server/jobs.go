@@ -0,0 +1,512 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/fhir"
+	"serif_interview/pkg/metrics"
+	"serif_interview/pkg/output"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errQueueFull = errors.New("job queue is full")
+var errJobFinished = errors.New("job has already finished")
+
+const (
+	statusQueued   = "queued"
+	statusRunning  = "running"
+	statusDone     = "done"
+	statusFailed   = "failed"
+	statusCanceled = "canceled"
+)
+
+// Job is one submitted index file.
+type Job struct {
+	ID        string `json:"id"`
+	Source    string `json:"source"`
+	Mode      string `json:"mode"`
+	Status    string `json:"status"`
+	Submitted string `json:"submitted"`
+	Started   string `json:"started,omitempty"`
+	Finished  string `json:"finished,omitempty"`
+	Records   int    `json:"records"`
+	Error     string `json:"error,omitempty"`
+
+	// input is the url or the path of the upload
+	input string
+	// upload is true when input is a file to remove with the job
+	upload bool
+	// changed is closed and replaced whenever records are written or the
+	// job ends, waking the results streams
+	changed  chan struct{}
+	finished time.Time
+	// cancel stops a running job, canceled tells its worker the job was
+	// canceled rather than the server shut down
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// jobState is the file keeping a job across restarts.
+type jobState struct {
+	Job
+	Input  string `json:"input"`
+	Upload bool   `json:"upload,omitempty"`
+}
+
+// jobQueue holds the jobs and feeds the queued ones to the workers. Every
+// job is saved to <id>.job.json in the data dir when its status changes, and
+// running jobs checkpoint to <id>.checkpoint.json, so a restarted server
+// resumes the jobs it was interrupted in.
+type jobQueue struct {
+	opts    extract.Options
+	wake    chan struct{}
+	workers sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	queued []*Job
+}
+
+func newJobQueue(opts extract.Options) *jobQueue {
+	return &jobQueue{
+		opts: opts,
+		wake: make(chan struct{}, 1),
+		jobs: make(map[string]*Job),
+	}
+}
+
+// newJobID returns an unguessable id, the results of a shared service are
+// only protected by it.
+func newJobID() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func jobPath(id string, suffix string) string {
+	return filepath.Join(dataDir, id+suffix)
+}
+
+// restore loads the jobs saved in the data dir, queueing again those that
+// were queued or running when the server stopped.
+func (q *jobQueue) restore() error {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "*.job.json"))
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read job: %w", err)
+		}
+		var state jobState
+		if err := json.Unmarshal(content, &state); err != nil {
+			return fmt.Errorf("parse job %s: %w", path, err)
+		}
+		job := &state.Job
+		job.input, job.upload = state.Input, state.Upload
+		job.changed = make(chan struct{})
+		if job.Finished != "" {
+			job.finished, _ = time.ParseInLocation(time.DateTime, job.Finished, time.Local)
+		} else {
+			job.Status, job.Started = statusQueued, ""
+			q.queued = append(q.queued, job)
+		}
+		q.jobs[job.ID] = job
+	}
+	// ids are random, the saved jobs run in the order they were submitted
+	slices.SortStableFunc(q.queued, func(a, b *Job) int { return strings.Compare(a.Submitted, b.Submitted) })
+	if len(q.queued) > 0 {
+		slog.Info("restored jobs", "jobs", len(q.jobs), "queued", len(q.queued))
+		q.signal()
+	}
+	return nil
+}
+
+// save writes the job's state, called with q.mu held. The file is replaced,
+// a partially written one would lose the job.
+func (q *jobQueue) save(job *Job) {
+	content, err := json.Marshal(jobState{Job: *job, Input: job.input, Upload: job.upload})
+	if err != nil {
+		output.Logf(output.CodeSerialize, "job %s: %v", job.ID, err)
+		return
+	}
+	path := jobPath(job.ID, ".job.json")
+	if err := os.WriteFile(path+".tmp", content, 0o644); err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		output.Logf(output.CodeCheckpoint, "save job %s: %v", job.ID, err)
+	}
+}
+
+// submit queues job, creating its empty results file so streams can open it
+// right away.
+func (q *jobQueue) submit(job *Job) error {
+	q.prune()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.queued) >= queueSize {
+		return errQueueFull
+	}
+	job.Status = statusQueued
+	job.Submitted = time.Now().Format(time.DateTime)
+	job.changed = make(chan struct{})
+	if err := os.WriteFile(jobPath(job.ID, ".ndjson"), nil, 0o644); err != nil {
+		return fmt.Errorf("create results file: %w", err)
+	}
+	q.jobs[job.ID] = job
+	q.queued = append(q.queued, job)
+	q.save(job)
+	q.signal()
+	return nil
+}
+
+// signal wakes a waiting worker.
+func (q *jobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// prune forgets the jobs finished more than -job-ttl ago and removes their
+// files.
+func (q *jobQueue) prune() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cutoff := time.Now().Add(-jobTTL)
+	for id, job := range q.jobs {
+		if job.finished.IsZero() || job.finished.After(cutoff) {
+			continue
+		}
+		os.Remove(jobPath(id, ".ndjson"))
+		os.Remove(jobPath(id, ".job.json"))
+		delete(q.jobs, id)
+	}
+}
+
+// get returns a copy of job id, whose changed channel is closed on the next
+// change.
+func (q *jobQueue) get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// list returns copies of every job in the order they were submitted.
+func (q *jobQueue) list() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	slices.SortFunc(jobs, func(a, b Job) int {
+		return cmp.Or(strings.Compare(a.Submitted, b.Submitted), strings.Compare(a.ID, b.ID))
+	})
+	return jobs
+}
+
+// registerMetrics exports the number of jobs per status.
+func (q *jobQueue) registerMetrics(m *metrics.Metrics) {
+	for _, status := range []string{statusQueued, statusRunning, statusDone, statusFailed, statusCanceled} {
+		m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "server_jobs",
+			Help:        "jobs known to the server by status",
+			ConstLabels: prometheus.Labels{"status": status},
+		}, func() float64 {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			n := 0
+			for _, job := range q.jobs {
+				if job.Status == status {
+					n++
+				}
+			}
+			return float64(n)
+		}))
+	}
+}
+
+// update changes a job under the lock and wakes its streams.
+func (q *jobQueue) update(job *Job, change func(*Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	change(job)
+	q.changed(job)
+}
+
+// changed wakes the streams of job, called with q.mu held.
+func (q *jobQueue) changed(job *Job) {
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// cancelJob stops job id, dropping it from the queue or interrupting its
+// worker.
+func (q *jobQueue) cancelJob(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, os.ErrNotExist
+	}
+	switch job.Status {
+	case statusQueued:
+		q.queued = slices.DeleteFunc(q.queued, func(queued *Job) bool { return queued == job })
+		removeUpload(job)
+		q.finish(job, statusCanceled, "")
+	case statusRunning:
+		// the worker finishes the job once the extractor returns
+		job.canceled = true
+		job.cancel()
+	default:
+		return *job, errJobFinished
+	}
+	return *job, nil
+}
+
+// finish ends job with status, called with q.mu held.
+func (q *jobQueue) finish(job *Job, status string, message string) {
+	job.finished = time.Now()
+	job.Finished = job.finished.Format(time.DateTime)
+	job.Status, job.Error = status, message
+	q.save(job)
+	q.changed(job)
+}
+
+// next waits for a queued job and marks it running, nil once ctx is done.
+func (q *jobQueue) next(ctx context.Context) (*Job, context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		q.mu.Lock()
+		if len(q.queued) > 0 {
+			job := q.queued[0]
+			q.queued = q.queued[1:]
+			if len(q.queued) > 0 {
+				q.signal()
+			}
+			jobCtx, cancel := context.WithCancel(ctx)
+			job.cancel = cancel
+			job.Status = statusRunning
+			job.Started = time.Now().Format(time.DateTime)
+			job.Records = 0
+			q.save(job)
+			q.changed(job)
+			q.mu.Unlock()
+			return job, jobCtx
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+func (q *jobQueue) start(ctx context.Context, workers int) {
+	for range workers {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for {
+				job, jobCtx := q.next(ctx)
+				if job == nil {
+					return
+				}
+				q.process(ctx, jobCtx, job)
+			}
+		}()
+	}
+}
+
+// wait returns once the workers have stopped.
+func (q *jobQueue) wait() {
+	q.workers.Wait()
+}
+
+// process runs job. When ctx ends first the server is shutting down, the job
+// stays running in its saved state and resumes from its checkpoint after a
+// restart.
+func (q *jobQueue) process(ctx context.Context, jobCtx context.Context, job *Job) {
+	slog.Debug("job started", "job", job.ID, "source", job.Source, "mode", job.Mode)
+	err := q.extract(jobCtx, job)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job.cancel()
+	switch {
+	case job.canceled:
+		q.finish(job, statusCanceled, "")
+	case ctx.Err() != nil:
+		slog.Info("job interrupted", "job", job.ID)
+		return
+	case err != nil:
+		q.finish(job, statusFailed, err.Error())
+		output.Logf(output.CodeFileFailed, "job %s: %v", job.ID, err)
+	default:
+		q.finish(job, statusDone, "")
+	}
+	removeUpload(job)
+	if err := os.Remove(jobPath(job.ID, ".checkpoint.json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		output.Logf(output.CodeCheckpoint, "job %s: remove checkpoint: %v", job.ID, err)
+	}
+}
+
+// extract parses the job's index file, writing its records to the results
+// file as they are found. Records are only written once the file is parsed,
+// so a job resuming from its checkpoint starts the results file over.
+func (q *jobQueue) extract(ctx context.Context, job *Job) error {
+	f, err := os.OpenFile(jobPath(job.ID, ".ndjson"), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return fmt.Errorf("open results file: %w", err)
+	}
+	defer f.Close()
+
+	results, err := output.NewWriter(f, output.Options{Format: output.FormatNDJSON})
+	if err != nil {
+		return err
+	}
+	match := func(record any) {
+		if err := results.Match(record); err != nil {
+			output.Logf(output.CodeSerialize, "job %s: %v", job.ID, err)
+			return
+		}
+		q.update(job, func(job *Job) { job.Records++ })
+	}
+
+	opts := q.opts
+	opts.Mode = extractMode(job.Mode)
+	opts.OnMatch = func(m extract.Match) { match(m) }
+	if err := q.applyCheckpoints(&opts, job); err != nil {
+		return err
+	}
+	extractor := extract.New(opts)
+
+	parseErr := extractor.ParseFileContext(ctx, job.input)
+	if parseErr == nil {
+		modeResults(extractor, job.Mode, match)
+	} else {
+		results.Error(struct {
+			Code  output.Code `json:"code"`
+			Error string      `json:"error"`
+		}{Code: output.CodeFileFailed, Error: parseErr.Error()})
+	}
+
+	if err := results.Close(); err != nil {
+		return errors.Join(parseErr, fmt.Errorf("write results: %w", err))
+	}
+	return parseErr
+}
+
+// applyCheckpoints resumes job from its checkpoint when it has one and
+// checkpoints it every -checkpoint-interval.
+func (q *jobQueue) applyCheckpoints(opts *extract.Options, job *Job) error {
+	path := jobPath(job.ID, ".checkpoint.json")
+	content, err := os.ReadFile(path)
+	if err == nil {
+		var cp extract.Checkpoint
+		if err := json.Unmarshal(content, &cp); err != nil {
+			return fmt.Errorf("parse checkpoint %s: %w", path, err)
+		}
+		opts.Resume = &cp
+		slog.Info("resuming job", "job", job.ID, "records", cp.Records, "checkpoint", cp.CreatedAt.Format(time.DateTime))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	if checkpointInterval <= 0 {
+		return nil
+	}
+	opts.CheckpointInterval = checkpointInterval
+	opts.OnCheckpoint = func(cp extract.Checkpoint) {
+		content, err := json.Marshal(cp)
+		if err == nil {
+			if err = os.WriteFile(path+".tmp", content, 0o644); err == nil {
+				err = os.Rename(path+".tmp", path)
+			}
+		}
+		if err != nil {
+			output.Logf(output.CodeCheckpoint, "job %s: write checkpoint: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// modeResults passes the records mode collected in extractor to emit, the
+// analysis mode matches were passed to Options.OnMatch instead.
+func modeResults(extractor *extract.Extractor, mode string, emit func(any)) {
+	switch mode {
+	case modeHeuristics:
+		for _, location := range extractor.PpoPrices() {
+			emit(location)
+		}
+	case modeUniquePlans:
+		for _, summary := range extractor.PlanSummaries() {
+			emit(summary)
+		}
+	case modeFileSets:
+		for _, set := range extractor.FileSets() {
+			emit(set)
+		}
+	case modeFHIR:
+		for _, resource := range fhir.Resources(extractor.Header(), extractor.Coverage()) {
+			emit(resource)
+		}
+	case modeStats:
+		emit(extractor.IndexStats())
+	}
+}
+
+func extractMode(mode string) extract.Mode {
+	switch mode {
+	case modeUniquePlans:
+		return extract.ModeUniquePlans
+	case modeAnalysis:
+		return extract.ModeAnalysis
+	case modeFHIR:
+		return extract.ModeCoverage
+	case modeStats:
+		return extract.ModeStats
+	default:
+		return extract.ModeHeuristics
+	}
+}
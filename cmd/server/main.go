@@ -0,0 +1,233 @@
+// Command server runs the extractor as a shared HTTP service. Index files
+// are submitted by upload or url to POST /extract and processed by a pool of
+// workers, GET /jobs/{id} reports a job's progress and GET
+// /jobs/{id}/results streams its records as NDJSON, following a running job
+// until it finishes.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/llm"
+	"serif_interview/pkg/logging"
+	"serif_interview/pkg/metrics"
+	"serif_interview/pkg/output"
+)
+
+var listenAddr = ":8080"
+var grpcAddr = ""
+var dataDir = ""
+var defaultMode = modeHeuristics
+var jobWorkers = 2
+var queueSize = 100
+var jobTTL = 24 * time.Hour
+var checkpointInterval = time.Minute
+var maxUploadMB = int64(1024)
+var maxDecompressedMB = int64(0)
+var maxExpansionRatio = 500.0
+var llmBackend = llm.BackendNone
+var llmModel = ""
+var ollamaURL = ""
+var llmTemperature = -1.0
+var llmNumCtx = 0
+var llmTimeout = 2 * time.Minute
+var llmRetries = 2
+var llmMaxFailures = 5
+var isVerbose = false
+var logLevel = "info"
+var logFormat = logging.FormatText
+
+// apiKeyEnv holds the bearer token every request must carry, the api is
+// open when it is unset.
+const apiKeyEnv = "EXTRACT_SERVER_KEY"
+
+const (
+	modeHeuristics  = "heuristics"
+	modeUniquePlans = "uniquePlans"
+	modeAnalysis    = "analysis"
+	modeFileSets    = "fileSets"
+	modeFHIR        = "fhir"
+	modeStats       = "stats"
+)
+
+var modeNames = []string{modeHeuristics, modeUniquePlans, modeAnalysis, modeFileSets, modeFHIR, modeStats}
+
+func main() {
+	if err := parseArgs(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			output.Logf(output.CodeUsage, "%v", err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	if err := run(); err != nil {
+		output.Logf(output.CodeRunFailed, "%v", err)
+		os.Exit(1)
+	}
+}
+
+func parseArgs(args []string) error {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "extractor http api")
+		fmt.Fprintln(w, "usage: server [options]")
+		fmt.Fprintln(w, " endpoints:")
+		fmt.Fprintln(w, "   POST /extract            - index file as the request body, a multipart \"file\" or {\"url\": ...} json, ?mode= or a \"mode\" field selects the mode, returns the job")
+		fmt.Fprintln(w, "   GET  /jobs               - every job in the order they were submitted")
+		fmt.Fprintln(w, "   GET  /jobs/{id}          - status and record count of a job")
+		fmt.Fprintln(w, "   POST /jobs/{id}/cancel   - stop a queued or running job")
+		fmt.Fprintln(w, "   GET  /jobs/{id}/results  - ndjson records of a job, streamed until it finishes")
+		fmt.Fprintln(w, "   GET  /metrics            - prometheus metrics, served without the api key")
+		fmt.Fprintln(w, " with -grpc-addr the Extractor service of proto/extractor/v1/extractor.proto streams the same results over gRPC")
+		fmt.Fprintln(w, " options:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&listenAddr, "addr", listenAddr, "address the api listens on")
+	fs.StringVar(&grpcAddr, "grpc-addr", "", "address the Extractor gRPC service listens on, empty disables it")
+	fs.StringVar(&dataDir, "data-dir", "", "directory keeping uploads, jobs and their results across restarts, a temporary directory when empty")
+	fs.StringVar(&defaultMode, "mode", defaultMode, "mode of jobs that do not choose one, one of heuristics, uniquePlans, analysis, fileSets, fhir, stats")
+	fs.IntVar(&jobWorkers, "workers", jobWorkers, "number of jobs processed concurrently")
+	fs.IntVar(&queueSize, "queue", queueSize, "number of jobs waiting for a worker before submissions are refused with 503")
+	fs.DurationVar(&jobTTL, "job-ttl", jobTTL, "how long finished jobs and their results are kept")
+	fs.DurationVar(&checkpointInterval, "checkpoint-interval", checkpointInterval, "how often running jobs checkpoint, a restarted server resumes them from their last checkpoint, 0 restarts them from the beginning")
+	fs.Int64Var(&maxUploadMB, "max-upload-mb", maxUploadMB, "refuse uploads larger than this many megabytes, 0 for no limit")
+	fs.Int64Var(&maxDecompressedMB, "max-decompressed-mb", 0, "fail index files that decompress to more than this many megabytes, 0 for no limit")
+	fs.Float64Var(&maxExpansionRatio, "max-ratio", maxExpansionRatio, "fail index files that decompress to more than this many bytes per compressed byte, 0 for no limit")
+	fs.StringVar(&llmBackend, "llm", llmBackend, "llm asked by analysis jobs, ollama, openai or none")
+	fs.StringVar(&llmModel, "llm-model", "", "model of -llm, defaults to llama3 for ollama and gpt-4o-mini for openai")
+	fs.StringVar(&ollamaURL, "ollama-url", "", "url of the ollama server of -llm=ollama, defaults to OLLAMA_HOST or http://127.0.0.1:11434")
+	fs.Float64Var(&llmTemperature, "llm-temperature", llmTemperature, "sampling temperature of -llm, negative keeps the model's default")
+	fs.IntVar(&llmNumCtx, "llm-num-ctx", 0, "context window of the -llm=ollama model in tokens, 0 keeps the model's default")
+	fs.DurationVar(&llmTimeout, "llm-timeout", llmTimeout, "give up on an llm call after this long, 0 waits indefinitely")
+	fs.IntVar(&llmRetries, "llm-retries", llmRetries, "additional attempts of a failed llm call, with exponential backoff from a second")
+	fs.IntVar(&llmMaxFailures, "llm-max-failures", llmMaxFailures, "after this many llm calls fail in a row, continue without the llm and only try it again every 5 minutes, 0 keeps asking")
+	fs.BoolVar(&isVerbose, "v", false, "log progress details to stderr, the same as -log-level=debug")
+	fs.StringVar(&logLevel, "log-level", logLevel, "lowest level logged to stderr, debug, info, warn or error")
+	fs.StringVar(&logFormat, "log-format", logFormat, "text for key=value log lines, json for one json object per line")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if !slices.Contains(modeNames, defaultMode) {
+		return fmt.Errorf("unknown mode %q, expected one of %v", defaultMode, modeNames)
+	}
+	if jobWorkers < 1 {
+		return fmt.Errorf("-workers must be at least 1, got %d", jobWorkers)
+	}
+	if !slices.Contains(llm.Backends, llmBackend) {
+		return fmt.Errorf("unknown llm %q, expected one of %v", llmBackend, llm.Backends)
+	}
+	if err := (llm.Options{OllamaURL: ollamaURL}).Check(); err != nil {
+		return fmt.Errorf("-ollama-url: %w", err)
+	}
+	if llmRetries < 0 || llmMaxFailures < 0 || llmNumCtx < 0 {
+		return errors.New("-llm-retries, -llm-max-failures and -llm-num-ctx must not be negative")
+	}
+
+	return logging.Setup(os.Stderr, logging.Options{Level: logLevel, Verbose: isVerbose, Format: logFormat})
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if dataDir == "" {
+		dir, err := os.MkdirTemp("", "extract-server-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		dataDir = dir
+	} else if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+
+	// -llm=none leaves the extractors without a client
+	var client extract.LLMClient
+	if llmBackend != llm.BackendNone {
+		lazy, err := llm.NewLazy(llmBackend, llm.Options{
+			Model:       llmModel,
+			OllamaURL:   ollamaURL,
+			Temperature: llmTemperature,
+			NumCtx:      llmNumCtx,
+		})
+		if err != nil {
+			return err
+		}
+		client = llm.WithPolicy(lazy, llm.Policy{
+			Timeout:     llmTimeout,
+			Retries:     llmRetries,
+			MaxFailures: llmMaxFailures,
+			OnOpen: func(err error) {
+				output.Logf(output.CodeLLMCircuitOpen, "the %s llm failed %d calls in a row, continuing without it: %v", llmBackend, llmMaxFailures, err)
+			},
+			OnClose: func() {
+				slog.Info("llm answering again", "llm", llmBackend)
+			},
+		})
+	}
+	observer := metrics.New()
+	opts := extract.Options{
+		LLM:             client,
+		LLMCache:        extract.NewLLMCache(),
+		LLMBatchSize:    20,
+		MaxDepth:        64,
+		MaxStringLength: 1 << 20,
+		Limits:          download.Limits{MaxBytes: maxDecompressedMB << 20, MaxRatio: maxExpansionRatio},
+		Observer:        observer,
+	}
+	jobs := newJobQueue(opts)
+	jobs.registerMetrics(observer)
+	if err := jobs.restore(); err != nil {
+		return err
+	}
+	jobs.start(ctx, jobWorkers)
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	// streams following a job end with the server
+	server := &http.Server{Handler: apiHandler(jobs, observer), BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if grpcAddr != "" {
+		waitGRPC, err := serveGRPC(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer waitGRPC()
+		slog.Info("serving grpc", "addr", grpcAddr)
+	}
+
+	slog.Info("listening", "addr", ln.Addr().String(), "data", dataDir)
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	jobs.wait()
+	return nil
+}
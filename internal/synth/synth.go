@@ -0,0 +1,184 @@
+// Package synth generates synthetic CMS table of contents index files for
+// tests. The same Options always give the same bytes: a seeded mix of
+// allow-listed New York PPO plans with region plan codes, plans of other
+// states, sharded rate files and prescription drug files, optionally broken
+// in one of the ways payers' files are.
+package synth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// Malformation is a way the generated index is broken.
+type Malformation string
+
+const (
+	// Valid is an index following the schema.
+	Valid Malformation = ""
+	// Truncated cuts the index off two thirds of the way through, like an
+	// interrupted download.
+	Truncated Malformation = "truncated"
+	// WrongTypes gives every fifth in_network_files description as a number
+	// and every fourth plan_id as one.
+	WrongTypes Malformation = "wrongTypes"
+	// MissingFields drops the location of every fifth in_network_files
+	// element and the reporting_plans of every fourth record.
+	MissingFields Malformation = "missingFields"
+	// NotArray makes reporting_structure an object.
+	NotArray Malformation = "notArray"
+)
+
+// Malformations lists every broken variant.
+var Malformations = []Malformation{Truncated, WrongTypes, MissingFields, NotArray}
+
+// Options shape a generated index.
+type Options struct {
+	// Records is the number of reporting_structure records, 10 when 0.
+	Records int
+	// FilesPerRecord is the number of in_network_files of each record, 3
+	// when 0.
+	FilesPerRecord int
+	// Seed picks the plans, shards and EINs.
+	Seed uint64
+	// BaseURL prefixes the locations, https://rates.example.com when empty.
+	BaseURL   string
+	Malformed Malformation
+}
+
+// Plan is a plan description with the plan code of its rate files.
+type Plan struct {
+	Description string
+	PlanCode    string
+	// Shards is the number of rate files the plan is split into, 0 for one
+	// file without shard numbering.
+	Shards int
+}
+
+// Plans are the plans records are drawn from. The first three are on the
+// built-in allow-list with New York region codes, the fourth has a New York
+// region code without being allow-listed, the others are neither.
+var Plans = []Plan{
+	{Description: "excellus bcbs : blueppo", PlanCode: "301_71A0", Shards: 2},
+	{Description: "highmark bs northeastern ny : highmark blue shield of northeastern new york - ppo", PlanCode: "302_42B0"},
+	{Description: "highmark bcbs western ny : highmark bluecross blueshield of western new york-ppo", PlanCode: "254_39B0", Shards: 3},
+	{Description: "empire bcbs : new york hmo", PlanCode: "800_72A0"},
+	{Description: "demo health : texas hmo", PlanCode: "999_99Z9"},
+	{Description: "anthem bc : california epo", PlanCode: "040_12C0", Shards: 2},
+}
+
+// DrugFile is the description of the prescription drug files mixed in.
+const DrugFile = "prescription drug pricing"
+
+type index struct {
+	ReportingEntityName string `json:"reporting_entity_name"`
+	ReportingEntityType string `json:"reporting_entity_type"`
+	ReportingStructure  any    `json:"reporting_structure"`
+	LastUpdatedOn       string `json:"last_updated_on"`
+	Version             string `json:"version"`
+}
+
+// Index returns the index opts describe.
+func Index(opts Options) []byte {
+	var buf bytes.Buffer
+	// writing to a buffer cannot fail
+	_ = Write(&buf, opts)
+	return buf.Bytes()
+}
+
+// Write writes the index opts describe to w.
+func Write(w io.Writer, opts Options) error {
+	if opts.Records == 0 {
+		opts.Records = 10
+	}
+	if opts.FilesPerRecord == 0 {
+		opts.FilesPerRecord = 3
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = "https://rates.example.com"
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, 0x5eed))
+
+	records := make([]map[string]any, 0, opts.Records)
+	file := 0
+	for i := range opts.Records {
+		record := make(map[string]any)
+		plan := Plans[rng.IntN(len(Plans))]
+		if opts.Malformed != MissingFields || i%4 != 3 {
+			reportingPlans := []map[string]any{reportingPlan(rng, plan, i, opts.Malformed)}
+			if rng.IntN(3) == 0 {
+				reportingPlans = append(reportingPlans, reportingPlan(rng, Plans[rng.IntN(len(Plans))], i, Valid))
+			}
+			record["reporting_plans"] = reportingPlans
+		}
+
+		var files []map[string]any
+		for j := range opts.FilesPerRecord {
+			filePlan := plan
+			if j > 0 {
+				filePlan = Plans[rng.IntN(len(Plans))]
+			}
+			description, location := filePlan.Description, rateFile(opts.BaseURL, filePlan, rng)
+			if rng.IntN(10) == 0 {
+				description, location = DrugFile, fmt.Sprintf("%s/2026-01_%s_prescription-drugs.json.gz", opts.BaseURL, filePlan.PlanCode)
+			}
+
+			element := map[string]any{"description": description, "location": location}
+			switch {
+			case opts.Malformed == WrongTypes && file%5 == 4:
+				element["description"] = file
+			case opts.Malformed == MissingFields && file%5 == 4:
+				delete(element, "location")
+			}
+			files = append(files, element)
+			file++
+		}
+		record["in_network_files"] = files
+		records = append(records, record)
+	}
+
+	var structure any = records
+	if opts.Malformed == NotArray {
+		structure = map[string]any{"records": records}
+	}
+	content, err := json.MarshalIndent(index{
+		ReportingEntityName: "Synthetic Health",
+		ReportingEntityType: "health insurance issuer",
+		ReportingStructure:  structure,
+		LastUpdatedOn:       "2026-01-01",
+		Version:             "1.0.0",
+	}, "", " ")
+	if err != nil {
+		return err
+	}
+	if opts.Malformed == Truncated {
+		content = content[:len(content)*2/3]
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+func reportingPlan(rng *rand.Rand, plan Plan, record int, malformed Malformation) map[string]any {
+	var id any = fmt.Sprintf("%09d", rng.IntN(1_000_000_000))
+	if malformed == WrongTypes && record%4 == 3 {
+		id = rng.IntN(1_000_000_000)
+	}
+	return map[string]any{
+		"plan_name":        plan.Description,
+		"plan_id_type":     "EIN",
+		"plan_id":          id,
+		"plan_market_type": "group",
+	}
+}
+
+// rateFile is the location of one of the plan's rate files, a random shard of
+// sharded plans.
+func rateFile(baseURL string, plan Plan, rng *rand.Rand) string {
+	if plan.Shards == 0 {
+		return fmt.Sprintf("%s/2026-01_%s_in-network-rates.json.gz", baseURL, plan.PlanCode)
+	}
+	return fmt.Sprintf("%s/2026-01_%s_in-network-rates_%d_of_%d.json.gz", baseURL, plan.PlanCode, rng.IntN(plan.Shards)+1, plan.Shards)
+}
@@ -0,0 +1,78 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// azureClient is the client of the storage account of az:// uris, configured
+// like the az cli: AZURE_STORAGE_CONNECTION_STRING, or AZURE_STORAGE_ACCOUNT
+// with AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN or else the default Azure
+// credential chain (environment, workload and managed identity, az login),
+// and AZURE_STORAGE_BLOB_ENDPOINT for Azurite or another cloud.
+var azureClient = sync.OnceValues(azureFromEnv)
+
+func azureFromEnv() (*azblob.Client, error) {
+	if conn := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); conn != "" {
+		return azblob.NewClientFromConnectionString(conn, nil)
+	}
+	name := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if name == "" {
+		return nil, errors.New("neither AZURE_STORAGE_CONNECTION_STRING nor AZURE_STORAGE_ACCOUNT is set")
+	}
+	endpoint := strings.TrimSuffix(os.Getenv("AZURE_STORAGE_BLOB_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "https://" + name + ".blob.core.windows.net"
+	}
+	endpoint += "/"
+
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		cred, err := azblob.NewSharedKeyCredential(name, key)
+		if err != nil {
+			return nil, err
+		}
+		return azblob.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	}
+	if sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"); sas != "" {
+		return azblob.NewClientWithNoCredential(endpoint+"?"+sas, nil)
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClient(endpoint, cred, nil)
+}
+
+func openAzure(ctx context.Context, l Location) (io.ReadCloser, int64, error) {
+	client, err := azureClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.DownloadStream(ctx, l.Bucket, l.Key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	if resp.ContentLength != nil {
+		size = *resp.ContentLength
+	}
+	return resp.Body, size, nil
+}
+
+// uploadAzure stages the blob in blocks, Azure only creates it once the
+// block list is committed.
+func uploadAzure(ctx context.Context, l Location, body io.ReadSeeker, size int64) error {
+	client, err := azureClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.UploadStream(ctx, l.Bucket, l.Key, body, nil)
+	return err
+}
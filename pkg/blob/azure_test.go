@@ -0,0 +1,144 @@
+package blob
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const azureAccountName = "devstoreaccount1"
+
+// fakeAzure serves the Blob Storage calls of reads and block uploads of the
+// account azureAccountName, checking each is authorized by authorized.
+func fakeAzure(t *testing.T, store *objectStore, authorized func(r *http.Request) bool) *httptest.Server {
+	var mu sync.Mutex
+	blocks := make(map[string]string)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-version", r.Header.Get("x-ms-version"))
+		if !authorized(r) {
+			w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.</Message></Error>`)
+			return
+		}
+		path, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"+azureAccountName+"/"))
+		container, name, _ := strings.Cut(path, "/")
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPut && query.Get("comp") == "block":
+			content, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			blocks[path+"#"+query.Get("blockid")] = string(content)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+			var list struct {
+				Latest []string `xml:"Latest"`
+			}
+			if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+				t.Errorf("block list: %v", err)
+			}
+			var content strings.Builder
+			mu.Lock()
+			for _, id := range list.Latest {
+				content.WriteString(blocks[path+"#"+id])
+			}
+			mu.Unlock()
+			store.put(container, name, content.String())
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && r.Header.Get("x-ms-blob-type") == "BlockBlob":
+			content, _ := io.ReadAll(r.Body)
+			store.put(container, name, string(content))
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet:
+			content, ok := store.get(container, name)
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			io.WriteString(w, content)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+}
+
+// useAzure sets the environment of az:// uris to server, the other
+// variables to values.
+func useAzure(t *testing.T, server *httptest.Server, values map[string]string) {
+	for _, name := range []string{"AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_KEY", "AZURE_STORAGE_SAS_TOKEN"} {
+		t.Setenv(name, values[name])
+	}
+	t.Setenv("AZURE_STORAGE_ACCOUNT", azureAccountName)
+	t.Setenv("AZURE_STORAGE_BLOB_ENDPOINT", server.URL+"/"+azureAccountName)
+	prev := azureClient
+	azureClient = sync.OnceValues(azureFromEnv)
+	t.Cleanup(func() { azureClient = prev })
+}
+
+func TestAzure(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("not the account key"))
+	sharedKey := func(r *http.Request) bool {
+		return strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey "+azureAccountName+":")
+	}
+	sas := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "" && r.URL.Query().Get("sig") == "c2lnbmF0dXJl"
+	}
+
+	t.Run("shared key", func(t *testing.T) {
+		store := newObjectStore()
+		server := fakeAzure(t, store, sharedKey)
+		defer server.Close()
+		useAzure(t, server, map[string]string{"AZURE_STORAGE_KEY": key})
+
+		roundTrip(t, "az://results/2026-01/anthem.json.gz", "matches")
+		if _, ok := store.get("results", "2026-01/anthem.json.gz"); !ok {
+			t.Errorf("blob not stored under its name, have %v", store.objects)
+		}
+		if _, _, err := Open(context.Background(), "az://results/missing.json"); err == nil || !strings.Contains(err.Error(), "BlobNotFound") {
+			t.Errorf("opening a missing blob failed with %v, want BlobNotFound", err)
+		}
+	})
+
+	t.Run("sas token", func(t *testing.T) {
+		store := newObjectStore()
+		server := fakeAzure(t, store, sas)
+		defer server.Close()
+		useAzure(t, server, map[string]string{"AZURE_STORAGE_SAS_TOKEN": "?sv=2021-08-06&sp=rw&sig=c2lnbmF0dXJl"})
+
+		roundTrip(t, "az://results/anthem.json", "matches")
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		server := fakeAzure(t, newObjectStore(), sas)
+		defer server.Close()
+		useAzure(t, server, map[string]string{"AZURE_STORAGE_KEY": key})
+
+		_, _, err := Open(context.Background(), "az://results/anthem.json")
+		if err == nil || !strings.Contains(err.Error(), "AuthenticationFailed") {
+			t.Errorf("rejected request failed with %v, want AuthenticationFailed", err)
+		}
+	})
+
+	t.Run("no account", func(t *testing.T) {
+		t.Setenv("AZURE_STORAGE_CONNECTION_STRING", "")
+		t.Setenv("AZURE_STORAGE_ACCOUNT", "")
+		prev := azureClient
+		azureClient = sync.OnceValues(azureFromEnv)
+		defer func() { azureClient = prev }()
+		if _, _, err := Open(context.Background(), "az://results/anthem.json"); err == nil {
+			t.Error("opened a blob without an account")
+		}
+	})
+}
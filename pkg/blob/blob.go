@@ -0,0 +1,103 @@
+// Package blob reads and writes objects of Amazon S3 (s3://bucket/key),
+// Google Cloud Storage (gs://bucket/object) and Azure Blob Storage
+// (az://container/blob), with credentials from the environment the way each
+// cloud's own tools find them.
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Schemes are the URI schemes of the supported object stores.
+var Schemes = []string{"s3", "gs", "az"}
+
+// Location is a parsed object URI.
+type Location struct {
+	Scheme string
+	// Bucket is the S3 or Cloud Storage bucket or the Azure container
+	Bucket string
+	Key    string
+}
+
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
+
+// IsURI reports whether name is an object URI rather than a local path or
+// an http url.
+func IsURI(name string) bool {
+	scheme, _, ok := strings.Cut(name, "://")
+	if !ok {
+		return false
+	}
+	scheme = strings.ToLower(scheme)
+	for _, s := range Schemes {
+		if scheme == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse splits an object URI into its bucket and key.
+func Parse(uri string) (Location, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Location{}, err
+	}
+	l := Location{Scheme: strings.ToLower(u.Scheme), Bucket: u.Host, Key: strings.TrimPrefix(u.Path, "/")}
+	if !IsURI(uri) {
+		return Location{}, fmt.Errorf("%s is not an s3://, gs:// or az:// uri", uri)
+	}
+	if l.Bucket == "" || l.Key == "" {
+		return Location{}, fmt.Errorf("%s names no bucket and object", uri)
+	}
+	return l, nil
+}
+
+// Open streams the object at uri, returning its size.
+func Open(ctx context.Context, uri string) (io.ReadCloser, int64, error) {
+	l, err := Parse(uri)
+	if err != nil {
+		return nil, 0, err
+	}
+	var body io.ReadCloser
+	var size int64
+	switch l.Scheme {
+	case "s3":
+		body, size, err = openS3(ctx, l)
+	case "gs":
+		body, size, err = openGCS(ctx, l)
+	default:
+		body, size, err = openAzure(ctx, l)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("open %s: %w", uri, err)
+	}
+	return body, size, nil
+}
+
+// Upload stores size bytes of body as the object at uri, replacing it. The
+// object only appears once it is complete.
+func Upload(ctx context.Context, uri string, body io.ReadSeeker, size int64) error {
+	l, err := Parse(uri)
+	if err != nil {
+		return err
+	}
+	switch l.Scheme {
+	case "s3":
+		err = uploadS3(ctx, l, body, size)
+	case "gs":
+		err = uploadGCS(ctx, l, body, size)
+	default:
+		err = uploadAzure(ctx, l, body, size)
+	}
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", uri, err)
+	}
+	return nil
+}
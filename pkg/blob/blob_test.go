@@ -0,0 +1,90 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want Location
+		err  bool
+	}{
+		{uri: "s3://ppo-results/anthem/index.json.gz", want: Location{Scheme: "s3", Bucket: "ppo-results", Key: "anthem/index.json.gz"}},
+		{uri: "GS://payer-indexes/a.json", want: Location{Scheme: "gs", Bucket: "payer-indexes", Key: "a.json"}},
+		{uri: "az://container/dir/blob.json", want: Location{Scheme: "az", Bucket: "container", Key: "dir/blob.json"}},
+		{uri: "s3://bucket-only", err: true},
+		{uri: "s3:///key-only", err: true},
+		{uri: "https://example.com/a.json", err: true},
+		{uri: "/tmp/a.json", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.uri, func(t *testing.T) {
+			got, err := Parse(tc.uri)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Parse(%q) = %+v, want an error", tc.uri, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tc.uri, got, tc.want)
+			}
+		})
+	}
+}
+
+// objectStore is the state of a fake object store server, objects by
+// bucket and key.
+type objectStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func newObjectStore() *objectStore {
+	return &objectStore{objects: make(map[string]string)}
+}
+
+func (s *objectStore) get(bucket, key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.objects[bucket+"/"+key]
+	return content, ok
+}
+
+func (s *objectStore) put(bucket, key, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[bucket+"/"+key] = content
+}
+
+// roundTrip uploads content to uri and reads it back through Open.
+func roundTrip(t *testing.T, uri string, content string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := Upload(ctx, uri, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	body, size, err := Open(ctx, uri)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("read back %q, want %q", got, content)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("size %d, want %d", size, len(content))
+	}
+}
@@ -0,0 +1,49 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsClient finds credentials like the other Google client libraries, the
+// GOOGLE_APPLICATION_CREDENTIALS file, the gcloud application default
+// credentials, then the metadata server, and talks to STORAGE_EMULATOR_HOST
+// without credentials when it is set.
+var gcsClient = sync.OnceValues(func() (*storage.Client, error) {
+	return storage.NewClient(context.Background())
+})
+
+func openGCS(ctx context.Context, l Location) (io.ReadCloser, int64, error) {
+	client, err := gcsClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	r, err := client.Bucket(l.Bucket).Object(l.Key).NewReader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, max(r.Attrs.Size, 0), nil
+}
+
+// uploadGCS streams the object in chunks, Cloud Storage only creates it once
+// the writer is closed.
+func uploadGCS(ctx context.Context, l Location, body io.ReadSeeker, size int64) error {
+	client, err := gcsClient()
+	if err != nil {
+		return err
+	}
+	// cancelling the context is what abandons a failed upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := client.Bucket(l.Bucket).Object(l.Key).NewWriter(ctx)
+	w.ContentType = "application/octet-stream"
+	if _, err := io.Copy(w, body); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
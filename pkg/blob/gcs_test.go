@@ -0,0 +1,107 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+// fakeGCS serves the Cloud Storage JSON and XML API calls the client makes
+// to read objects and to upload small ones.
+func fakeGCS(t *testing.T, store *objectStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(path, "/upload/storage/v1/b/"):
+			bucket, _ := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(path, "/upload/storage/v1/b/"), "/o"))
+			name, content, err := readMultipartUpload(r)
+			if err != nil {
+				t.Errorf("upload: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			store.put(bucket, name, content)
+			json.NewEncoder(w).Encode(map[string]string{"bucket": bucket, "name": name, "size": strconv.Itoa(len(content))})
+		case r.Method == http.MethodGet:
+			// the XML API /bucket/object or the JSON API with alt=media
+			rest := strings.TrimPrefix(path, "/storage/v1/b/")
+			bucket, object, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+			object = strings.TrimPrefix(object, "o/")
+			bucket, _ = url.PathUnescape(bucket)
+			object, _ = url.PathUnescape(object)
+			content, ok := store.get(bucket, object)
+			if !ok {
+				http.Error(w, "No such object", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			io.WriteString(w, content)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.Error(w, "unexpected request", http.StatusNotImplemented)
+		}
+	}))
+}
+
+// readMultipartUpload returns the object name of the metadata part and the
+// content of a multipart upload.
+func readMultipartUpload(r *http.Request) (string, string, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", "", err
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	meta, err := parts.NextPart()
+	if err != nil {
+		return "", "", err
+	}
+	var object struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(meta).Decode(&object); err != nil {
+		return "", "", err
+	}
+	media, err := parts.NextPart()
+	if err != nil {
+		return "", "", err
+	}
+	content, err := io.ReadAll(media)
+	return object.Name, string(content), err
+}
+
+// useGCS points gs:// uris at server through STORAGE_EMULATOR_HOST.
+func useGCS(t *testing.T, server *httptest.Server) {
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	prev := gcsClient
+	gcsClient = sync.OnceValues(func() (*storage.Client, error) {
+		return storage.NewClient(context.Background())
+	})
+	t.Cleanup(func() { gcsClient = prev })
+}
+
+func TestGCS(t *testing.T) {
+	store := newObjectStore()
+	server := fakeGCS(t, store)
+	defer server.Close()
+	useGCS(t, server)
+
+	roundTrip(t, "gs://payer-indexes/2026-01/anthem index.json", `{"reporting_structure":[]}`)
+	if _, ok := store.get("payer-indexes", "2026-01/anthem index.json"); !ok {
+		t.Errorf("object not stored under its name, have %v", store.objects)
+	}
+
+	if _, _, err := Open(context.Background(), "gs://payer-indexes/missing.json"); err == nil {
+		t.Error("opened a missing object")
+	}
+}
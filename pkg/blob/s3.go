@@ -0,0 +1,48 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var s3Client = sync.OnceValues(func() (*s3.Client, error) {
+	// the environment, shared config files and instance roles, and
+	// AWS_ENDPOINT_URL for S3 compatible stores
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg), nil
+})
+
+func openS3(ctx context.Context, l Location) (io.ReadCloser, int64, error) {
+	client, err := s3Client()
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(l.Bucket), Key: aws.String(l.Key)})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.Body, aws.ToInt64(out.ContentLength), nil
+}
+
+// uploadS3 puts the object in one request, up to the 5GB S3 allows.
+func uploadS3(ctx context.Context, l Location, body io.ReadSeeker, size int64) error {
+	client, err := s3Client()
+	if err != nil {
+		return err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(l.Bucket),
+		Key:           aws.String(l.Key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	return err
+}
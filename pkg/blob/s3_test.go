@@ -0,0 +1,84 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 serves path style GetObject and PutObject calls signed with the
+// access key "test".
+func fakeS3(t *testing.T, store *objectStore) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=test/") {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<Error><Code>InvalidAccessKeyId</Code></Error>`)
+			return
+		}
+		path, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+		bucket, key, _ := strings.Cut(path, "/")
+		switch r.Method {
+		case http.MethodPut:
+			content, _ := io.ReadAll(r.Body)
+			store.put(bucket, key, string(content))
+		case http.MethodGet:
+			content, ok := store.get(bucket, key)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			io.WriteString(w, content)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+}
+
+// useS3 points s3:// uris at server.
+func useS3(t *testing.T, server *httptest.Server) {
+	prev := s3Client
+	s3Client = sync.OnceValues(func() (*s3.Client, error) {
+		cfg, err := config.LoadDefaultConfig(context.Background(),
+			config.WithRegion("us-east-1"),
+			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "secret", "")),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(server.URL)
+			o.UsePathStyle = true
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		}), nil
+	})
+	t.Cleanup(func() { s3Client = prev })
+}
+
+func TestS3(t *testing.T) {
+	store := newObjectStore()
+	server := fakeS3(t, store)
+	defer server.Close()
+	useS3(t, server)
+
+	roundTrip(t, "s3://ppo-results/2026-01/anthem.json.gz", "matches")
+	if _, ok := store.get("ppo-results", "2026-01/anthem.json.gz"); !ok {
+		t.Errorf("object not stored under its key, have %v", store.objects)
+	}
+	if _, _, err := Open(context.Background(), "s3://ppo-results/missing.json"); err == nil || !strings.Contains(err.Error(), "NoSuchKey") {
+		t.Errorf("opening a missing object failed with %v, want NoSuchKey", err)
+	}
+}
@@ -0,0 +1,104 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DirManifestName is the file in the download directory listing what it
+// holds.
+const DirManifestName = "manifest.json"
+
+// DirManifest lists the files of a download directory with what is needed
+// to verify them and to ask the server whether they changed since.
+type DirManifest struct {
+	UpdatedAt time.Time   `json:"updatedAt"`
+	Files     []FileEntry `json:"files"`
+}
+
+// FileEntry is one downloaded file of a DirManifest.
+type FileEntry struct {
+	URL          string `json:"url"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+	LastModified string `json:"lastModified,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	// Duration is how long the download took, retries included
+	Duration     string    `json:"duration,omitempty"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+// loadDirManifest reads the manifest of the download directory once, keyed
+// by path. A directory without one has no entries.
+func (d *Downloader) loadDirManifest() map[string]FileEntry {
+	d.dirManifestOnce.Do(func() {
+		d.dirFiles = make(map[string]FileEntry)
+		content, err := os.ReadFile(filepath.Join(d.dir, DirManifestName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		var m DirManifest
+		if err == nil {
+			err = json.Unmarshal(content, &m)
+		}
+		if err != nil {
+			d.dirManifestErr = fmt.Errorf("read %s: %w", DirManifestName, err)
+			return
+		}
+		for _, entry := range m.Files {
+			d.dirFiles[entry.Path] = entry
+		}
+	})
+	return d.dirFiles
+}
+
+// WriteDirManifest adds the files downloaded into results to the manifest
+// of the download directory, replacing earlier entries of the same paths.
+// Files skipped as complete keep their entries.
+func (d *Downloader) WriteDirManifest(results []Result) error {
+	files := maps.Clone(d.loadDirManifest())
+	if d.dirManifestErr != nil {
+		return d.dirManifestErr
+	}
+	now := time.Now().UTC()
+	for _, result := range results {
+		if result.Error != "" || result.Skipped {
+			continue
+		}
+		files[result.Path] = FileEntry{
+			URL:          result.URL,
+			Path:         result.Path,
+			Size:         result.Size,
+			SHA256:       result.SHA256,
+			LastModified: result.LastModified,
+			ETag:         result.ETag,
+			Duration:     result.Duration,
+			DownloadedAt: now,
+		}
+	}
+
+	m := DirManifest{UpdatedAt: now, Files: make([]FileEntry, 0, len(files))}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		m.Files = append(m.Files, files[path])
+	}
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(d.dir, DirManifestName)
+	if err := os.WriteFile(path+".tmp", append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", DirManifestName, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
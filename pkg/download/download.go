@@ -0,0 +1,554 @@
+// Package download fetches the in-network rate files selected by the
+// extractor, resuming partial downloads and verifying them against the
+// checksums the server advertises.
+package download
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures a Downloader.
+type Options struct {
+	// Dir receives the files, one subdirectory per host.
+	Dir string
+	// Workers is the number of concurrent downloads, default 4.
+	Workers int
+	// Retries is the number of additional attempts per file, default 3,
+	// negative disables retries.
+	Retries int
+	// Backoff is the delay before the first retry, doubled on each further
+	// retry, default 2s.
+	Backoff time.Duration
+	// Client defaults to an http.Client without timeout, rate files are
+	// multiple gigabytes.
+	Client *http.Client
+	// MaxBytes fails downloads larger than this many bytes, 0 for no limit.
+	MaxBytes int64
+	// Limits apply to bodies with a gzip Content-Encoding decoded by Stream.
+	Limits Limits
+	// PerHost caps the concurrent downloads from one host, 0 for no cap
+	// beyond Workers, so a run spreads over several payers' CDNs instead of
+	// hitting one with every worker.
+	PerHost int
+	// Rate is the most requests per second sent to one host, 0 for no
+	// limit.
+	Rate float64
+	// MaxRetryAfter caps the wait a Retry-After header of a 429 or 503
+	// response asks for, default 10 minutes. Other hosts are not held up.
+	MaxRetryAfter time.Duration
+	// Revalidate asks the server whether a file completed by an earlier run
+	// changed since, with the ETag and Last-Modified the DirManifest
+	// recorded, and downloads it again if it did. Otherwise completed files
+	// are reused without a request.
+	Revalidate bool
+}
+
+// Result describes the outcome for one location.
+type Result struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Verified is true when the server advertised a checksum and it matched.
+	Verified bool `json:"verified"`
+	// Skipped is true when a completed download from an earlier run was reused.
+	Skipped bool `json:"skipped,omitempty"`
+	// Unchanged is true when the server confirmed a reused file is current.
+	Unchanged    bool   `json:"unchanged,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	// Duration is how long the download took, retries included
+	Duration string `json:"duration,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ChecksumError reports a download whose content does not match the
+// checksum advertised by the server.
+type ChecksumError struct {
+	URL      string
+	Kind     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch for %s: expected %s, got %s", e.Kind, e.URL, e.Expected, e.Actual)
+}
+
+type Downloader struct {
+	dir     string
+	workers int
+	retries int
+	backoff time.Duration
+	client  *http.Client
+	maxSize int64
+	limits  Limits
+	hosts   *hosts
+	// maxRetryAfter caps the wait of a Retry-After header
+	maxRetryAfter time.Duration
+	manifest      *Manifest
+	revalidate    bool
+
+	dirManifestOnce sync.Once
+	dirFiles        map[string]FileEntry
+	dirManifestErr  error
+}
+
+func New(opts Options) *Downloader {
+	d := &Downloader{
+		dir:     opts.Dir,
+		workers: opts.Workers,
+		retries: opts.Retries,
+		backoff: opts.Backoff,
+		client:  opts.Client,
+		maxSize: opts.MaxBytes,
+		limits:  opts.Limits,
+		hosts:   newHosts(opts.PerHost, opts.Rate),
+
+		maxRetryAfter: opts.MaxRetryAfter,
+		revalidate:    opts.Revalidate,
+	}
+	if d.workers <= 0 {
+		d.workers = 4
+	}
+	if d.retries < 0 {
+		d.retries = 0
+	} else if d.retries == 0 {
+		d.retries = 3
+	}
+	if d.backoff <= 0 {
+		d.backoff = 2 * time.Second
+	}
+	if d.client == nil {
+		d.client = &http.Client{}
+	}
+	if d.maxRetryAfter <= 0 {
+		d.maxRetryAfter = 10 * time.Minute
+	}
+	return d
+}
+
+// LocalPath returns where location is stored below the download directory:
+// below its host at its cleaned path, so files of the same name in
+// different directories stay apart. A location serving the file of its
+// query, download?file=..., is stored under that name, and the query
+// parameters other than the signature add a short hash to the filename.
+// Signed urls of the same file share a path.
+func (d *Downloader) LocalPath(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	dir, filename := path.Split(path.Clean("/" + u.Path))
+	query := u.Query()
+	for name := range query {
+		if IsSignatureParam(name) {
+			query.Del(name)
+		}
+	}
+	if !strings.Contains(filename, ".") {
+	keys:
+		for _, k := range slices.Sorted(maps.Keys(query)) {
+			for _, v := range query[k] {
+				if base := path.Base(v); strings.Contains(base, ".") && base != ".." {
+					dir, filename = dir+filename, base
+					break keys
+				}
+			}
+		}
+	}
+	if filename == "" {
+		return "", fmt.Errorf("no filename in location %s", location)
+	}
+	if len(query) > 0 {
+		// Encode sorts the parameters
+		sum := sha256.Sum256([]byte(query.Encode()))
+		tag := "-" + hex.EncodeToString(sum[:4])
+		if i := strings.IndexByte(filename, '.'); i > 0 {
+			filename = filename[:i] + tag + filename[i:]
+		} else {
+			filename += tag
+		}
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		host = "_"
+	}
+
+	return filepath.Join(d.dir, host, filepath.FromSlash(dir), filename), nil
+}
+
+// DownloadAll fetches every distinct location and returns one Result per
+// location in input order. Locations a manifest opened with OpenManifest
+// records as finished are Skipped.
+func (d *Downloader) DownloadAll(ctx context.Context, locations []string) []Result {
+	seen := make(map[string]struct{})
+	var unique []string
+	for _, location := range locations {
+		if _, exists := seen[location]; exists {
+			continue
+		}
+		seen[location] = struct{}{}
+		unique = append(unique, location)
+	}
+
+	results := make([]Result, len(unique))
+	var queued []string
+	for i, location := range unique {
+		if d.manifest == nil {
+			queued = append(queued, location)
+		} else if result, ok := d.manifest.finished(location); ok {
+			result.Skipped, result.Attempts = true, 0
+			results[i] = result
+		} else {
+			queued = append(queued, location)
+		}
+	}
+	if d.manifest != nil {
+		d.manifest.queue(queued)
+	}
+
+	// a worker is only taken once the location's host has a free slot, so
+	// the downloads of a capped host do not hold up the others
+	sem := make(chan struct{}, d.workers)
+	var wg sync.WaitGroup
+	for i, location := range unique {
+		if results[i].Skipped {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := d.hosts.acquire(ctx, hostOf(location))
+			if err != nil {
+				results[i] = Result{URL: location, Error: err.Error()}
+				return
+			}
+			defer release()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = Result{URL: location, Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-sem }()
+			results[i] = d.download(ctx, location)
+			if d.manifest != nil {
+				d.manifest.record(results[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Download fetches a single location, retrying transient failures and
+// checksum mismatches. A 429 or 503 response with a Retry-After header holds
+// back every request to its host for as long as it asks.
+func (d *Downloader) Download(ctx context.Context, location string) Result {
+	release, err := d.hosts.acquire(ctx, hostOf(location))
+	if err != nil {
+		return Result{URL: location, Error: err.Error()}
+	}
+	defer release()
+	return d.download(ctx, location)
+}
+
+func (d *Downloader) download(ctx context.Context, location string) Result {
+	result := Result{URL: location}
+
+	target, err := d.LocalPath(location)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Path = target
+
+	var cached *FileEntry
+	if sum, size, ok := completed(target); ok {
+		entry, known := d.loadDirManifest()[target]
+		if !d.revalidate || !known || entry.SHA256 != sum || (entry.ETag == "" && entry.LastModified == "") {
+			result.SHA256 = sum
+			result.Size = size
+			result.Skipped = true
+			return result
+		}
+		cached = &entry
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	host := hostOf(location)
+	start := time.Now()
+	backoff := d.backoff
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			delay, hostAsked := retryDelay(err, backoff, d.maxRetryAfter)
+			if hostAsked {
+				// the host's other downloads wait as well
+				d.hosts.pause(host, time.Now().Add(delay))
+			} else {
+				select {
+				case <-ctx.Done():
+					result.Error = ctx.Err().Error()
+					return result
+				case <-time.After(delay):
+				}
+				backoff *= 2
+			}
+		}
+		if err := d.hosts.wait(ctx, host); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Attempts = attempt + 1
+
+		err = d.fetch(ctx, location, target, cached, &result)
+		if err == nil {
+			result.Error = ""
+			if !result.Skipped {
+				result.Duration = time.Since(start).Round(time.Millisecond).String()
+			}
+			return result
+		}
+		result.Error = err.Error()
+
+		var checksumErr *ChecksumError
+		if errors.As(err, &checksumErr) {
+			// start over rather than resume onto corrupt bytes
+			os.Remove(target + ".part")
+		}
+		if ctx.Err() != nil || errors.Is(err, errPermanent) {
+			return result
+		}
+	}
+
+	return result
+}
+
+var errPermanent = errors.New("permanent failure")
+
+// fetch downloads location into target.part, resuming from its current
+// size, then verifies and renames it to target. When cached is the entry of
+// a completed target the request is conditional on its validators and a
+// 304 reuses the target.
+func (d *Downloader) fetch(ctx context.Context, location string, target string, cached *FileEntry, result *Result) error {
+	partPath := target + ".part"
+
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if expected := expectedSize(resp, offset); d.maxSize > 0 && expected > d.maxSize {
+		return fmt.Errorf("%w: %s: %w", errPermanent, location, &LimitError{Reason: fmt.Sprintf("%d bytes are larger than %d", expected, d.maxSize)})
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		result.Size, result.SHA256 = cached.Size, cached.SHA256
+		result.LastModified, result.ETag = cached.LastModified, cached.ETag
+		result.Skipped, result.Unchanged = true, true
+		return nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the part file already holds the whole body, verify it below
+		flags = -1
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: GET %s: %s", errPermanent, location, resp.Status)
+	default:
+		if wait, ok := retryAfter(resp); ok {
+			return fmt.Errorf("GET %s: %w", location, &RetryAfterError{Status: resp.Status, Wait: wait})
+		}
+		return fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+
+	if flags != -1 {
+		f, err := os.OpenFile(partPath, flags, 0o644)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errPermanent, err)
+		}
+		var body io.Reader = resp.Body
+		if d.maxSize > 0 {
+			// one byte more tells a file at the limit from a larger one
+			body = io.LimitReader(resp.Body, d.maxSize-offset+1)
+		}
+		written, copyErr := io.Copy(f, body)
+		closeErr := f.Close()
+		if copyErr == nil && d.maxSize > 0 && offset+written > d.maxSize {
+			os.Remove(partPath)
+			return fmt.Errorf("%w: %s: %w", errPermanent, location, &LimitError{Reason: fmt.Sprintf("download passed %d bytes", d.maxSize)})
+		}
+		if copyErr != nil {
+			return fmt.Errorf("download %s: %w", location, copyErr)
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	size, sha, md5sum, err := hashFile(partPath)
+	if err != nil {
+		return err
+	}
+	result.Size = size
+	result.SHA256 = sha
+	result.LastModified = resp.Header.Get("Last-Modified")
+	result.ETag = resp.Header.Get("ETag")
+
+	if expected := expectedSize(resp, offset); expected >= 0 && expected != size {
+		return fmt.Errorf("download %s: got %d bytes, expected %d", location, size, expected)
+	}
+
+	verified, err := verify(location, resp.Header, resp.StatusCode == http.StatusPartialContent, sha, md5sum)
+	if err != nil {
+		return err
+	}
+	result.Verified = verified
+
+	if err := os.WriteFile(target+".sha256", []byte(sha+"  "+filepath.Base(target)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(partPath, target)
+}
+
+// expectedSize is the total object size implied by the response, -1 if unknown.
+func expectedSize(resp *http.Response, offset int64) int64 {
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Range: bytes 100-199/200
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok && total != "*" {
+			var n int64
+			if _, err := fmt.Sscan(total, &n); err == nil {
+				return n
+			}
+		}
+	}
+	if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+		return offset + resp.ContentLength
+	}
+	return -1
+}
+
+var md5ETagPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// verify compares the file hashes to whatever checksum headers the server
+// sent. S3 and CloudFront ETags are the MD5 of single part uploads, multipart
+// ETags contain a dash and cannot be checked. Content-MD5 of a partial
+// response only covers the range and is ignored.
+func verify(location string, header http.Header, partial bool, sha string, md5sum string) (bool, error) {
+	if v := header.Get("X-Amz-Checksum-Sha256"); v != "" {
+		expected, err := base64.StdEncoding.DecodeString(v)
+		if err == nil {
+			if hex.EncodeToString(expected) != sha {
+				return false, &ChecksumError{URL: location, Kind: "sha256", Expected: hex.EncodeToString(expected), Actual: sha}
+			}
+			return true, nil
+		}
+	}
+
+	if v := header.Get("Content-Md5"); v != "" && !partial {
+		expected, err := base64.StdEncoding.DecodeString(v)
+		if err == nil {
+			if hex.EncodeToString(expected) != md5sum {
+				return false, &ChecksumError{URL: location, Kind: "md5", Expected: hex.EncodeToString(expected), Actual: md5sum}
+			}
+			return true, nil
+		}
+	}
+
+	etag := strings.Trim(strings.TrimPrefix(header.Get("ETag"), "W/"), `"`)
+	if md5ETagPattern.MatchString(etag) {
+		if strings.ToLower(etag) != md5sum {
+			return false, &ChecksumError{URL: location, Kind: "etag md5", Expected: strings.ToLower(etag), Actual: md5sum}
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func hashFile(filename string) (size int64, sha string, md5sum string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer f.Close()
+
+	shaHash := sha256.New()
+	md5Hash := md5.New()
+	size, err = io.Copy(io.MultiWriter(shaHash, md5Hash), f)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	return size, hex.EncodeToString(shaHash.Sum(nil)), hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
+
+// completed reports a file finished by an earlier run, recognised by its
+// .sha256 sidecar.
+func completed(target string) (sha string, size int64, ok bool) {
+	content, err := os.ReadFile(target + ".sha256")
+	if err != nil {
+		return "", 0, false
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", 0, false
+	}
+
+	sha, _, _ = strings.Cut(string(content), " ")
+	return sha, info.Size(), true
+}
@@ -0,0 +1,61 @@
+package download_test
+
+import (
+	"testing"
+
+	"serif_interview/pkg/download"
+)
+
+func TestLocalPath(t *testing.T) {
+	d := download.New(download.Options{Dir: "downloads"})
+	localPath := func(location string) string {
+		t.Helper()
+		p, err := d.LocalPath(location)
+		if err != nil {
+			t.Fatalf("LocalPath(%q): %v", location, err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{
+			name: "same basename in different directories",
+			a:    "https://cdn.example.com/a/rates.json.gz",
+			b:    "https://cdn.example.com/b/rates.json.gz",
+		},
+		{
+			name: "query filename",
+			a:    "https://cdn.example.com/download?file=a/rates.json.gz",
+			b:    "https://cdn.example.com/download?file=b/rates.json.gz",
+		},
+		{
+			name: "query parameter",
+			a:    "https://cdn.example.com/rates.json.gz?plan=1",
+			b:    "https://cdn.example.com/rates.json.gz?plan=2",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if a, b := localPath(tc.a), localPath(tc.b); a == b {
+				t.Errorf("%q and %q are both stored at %s", tc.a, tc.b, a)
+			}
+		})
+	}
+
+	t.Run("signed twice", func(t *testing.T) {
+		a := localPath("https://cdn.example.com/a/rates.json.gz?file=x&X-Amz-Signature=abc&Expires=1")
+		b := localPath("https://CDN.example.com/a/rates.json.gz?Expires=2&X-Amz-Signature=def&file=x")
+		if a != b {
+			t.Errorf("signed urls of one file are stored at %s and %s", a, b)
+		}
+	})
+
+	t.Run("stays below the directory", func(t *testing.T) {
+		if got, want := localPath("https://cdn.example.com/../../etc/rates.json"), "downloads/cdn.example.com/etc/rates.json"; got != want {
+			t.Errorf("LocalPath = %s, want %s", got, want)
+		}
+	})
+}
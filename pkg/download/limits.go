@@ -0,0 +1,90 @@
+package download
+
+import (
+	"fmt"
+	"io"
+)
+
+// ratioFloor is how many bytes must be decompressed before the expansion
+// ratio is checked, the first blocks of any file compress unevenly.
+const ratioFloor = 1 << 20
+
+// Limits bound what decompressing a file may produce, so a malformed or
+// malicious file cannot exhaust the disk or keep the pipeline busy forever.
+// The zero value imposes no limits.
+type Limits struct {
+	// MaxBytes is the most bytes a file may decompress to, 0 for no limit.
+	MaxBytes int64
+	// MaxRatio is the most decompressed bytes per compressed byte, 0 for no
+	// limit.
+	MaxRatio float64
+}
+
+// LimitError reports a file exceeding one of its Limits.
+type LimitError struct {
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return "size limit exceeded: " + e.Reason
+}
+
+// CountingReader counts the bytes read through it.
+type CountingReader struct {
+	R io.Reader
+	N int64
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.R.Read(p)
+	c.N += int64(n)
+	return n, err
+}
+
+// Reader enforces the limits on decompressed, the decoded form of what was
+// read through compressed. compressed may be nil for uncompressed files.
+func (l Limits) Reader(decompressed io.Reader, compressed *CountingReader) io.Reader {
+	if l.MaxBytes <= 0 && (l.MaxRatio <= 0 || compressed == nil) {
+		return decompressed
+	}
+	return &limitedReader{r: decompressed, compressed: compressed, limits: l}
+}
+
+type limitedReader struct {
+	r          io.Reader
+	compressed *CountingReader
+	limits     Limits
+	n          int64
+	err        error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	// small reads keep the checks close to the data a reader sees, it could
+	// otherwise finish a document from a single oversized read
+	if len(p) > ratioFloor {
+		p = p[:ratioFloor]
+	}
+	if l.limits.MaxBytes > 0 && int64(len(p)) > l.limits.MaxBytes-l.n+1 {
+		p = p[:l.limits.MaxBytes-l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+
+	// the bytes of a read passing a limit are dropped and every later read
+	// fails, json.Decoder would otherwise keep decoding what it buffered
+	if l.limits.MaxBytes > 0 && l.n > l.limits.MaxBytes {
+		l.err = &LimitError{Reason: fmt.Sprintf("decompressed size passed %d bytes", l.limits.MaxBytes)}
+		return 0, l.err
+	}
+	if l.limits.MaxRatio > 0 && l.compressed != nil && l.n >= ratioFloor && l.compressed.N > 0 {
+		if ratio := float64(l.n) / float64(l.compressed.N); ratio > l.limits.MaxRatio {
+			l.err = &LimitError{Reason: fmt.Sprintf("expansion ratio %.0f:1 after %d bytes passed %.0f:1", ratio, l.n, l.limits.MaxRatio)}
+			return 0, l.err
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,141 @@
+package download
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Manifest is a JSON lines log of the downloads of one or more runs: a
+// {"queued": url} line per location before its download starts and a
+// {"result": {...}} line once it finishes. A run resumed from it skips the
+// files an earlier run finished, even when they have since been moved out
+// of the download directory, and can pick up the ones it never finished.
+// Files are identified by their LocalPath, so a location signed anew is
+// still recognised.
+type Manifest struct {
+	d    *Downloader
+	mu   sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	err  error
+	done map[string]Result
+	// pending are queued locations without a successful result, in the
+	// order they were queued
+	pending []string
+}
+
+type manifestEntry struct {
+	Queued string  `json:"queued,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+// OpenManifest reads the manifest at path, if there is one, and opens it to
+// record the downloads of d from now on.
+func (d *Downloader) OpenManifest(path string) (*Manifest, error) {
+	m := &Manifest{d: d, done: make(map[string]Result)}
+	queued := make(map[string]string)
+	var order []string
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open download manifest: %w", err)
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var entry manifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last line of a killed run may be cut short
+			continue
+		}
+		switch {
+		case entry.Queued != "":
+			key, err := d.LocalPath(entry.Queued)
+			if err != nil {
+				continue
+			}
+			if _, seen := queued[key]; !seen {
+				order = append(order, key)
+			}
+			queued[key] = entry.Queued
+		case entry.Result != nil && entry.Result.Error == "" && entry.Result.Path != "":
+			key, err := d.LocalPath(entry.Result.URL)
+			if err != nil {
+				continue
+			}
+			m.done[key] = *entry.Result
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read download manifest: %w", err)
+	}
+	for _, key := range order {
+		if _, done := m.done[key]; !done {
+			m.pending = append(m.pending, queued[key])
+		}
+	}
+	// new lines must not run on from one cut short
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("write download manifest: %w", err)
+			}
+		}
+	}
+	m.f, m.enc = f, json.NewEncoder(f)
+	d.manifest = m
+	return m, nil
+}
+
+// Pending returns the locations queued by earlier runs that were never
+// downloaded successfully.
+func (m *Manifest) Pending() []string {
+	return m.pending
+}
+
+// finished returns the result an earlier run recorded for the file of
+// location.
+func (m *Manifest) finished(location string) (Result, bool) {
+	key, err := m.d.LocalPath(location)
+	if err != nil {
+		return Result{}, false
+	}
+	result, ok := m.done[key]
+	return result, ok
+}
+
+func (m *Manifest) queue(locations []string) {
+	for _, location := range locations {
+		m.write(manifestEntry{Queued: location})
+	}
+}
+
+func (m *Manifest) record(result Result) {
+	m.write(manifestEntry{Result: &result})
+}
+
+func (m *Manifest) write(entry manifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err == nil {
+		m.err = m.enc.Encode(entry)
+	}
+}
+
+// Close closes the manifest, reporting the first write that failed.
+func (m *Manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := errors.Join(m.err, m.f.Close())
+	if err != nil {
+		return fmt.Errorf("write download manifest: %w", err)
+	}
+	return nil
+}
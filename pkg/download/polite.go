@@ -0,0 +1,148 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hosts keeps the downloads of one host within its share: at most perHost
+// at a time, requests at least interval apart, and none before a
+// Retry-After the host asked for has passed.
+type hosts struct {
+	perHost  int
+	interval time.Duration
+
+	mu    sync.Mutex
+	state map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+	// next is the earliest time the next request may start
+	next time.Time
+}
+
+func newHosts(perHost int, rate float64) *hosts {
+	h := &hosts{perHost: perHost, state: make(map[string]*hostState)}
+	if rate > 0 {
+		h.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return h
+}
+
+func (h *hosts) get(host string) *hostState {
+	s := h.state[host]
+	if s == nil {
+		s = &hostState{}
+		if h.perHost > 0 {
+			s.slots = make(chan struct{}, h.perHost)
+		}
+		h.state[host] = s
+	}
+	return s
+}
+
+// acquire takes one of host's download slots, release gives it back.
+func (h *hosts) acquire(ctx context.Context, host string) (release func(), err error) {
+	h.mu.Lock()
+	s := h.get(host)
+	h.mu.Unlock()
+	if s.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wait blocks until host may be sent another request.
+func (h *hosts) wait(ctx context.Context, host string) error {
+	h.mu.Lock()
+	s := h.get(host)
+	start := time.Now()
+	if s.next.After(start) {
+		start = s.next
+	}
+	s.next = start.Add(h.interval)
+	h.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pause holds back every request to host until t.
+func (h *hosts) pause(host string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.get(host); t.After(s.next) {
+		s.next = t
+	}
+}
+
+// hostOf is the lowercased host of location, the key of its limits.
+func hostOf(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// RetryAfterError reports a 429 or 503 response asking to retry after Wait.
+type RetryAfterError struct {
+	Status string
+	Wait   time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Status, e.Wait)
+}
+
+// retryAfter returns the wait a 429 or 503 response asks for in its
+// Retry-After header, in seconds or as an http date, and false without one.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// retryDelay is how long to wait before retrying after err, the host's
+// Retry-After capped at maxWait when it sent one and backoff otherwise.
+func retryDelay(err error, backoff time.Duration, maxWait time.Duration) (time.Duration, bool) {
+	var retryErr *RetryAfterError
+	if errors.As(err, &retryErr) {
+		return min(retryErr.Wait, maxWait), true
+	}
+	return backoff, false
+}
@@ -0,0 +1,171 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProbeResult is what the server says about a location without sending it.
+type ProbeResult struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	// ContentLength is the size of the file, -1 when the server did not say
+	ContentLength int64  `json:"contentLength"`
+	LastModified  string `json:"lastModified,omitempty"`
+	// Ranged is true when the server refused HEAD and the first byte was
+	// requested instead
+	Ranged   bool   `json:"ranged,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ProbeAll probes every distinct location like DownloadAll downloads them,
+// with the same workers and limits per host, and returns one ProbeResult per
+// location in input order.
+func (d *Downloader) ProbeAll(ctx context.Context, locations []string) []ProbeResult {
+	seen := make(map[string]struct{})
+	var unique []string
+	for _, location := range locations {
+		if _, exists := seen[location]; !exists {
+			seen[location] = struct{}{}
+			unique = append(unique, location)
+		}
+	}
+
+	results := make([]ProbeResult, len(unique))
+	sem := make(chan struct{}, d.workers)
+	var wg sync.WaitGroup
+	for i, location := range unique {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := d.hosts.acquire(ctx, hostOf(location))
+			if err != nil {
+				results[i] = ProbeResult{URL: location, ContentLength: -1, Error: err.Error()}
+				return
+			}
+			defer release()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = ProbeResult{URL: location, ContentLength: -1, Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-sem }()
+			results[i] = d.probe(ctx, location)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Probe asks for the status, size and modification time of location with a
+// HEAD request, retrying transient failures like Download. S3 urls signed
+// for GET refuse HEAD, a 403, 405 or 501 is tried again as a GET of the
+// first byte.
+func (d *Downloader) Probe(ctx context.Context, location string) ProbeResult {
+	release, err := d.hosts.acquire(ctx, hostOf(location))
+	if err != nil {
+		return ProbeResult{URL: location, ContentLength: -1, Error: err.Error()}
+	}
+	defer release()
+	return d.probe(ctx, location)
+}
+
+func (d *Downloader) probe(ctx context.Context, location string) ProbeResult {
+	host := hostOf(location)
+	backoff := d.backoff
+	var result ProbeResult
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			delay, hostAsked := retryDelay(err, backoff, d.maxRetryAfter)
+			if hostAsked {
+				d.hosts.pause(host, time.Now().Add(delay))
+			} else {
+				select {
+				case <-ctx.Done():
+					result.Error = ctx.Err().Error()
+					return result
+				case <-time.After(delay):
+				}
+				backoff *= 2
+			}
+		}
+		if err := d.hosts.wait(ctx, host); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+
+		result, err = d.head(ctx, location)
+		result.Attempts = attempt + 1
+		if err == nil {
+			return result
+		}
+		result.Error = err.Error()
+		if ctx.Err() != nil || errors.Is(err, errPermanent) {
+			return result
+		}
+	}
+	return result
+}
+
+// head sends one probe, errors are errPermanent unless a retry may help.
+func (d *Downloader) head(ctx context.Context, location string) (ProbeResult, error) {
+	result := ProbeResult{URL: location, ContentLength: -1}
+	resp, err := d.probeRequest(ctx, http.MethodHead, location)
+	if err != nil {
+		return result, err
+	}
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		result.Ranged = true
+		resp, err = d.probeRequest(ctx, http.MethodGet, location)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Status = resp.StatusCode
+	result.LastModified = resp.Header.Get("Last-Modified")
+	if result.Ranged {
+		result.ContentLength = expectedSize(resp, 0)
+	} else if resp.ContentLength >= 0 {
+		result.ContentLength = resp.ContentLength
+	}
+
+	switch {
+	case resp.StatusCode < 400:
+		return result, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		if wait, ok := retryAfter(resp); ok {
+			return result, fmt.Errorf("%s %s: %w", resp.Request.Method, location, &RetryAfterError{Status: resp.Status, Wait: wait})
+		}
+		return result, fmt.Errorf("%s %s: %s", resp.Request.Method, location, resp.Status)
+	default:
+		return result, fmt.Errorf("%w: %s %s: %s", errPermanent, resp.Request.Method, location, resp.Status)
+	}
+}
+
+// probeRequest sends a HEAD, or a GET of the first byte, and closes the
+// body unread.
+func (d *Downloader) probeRequest(ctx context.Context, method string, location string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
@@ -0,0 +1,32 @@
+package download
+
+import "strings"
+
+// signatureParams are the lowercase query parameters of signed urls, which
+// differ between listings of the same file: S3 and CloudFront, Google Cloud
+// Storage and Azure SAS signatures, expiries and tokens.
+var signatureParams = map[string]struct{}{
+	"awsaccesskeyid": {}, "signature": {}, "expires": {}, "policy": {}, "key-pair-id": {},
+	"googleaccessid": {},
+	"sv":             {}, "ss": {}, "srt": {}, "sp": {}, "se": {}, "st": {}, "spr": {}, "sig": {}, "sr": {}, "si": {},
+	"skoid": {}, "sktid": {}, "skt": {}, "ske": {}, "sks": {}, "skv": {}, "sdd": {},
+	"token": {}, "access_token": {},
+}
+
+// signaturePrefixes are the lowercase prefixes of signature parameters.
+var signaturePrefixes = []string{"x-amz-", "x-goog-"}
+
+// IsSignatureParam reports whether the query parameter name is part of the
+// signature of a url rather than of the file it names.
+func IsSignatureParam(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := signatureParams[name]; ok {
+		return true
+	}
+	for _, prefix := range signaturePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
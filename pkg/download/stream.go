@@ -0,0 +1,185 @@
+package download
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsURL reports whether name is an http or https location rather than a
+// local filename.
+func IsURL(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// stream reads a location sequentially, reconnecting after transient
+// failures and continuing where the broken connection stopped.
+type stream struct {
+	ctx      context.Context
+	d        *Downloader
+	location string
+
+	body io.ReadCloser
+	// offset counts the decoded bytes handed to the reader so far
+	offset int64
+	etag   string
+	// ranged is false when offset is not a byte offset into the resource
+	// because the body was content-decoded
+	ranged bool
+	// size is the length of the resource, 0 when unknown or content-decoded
+	size int64
+}
+
+// Stream opens location for reading without storing it, for files parsed on
+// the fly. Bodies with a gzip Content-Encoding are decoded, the
+// file's own gzip compression is left to the caller. Transient failures are
+// retried like Download, a connection that breaks mid-body is resumed with a
+// Range request, or by skipping the bytes already read when the server
+// ignores ranges, as long as the ETag is unchanged.
+func (d *Downloader) Stream(ctx context.Context, location string) (io.ReadCloser, error) {
+	s := &stream{ctx: ctx, d: d, location: location}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	failures := 0
+	for {
+		n, err := s.body.Read(p)
+		s.offset += int64(n)
+		if err == nil || err == io.EOF || n > 0 {
+			return n, err
+		}
+		if s.ctx.Err() != nil || failures >= s.d.retries {
+			return 0, fmt.Errorf("read %s at byte %d: %w", s.location, s.offset, err)
+		}
+		failures++
+
+		s.body.Close()
+		if openErr := s.open(); openErr != nil {
+			return 0, errors.Join(fmt.Errorf("read %s at byte %d: %w", s.location, s.offset, err), openErr)
+		}
+	}
+}
+
+// Size is the length of the resource as read, 0 when the server did not
+// send it or the body is content-decoded.
+func (s *stream) Size() int64 {
+	return s.size
+}
+
+func (s *stream) Close() error {
+	return s.body.Close()
+}
+
+// open connects, or reconnects at s.offset, retrying transient failures with
+// the downloader's backoff.
+func (s *stream) open() error {
+	backoff := s.d.backoff
+	var err error
+	for attempt := 0; attempt <= s.d.retries; attempt++ {
+		if attempt > 0 || s.offset > 0 {
+			select {
+			case <-s.ctx.Done():
+				return s.ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = s.connect()
+		if err == nil || s.ctx.Err() != nil || errors.Is(err, errPermanent) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *stream) connect() error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	resuming := s.offset > 0
+	if resuming && s.ranged {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", s.offset))
+		if s.etag != "" {
+			req.Header.Set("If-Range", s.etag)
+		}
+	}
+
+	resp, err := s.d.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK, resp.StatusCode == http.StatusPartialContent && resuming:
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		resp.Body.Close()
+		return fmt.Errorf("%w: GET %s: %s", errPermanent, s.location, resp.Status)
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("GET %s: %s", s.location, resp.Status)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resuming && s.etag != "" && etag != "" && etag != s.etag {
+		resp.Body.Close()
+		return fmt.Errorf("%w: %s changed while it was read, etag %s is now %s", errPermanent, s.location, s.etag, etag)
+	}
+
+	body, decoded, err := decodeBody(resp, s.d.limits)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+
+	if !resuming {
+		s.etag = etag
+		s.ranged = !decoded && resp.Header.Get("Accept-Ranges") == "bytes"
+		if !decoded && resp.ContentLength > 0 {
+			s.size = resp.ContentLength
+		}
+	} else if resp.StatusCode == http.StatusOK {
+		// the server sent everything again, skip what was already read
+		if _, err := io.CopyN(io.Discard, body, s.offset); err != nil {
+			body.Close()
+			return fmt.Errorf("skip to byte %d of %s: %w", s.offset, s.location, err)
+		}
+	}
+
+	s.body = body
+	return nil
+}
+
+// decodeBody undoes a Content-Encoding the transport did not already handle,
+// within limits.
+func decodeBody(resp *http.Response, limits Limits) (io.ReadCloser, bool, error) {
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "", "identity":
+		return resp.Body, resp.Uncompressed, nil
+	case "gzip", "x-gzip":
+		compressed := &CountingReader{R: resp.Body}
+		gr, err := gzip.NewReader(compressed)
+		if err != nil {
+			return nil, false, fmt.Errorf("open gzip content encoding: %w", err)
+		}
+		return readCloser{Reader: limits.Reader(gr, compressed), Closer: resp.Body}, true, nil
+	default:
+		return nil, false, fmt.Errorf("%w: unsupported content encoding %q", errPermanent, resp.Header.Get("Content-Encoding"))
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,319 @@
+// Package embed keeps the embeddings of plan descriptions in a sqlite table
+// keyed by a hash of the description, so monthly runs over mostly the same
+// descriptions only embed the ones they have not seen, and finds the stored
+// descriptions most similar to another. The vectors of the model in use are
+// held in memory as a flat index and compared by cosine similarity.
+package embed
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchSize is the number of descriptions embedded per call when
+// Options.BatchSize is not set.
+const DefaultBatchSize = 64
+
+const schema = `
+CREATE TABLE IF NOT EXISTS embeddings (
+	hash TEXT NOT NULL,
+	model TEXT NOT NULL,
+	description TEXT NOT NULL,
+	vector BLOB NOT NULL,
+	embedded_at TEXT NOT NULL,
+	PRIMARY KEY (hash, model)
+);
+`
+
+// Embedder computes the embeddings of texts, one vector per text in their
+// order. The llm package provides them.
+type Embedder interface {
+	CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Options configures a Store.
+type Options struct {
+	// Model names the embeddings of the Embedder, a store keeps those of
+	// every model apart.
+	Model string
+	// BatchSize is the number of descriptions embedded per call,
+	// DefaultBatchSize when 0.
+	BatchSize int
+	// Timeout bounds each call of the Embedder, 0 waits indefinitely.
+	Timeout time.Duration
+}
+
+// Neighbor is a stored description similar to another.
+type Neighbor struct {
+	Description string  `json:"description"`
+	Score       float64 `json:"score"`
+}
+
+// Store embeds descriptions through the Embedder unless they are stored.
+// It is safe for concurrent use.
+type Store struct {
+	db       *sql.DB
+	embedder Embedder
+	opts     Options
+
+	mu sync.Mutex
+	// vectors and descriptions are the stored embeddings of the model by
+	// hash
+	vectors      map[string][]float32
+	descriptions map[string]string
+}
+
+// Open creates the embeddings table of db when missing and loads the
+// vectors of opts.Model. The caller registers the driver and closes db.
+func Open(db *sql.DB, embedder Embedder, opts Options) (*Store, error) {
+	if opts.Model == "" {
+		return nil, errors.New("embedding model not set")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("create embeddings table: %w", err)
+	}
+
+	s := &Store{
+		db:           db,
+		embedder:     embedder,
+		opts:         opts,
+		vectors:      make(map[string][]float32),
+		descriptions: make(map[string]string),
+	}
+	rows, err := db.Query("SELECT hash, description, vector FROM embeddings WHERE model = ?", opts.Model)
+	if err != nil {
+		return nil, fmt.Errorf("read embeddings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, description string
+		var blob []byte
+		if err := rows.Scan(&hash, &description, &blob); err != nil {
+			return nil, fmt.Errorf("read embeddings: %w", err)
+		}
+		vector, err := decode(blob)
+		if err != nil {
+			return nil, fmt.Errorf("embedding of %q: %w", description, err)
+		}
+		s.vectors[hash], s.descriptions[hash] = vector, description
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read embeddings: %w", err)
+	}
+	return s, nil
+}
+
+// Key is the hash a description is stored by. Descriptions are embedded
+// lowercase with their surrounding spaces trimmed, so those differing only
+// in case share an embedding.
+func Key(description string) string {
+	sum := sha256.Sum256([]byte(normalize(description)))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalize(description string) string {
+	return strings.ToLower(strings.TrimSpace(description))
+}
+
+// Len is the number of stored embeddings of the model.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.vectors)
+}
+
+// Embed returns the embeddings of descriptions, embedding and storing those
+// that are not stored yet.
+func (s *Store) Embed(ctx context.Context, descriptions []string) ([][]float32, error) {
+	hashes := make([]string, len(descriptions))
+	var missing []string
+	seen := make(map[string]bool)
+	s.mu.Lock()
+	for i, description := range descriptions {
+		hashes[i] = Key(description)
+		if _, stored := s.vectors[hashes[i]]; !stored && !seen[hashes[i]] {
+			seen[hashes[i]] = true
+			missing = append(missing, normalize(description))
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.embed(ctx, missing); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vectors := make([][]float32, len(hashes))
+	for i, hash := range hashes {
+		vectors[i] = s.vectors[hash]
+	}
+	return vectors, nil
+}
+
+// Reindex embeds every stored description of the model again, replacing
+// its vector, e.g. after the model was updated under the same name. It
+// returns the number of descriptions embedded.
+func (s *Store) Reindex(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	descriptions := make([]string, 0, len(s.descriptions))
+	for _, description := range s.descriptions {
+		descriptions = append(descriptions, description)
+	}
+	s.mu.Unlock()
+	slices.Sort(descriptions)
+
+	if err := s.embed(ctx, descriptions); err != nil {
+		return 0, err
+	}
+	return len(descriptions), nil
+}
+
+// embed embeds the normalized descriptions in batches and stores them, a
+// batch at a time.
+func (s *Store) embed(ctx context.Context, descriptions []string) error {
+	for start := 0; start < len(descriptions); start += s.opts.BatchSize {
+		batch := descriptions[start:min(start+s.opts.BatchSize, len(descriptions))]
+		vectors, err := s.createEmbedding(ctx, batch)
+		if err != nil {
+			return err
+		}
+		if err := s.put(ctx, batch, vectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) createEmbedding(ctx context.Context, batch []string) ([][]float32, error) {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	vectors, err := s.embedder.CreateEmbedding(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("embed %d descriptions: %w", len(batch), err)
+	}
+	if len(vectors) != len(batch) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d descriptions", len(vectors), len(batch))
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("empty embedding of %q", batch[i])
+		}
+	}
+	return vectors, nil
+}
+
+// put stores the vectors of a batch in one transaction.
+func (s *Store) put(ctx context.Context, batch []string, vectors [][]float32) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin embeddings batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	embeddedAt := time.Now().UTC().Format(time.DateTime)
+	for i, description := range batch {
+		_, err := tx.ExecContext(ctx, `INSERT INTO embeddings (hash, model, description, vector, embedded_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (hash, model) DO UPDATE SET vector = excluded.vector, embedded_at = excluded.embedded_at`,
+			Key(description), s.opts.Model, description, encode(vectors[i]), embeddedAt)
+		if err != nil {
+			return fmt.Errorf("store embedding of %q: %w", description, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit embeddings batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, description := range batch {
+		hash := Key(description)
+		s.vectors[hash], s.descriptions[hash] = vectors[i], description
+	}
+	return nil
+}
+
+// Similar returns the k stored descriptions most similar to description,
+// most similar first, leaving out description itself. description is
+// embedded and stored when it is not yet.
+func (s *Store) Similar(ctx context.Context, description string, k int) ([]Neighbor, error) {
+	vectors, err := s.Embed(ctx, []string{description})
+	if err != nil {
+		return nil, err
+	}
+	query, own := vectors[0], Key(description)
+
+	s.mu.Lock()
+	neighbors := make([]Neighbor, 0, len(s.vectors))
+	for hash, vector := range s.vectors {
+		if hash != own {
+			neighbors = append(neighbors, Neighbor{Description: s.descriptions[hash], Score: Cosine(query, vector)})
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(neighbors, func(a, b Neighbor) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Description, b.Description)
+	})
+	return neighbors[:min(k, len(neighbors))], nil
+}
+
+// Cosine is the cosine similarity of a and b, 0 when their dimensions
+// differ or either is all zeros.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// encode stores a vector as little endian float32s.
+func encode(vector []float32) []byte {
+	blob := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+	}
+	return blob
+}
+
+func decode(blob []byte) ([]float32, error) {
+	if len(blob) == 0 || len(blob)%4 != 0 {
+		return nil, fmt.Errorf("vector of %d bytes", len(blob))
+	}
+	vector := make([]float32, len(blob)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return vector, nil
+}
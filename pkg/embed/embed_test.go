@@ -0,0 +1,255 @@
+package embed_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"serif_interview/pkg/embed"
+
+	_ "modernc.org/sqlite"
+)
+
+// fakeEmbedder embeds a text as how often it names each of its words,
+// recording the batches it is asked. version is added to every vector, a
+// model updated under the same name.
+type fakeEmbedder struct {
+	words []string
+
+	mu      sync.Mutex
+	batches [][]string
+	version float32
+	err     error
+	short   bool
+	empty   bool
+	block   bool
+}
+
+func (f *fakeEmbedder) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, texts)
+	if f.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	var vectors [][]float32
+	for _, text := range texts {
+		vector := make([]float32, len(f.words)+1)
+		for i, word := range f.words {
+			vector[i] = float32(strings.Count(text, word))
+		}
+		vector[len(f.words)] = f.version
+		if f.empty {
+			vector = nil
+		}
+		vectors = append(vectors, vector)
+	}
+	if f.short {
+		vectors = vectors[1:]
+	}
+	return vectors, nil
+}
+
+func (f *fakeEmbedder) embedded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, batch := range f.batches {
+		texts = append(texts, batch...)
+	}
+	f.batches = nil
+	return texts
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "embeddings.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func open(t *testing.T, db *sql.DB, embedder embed.Embedder, opts embed.Options) *embed.Store {
+	t.Helper()
+	store, err := embed.Open(db, embedder, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+var ppoWords = []string{"ppo", "blue", "excellus", "empire", "dental"}
+
+func TestEmbed(t *testing.T) {
+	db := openDB(t)
+	embedder := &fakeEmbedder{words: ppoWords}
+	store := open(t, db, embedder, embed.Options{Model: "ollama/nomic-embed-text", BatchSize: 2})
+
+	vectors, err := store.Embed(context.Background(), []string{"Excellus Blue PPO", "empire ppo", " excellus blue ppo ", "empire dental"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// descriptions are embedded lowercase and trimmed, each once, in batches
+	embedder.mu.Lock()
+	batches := embedder.batches
+	embedder.mu.Unlock()
+	if len(batches) != 2 || strings.Join(batches[0], "|") != "excellus blue ppo|empire ppo" || strings.Join(batches[1], "|") != "empire dental" {
+		t.Errorf("embedded batches %q", batches)
+	}
+	embedder.embedded()
+	if len(vectors) != 4 || vectors[0][0] != 1 || vectors[0][2] != 1 || embed.Cosine(vectors[0], vectors[2]) != 1 {
+		t.Errorf("vectors %v", vectors)
+	}
+	if store.Len() != 3 {
+		t.Errorf("%d stored embeddings, want 3", store.Len())
+	}
+
+	// stored descriptions are not embedded again, by this run or the next
+	if _, err := store.Embed(context.Background(), []string{"EMPIRE PPO", "empire blue ppo"}); err != nil {
+		t.Fatal(err)
+	}
+	if texts := embedder.embedded(); strings.Join(texts, "|") != "empire blue ppo" {
+		t.Errorf("embedded %q, want only the new description", texts)
+	}
+	next := open(t, db, embedder, embed.Options{Model: "ollama/nomic-embed-text"})
+	if next.Len() != 4 {
+		t.Errorf("next run loaded %d embeddings, want 4", next.Len())
+	}
+	reloaded, err := next.Embed(context.Background(), []string{"excellus blue ppo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texts := embedder.embedded(); len(texts) != 0 || embed.Cosine(reloaded[0], vectors[0]) != 1 {
+		t.Errorf("next run embedded %q, reloaded %v", texts, reloaded[0])
+	}
+
+	// another model's embeddings are kept apart
+	other := open(t, db, embedder, embed.Options{Model: "openai/text-embedding-3-small"})
+	if other.Len() != 0 {
+		t.Errorf("another model loaded %d embeddings", other.Len())
+	}
+}
+
+func TestReindex(t *testing.T) {
+	db := openDB(t)
+	embedder := &fakeEmbedder{words: ppoWords}
+	store := open(t, db, embedder, embed.Options{Model: "nomic-embed-text"})
+	descriptions := []string{"excellus blue ppo", "empire ppo", "empire dental"}
+	if _, err := store.Embed(context.Background(), descriptions); err != nil {
+		t.Fatal(err)
+	}
+	embedder.embedded()
+
+	embedder.version = 1
+	n, err := store.Reindex(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("reindexed %d, %v, want 3", n, err)
+	}
+	if texts := embedder.embedded(); len(texts) != 3 {
+		t.Errorf("reindex embedded %q, want every stored description", texts)
+	}
+	// the rebuilt vectors are stored, the next run reads them
+	next := open(t, db, embedder, embed.Options{Model: "nomic-embed-text"})
+	vectors, _ := next.Embed(context.Background(), descriptions[:1])
+	if vector := vectors[0]; vector[len(vector)-1] != 1 || next.Len() != 3 {
+		t.Errorf("reloaded vector %v of %d, want the reindexed one", vector, next.Len())
+	}
+	if texts := embedder.embedded(); len(texts) != 0 {
+		t.Errorf("embedded %q after reindexing", texts)
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	store := open(t, openDB(t), &fakeEmbedder{words: ppoWords}, embed.Options{Model: "nomic-embed-text"})
+	if _, err := store.Embed(context.Background(), []string{"excellus blue ppo", "empire blue ppo", "empire dental", "excellus dental"}); err != nil {
+		t.Fatal(err)
+	}
+
+	neighbors, err := store.Similar(context.Background(), "Excellus Blue PPO", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 2 || neighbors[0].Description != "empire blue ppo" || neighbors[1].Description != "excellus dental" {
+		t.Fatalf("neighbors %+v, want the other blue ppo then the other excellus plan", neighbors)
+	}
+	if math.Abs(neighbors[0].Score-2.0/3) > 1e-9 || neighbors[0].Score <= neighbors[1].Score {
+		t.Errorf("scores %v and %v", neighbors[0].Score, neighbors[1].Score)
+	}
+
+	// a new description is stored by the lookup
+	neighbors, err = store.Similar(context.Background(), "blue ppo", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 4 || store.Len() != 5 {
+		t.Errorf("%d neighbors of %d stored, want all the others", len(neighbors), store.Len())
+	}
+}
+
+func TestEmbedderFailures(t *testing.T) {
+	db := openDB(t)
+	embedder := &fakeEmbedder{words: ppoWords, err: errors.New("model not found")}
+	store := open(t, db, embedder, embed.Options{Model: "nomic-embed-text", Timeout: 20 * time.Millisecond})
+
+	if _, err := store.Embed(context.Background(), []string{"empire ppo"}); err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Embed returned %v, want the embedder's error", err)
+	}
+	embedder.err, embedder.short = nil, true
+	if _, err := store.Embed(context.Background(), []string{"empire ppo", "excellus ppo"}); err == nil {
+		t.Error("missing embeddings accepted")
+	}
+	embedder.short, embedder.empty = false, true
+	if _, err := store.Embed(context.Background(), []string{"empire ppo"}); err == nil {
+		t.Error("empty embedding accepted")
+	}
+	embedder.block = true
+	if _, err := store.Embed(context.Background(), []string{"empire ppo"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Embed of a hanging embedder returned %v, want the timeout", err)
+	}
+	if store.Len() != 0 || open(t, db, embedder, embed.Options{Model: "nomic-embed-text"}).Len() != 0 {
+		t.Error("failed embeddings were stored")
+	}
+
+	if _, err := embed.Open(db, embedder, embed.Options{}); err == nil {
+		t.Error("store without a model opened")
+	}
+	if _, err := db.Exec("INSERT INTO embeddings VALUES ('x', 'nomic-embed-text', 'x', x'0102', '2026-01-01')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embed.Open(db, embedder, embed.Options{Model: "nomic-embed-text"}); err == nil {
+		t.Error("store with a truncated vector opened")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	for _, tt := range []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{3, 4}, []float32{4, 3}, 0.96},
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+	} {
+		if got := embed.Cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if embed.Key("Empire PPO ") != embed.Key("empire ppo") || embed.Key("empire ppo") == embed.Key("empire hmo") {
+		t.Error("keys do not follow the normalized description")
+	}
+}
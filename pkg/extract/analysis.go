@@ -0,0 +1,419 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Match is an analysis mode candidate with the signals that selected it.
+// AIConfidence is the model's confidence in AIMatch, the lower of its two
+// answers for a match, 0 when it gave none, and AIRationale the reasons it
+// gave for the answers it was asked. Score is the weighted sum of the
+// Signals, every signal is listed whether it contributed or not. Merged
+// matches aggregate the Records listing the location, with their distinct
+// Eins and Descriptions and the strongest of each signal, Description being
+// the first. Evidence is what the signals were decided on, of the first
+// record for merged matches, kept for audits rather than written with the
+// match. PlanType is the plan type of the description, see ClassifyPlanType,
+// "" when it is unknown. Header holds the version, reporting entity and
+// last_updated_on fields of the in_network_files element, for payers that
+// date each file, nil when it has none. Path and Offset locate the
+// in_network_files element of the match, that of the first record for
+// merged matches, and are only set with Options.SourcePaths.
+type Match struct {
+	Description     string        `json:"description"`
+	Location        string        `json:"location"`
+	Eins            []string      `json:"eins"`
+	AIMatch         bool          `json:"aiMatch"`
+	AIConfidence    float64       `json:"aiConfidence"`
+	AIRationale     string        `json:"aiRationale,omitempty"`
+	HeuristicMatch  bool          `json:"heuristicMatch"`
+	RegionCodeMatch bool          `json:"regionCodeMatch"`
+	PlanType        string        `json:"planType"`
+	Header          *IndexHeader  `json:"header,omitempty"`
+	Score           float64       `json:"score"`
+	Signals         []SignalScore `json:"signals"`
+	Records         int           `json:"records,omitempty"`
+	Descriptions    []string      `json:"descriptions,omitempty"`
+	Path            string        `json:"path,omitempty"`
+	Offset          int64         `json:"offset,omitempty"`
+	Evidence        *Evidence     `json:"-"`
+}
+
+// Evidence is the source element of a Match and the rules and llm answers
+// that selected it.
+type Evidence struct {
+	// Path is the JSON pointer of the in_network_files element in the index.
+	Path   string          `json:"path"`
+	Source json.RawMessage `json:"source"`
+	// Rules describe the heuristic and region code rules that matched.
+	Rules []string    `json:"rules"`
+	LLM   []LLMAnswer `json:"llm,omitempty"`
+}
+
+// LLMAnswer is one question asked about a description.
+type LLMAnswer struct {
+	Question   string  `json:"question"`
+	Prompt     string  `json:"prompt"`
+	Answer     bool    `json:"answer"`
+	Confidence float64 `json:"confidence"`
+	Rationale  string  `json:"rationale,omitempty"`
+}
+
+const allowListRule = "description is allow-listed"
+const einRule = "record lists a reporting plan of a requested EIN"
+
+// pendingFile is an in_network_files element waiting for its llm answers.
+type pendingFile struct {
+	description     string
+	location        string
+	eins            []string
+	naiveMatch      bool
+	regionCodeMatch bool
+	allowListMatch  bool
+	einMatch        bool
+	path            string
+	offset          int64
+	source          json.RawMessage
+	planCode        string
+	header          *IndexHeader
+}
+
+// newPendingFile decides the allow-list, keyword and region code signals of
+// an in_network_files element.
+func (e *Extractor) newPendingFile(description string, location string) pendingFile {
+	lowerDesc := strings.ToLower(description)
+	pending := pendingFile{
+		description:    description,
+		location:       location,
+		allowListMatch: e.isPpoPlan(lowerDesc),
+		naiveMatch:     e.keywords.Match(lowerDesc),
+	}
+	planCode, err := ExtractPlanCode(location)
+	if err == nil {
+		if _, exists := e.regionCodes[strings.ToLower(planCode)]; exists {
+			pending.regionCodeMatch = true
+			pending.planCode = planCode
+		}
+	}
+	return pending
+}
+
+// checkInNetworkFiles reads the in_network_files of a record as pending
+// files, queued by queueAnalysis once the record's eins are known.
+func (e *Extractor) checkInNetworkFiles(dec *json.Decoder) ([]pendingFile, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read in_network_files value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("in_network_files is not an array")
+	}
+
+	var files []pendingFile
+
+	for i := 0; dec.More(); i++ {
+		var inNetworkFile struct {
+			Description string `json:"description"`
+			Location    string `json:"location"`
+			// raw so dates of unexpected types do not fail the element
+			Version             json.RawMessage `json:"version"`
+			ReportingEntityName json.RawMessage `json:"reporting_entity_name"`
+			ReportingEntityType json.RawMessage `json:"reporting_entity_type"`
+			LastUpdatedOn       json.RawMessage `json:"last_updated_on"`
+		}
+		source := e.elementSource(i, dec.InputOffset())
+		raw, ok, err := e.decodeRawElement(dec, source.Path, &inNetworkFile)
+		if err != nil {
+			return nil, fmt.Errorf("decode plan: %w", err)
+		} else if !ok {
+			continue
+		}
+		inNetworkFile.Description = e.cutDescription(inNetworkFile.Description)
+
+		if e.noteDrugFile(inNetworkFile.Description, inNetworkFile.Location) || e.noteTOCFile(inNetworkFile.Location) {
+			continue
+		}
+		if e.urlPatterns {
+			e.addURLPattern(inNetworkFile.Location)
+		}
+
+		lowerDesc := strings.ToLower(inNetworkFile.Description)
+		e.descriptions[lowerDesc] = struct{}{}
+		if e.isDenied(lowerDesc) {
+			continue
+		}
+
+		pending := e.newPendingFile(inNetworkFile.Description, inNetworkFile.Location)
+		pending.path = source.Path
+		pending.offset = source.Offset
+		pending.source = raw
+		header := IndexHeader{
+			Version:             headerString(inNetworkFile.Version),
+			ReportingEntityName: headerString(inNetworkFile.ReportingEntityName),
+			ReportingEntityType: headerString(inNetworkFile.ReportingEntityType),
+			LastUpdatedOn:       headerString(inNetworkFile.LastUpdatedOn),
+		}
+		if header != (IndexHeader{}) {
+			pending.header = &header
+		}
+		files = append(files, pending)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("close reporting_plans array: %w", err)
+	}
+
+	return files, nil
+}
+
+// queueAnalysis queues the files of a record for classification with the
+// eins of its reporting plans, which may come before or after them.
+func (e *Extractor) queueAnalysis(files []pendingFile, eins []string) {
+	einMatch := e.einListed(eins)
+	for _, pending := range files {
+		pending.eins = eins
+		pending.einMatch = einMatch
+
+		e.pending = append(e.pending, pending)
+		if _, cached := e.llmCache.get(e.keywords.planTypeQuestion(), pending.description); !cached {
+			e.uncached[pending.description] = struct{}{}
+		}
+		if e.llm == nil || e.llmBatchSize <= 1 || len(e.uncached) >= e.llmBatchSize {
+			e.flushAnalysis(context.Background())
+		}
+	}
+}
+
+// flushAnalysis classifies the descriptions of the pending files, in batches
+// when a batch size is set, and reports their matches in order. With
+// llmWorkers the files are queued to the llm pipeline instead, and
+// waitAnalysis reports them.
+func (e *Extractor) flushAnalysis(ctx context.Context) {
+	var descriptions []string
+	if e.llm != nil && e.llmBatchSize > 1 && len(e.uncached) > 1 {
+		descriptions = make([]string, 0, len(e.uncached))
+		for _, pending := range e.pending {
+			if _, ok := e.uncached[pending.description]; ok {
+				descriptions = append(descriptions, pending.description)
+				delete(e.uncached, pending.description)
+			}
+		}
+	}
+	clear(e.uncached)
+	if len(e.pending) == 0 {
+		return
+	}
+
+	if e.llm != nil && e.llmWorkers > 1 {
+		if e.llmPipeline == nil {
+			e.llmPipeline = e.startLLMPipeline()
+		}
+		e.llmPipeline.submit(llmBatch{ctx: ctx, pending: e.pending, descriptions: descriptions})
+		// the queued batch keeps the slice
+		e.pending = nil
+		return
+	}
+	for _, m := range e.classifyPending(ctx, e.pending, descriptions) {
+		e.reportMatch(m)
+	}
+	e.pending = e.pending[:0]
+}
+
+// reportMatch counts m and passes it on.
+func (e *Extractor) reportMatch(m Match) {
+	e.matchCount++
+	e.onMatch(m)
+}
+
+// classifyPending asks the llm about the pending files, descriptions in
+// batches first, and returns the matches among them in order. It only reads
+// e, so llm workers run it concurrently.
+func (e *Extractor) classifyPending(ctx context.Context, pendingFiles []pendingFile, descriptions []string) []Match {
+	e.classifyBatches(ctx, descriptions)
+
+	var matches []Match
+	for _, pending := range pendingFiles {
+		if m := e.classifyFile(ctx, pending); m.Score > 0 && m.Score >= e.minScore {
+			m.PlanType = e.planTypeOf(ctx, pending.description)
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// classifyBatches asks about descriptions llmBatchSize at a time.
+func (e *Extractor) classifyBatches(ctx context.Context, descriptions []string) {
+	for start := 0; start < len(descriptions); start += e.llmBatchSize {
+		end := min(start+e.llmBatchSize, len(descriptions))
+		// descriptions the batch leaves unanswered are asked one by one
+		e.classifyBatch(ctx, descriptions[start:end])
+	}
+}
+
+// classifyFile asks the llm about the description of pending, unless the
+// answers are cached, and scores the file on all its signals. The plan type
+// is left to the caller, it may take another llm call.
+func (e *Extractor) classifyFile(ctx context.Context, pending pendingFile) Match {
+	aiMatch := false
+	aiConfidence := 0.0
+	evidence := &Evidence{Path: pending.path, Source: pending.source, Rules: []string{}}
+	if pending.naiveMatch {
+		evidence.Rules = append(evidence.Rules, e.keywords.rule())
+	}
+	if pending.regionCodeMatch {
+		evidence.Rules = append(evidence.Rules, fmt.Sprintf("plan code %s of the location is a region code of the state", pending.planCode))
+	}
+	if pending.allowListMatch {
+		evidence.Rules = append(evidence.Rules, allowListRule)
+	}
+	if pending.einMatch {
+		evidence.Rules = append(evidence.Rules, einRule)
+	}
+
+	stateQuestion, statePrompt := e.keywords.stateQuestion(), e.prompts.state
+	inState, err := e.doLlmQuery(ctx, stateQuestion, pending.description, statePrompt)
+	if err == nil {
+		aiConfidence = inState.confidence
+		evidence.LLM = append(evidence.LLM, llmEvidence(stateQuestion, statePrompt, inState))
+	}
+	if err == nil && inState.value {
+		planTypeQuestion, planTypePrompt := e.keywords.planTypeQuestion(), e.prompts.planType
+		isPlanType, err := e.doLlmQuery(ctx, planTypeQuestion, pending.description, planTypePrompt)
+		if err == nil {
+			aiConfidence = isPlanType.confidence
+			evidence.LLM = append(evidence.LLM, llmEvidence(planTypeQuestion, planTypePrompt, isPlanType))
+		}
+		if err == nil && isPlanType.value {
+			aiMatch = true
+			aiConfidence = min(inState.confidence, isPlanType.confidence)
+		}
+	}
+
+	values := map[Signal]float64{
+		SignalAllowList:  boolValue(pending.allowListMatch),
+		SignalRegionCode: boolValue(pending.regionCodeMatch),
+		SignalKeyword:    boolValue(pending.naiveMatch),
+		SignalEIN:        boolValue(pending.einMatch),
+	}
+	if aiMatch {
+		// models that give no confidence count fully
+		values[SignalLLM] = aiConfidence
+		if aiConfidence == 0 {
+			values[SignalLLM] = 1
+		}
+	}
+	score, signals := e.score(values)
+
+	var rationales []string
+	for _, answer := range evidence.LLM {
+		if answer.Rationale != "" {
+			rationales = append(rationales, answer.Rationale)
+		}
+	}
+
+	m := Match{
+		Description:     pending.description,
+		Location:        pending.location,
+		Eins:            pending.eins,
+		AIMatch:         aiMatch,
+		AIConfidence:    aiConfidence,
+		AIRationale:     strings.Join(rationales, " "),
+		HeuristicMatch:  pending.naiveMatch,
+		RegionCodeMatch: pending.regionCodeMatch,
+		Header:          pending.header,
+		Score:           score,
+		Signals:         signals,
+		Evidence:        evidence,
+	}
+	if e.sourcePaths {
+		m.Path, m.Offset = pending.path, pending.offset
+	}
+	return m
+}
+
+func boolValue(hit bool) float64 {
+	if hit {
+		return 1
+	}
+	return 0
+}
+
+func llmEvidence(question string, prompt string, answer llmAnswer) LLMAnswer {
+	return LLMAnswer{Question: question, Prompt: strings.TrimSpace(prompt), Answer: answer.value, Confidence: answer.confidence, Rationale: answer.rationale}
+}
+
+// classifyBatch asks both analysis questions about several descriptions in
+// one call and caches the answers it could parse.
+func (e *Extractor) classifyBatch(ctx context.Context, descriptions []string) {
+	var input strings.Builder
+	for i, description := range descriptions {
+		fmt.Fprintf(&input, "%d. %s\n", i+1, description)
+	}
+
+	response, err := e.generate(ctx, e.prompts.batch, input.String())
+	e.observer.LLMCall(false, err)
+	if err != nil {
+		return
+	}
+	answers, ok := jsonObject(response)
+	if !ok {
+		return
+	}
+
+	for i, description := range descriptions {
+		var questions struct {
+			State    json.RawMessage `json:"state"`
+			PlanType json.RawMessage `json:"planType"`
+		}
+		if err := json.Unmarshal(answers[strconv.Itoa(i+1)], &questions); err != nil {
+			continue
+		}
+		inState, ok := decodeQuestion(questions.State)
+		if !ok {
+			continue
+		}
+		isPlanType, ok := decodeQuestion(questions.PlanType)
+		if !ok {
+			continue
+		}
+		e.llmCache.put(e.keywords.stateQuestion(), description, inState)
+		e.llmCache.put(e.keywords.planTypeQuestion(), description, isPlanType)
+	}
+}
+
+// doLlmQuery asks the llm the named prompt about description, answers are
+// cached under promptName so failures are retried but answers are not. An
+// answer that cannot be read counts as no with no confidence.
+func (e *Extractor) doLlmQuery(ctx context.Context, promptName string, description string, prompt string) (llmAnswer, error) {
+	if e.llm == nil {
+		return llmAnswer{}, errors.New("no llm configured")
+	}
+	if answer, ok := e.llmCache.get(promptName, description); ok {
+		e.observer.LLMCall(true, nil)
+		return answer, nil
+	}
+
+	aiResponse, err := e.generate(ctx, prompt, description)
+	e.observer.LLMCall(false, err)
+	if err != nil {
+		return llmAnswer{}, err
+	}
+
+	answer, _ := parseAnswer(aiResponse)
+	e.llmCache.put(promptName, description, answer)
+
+	return answer, nil
+}
+
+// generate prefers the JSON mode of clients that have one.
+func (e *Extractor) generate(ctx context.Context, system string, input string) (string, error) {
+	if client, ok := e.llm.(JSONClient); ok {
+		return client.GenerateJSON(ctx, system, input)
+	}
+	return e.llm.Generate(ctx, system, input)
+}
@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// llmAnswer is a yes or no answer with the model's confidence in it, from 0
+// to 1, or 0 when it gave none, and the one sentence rationale it gave for
+// it, if any.
+type llmAnswer struct {
+	value      bool
+	confidence float64
+	rationale  string
+}
+
+var answerWordPattern = regexp.MustCompile(`\b(true|false|yes|no)\b`)
+var confidencePattern = regexp.MustCompile(`confidence\W*([0-9]*\.?[0-9]+\s*%?)`)
+
+// parseAnswer reads the answer object wherever it is in response, models
+// like to wrap json in prose or code fences. Without one the first true,
+// false, yes or no decides, with a confidence when one is mentioned.
+func parseAnswer(response string) (llmAnswer, bool) {
+	if fields, ok := jsonObject(response); ok {
+		if answer, ok := decodeAnswer(fields); ok {
+			return answer, true
+		}
+	}
+
+	lower := strings.ToLower(response)
+	word := answerWordPattern.FindString(lower)
+	if word == "" {
+		return llmAnswer{}, false
+	}
+	answer := llmAnswer{value: word == "true" || word == "yes"}
+	if m := confidencePattern.FindStringSubmatch(lower); m != nil {
+		answer.confidence = parseConfidence(m[1])
+	}
+	return answer, true
+}
+
+// jsonObject decodes the outermost braces of response.
+func jsonObject(response string) (map[string]json.RawMessage, bool) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response[start:end+1]), &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+// decodeAnswer accepts {"answer": ..., "confidence": ..., "rationale": ...}
+// with the answer as a boolean or a true, false, yes or no string. Models
+// asked for a rationale sometimes call it a reason.
+func decodeAnswer(fields map[string]json.RawMessage) (llmAnswer, bool) {
+	var answer llmAnswer
+	if !decodeBool(fields["answer"], &answer.value) {
+		return llmAnswer{}, false
+	}
+	answer.confidence = decodeConfidence(fields["confidence"])
+	answer.rationale = decodeRationale(fields["rationale"])
+	if answer.rationale == "" {
+		answer.rationale = decodeRationale(fields["reason"])
+	}
+	return answer, true
+}
+
+// decodeRationale reads a string, "" for anything else.
+func decodeRationale(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+// decodeQuestion reads one question of a batch answer, either an answer
+// object or a bare boolean.
+func decodeQuestion(raw json.RawMessage) (llmAnswer, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) == nil {
+		return decodeAnswer(fields)
+	}
+	var answer llmAnswer
+	return answer, decodeBool(raw, &answer.value)
+}
+
+func decodeBool(raw json.RawMessage, value *bool) bool {
+	if json.Unmarshal(raw, value) == nil {
+		return true
+	}
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "true", "yes":
+		*value = true
+	case "false", "no":
+		*value = false
+	default:
+		return false
+	}
+	return true
+}
+
+func decodeConfidence(raw json.RawMessage) float64 {
+	var text string
+	if json.Unmarshal(raw, &text) != nil {
+		text = string(raw)
+	}
+	return parseConfidence(text)
+}
+
+// parseConfidence reads a fraction or a percentage, 80% and 80 both being
+// 0.8, and clamps it to 0 to 1.
+func parseConfidence(text string) float64 {
+	text = strings.TrimSpace(text)
+	percent := strings.HasSuffix(text, "%")
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, "%")), 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+	if percent || value > 1 {
+		value /= 100
+	}
+	return min(value, 1)
+}
@@ -0,0 +1,97 @@
+package extract
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LLMCache remembers llm answers per prompt and description so the same
+// description is only classified once, across files and goroutines.
+type LLMCache struct {
+	mu      sync.Mutex
+	answers map[string]cachedAnswer
+}
+
+type cachedAnswer struct {
+	answer llmAnswer
+	added  time.Time
+}
+
+func NewLLMCache() *LLMCache {
+	return &LLMCache{answers: make(map[string]cachedAnswer)}
+}
+
+func (c *LLMCache) get(prompt string, description string) (answer llmAnswer, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.answers[prompt+"\x00"+description]
+	return cached.answer, ok
+}
+
+func (c *LLMCache) put(prompt string, description string, answer llmAnswer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.answers[prompt+"\x00"+description] = cachedAnswer{answer: answer, added: time.Now()}
+}
+
+// Len returns the number of cached answers.
+func (c *LLMCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.answers)
+}
+
+// Prune drops answers added before cutoff, unless it is zero, and then the
+// oldest answers beyond maxEntries, unless it is 0. It returns how many were
+// dropped.
+func (c *LLMCache) Prune(cutoff time.Time, maxEntries int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	before := len(c.answers)
+	if !cutoff.IsZero() {
+		for key, cached := range c.answers {
+			if cached.added.Before(cutoff) {
+				delete(c.answers, key)
+			}
+		}
+	}
+
+	if maxEntries > 0 && len(c.answers) > maxEntries {
+		keys := make([]string, 0, len(c.answers))
+		for key := range c.answers {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return c.answers[keys[i]].added.After(c.answers[keys[j]].added)
+		})
+		for _, key := range keys[maxEntries:] {
+			delete(c.answers, key)
+		}
+	}
+
+	return before - len(c.answers)
+}
+
+// DedupStore is a set shared between extractors, so results found in several
+// index files are only reported once.
+type DedupStore struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func NewDedupStore() *DedupStore {
+	return &DedupStore{seen: make(map[string]struct{})}
+}
+
+// Add records key and reports whether it was new.
+func (d *DedupStore) Add(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.seen[key]; exists {
+		return false
+	}
+	d.seen[key] = struct{}{}
+	return true
+}
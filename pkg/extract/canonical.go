@@ -0,0 +1,45 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+
+	"serif_interview/pkg/download"
+)
+
+// CanonicalLocation is the identity of the file at location, the same for
+// every signed url of it: the signature and expiry parameters, the fragment
+// and a default port are dropped, the scheme and host lowercased and the
+// remaining parameters sorted. Locations that are not absolute urls are
+// returned as is.
+func CanonicalLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return location
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+	u.User = nil
+	u.Fragment = ""
+
+	query := u.Query()
+	for name := range query {
+		if download.IsSignatureParam(name) {
+			query.Del(name)
+		}
+	}
+	// Encode sorts the parameters
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,189 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Carrier adapts the index files of one payer that deviate from the CMS
+// schema. With a carrier set, keys are compared in snake case whatever case
+// the payer wrote them in, a root array wrapping the index objects is
+// accepted and the keys a payer renamed are mapped back.
+type Carrier struct {
+	Name string
+	// Match detects the carrier from the index filename or url and from the
+	// reporting_entity_name.
+	Match *regexp.Regexp
+	// Keys map the snake case keys of the payer to their CMS name.
+	Keys map[string]string
+	// PartKeys are root keys listing further parts of a multi-part index,
+	// followed like nested table of contents files.
+	PartKeys []string
+}
+
+// Carriers are the adapters -carrier selects by name and auto detection
+// tries in order.
+var Carriers = []*Carrier{
+	{
+		Name:     "uhc",
+		Match:    regexp.MustCompile(`(?i)united ?health|\buhc\b|\boptum\b`),
+		Keys:     map[string]string{"reporting_structures": "reporting_structure"},
+		PartKeys: []string{"index_parts"},
+	},
+	{
+		Name:  "aetna",
+		Match: regexp.MustCompile(`(?i)\baetna\b`),
+		Keys: map[string]string{
+			"in_network_file": "in_network_files",
+			"reporting_plan":  "reporting_plans",
+		},
+	},
+	{
+		Name:  "cigna",
+		Match: regexp.MustCompile(`(?i)\bcigna\b`),
+		Keys:  map[string]string{"in_network_rate_files": "in_network_files"},
+	},
+	{
+		Name:     "anthem",
+		Match:    regexp.MustCompile(`(?i)\banthem\b|\belevance\b|\bempire blue`),
+		PartKeys: []string{"index_file_parts", "additional_index_files"},
+	},
+}
+
+// CarrierByName returns the adapter named name.
+func CarrierByName(name string) (*Carrier, error) {
+	for _, c := range Carriers {
+		if strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+	}
+	names := make([]string, 0, len(Carriers))
+	for _, c := range Carriers {
+		names = append(names, c.Name)
+	}
+	return nil, fmt.Errorf("unknown carrier %q, expected one of %v", name, names)
+}
+
+// DetectCarrier returns the first adapter matching s, a filename, url or
+// reporting entity name, nil when none does.
+func DetectCarrier(s string) *Carrier {
+	for _, c := range Carriers {
+		if c.Match.MatchString(s) {
+			return c
+		}
+	}
+	return nil
+}
+
+// key returns the CMS name of a key as the carrier writes it.
+func (c *Carrier) key(key string) string {
+	key = snakeCase(key)
+	if mapped, ok := c.Keys[key]; ok {
+		return mapped
+	}
+	return key
+}
+
+func (c *Carrier) isPartKey(key string) bool {
+	for _, part := range c.PartKeys {
+		if key == part {
+			return true
+		}
+	}
+	return false
+}
+
+// snakeCase turns inNetworkFiles, In-Network-Files and IN_NETWORK_FILES
+// into in_network_files.
+func snakeCase(key string) string {
+	plain := true
+	for _, r := range key {
+		if unicode.IsUpper(r) || r == '-' || r == ' ' {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return key
+	}
+
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			// a word starts at an upper case rune after a lower case one
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// key maps a key read from the index to its CMS name under the carrier.
+// Until auto detection picks one keys are only put in snake case, so the
+// reporting_entity_name it detects from is found in any case.
+func (e *Extractor) key(key string) string {
+	switch {
+	case e.carrier != nil:
+		return e.carrier.key(key)
+	case e.detect:
+		return snakeCase(key)
+	}
+	return key
+}
+
+// Carrier returns the adapter in use, nil for the CMS layout.
+func (e *Extractor) Carrier() *Carrier {
+	return e.carrier
+}
+
+// detectCarrier picks the adapter matching s when auto detection is on and
+// none was picked yet.
+func (e *Extractor) detectCarrier(s string) {
+	if !e.detect || e.carrier != nil {
+		return
+	}
+	e.carrier = DetectCarrier(s)
+}
+
+// adaptElement rewrites the keys of an element to their CMS names, objects
+// nested in it included.
+func (e *Extractor) adaptElement(raw json.RawMessage) (json.RawMessage, error) {
+	if e.carrier == nil {
+		return raw, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(e.adaptValue(v))
+}
+
+func (e *Extractor) adaptValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		adapted := make(map[string]any, len(v))
+		for key, value := range v {
+			adapted[e.carrier.key(key)] = e.adaptValue(value)
+		}
+		return adapted
+	case []any:
+		for i, element := range v {
+			v[i] = e.adaptValue(element)
+		}
+	}
+	return v
+}
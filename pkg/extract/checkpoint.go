@@ -0,0 +1,210 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Checkpoint records how many reporting_structure records of an index file
+// a parse has finished and everything it collected from them, so an
+// interrupted parse can resume with Options.Resume. Compressed streams
+// cannot be seeked into, the resumed parse decodes the finished records
+// again but skips matching them, which is where the hours go. Gzip files
+// with a seek index are the exception, see Options.GzipIndex: Offset is
+// where the resumed parse starts decompressing.
+//
+// Raw analysis mode matches of the finished records were passed to OnMatch
+// before the checkpoint was taken and are not repeated. Merged matches wait
+// for the end of the file and are kept in Merged instead, without their
+// Evidence.
+type Checkpoint struct {
+	File      string `json:"file"`
+	Mode      Mode   `json:"mode"`
+	Records   int    `json:"records"`
+	BytesRead int64  `json:"bytesRead"`
+	// Offset is where the record after the finished ones starts in the
+	// decompressed file, 0 when it is not known
+	Offset    int64       `json:"offset,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Header    IndexHeader `json:"header"`
+
+	PpoPrices    []string          `json:"ppoPrices,omitempty"`
+	Plans        []PlanSummary     `json:"plans,omitempty"`
+	Descriptions []string          `json:"descriptions,omitempty"`
+	Coverage     []PlanCoverage    `json:"coverage,omitempty"`
+	DrugFiles    map[string]string `json:"drugFiles,omitempty"`
+	TOCFiles     []string          `json:"tocFiles,omitempty"`
+	FuzzyMatches []FuzzyMatch      `json:"fuzzyMatches,omitempty"`
+	Shards       *ShardIndex       `json:"shards"`
+	Merged       []Match           `json:"merged,omitempty"`
+	Counts       *indexCounts      `json:"counts,omitempty"`
+	Matches      int               `json:"matches"`
+	Quarantined  int               `json:"quarantined"`
+	Skipped      int               `json:"skipped,omitempty"`
+	Denied       int               `json:"denied,omitempty"`
+	URLClusters  []URLCluster      `json:"urlClusters,omitempty"`
+	RecordErrors []RecordError     `json:"recordErrors,omitempty"`
+
+	EmbeddingMatches []FuzzyMatch `json:"embeddingMatches,omitempty"`
+}
+
+// checkpointer calls onCheckpoint at most every interval while the records
+// of the file passed to ParseFile are parsed.
+type checkpointer struct {
+	file         string
+	interval     time.Duration
+	onCheckpoint func(Checkpoint)
+	last         time.Time
+	// active is false while nested table of contents files are parsed, a
+	// resume parses those again from their start
+	active bool
+}
+
+// recordDone is called after the first records records are merged into e,
+// the last ending at end of the decoder's input, and takes a checkpoint when
+// one is due.
+func (e *Extractor) recordDone(records int, end int64) {
+	c := e.checkpoints
+	if c == nil || !c.active || time.Since(c.last) < c.interval {
+		return
+	}
+	c.last = time.Now()
+	c.onCheckpoint(e.checkpoint(records, end))
+}
+
+func (e *Extractor) checkpoint(records int, end int64) Checkpoint {
+	// pending analysis files belong to finished records
+	e.flushAnalysis(context.Background())
+	e.waitAnalysis()
+
+	cp := Checkpoint{
+		File:         e.checkpoints.file,
+		Mode:         e.mode,
+		Records:      records,
+		BytesRead:    e.progress.read.Load(),
+		CreatedAt:    time.Now().UTC(),
+		Header:       e.header,
+		PpoPrices:    slices.Sorted(maps.Values(e.uniquePpoPrices)),
+		Plans:        e.PlanSummaries(),
+		Descriptions: slices.Sorted(maps.Keys(e.descriptions)),
+		Coverage:     e.Coverage(),
+		DrugFiles:    maps.Clone(e.drugFiles),
+		TOCFiles:     slices.Sorted(maps.Keys(e.tocFiles)),
+		FuzzyMatches: e.FuzzyMatches(),
+		Shards:       NewShardIndex(),
+		Matches:      e.matchCount,
+		Quarantined:  e.quarantinedCount,
+		Skipped:      e.skipped,
+		Denied:       e.denied,
+		URLClusters:  e.URLClusters(),
+		RecordErrors: slices.Clone(e.recordErrors),
+
+		EmbeddingMatches: e.EmbeddingMatches(),
+	}
+	cp.Shards.Merge(e.shards)
+	if e.seekable {
+		cp.Offset = e.streamBase + end
+	}
+	if e.merger != nil {
+		cp.Merged = slices.Clone(e.merger.matches)
+	}
+	if e.mode == ModeStats {
+		cp.Counts = e.counts.snapshot()
+	}
+	return cp
+}
+
+// restore loads the results of cp into a new Extractor.
+func (e *Extractor) restore(cp *Checkpoint) {
+	e.header = cp.Header
+	for _, location := range cp.PpoPrices {
+		e.addPpoPrice(location, Source{})
+	}
+	for _, summary := range cp.Plans {
+		e.mergePlan(summary)
+	}
+	for _, description := range cp.Descriptions {
+		e.descriptions[description] = struct{}{}
+	}
+	for _, coverage := range cp.Coverage {
+		e.addCoverage([]ReportingPlan{coverage.Plan}, coverage.Networks)
+	}
+	maps.Copy(e.drugFiles, cp.DrugFiles)
+	for _, location := range cp.TOCFiles {
+		e.tocFiles[location] = struct{}{}
+	}
+	for _, match := range cp.FuzzyMatches {
+		e.fuzzyScores[match.Description] = match
+	}
+	for _, match := range cp.EmbeddingMatches {
+		e.embeddingScores[match.Description] = match
+	}
+	if cp.Shards != nil {
+		e.shards.Merge(cp.Shards)
+	}
+	if e.merger != nil {
+		for _, m := range cp.Merged {
+			e.merger.hold(CanonicalLocation(m.Location), m)
+		}
+	}
+	if cp.Counts != nil {
+		e.mergeCounts(cp.Counts)
+	}
+	e.matchCount = cp.Matches
+	e.quarantinedCount = cp.Quarantined
+	e.denied = cp.Denied
+	for _, cluster := range cp.URLClusters {
+		e.addURLCluster(cluster)
+	}
+	e.skipped, e.recordErrors = cp.Skipped, slices.Clone(cp.RecordErrors)
+	e.skipRecords, e.resumeOffset = cp.Records, cp.Offset
+}
+
+// skipResumed decodes the records a resumed checkpoint already finished
+// without matching them.
+func (e *Extractor) skipResumed(dec *json.Decoder) error {
+	for e.recordIndex+1 < e.skipRecords && dec.More() {
+		var discard json.RawMessage
+		if err := dec.Decode(&discard); err != nil {
+			return fmt.Errorf("skip resumed reporting_structure element: %w", err)
+		}
+		e.recordIndex++
+		e.progress.records.Add(1)
+	}
+	return nil
+}
+
+// MarshalJSON writes the shards seen per network and the totals their
+// names state.
+func (s *ShardIndex) MarshalJSON() ([]byte, error) {
+	seen := make(map[string][]int, len(s.seen))
+	for network, indexes := range s.seen {
+		seen[network] = slices.Sorted(maps.Keys(indexes))
+	}
+	return json.Marshal(shardIndexJSON{Seen: seen, Totals: s.totals})
+}
+
+func (s *ShardIndex) UnmarshalJSON(data []byte) error {
+	var v shardIndexJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = *NewShardIndex()
+	for network, indexes := range v.Seen {
+		s.seen[network] = make(map[int]struct{}, len(indexes))
+		for _, index := range indexes {
+			s.seen[network][index] = struct{}{}
+		}
+	}
+	maps.Copy(s.totals, v.Totals)
+	return nil
+}
+
+type shardIndexJSON struct {
+	Seen   map[string][]int `json:"seen"`
+	Totals map[string]int   `json:"totals"`
+}
@@ -0,0 +1,58 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"serif_interview/pkg/download"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// decompress picks the decompressor from the magic bytes at the start of r,
+// whatever the file is named, and enforces limits on what it produces.
+// Anything else is read as plain JSON.
+func decompress(r io.Reader, limits download.Limits) (io.ReadCloser, error) {
+	compressed := &download.CountingReader{R: r}
+	br := bufio.NewReader(compressed)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip stream: %w", err)
+		}
+		return readCloser{Reader: limits.Reader(gr, compressed), Closer: gr}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open zstd stream: %w", err)
+		}
+		zrc := zr.IOReadCloser()
+		return readCloser{Reader: limits.Reader(zrc, compressed), Closer: zrc}, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return io.NopCloser(limits.Reader(bzip2.NewReader(br), compressed)), nil
+	default:
+		return io.NopCloser(limits.Reader(br, nil)), nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
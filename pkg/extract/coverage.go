@@ -0,0 +1,93 @@
+package extract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ReportingPlan is an element of a record's reporting_plans array.
+type ReportingPlan struct {
+	Name       string `json:"plan_name"`
+	IDType     string `json:"plan_id_type"`
+	ID         string `json:"plan_id"`
+	MarketType string `json:"plan_market_type"`
+}
+
+// NetworkFile is a matched in_network_files element, the description names
+// the network.
+type NetworkFile struct {
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	// source is where the file was matched, with Options.SourcePaths
+	source Source
+}
+
+// PlanCoverage lists the matched network files a reporting plan uses.
+type PlanCoverage struct {
+	Plan     ReportingPlan `json:"plan"`
+	Networks []NetworkFile `json:"networks"`
+}
+
+func (e *Extractor) readReportingPlans(dec *json.Decoder) ([]ReportingPlan, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read reporting_plans value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("reporting_plans is not an array")
+	}
+
+	var plans []ReportingPlan
+	for i := 0; dec.More(); i++ {
+		var plan ReportingPlan
+		path := fmt.Sprintf("/reporting_structure/%d/reporting_plans/%d", e.recordIndex, i)
+		if ok, err := e.decodeElement(dec, path, &plan); err != nil {
+			return nil, fmt.Errorf("decode reporting plan: %w", err)
+		} else if !ok {
+			continue
+		}
+		plans = append(plans, plan)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("close reporting_plans element: %w", err)
+	}
+
+	return plans, nil
+}
+
+func (e *Extractor) addCoverage(plans []ReportingPlan, networks []NetworkFile) {
+	for _, plan := range plans {
+		key := plan.IDType + "\x00" + plan.ID + "\x00" + plan.Name
+		coverage, exists := e.coverage[key]
+		if !exists {
+			coverage = &PlanCoverage{Plan: plan}
+			e.coverage[key] = coverage
+		}
+		for _, network := range networks {
+			key := CanonicalLocation(network.Location)
+			if !slices.ContainsFunc(coverage.Networks, func(n NetworkFile) bool { return CanonicalLocation(n.Location) == key }) {
+				coverage.Networks = append(coverage.Networks, network)
+			}
+		}
+	}
+}
+
+// Coverage returns the reporting plans of the records with coverage mode
+// matches, ordered by plan id, each with the matched files of its records.
+func (e *Extractor) Coverage() []PlanCoverage {
+	result := make([]PlanCoverage, 0, len(e.coverage))
+	for _, coverage := range e.coverage {
+		result = append(result, *coverage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Plan.ID != result[j].Plan.ID {
+			return result[i].Plan.ID < result[j].Plan.ID
+		}
+		return result[i].Plan.Name < result[j].Plan.Name
+	})
+	return result
+}
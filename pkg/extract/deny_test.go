@@ -0,0 +1,66 @@
+package extract_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+// TestDenyList checks that deny-listed descriptions are left out of the
+// matches of every mode, and counted, with record workers too.
+func TestDenyList(t *testing.T) {
+	index := synth.Index(goldenIndex)
+	analyze := func(opts extract.Options) ([]extract.Match, extract.Stats) {
+		var matches []extract.Match
+		opts.Mode = extract.ModeAnalysis
+		opts.OnMatch = func(m extract.Match) { matches = append(matches, m) }
+		e := extract.New(opts)
+		if err := e.Parse(bytes.NewReader(index)); err != nil {
+			t.Fatal(err)
+		}
+		return matches, e.Stats()
+	}
+
+	matches, _ := analyze(extract.Options{})
+	if len(matches) == 0 {
+		t.Fatal("no analysis matches")
+	}
+	denied := strings.ToLower(matches[0].Description)
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			deny := extract.Options{
+				Workers:      workers,
+				DenyPlans:    map[string]struct{}{denied: {}},
+				DenyPatterns: []*regexp.Regexp{regexp.MustCompile(`(?i)\bdental\b`)},
+			}
+			matches, stats := analyze(deny)
+			for _, m := range matches {
+				for _, description := range m.Descriptions {
+					if lower := strings.ToLower(description); lower == denied || strings.Contains(lower, "dental") {
+						t.Errorf("deny-listed %q matched %s", description, m.Location)
+					}
+				}
+			}
+			if stats.Denied == 0 {
+				t.Error("no denied elements counted")
+			}
+
+			deny.Mode = extract.ModeUniquePlans
+			e := extract.New(deny)
+			if err := e.Parse(bytes.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+			for _, plan := range e.UniquePlans() {
+				if plan == denied {
+					t.Errorf("deny-listed %q is a unique plan", plan)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// drugFilePattern recognises prescription drug files by their description or
+// location, some payers list them in in_network_files.
+var drugFilePattern = regexp.MustCompile(`(?i)prescription|pharmac|\bndc\b|drug|[_\-/ ]rx[_\-. ]`)
+
+// isDrugFileKey reports reporting_structure keys payers use for prescription
+// drug files outside the schema, e.g. prescription_drug_file.
+func isDrugFileKey(key string) bool {
+	lower := strings.ToLower(key)
+	return strings.Contains(lower, "prescription") || strings.Contains(lower, "drug")
+}
+
+// noteDrugFile records the file when it looks like a prescription drug file
+// and reports whether it did.
+func (e *Extractor) noteDrugFile(description string, location string) bool {
+	if !drugFilePattern.MatchString(description) && !drugFilePattern.MatchString(location) {
+		return false
+	}
+	e.drugFiles[location] = description
+	return true
+}
+
+// collectDrugFiles records every object with a location inside the value of
+// a drug file key, whatever its exact shape.
+func (e *Extractor) collectDrugFiles(dec *json.Decoder, key string) error {
+	return collectLocations(dec, key, func(description string, location string) {
+		e.drugFiles[location] = description
+	})
+}
+
+// collectLocations calls found for every object with a location inside the
+// next value of dec.
+func collectLocations(dec *json.Decoder, key string, found func(description string, location string)) error {
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("decode %s: %w", key, err)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, element := range v {
+				walk(element)
+			}
+		case map[string]any:
+			if location, ok := v["location"].(string); ok {
+				description, _ := v["description"].(string)
+				found(description, location)
+				return
+			}
+			for _, element := range v {
+				walk(element)
+			}
+		}
+	}
+	walk(value)
+	return nil
+}
+
+// DrugFiles returns the prescription drug files the index lists, ordered by
+// location. They are never matched as rate files.
+func (e *Extractor) DrugFiles() []NetworkFile {
+	result := make([]NetworkFile, 0, len(e.drugFiles))
+	for location, description := range e.drugFiles {
+		result = append(result, NetworkFile{Description: description, Location: location})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Location < result[j].Location })
+	return result
+}
@@ -0,0 +1,41 @@
+package extract_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// TestUniquePlansSkipNonRateFiles checks that unique plans mode lists
+// neither prescription drug files, nor table of contents files, nor the
+// generic description some payers give every file, with record workers too.
+func TestUniquePlansSkipNonRateFiles(t *testing.T) {
+	index := `{"reporting_structure":[{"reporting_plans":[],"in_network_files":[
+		{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"},
+		{"description":"prescription drug pricing","location":"https://example.com/2026-01_301_71A0_prescription-drugs.json.gz"},
+		{"description":"pharmacy network","location":"https://example.com/2026-01_301_71A0_rates.json.gz"},
+		{"description":"excellus bcbs : nested","location":"https://example.com/2026-01_table-of-contents.json"},
+		{"description":"In-Network Negotiated Rates Files","location":"https://example.com/2026-01_302_42B0_in-network-rates.json.gz"}
+	]}]}`
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			e := extract.New(extract.Options{Mode: extract.ModeUniquePlans, Workers: workers})
+			if err := e.Parse(strings.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+			if plans := e.UniquePlans(); !slices.Equal(plans, []string{"excellus bcbs : blueppo"}) {
+				t.Errorf("unique plans %q, want only the rate file plan", plans)
+			}
+			var drugs []string
+			for _, file := range e.DrugFiles() {
+				drugs = append(drugs, file.Description)
+			}
+			if !slices.Equal(drugs, []string{"prescription drug pricing", "pharmacy network"}) {
+				t.Errorf("drug files %q, want both drug files", drugs)
+			}
+		})
+	}
+}
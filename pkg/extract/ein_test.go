@@ -0,0 +1,48 @@
+package extract_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// TestEINSignal checks that analysis mode scores the eins of a record
+// whether its reporting_plans come before or after its in_network_files,
+// with record workers too.
+func TestEINSignal(t *testing.T) {
+	files := `"in_network_files":[{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"}]`
+	plans := `"reporting_plans":[{"plan_id_type":"EIN","plan_id":"12-3456789"},{"plan_id_type":"HIOS","plan_id":"12345NY0010001"}]`
+	for _, order := range []string{"plans first", "files first"} {
+		record := "{" + plans + "," + files + "}"
+		if order == "files first" {
+			record = "{" + files + "," + plans + "}"
+		}
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", order, workers), func(t *testing.T) {
+				var matches []extract.Match
+				e := extract.New(extract.Options{
+					Mode:    extract.ModeAnalysis,
+					EINs:    map[string]struct{}{"123456789": {}},
+					Workers: workers,
+					OnMatch: func(m extract.Match) { matches = append(matches, m) },
+				})
+				if err := e.Parse(strings.NewReader(`{"reporting_structure":[` + record + `]}`)); err != nil {
+					t.Fatal(err)
+				}
+				if len(matches) != 1 {
+					t.Fatalf("got %d matches, want 1", len(matches))
+				}
+				m := matches[0]
+				if !slices.Equal(m.Eins, []string{"12-3456789"}) {
+					t.Errorf("eins %v, want [12-3456789]", m.Eins)
+				}
+				if i := slices.IndexFunc(m.Signals, func(s extract.SignalScore) bool { return s.Signal == extract.SignalEIN }); i < 0 || m.Signals[i].Value != 1 {
+					t.Errorf("ein signal not scored: %+v", m.Signals)
+				}
+			})
+		}
+	}
+}
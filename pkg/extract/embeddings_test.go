@@ -0,0 +1,137 @@
+package extract_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"serif_interview/pkg/embed"
+	"serif_interview/pkg/extract"
+
+	_ "modernc.org/sqlite"
+)
+
+// networkEmbedder places a description by the network it names, blue ppo
+// and blueppo being one, its plan type pulling it apart a little.
+type networkEmbedder struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (n *networkEmbedder) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	n.calls.Add(1)
+	if n.err != nil {
+		return nil, n.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := []float32{0, 0, 0}
+		if strings.Contains(text, "blue ppo") || strings.Contains(text, "blueppo") {
+			vector[0] = 1
+		}
+		if strings.Contains(text, "hmo") {
+			vector[0], vector[1] = 0.8, 0.6
+		}
+		if strings.Contains(text, "dental") {
+			vector[2] = 1
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// TestEmbeddingAllowList checks that descriptions close enough to an
+// allow-listed name by embedding match, with record workers too, and that a
+// later run finds them stored.
+func TestEmbeddingAllowList(t *testing.T) {
+	index := `{"reporting_structure":[{"reporting_plans":[],"in_network_files":[
+		{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"},
+		{"description":"Excellus Blue PPO Network","location":"https://example.com/2026-01_302_42B0_in-network-rates.json.gz"},
+		{"description":"Excellus Blue PPO HMO","location":"https://example.com/2026-01_254_39B0_in-network-rates.json.gz"},
+		{"description":"Excellus Dental","location":"https://example.com/2026-01_800_72A0_in-network-rates.json.gz"}
+	]}]}`
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "embeddings.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	embedder := &networkEmbedder{}
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			store, err := embed.Open(db, embedder, embed.Options{Model: "nomic-embed-text"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := extract.New(extract.Options{
+				PpoPlans:           map[string]struct{}{"excellus bcbs : blueppo": {}},
+				Embeddings:         store,
+				EmbeddingThreshold: 0.9,
+				Workers:            workers,
+			})
+			if err := e.Parse(strings.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+
+			locations := e.PpoPrices()
+			slices.Sort(locations)
+			want := []string{"https://example.com/2026-01_301_71A0_in-network-rates.json.gz", "https://example.com/2026-01_302_42B0_in-network-rates.json.gz"}
+			if !slices.Equal(locations, want) {
+				t.Errorf("matched %q, want the allow-listed network and its other spelling", locations)
+			}
+			matches := e.EmbeddingMatches()
+			if len(matches) != 1 || matches[0] != (extract.FuzzyMatch{Description: "excellus blue ppo network", Plan: "excellus bcbs : blueppo", Score: 1}) {
+				t.Errorf("embedding matches %+v, want only the other spelling, the hmo too far", matches)
+			}
+		})
+	}
+	// the second run embedded nothing
+	if calls := embedder.calls.Load(); calls != 4 {
+		t.Errorf("%d embedding calls, want the name and 3 descriptions once", calls)
+	}
+}
+
+func TestEmbeddingAllowListFailing(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "embeddings.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store, err := embed.Open(db, &networkEmbedder{err: errors.New("connection refused")}, embed.Options{Model: "nomic-embed-text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the allow-list and keywords still match without the embedder
+	var matches []extract.Match
+	e := extract.New(extract.Options{
+		Mode:               extract.ModeAnalysis,
+		PpoPlans:           map[string]struct{}{"excellus bcbs : blueppo": {}},
+		Embeddings:         store,
+		EmbeddingThreshold: 0.9,
+		OnMatch:            func(m extract.Match) { matches = append(matches, m) },
+	})
+	index := `{"reporting_structure":[{"reporting_plans":[],"in_network_files":[
+		{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates.json.gz"},
+		{"description":"Excellus Blue PPO Network","location":"https://example.com/2026-01_302_42B0_in-network-rates.json.gz"}
+	]}]}`
+	if err := e.Parse(strings.NewReader(index)); err != nil {
+		t.Fatal(err)
+	}
+	allowListed := func(m extract.Match) bool {
+		i := slices.IndexFunc(m.Signals, func(s extract.SignalScore) bool { return s.Signal == extract.SignalAllowList })
+		return i >= 0 && m.Signals[i].Value == 1
+	}
+	if len(matches) != 2 || !allowListed(matches[0]) || allowListed(matches[1]) {
+		t.Errorf("matches %+v, want only the listed name allow-listed", matches)
+	}
+	if len(e.EmbeddingMatches()) != 0 {
+		t.Errorf("embedding matches %+v without embeddings", e.EmbeddingMatches())
+	}
+}
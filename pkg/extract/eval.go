@@ -0,0 +1,252 @@
+package extract
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Label is a hand-labeled plan description of an evaluation set, Match
+// saying whether it is a plan of the state and plan type of the keyword
+// rules. Location is optional, without one the region code signal is never
+// set.
+type Label struct {
+	Description string
+	Location    string
+	Match       bool
+}
+
+// ReadLabels reads a csv evaluation set with a header naming a description
+// and a match column, and optionally a location column. Match is true, yes,
+// y or 1 for a match and false, no, n, 0 or empty otherwise.
+func ReadLabels(r io.Reader) ([]Label, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read labels header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	descriptionColumn, ok := columns["description"]
+	if !ok {
+		return nil, errors.New("labels have no description column")
+	}
+	matchColumn, ok := columns["match"]
+	if !ok {
+		return nil, errors.New("labels have no match column")
+	}
+	locationColumn, hasLocation := columns["location"]
+
+	var labels []Label
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read labels: %w", err)
+		}
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		label := Label{Description: field(descriptionColumn)}
+		if label.Description == "" {
+			continue
+		}
+		switch strings.ToLower(field(matchColumn)) {
+		case "true", "yes", "y", "1":
+			label.Match = true
+		case "false", "no", "n", "0", "":
+		default:
+			line, _ := cr.FieldPos(matchColumn)
+			return nil, fmt.Errorf("labels line %d: match %q is not true or false", line, field(matchColumn))
+		}
+		if hasLocation {
+			label.Location = field(locationColumn)
+		}
+		labels = append(labels, label)
+	}
+	if len(labels) == 0 {
+		return nil, errors.New("no labels")
+	}
+	return labels, nil
+}
+
+// WriteLabels writes labels in the layout ReadLabels reads.
+func WriteLabels(w io.Writer, labels []Label) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"description", "match", "location"})
+	for _, label := range labels {
+		cw.Write([]string{label.Description, strconv.FormatBool(label.Match), label.Location})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Classifiers of an Evaluation.
+const (
+	// ClassifierHeuristics is the heuristics mode rule, an allow-listed
+	// description with a region code of the state, or naming the state
+	// when it has no region codes.
+	ClassifierHeuristics = "heuristics"
+	// ClassifierKeyword is the keyword signal, the description naming the
+	// state and the plan type.
+	ClassifierKeyword = "keyword"
+	// ClassifierLLM is the llm answering yes to both questions.
+	ClassifierLLM = "llm"
+	// ClassifierScore is analysis mode reporting the file, a score above 0
+	// and at least Options.MinScore.
+	ClassifierScore = "score"
+)
+
+// ClassifierResult compares the answers of one classifier to the labels.
+// Precision, Recall and F1 are 0 when undefined.
+type ClassifierResult struct {
+	Classifier     string  `json:"classifier"`
+	TruePositives  int     `json:"truePositives"`
+	FalsePositives int     `json:"falsePositives"`
+	FalseNegatives int     `json:"falseNegatives"`
+	TrueNegatives  int     `json:"trueNegatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+// Evaluation is how well each classifier agrees with an evaluation set.
+type Evaluation struct {
+	Labels      int                `json:"labels"`
+	Positives   int                `json:"positives"`
+	Classifiers []ClassifierResult `json:"classifiers"`
+}
+
+// Disagreement is a label that any classifier got wrong, Wrong naming them,
+// with the signals and llm answers of its description.
+type Disagreement struct {
+	Description     string      `json:"description"`
+	Location        string      `json:"location,omitempty"`
+	Label           bool        `json:"label"`
+	Wrong           []string    `json:"wrong"`
+	Score           float64     `json:"score"`
+	AIMatch         bool        `json:"aiMatch"`
+	AIConfidence    float64     `json:"aiConfidence"`
+	AIRationale     string      `json:"aiRationale,omitempty"`
+	HeuristicMatch  bool        `json:"heuristicMatch"`
+	RegionCodeMatch bool        `json:"regionCodeMatch"`
+	LLM             []LLMAnswer `json:"llm,omitempty"`
+}
+
+// Evaluate classifies the labeled descriptions like analysis mode, with the
+// rules, prompts, weights and llm of the Extractor, and compares each
+// classifier to the labels. The llm classifier is left out without an llm.
+func (e *Extractor) Evaluate(ctx context.Context, labels []Label) (Evaluation, []Disagreement) {
+	classifiers := []string{ClassifierHeuristics, ClassifierKeyword, ClassifierLLM, ClassifierScore}
+	if e.llm == nil {
+		classifiers = slices.DeleteFunc(classifiers, func(c string) bool { return c == ClassifierLLM })
+	}
+	results := make(map[string]*ClassifierResult, len(classifiers))
+	for _, c := range classifiers {
+		results[c] = &ClassifierResult{Classifier: c}
+	}
+
+	pendingFiles := make([]pendingFile, len(labels))
+	seen := make(map[string]struct{})
+	var descriptions []string
+	for i, label := range labels {
+		pendingFiles[i] = e.newPendingFile(label.Description, label.Location)
+		if _, ok := seen[label.Description]; !ok {
+			seen[label.Description] = struct{}{}
+			descriptions = append(descriptions, label.Description)
+		}
+	}
+	if e.llm != nil && e.llmBatchSize > 1 {
+		e.classifyBatches(ctx, descriptions)
+	}
+
+	evaluation := Evaluation{Labels: len(labels)}
+	var disagreements []Disagreement
+	for i, label := range labels {
+		if ctx.Err() != nil {
+			break
+		}
+		if label.Match {
+			evaluation.Positives++
+		}
+		m := e.classifyFile(ctx, pendingFiles[i])
+		predictions := map[string]bool{
+			ClassifierHeuristics: e.heuristicMatch(pendingFiles[i]),
+			ClassifierKeyword:    m.HeuristicMatch,
+			ClassifierLLM:        m.AIMatch,
+			ClassifierScore:      m.Score > 0 && m.Score >= e.minScore,
+		}
+
+		var wrong []string
+		for _, c := range classifiers {
+			r := results[c]
+			switch predicted := predictions[c]; {
+			case predicted && label.Match:
+				r.TruePositives++
+			case predicted:
+				r.FalsePositives++
+			case label.Match:
+				r.FalseNegatives++
+			default:
+				r.TrueNegatives++
+			}
+			if predictions[c] != label.Match {
+				wrong = append(wrong, c)
+			}
+		}
+		if len(wrong) > 0 {
+			disagreements = append(disagreements, Disagreement{
+				Description:     label.Description,
+				Location:        label.Location,
+				Label:           label.Match,
+				Wrong:           wrong,
+				Score:           m.Score,
+				AIMatch:         m.AIMatch,
+				AIConfidence:    m.AIConfidence,
+				AIRationale:     m.AIRationale,
+				HeuristicMatch:  m.HeuristicMatch,
+				RegionCodeMatch: m.RegionCodeMatch,
+				LLM:             m.Evidence.LLM,
+			})
+		}
+	}
+
+	for _, c := range classifiers {
+		r := results[c]
+		if predicted := r.TruePositives + r.FalsePositives; predicted > 0 {
+			r.Precision = roundScore(float64(r.TruePositives) / float64(predicted))
+		}
+		if actual := r.TruePositives + r.FalseNegatives; actual > 0 {
+			r.Recall = roundScore(float64(r.TruePositives) / float64(actual))
+		}
+		if r.TruePositives > 0 {
+			r.F1 = roundScore(float64(2*r.TruePositives) / float64(2*r.TruePositives+r.FalsePositives+r.FalseNegatives))
+		}
+		evaluation.Classifiers = append(evaluation.Classifiers, *r)
+	}
+	return evaluation, disagreements
+}
+
+// heuristicMatch is whether heuristics mode takes the file of pending.
+func (e *Extractor) heuristicMatch(pending pendingFile) bool {
+	lowerDesc := strings.ToLower(pending.description)
+	if !pending.allowListMatch || !e.passesPlanFilter(lowerDesc) {
+		return false
+	}
+	if len(e.regionCodes) == 0 {
+		return e.keywords.NamesState(lowerDesc)
+	}
+	return pending.regionCodeMatch
+}
@@ -0,0 +1,67 @@
+package extract_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// nyAnswers says yes to both questions about descriptions mentioning ny.
+type nyAnswers struct{}
+
+func (nyAnswers) Generate(ctx context.Context, system string, input string) (string, error) {
+	if strings.Contains(strings.ToLower(input), "ny") {
+		return `{"answer": true, "confidence": 0.9}`, nil
+	}
+	return `{"answer": false, "confidence": 0.9}`, nil
+}
+
+// TestEvaluate checks the counts and scores of the classifiers against a
+// labeled set, and that only descriptions some classifier got wrong are
+// listed as disagreements.
+func TestEvaluate(t *testing.T) {
+	labels, err := extract.ReadLabels(strings.NewReader(`description,match
+New York PPO,true
+Empire NY plan,yes
+Choice PPO of Buffalo,true
+New Jersey PPO,no
+NYC HMO,0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := extract.New(extract.Options{Mode: extract.ModeAnalysis, LLM: nyAnswers{}})
+	evaluation, disagreements := e.Evaluate(context.Background(), labels)
+	if evaluation.Labels != 5 || evaluation.Positives != 3 {
+		t.Fatalf("got %d labels and %d positives, want 5 and 3", evaluation.Labels, evaluation.Positives)
+	}
+
+	want := map[string]extract.ClassifierResult{
+		// New York PPO only
+		extract.ClassifierKeyword: {TruePositives: 1, FalseNegatives: 2, TrueNegatives: 2, Precision: 1, Recall: 0.333333, F1: 0.5},
+		// the ny descriptions, NYC HMO wrongly
+		extract.ClassifierLLM: {TruePositives: 1, FalsePositives: 1, FalseNegatives: 2, TrueNegatives: 1, Precision: 0.5, Recall: 0.333333, F1: 0.4},
+	}
+	for _, got := range evaluation.Classifiers {
+		w, ok := want[got.Classifier]
+		if !ok {
+			continue
+		}
+		w.Classifier = got.Classifier
+		if got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+
+	for _, d := range disagreements {
+		if d.Description == "New Jersey PPO" {
+			t.Errorf("New Jersey PPO listed as a disagreement: %+v", d)
+		}
+	}
+	if len(disagreements) != 4 {
+		t.Errorf("got %d disagreements, want 4", len(disagreements))
+	}
+}
@@ -0,0 +1,1051 @@
+// Package extract streams CMS Transparency-in-Coverage index files and
+// picks out the in-network rate files of interest, New York PPO plans by
+// default.
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"serif_interview/pkg/blob"
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/embed"
+	"serif_interview/pkg/fuzzy"
+)
+
+// Mode selects what the Extractor collects from in_network_files.
+type Mode string
+
+const (
+	// ModeHeuristics collects rate file locations matching the plan
+	// allow-list and region codes.
+	ModeHeuristics Mode = "heuristics"
+	// ModeUniquePlans collects every distinct plan description.
+	ModeUniquePlans Mode = "uniquePlans"
+	// ModeAnalysis reports naive, region code and LLM matches for exploration.
+	ModeAnalysis Mode = "analysis"
+	// ModeCoverage is ModeHeuristics also collecting which reporting plans
+	// each matched file belongs to, see Coverage.
+	ModeCoverage Mode = "coverage"
+	// ModeStats only counts what the index holds, see IndexStats.
+	ModeStats Mode = "stats"
+)
+
+// Options configures an Extractor. The zero value runs heuristics mode with
+// the built-in New York PPO allow-list and no guards.
+type Options struct {
+	Mode Mode
+
+	// PpoPlans and RegionCodes override DefaultPpoPlans and the
+	// DefaultRegionsByState codes of the Keywords state, keys must be
+	// lowercase.
+	PpoPlans    map[string]struct{}
+	RegionCodes map[string]struct{}
+	// Keywords are the state and plan type of interest, nil uses
+	// DefaultKeywordRules. DefaultPpoPlans only applies to PPO plans, other
+	// plan types are allow-listed by name without PpoPlans. Without region
+	// codes heuristics mode takes descriptions naming the state instead.
+	Keywords *KeywordRules
+	// PlanPatterns additionally allow-list descriptions matching any of
+	// these expressions, see LoadPlanList.
+	PlanPatterns []*regexp.Regexp
+	// DenyPlans and DenyPatterns exclude descriptions from the results of
+	// every mode but stats even when the allow-list, the keywords or the llm
+	// would match them, such as dental or behavioral health carve-outs, see
+	// LoadPlanList. Stats counts the in_network_files elements they excluded.
+	DenyPlans    map[string]struct{}
+	DenyPatterns []*regexp.Regexp
+	// EINs limits heuristics and coverage mode matches to the records with a
+	// reporting plan of one of these employer identification numbers, keys
+	// as returned by NormalizeEIN. nil matches every record.
+	EINs map[string]struct{}
+	// PlanFilter narrows unique plans and heuristics and coverage mode
+	// matches to the lowercase descriptions it matches, nil keeps all.
+	PlanFilter *regexp.Regexp
+	// FuzzyThreshold also allow-lists descriptions whose fuzzy.Similarity to
+	// an allow-listed name is at least this, from 0 to 1. 0 disables it,
+	// names only match exactly.
+	FuzzyThreshold float64
+	// Embeddings with EmbeddingThreshold also allow-list descriptions whose
+	// embedding has at least this cosine similarity to that of an
+	// allow-listed name, from 0 to 1. Descriptions are embedded once per
+	// store, see embed.Store. 0 disables it.
+	Embeddings         *embed.Store
+	EmbeddingThreshold float64
+
+	// Weights override DefaultWeights of the analysis mode signals, and
+	// analysis mode only reports files scoring at least MinScore. Files
+	// without any signal are never reported.
+	Weights  map[Signal]float64
+	MinScore float64
+
+	// LLM is consulted in analysis mode, nil skips the llm checks. The llm
+	// package provides the clients.
+	LLM LLMClient
+	// LLMCache may be shared between extractors, nil uses a private cache.
+	LLMCache *LLMCache
+	// LLMBatchSize asks the llm about this many distinct descriptions per
+	// call, falling back to one call per description for those the batched
+	// answer misses. 0 or 1 asks about each description separately.
+	LLMBatchSize int
+	// LLMWorkers classify analysis mode files on this many goroutines while
+	// the index is read on, reporting the matches in index order. 0 or 1
+	// asks the llm between records. Record Workers ask the llm themselves
+	// and ignore it.
+	LLMWorkers int
+	// PlanTypeLLM asks the llm the plan type of unique plans and analysis
+	// matches whose descriptions name none, see ClassifyPlanType.
+	PlanTypeLLM bool
+	// Prompts are the system prompts of the llm questions, nil uses
+	// DefaultPrompts. See LoadPrompts.
+	Prompts *Prompts
+
+	// MaxDepth and MaxStringLength guard against adversarial input, 0 disables.
+	MaxDepth        int
+	MaxStringLength int
+	// MaxDescriptionLength cuts plan descriptions to this many bytes as they
+	// are read, before they are matched or kept, 0 keeps them whole.
+	MaxDescriptionLength int
+	// MaxSkippedFieldSize streams the fields the parser has no use for token
+	// by token instead of buffering each whole, and fails with a LimitError
+	// once one is larger than this many bytes. 0 buffers them.
+	MaxSkippedFieldSize int
+	// MaxMatches bounds the merged analysis matches held until the file is
+	// parsed. Past it the match whose location was first seen longest ago is
+	// passed to OnMatch early, and a later record listing that location
+	// starts a new match. 0 holds every match of the file.
+	MaxMatches int
+	// Limits bound the decompressed size and expansion ratio of index files,
+	// and of nested table of contents files.
+	Limits download.Limits
+
+	// Quarantine receives elements with unexpected types as json lines
+	// instead of aborting the parse, nil aborts.
+	Quarantine io.Writer
+	// KeepGoing skips reporting_structure records that cannot be read or
+	// matched, keeping a RecordError for each, instead of aborting the
+	// parse. A document that is not valid JSON still stops it where it
+	// breaks.
+	KeepGoing bool
+	// SourcePaths sets the JSON pointer and offset of the in_network_files
+	// element each analysis match was first found at, and keeps those of
+	// heuristics matches for Source and FileSets.
+	SourcePaths bool
+	// URLPatterns clusters the locations of the in_network_files elements
+	// analysis mode reads by host, path and filename convention, see
+	// URLClusters.
+	URLPatterns bool
+
+	// OnMatch receives analysis mode matches. The matches of a location are
+	// merged over the records listing it and passed once the file is parsed,
+	// with RawMatches each is passed as it is found.
+	OnMatch    func(Match)
+	RawMatches bool
+
+	// Carrier adapts the index layout of a payer deviating from the CMS
+	// schema, with DetectCarrier one is picked from the filename or the
+	// reporting_entity_name when Carrier is nil.
+	Carrier       *Carrier
+	DetectCarrier bool
+
+	// Workers is how many goroutines match reporting_structure records while
+	// the stream is decoded, 0 or 1 matches them inline. Results are merged
+	// in record order, so the output does not depend on it.
+	Workers int
+
+	// OnCheckpoint receives a Checkpoint of ParseFile's file at most every
+	// CheckpointInterval, after the record that passed it. Records of nested
+	// table of contents files are not checkpointed.
+	OnCheckpoint       func(Checkpoint)
+	CheckpointInterval time.Duration
+	// Resume continues the parse of the same file in the same mode from a
+	// checkpoint instead of its start.
+	Resume *Checkpoint
+	// GzipIndex lets Resume of a local gzip file seek to its first
+	// unfinished record through the seek index next to it, see gzindex.Path,
+	// instead of decompressing the finished records again.
+	GzipIndex bool
+
+	// Observer receives records, matches, llm calls and decompressed bytes
+	// for metrics, nil observes nothing.
+	Observer Observer
+}
+
+// LLMClient answers a system prompt about an input text. Analysis mode asks
+// for a JSON answer but tolerates prose around it or a plain true or false.
+type LLMClient interface {
+	Generate(ctx context.Context, system string, input string) (string, error)
+}
+
+// JSONClient is implemented by clients that can constrain a response to a
+// JSON object, such as ollama's format=json. Analysis mode prefers it.
+type JSONClient interface {
+	GenerateJSON(ctx context.Context, system string, input string) (string, error)
+}
+
+// IndexHeader holds the root level fields of an index file that identify
+// which schema and which reporting entity produced it, and the monthly drop
+// it belongs to by its last_updated_on date.
+type IndexHeader struct {
+	Version             string `json:"version"`
+	ReportingEntityName string `json:"reportingEntityName"`
+	ReportingEntityType string `json:"reportingEntityType"`
+	LastUpdatedOn       string `json:"lastUpdatedOn"`
+}
+
+// Extractor holds the settings and accumulated results of one or more parses.
+// It is not safe for concurrent use, run one Extractor per file and share an
+// LLMCache between them instead.
+type Extractor struct {
+	settings
+
+	llmPipeline *llmPipeline
+	merger      *matchMerger
+	// fuzzyScores caches the best similarity of every description checked
+	fuzzyScores map[string]FuzzyMatch
+	// embeddingScores caches the best embedding similarity of every
+	// description embedded
+	embeddingScores map[string]FuzzyMatch
+
+	header           IndexHeader
+	uniquePpoPrices  map[string]string // by CanonicalLocation
+	ppoSources       map[string]Source // by CanonicalLocation, with sourcePaths
+	urlClusters      map[URLPattern]*URLCluster
+	plansFound       map[string]*planStats
+	descriptions     map[string]struct{}
+	coverage         map[string]*PlanCoverage
+	drugFiles        map[string]string
+	tocFiles         map[string]struct{}
+	counts           *indexCounts
+	matchCount       int
+	shards           *ShardIndex
+	recordIndex      int
+	quarantinedCount int
+	skipped          int
+	denied           int
+	recordErrors     []RecordError
+	progress         progressCounter
+
+	checkpoints *checkpointer
+	resume      *Checkpoint
+	// skipRecords are the records of a resumed checkpoint, resumeOffset
+	// where the record after them starts
+	skipRecords  int
+	resumeOffset int64
+	// seekable is set once the root of the index is an object, the
+	// decompressed offsets of its records are then checkpointed, streamBase
+	// is the offset of the decoder's input
+	seekable   bool
+	streamBase int64
+	// failedAt is where the parse failed, for its ParseError
+	failedAt *Source
+
+	// pending are the analysis mode files waiting for a batch of llm answers
+	pending  []pendingFile
+	uncached map[string]struct{}
+}
+
+// settings are what New makes of the Options. The record workers match
+// records with Extractors of the same settings, so every option belongs here
+// rather than next to the results.
+type settings struct {
+	mode         Mode
+	ppoPlans     map[string]struct{}
+	planPatterns []*regexp.Regexp
+	denyPlans    map[string]struct{}
+	denyPatterns []*regexp.Regexp
+	regionCodes  map[string]struct{}
+	keywords     *KeywordRules
+	// planTypeNames allow-lists descriptions naming the plan type when no
+	// allow-list applies
+	planTypeNames bool
+	eins          map[string]struct{}
+	planFilter    *regexp.Regexp
+
+	fuzzyIndex     *fuzzy.Index
+	fuzzyThreshold float64
+
+	embeddings         *embed.Store
+	embeddingThreshold float64
+	// planEmbeddings are the embedded allow-listed names, shared with the
+	// record workers
+	planEmbeddings *planEmbeddings
+
+	weights  map[Signal]float64
+	minScore float64
+
+	llm          LLMClient
+	llmCache     *LLMCache
+	llmBatchSize int
+	llmWorkers   int
+	planTypeLLM  bool
+	prompts      renderedPrompts
+
+	maxDepth        int
+	maxStringLength int
+	maxDescription  int
+	maxSkippedField int
+	limits          download.Limits
+	quarantine      io.Writer
+	onMatch         func(Match)
+	observer        Observer
+	workers         int
+	keepGoing       bool
+	sourcePaths     bool
+	urlPatterns     bool
+	carrier         *Carrier
+	detect          bool
+
+	onCheckpoint       func(Checkpoint)
+	checkpointInterval time.Duration
+	gzipIndex          bool
+}
+
+func New(opts Options) *Extractor {
+	e := newExtractor(settings{
+		mode:            opts.Mode,
+		ppoPlans:        opts.PpoPlans,
+		planPatterns:    opts.PlanPatterns,
+		denyPlans:       opts.DenyPlans,
+		denyPatterns:    opts.DenyPatterns,
+		regionCodes:     opts.RegionCodes,
+		keywords:        opts.Keywords,
+		eins:            opts.EINs,
+		planFilter:      opts.PlanFilter,
+		fuzzyThreshold:  opts.FuzzyThreshold,
+		weights:         opts.Weights,
+		minScore:        opts.MinScore,
+		llm:             opts.LLM,
+		llmCache:        opts.LLMCache,
+		llmBatchSize:    opts.LLMBatchSize,
+		llmWorkers:      opts.LLMWorkers,
+		planTypeLLM:     opts.PlanTypeLLM,
+		maxDepth:        opts.MaxDepth,
+		maxStringLength: opts.MaxStringLength,
+		maxDescription:  opts.MaxDescriptionLength,
+		maxSkippedField: opts.MaxSkippedFieldSize,
+		limits:          opts.Limits,
+		quarantine:      opts.Quarantine,
+		onMatch:         opts.OnMatch,
+		observer:        opts.Observer,
+		workers:         opts.Workers,
+		keepGoing:       opts.KeepGoing,
+		sourcePaths:     opts.SourcePaths,
+		urlPatterns:     opts.URLPatterns,
+		carrier:         opts.Carrier,
+		detect:          opts.DetectCarrier,
+
+		onCheckpoint:       opts.OnCheckpoint,
+		checkpointInterval: opts.CheckpointInterval,
+		gzipIndex:          opts.GzipIndex,
+	})
+	e.resume = opts.Resume
+	if e.mode == "" {
+		e.mode = ModeHeuristics
+	}
+	if e.keywords == nil {
+		e.keywords = DefaultKeywordRules
+	}
+	if opts.Prompts == nil {
+		opts.Prompts = DefaultPrompts
+	}
+	e.prompts = renderPrompts(opts.Prompts, e.keywords)
+	if e.ppoPlans == nil && e.planPatterns == nil {
+		if e.keywords.PlanType == "PPO" {
+			e.ppoPlans = DefaultPpoPlans
+		} else {
+			e.planTypeNames = true
+		}
+	}
+	if e.regionCodes == nil {
+		e.regionCodes = RegionSet(DefaultRegionsByState[e.keywords.State])
+	}
+	if e.weights == nil {
+		e.weights = DefaultWeights
+	}
+	if e.fuzzyThreshold > 0 {
+		e.fuzzyIndex = fuzzy.NewIndex(slices.Collect(maps.Keys(e.ppoPlans)))
+	}
+	if opts.Embeddings != nil && opts.EmbeddingThreshold > 0 {
+		e.embeddings, e.embeddingThreshold = opts.Embeddings, opts.EmbeddingThreshold
+		e.planEmbeddings = &planEmbeddings{names: slices.Sorted(maps.Keys(e.ppoPlans))}
+	}
+	if e.llmCache == nil {
+		e.llmCache = NewLLMCache()
+	}
+	if e.onMatch == nil {
+		e.onMatch = func(Match) {}
+	}
+	if e.observer == nil {
+		e.observer = nopObserver{}
+	} else if e.mode == ModeAnalysis {
+		onMatch := e.onMatch
+		e.onMatch = func(m Match) {
+			e.observer.Matches(1)
+			onMatch(m)
+		}
+	}
+	if e.mode == ModeAnalysis && !opts.RawMatches {
+		e.merger = &matchMerger{emit: e.onMatch, index: make(map[string]int), max: opts.MaxMatches}
+		e.onMatch = e.mergeMatch
+	}
+	if e.resume != nil {
+		e.restore(e.resume)
+	}
+	return e
+}
+
+// newExtractor is an Extractor of settings without results yet.
+func newExtractor(s settings) *Extractor {
+	return &Extractor{
+		settings:        s,
+		fuzzyScores:     make(map[string]FuzzyMatch),
+		embeddingScores: make(map[string]FuzzyMatch),
+		uniquePpoPrices: make(map[string]string),
+		plansFound:      make(map[string]*planStats),
+		descriptions:    make(map[string]struct{}),
+		coverage:        make(map[string]*PlanCoverage),
+		drugFiles:       make(map[string]string),
+		tocFiles:        make(map[string]struct{}),
+		counts:          newIndexCounts(),
+		uncached:        make(map[string]struct{}),
+		shards:          NewShardIndex(),
+		recordIndex:     -1,
+	}
+}
+
+// ParseFile parses an index file, gzip, zstd or bzip2 compressed or plain
+// JSON. An http or https filename is streamed from the server without
+// storing it. Table of contents files the index references are parsed into
+// the same results.
+func (e *Extractor) ParseFile(filename string) error {
+	return e.ParseFileContext(context.Background(), filename)
+}
+
+// ParseFileContext is ParseFile stopping with ctx's error once ctx is done.
+func (e *Extractor) ParseFileContext(ctx context.Context, filename string) error {
+	if e.resume != nil {
+		if e.resume.File != filename || e.resume.Mode != e.mode {
+			return fmt.Errorf("checkpoint is for %s in %s mode, not %s in %s mode", e.resume.File, e.resume.Mode, filename, e.mode)
+		}
+		e.resume = nil
+	}
+	e.detectCarrier(filename)
+	if e.onCheckpoint != nil {
+		e.checkpoints = &checkpointer{file: filename, interval: e.checkpointInterval, onCheckpoint: e.onCheckpoint, last: time.Now()}
+	}
+	if err := e.parseFile(ctx, filename, map[string]struct{}{filename: {}}, 0); err != nil {
+		return err
+	}
+	e.flushMerged()
+	return nil
+}
+
+func (e *Extractor) parseFile(ctx context.Context, filename string, visited map[string]struct{}, depth int) error {
+	if depth == 0 {
+		r, src, err := e.openResumed(ctx, filename)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			if e.checkpoints != nil {
+				e.checkpoints.active = true
+			}
+			defer r.Close()
+			if err := e.parse(ctx, &observedReader{r: r, observer: e.observer}); err != nil {
+				return e.parseFailure(ctx, src, err)
+			}
+			e.streamBase = 0
+			return e.followTOCFiles(ctx, filename, visited, depth)
+		}
+	}
+
+	filestream, size, err := openStream(ctx, filename, e.limits)
+	if err != nil {
+		return err
+	}
+	defer filestream.Close()
+	e.startProgress(filename, size)
+	if e.checkpoints != nil {
+		e.checkpoints.active = depth == 0
+	}
+
+	counted := &progressReader{r: filestream, read: &e.progress.read}
+	r, err := decompress(&contextReader{ctx: ctx, r: counted}, e.limits)
+	if err != nil {
+		return e.parseFailure(ctx, counted, fmt.Errorf("read %s: %w", filename, err))
+	}
+	defer r.Close()
+
+	if err := e.parse(ctx, &observedReader{r: r, observer: e.observer}); err != nil {
+		return e.parseFailure(ctx, counted, err)
+	}
+	return e.followTOCFiles(ctx, filename, visited, depth)
+}
+
+// openStream opens a local file or streams a url or object store uri,
+// returning its size as stored when known.
+func openStream(ctx context.Context, filename string, limits download.Limits) (io.ReadCloser, int64, error) {
+	if blob.IsURI(filename) {
+		return blob.Open(ctx, filename)
+	}
+	if download.IsURL(filename) {
+		stream, err := download.New(download.Options{Limits: limits}).Stream(ctx, filename)
+		if err != nil {
+			return nil, 0, fmt.Errorf("open url stream: %s - %w", filename, err)
+		}
+		var size int64
+		if sized, ok := stream.(interface{ Size() int64 }); ok {
+			size = sized.Size()
+		}
+		return stream, size, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open file stream: %s - %w", filename, err)
+	}
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	return f, size, nil
+}
+
+// Parse parses an uncompressed index document from r. Nested table of
+// contents files are only followed by ParseFile. A malformed or truncated
+// document is an error, never a panic.
+func (e *Extractor) Parse(r io.Reader) error {
+	return e.ParseContext(context.Background(), r)
+}
+
+// ParseContext is Parse stopping with ctx's error once ctx is done.
+func (e *Extractor) ParseContext(ctx context.Context, r io.Reader) error {
+	if err := e.parse(ctx, r); err != nil {
+		return err
+	}
+	e.flushMerged()
+	return nil
+}
+
+func (e *Extractor) parse(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(newGuardReader(r, e.maxDepth, e.maxStringLength))
+	defer e.waitAnalysis()
+	e.failedAt = nil
+	err := e.parseIndexFile(ctx, dec)
+	if err != nil {
+		e.failAt("", dec.InputOffset())
+	}
+	return err
+}
+
+// ParseError reports an index file that was read but is not a table of
+// contents: invalid JSON, a document ending early, corrupt compression or a
+// value of the wrong shape. A file that could not be read, a size limit or
+// cancellation are other errors. Path is the JSON pointer of the
+// reporting_structure record the parse failed in, empty outside of one, and
+// Offset about where in the decompressed file it failed.
+type ParseError struct {
+	Err    error
+	Path   string
+	Offset int64
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseFailure wraps err of a parse of src in a ParseError unless reading src
+// failed, ctx is done or a size or structural limit was passed.
+func (e *Extractor) parseFailure(ctx context.Context, src *progressReader, err error) error {
+	var limitErr *download.LimitError
+	var guardErr *LimitError
+	var parseErr *ParseError
+	if ctx.Err() != nil || src.err != nil || errors.As(err, &limitErr) || errors.As(err, &guardErr) || errors.As(err, &parseErr) {
+		return err
+	}
+	parseErr = &ParseError{Err: err}
+	if e.failedAt != nil {
+		parseErr.Path, parseErr.Offset = e.failedAt.Path, e.failedAt.Offset
+	}
+	return parseErr
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Header returns the version and reporting entity of the last parsed index.
+func (e *Extractor) Header() IndexHeader {
+	return e.header
+}
+
+// PpoPrices returns the distinct locations matched in heuristics mode, one
+// signed url per file, see CanonicalLocation.
+func (e *Extractor) PpoPrices() []string {
+	result := make([]string, 0, len(e.uniquePpoPrices))
+	for _, location := range e.uniquePpoPrices {
+		result = append(result, location)
+	}
+	return result
+}
+
+// addPpoPrice records a heuristics match unless another url of the same file
+// was matched before, and with Options.SourcePaths where it was found.
+func (e *Extractor) addPpoPrice(location string, source Source) {
+	key := CanonicalLocation(location)
+	if _, seen := e.uniquePpoPrices[key]; !seen {
+		e.uniquePpoPrices[key] = location
+		if e.sourcePaths && source.Path != "" {
+			if e.ppoSources == nil {
+				e.ppoSources = make(map[string]Source)
+			}
+			e.ppoSources[key] = source
+		}
+	}
+}
+
+// UniquePlans returns the distinct lowercase descriptions seen in unique plans
+// mode, see PlanSummaries for how often each was seen.
+func (e *Extractor) UniquePlans() []string {
+	result := make([]string, 0, len(e.plansFound))
+	for k := range e.plansFound {
+		result = append(result, k)
+	}
+	return result
+}
+
+// FileSets groups the heuristics matches into logical network file sets,
+// noting shards that are missing from the index, and with
+// Options.SourcePaths where each location was found.
+func (e *Extractor) FileSets() []FileSet {
+	sets := GroupFileSets(e.PpoPrices())
+	for i := range sets {
+		sets[i].MissingShards = e.shards.Missing(sets[i].Network)
+		if e.sourcePaths {
+			for _, location := range sets[i].Locations {
+				source, _ := e.Source(location)
+				sets[i].Sources = append(sets[i].Sources, source)
+			}
+		}
+	}
+	return sets
+}
+
+// Stats summarises what the parses so far have seen.
+type Stats struct {
+	Records      int `json:"records"`
+	Descriptions int `json:"descriptions"`
+	Matches      int `json:"matches"`
+	Quarantined  int `json:"quarantined"`
+	Skipped      int `json:"skipped"`
+	Denied       int `json:"denied,omitempty"`
+}
+
+// Stats returns counts of reporting_structure records, distinct plan
+// descriptions, and results of the configured mode.
+func (e *Extractor) Stats() Stats {
+	stats := Stats{
+		Records:      e.recordIndex + 1,
+		Descriptions: len(e.descriptions),
+		Quarantined:  e.quarantinedCount,
+		Skipped:      e.skipped,
+		Denied:       e.denied,
+	}
+	switch e.mode {
+	case ModeHeuristics, ModeCoverage:
+		stats.Matches = len(e.uniquePpoPrices)
+	case ModeUniquePlans:
+		stats.Matches = len(e.plansFound)
+	case ModeAnalysis:
+		stats.Matches = e.matchCount
+	}
+	return stats
+}
+
+// Quarantined returns how many elements were written to the quarantine.
+func (e *Extractor) Quarantined() int {
+	return e.quarantinedCount
+}
+
+// parseIndexFile walks the JSON stream and collects
+// allowed_amount_file.location values for records that list the target plan
+// name. It stops with ctx's error between records once ctx is done.
+func (e *Extractor) parseIndexFile(ctx context.Context, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read root token: %w", err)
+	}
+	if d, ok := tok.(json.Delim); ok && d == '[' && (e.carrier != nil || e.detect) {
+		return e.parseIndexArray(ctx, dec)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return errors.New("expected root object")
+	}
+	e.seekable = true
+	if err := e.parseIndexObject(ctx, dec); err != nil {
+		return err
+	}
+	e.flushAnalysis(ctx)
+
+	return nil
+}
+
+// parseIndexArray parses a root array wrapping index objects, as if they
+// were one index.
+func (e *Extractor) parseIndexArray(ctx context.Context, dec *json.Decoder) error {
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read root array element: %w", err)
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '{' {
+			return errors.New("expected index objects in root array")
+		}
+		if err := e.parseIndexObject(ctx, dec); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close root array: %w", err)
+	}
+	e.flushAnalysis(ctx)
+
+	return nil
+}
+
+// parseIndexObject parses the keys of an index object after its '{'.
+func (e *Extractor) parseIndexObject(ctx context.Context, dec *json.Decoder) error {
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read root key: %w", err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return errors.New("unexpected non-string key at root")
+		}
+		key = e.key(key)
+
+		if _, isTOC := tocKeys[key]; isTOC || (e.carrier != nil && e.carrier.isPartKey(key)) {
+			err := collectLocations(dec, key, func(description string, location string) {
+				e.tocFiles[location] = struct{}{}
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if key != "reporting_structure" && !isHeaderKey(key) {
+			if err := e.skipValue(dec); err != nil {
+				return fmt.Errorf("skip field %q: %w", key, err)
+			}
+			continue
+		}
+		if key != "reporting_structure" {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return fmt.Errorf("read field %q: %w", key, err)
+			}
+			e.recordIndexHeader(key, value)
+			continue
+		}
+
+		if e.workers > 1 || e.keepGoing {
+			// records are decoded as a whole to skip a broken one
+			err = e.parseReportingStructureConcurrent(ctx, dec)
+		} else {
+			err = e.parseReportingStructure(ctx, dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close root object: %w", err)
+	}
+	return nil
+}
+
+// isHeaderKey reports whether the root key is one of the IndexHeader fields.
+func isHeaderKey(key string) bool {
+	switch key {
+	case "version", "reporting_entity_name", "reporting_entity_type", "last_updated_on":
+		return true
+	}
+	return false
+}
+
+func (e *Extractor) recordIndexHeader(key string, value json.RawMessage) {
+	var target *string
+	switch key {
+	case "version":
+		target = &e.header.Version
+	case "reporting_entity_name":
+		target = &e.header.ReportingEntityName
+	case "reporting_entity_type":
+		target = &e.header.ReportingEntityType
+	case "last_updated_on":
+		target = &e.header.LastUpdatedOn
+	default:
+		return
+	}
+
+	// non-string values are left empty, which shows up as drift on its own
+	*target = headerString(value)
+	if key == "reporting_entity_name" {
+		e.detectCarrier(*target)
+	}
+}
+
+// headerString is a string header field, "" when it is missing or not a
+// string.
+func headerString(value json.RawMessage) string {
+	var s string
+	if len(value) == 0 || json.Unmarshal(value, &s) != nil {
+		return ""
+	}
+	return s
+}
+
+func (e *Extractor) parseReportingStructure(ctx context.Context, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read reporting_structure value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("reporting_structure is not an array")
+	}
+	if err := e.skipResumed(dec); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read reporting_structure element: %w", err)
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '{' {
+			return errors.New("expected object in reporting_structure array")
+		}
+
+		e.recordIndex++
+		e.progress.records.Add(1)
+		start, before := time.Now(), len(e.uniquePpoPrices)
+		err = e.scanReportingRecord(dec)
+		if err != nil {
+			e.failAt(fmt.Sprintf("/reporting_structure/%d", e.recordIndex), dec.InputOffset())
+			return err
+		}
+		e.observeRecord(time.Since(start), before)
+		e.recordDone(e.recordIndex+1, dec.InputOffset())
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close reporting_structure array: %w", err)
+	}
+
+	return nil
+}
+
+func (e *Extractor) scanReportingRecord(dec *json.Decoder) error {
+	var eins []string
+	// heuristics matches count and coverage mode pairs them up once the
+	// whole record is read, either may come first
+	var plans []ReportingPlan
+	var networks []NetworkFile
+	// analysis mode files wait for the eins the same way
+	var files []pendingFile
+
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read reporting_structure key: %w", err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return errors.New("unexpected non-string key in reporting_structure element")
+		}
+		key = e.key(key)
+
+		switch key {
+		case "in_network_files":
+			switch e.mode {
+			case ModeUniquePlans:
+				err := e.getUniquePlans(dec)
+				if err != nil {
+					return err
+				}
+			case ModeAnalysis:
+				read, err := e.checkInNetworkFiles(dec)
+				if err != nil {
+					return err
+				}
+				files = append(files, read...)
+			case ModeHeuristics, ModeCoverage:
+				matched, err := e.getPpoPricesByHeuristics(dec)
+				if err != nil {
+					return err
+				}
+				networks = append(networks, matched...)
+			case ModeStats:
+				if err := e.countInNetworkFiles(dec); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown mode %q for reporting record", e.mode)
+			}
+		case "reporting_plans":
+			if e.mode == ModeAnalysis {
+				eins_2, err := e.processReportingPlan(dec)
+				if err != nil {
+					return err
+				}
+				eins = eins_2
+				break
+			}
+			if e.mode == ModeCoverage || e.mode == ModeStats || (e.mode == ModeHeuristics && e.eins != nil) {
+				plans, err = e.readReportingPlans(dec)
+				if err != nil {
+					return err
+				}
+				break
+			}
+			fallthrough
+		default:
+			if isDrugFileKey(key) {
+				if err := e.collectDrugFiles(dec, key); err != nil {
+					return err
+				}
+				break
+			}
+			if err := e.skipValue(dec); err != nil {
+				return fmt.Errorf("skip field %q: %w", key, err)
+			}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close reporting_structure element: %w", err)
+	}
+
+	if e.mode == ModeStats {
+		e.countPlans(plans)
+	}
+	if len(files) > 0 {
+		e.queueAnalysis(files, eins)
+	}
+	if len(networks) > 0 && e.hasEIN(plans) {
+		for _, network := range networks {
+			e.addPpoPrice(network.Location, network.source)
+		}
+		if e.mode == ModeCoverage {
+			e.addCoverage(plans, networks)
+		}
+	}
+
+	return nil
+}
+
+// hasEIN reports whether a record with these reporting plans passes the
+// EINs filter.
+func (e *Extractor) hasEIN(plans []ReportingPlan) bool {
+	if e.eins == nil {
+		return true
+	}
+	for _, plan := range plans {
+		if strings.EqualFold(plan.IDType, "ein") {
+			if _, ok := e.eins[NormalizeEIN(plan.ID)]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// einListed reports whether one of a record's eins is in Options.EINs.
+func (e *Extractor) einListed(eins []string) bool {
+	for _, ein := range eins {
+		if _, ok := e.eins[NormalizeEIN(ein)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeEIN strips the hyphen and any other formatting from an employer
+// identification number, payers publish both 12-3456789 and 123456789.
+func NormalizeEIN(ein string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, ein)
+}
+
+func (e *Extractor) processReportingPlan(dec *json.Decoder) ([]string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read reporting_plans value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("reporting_plans is not an array")
+	}
+
+	// distinct, in the order the plans list them
+	var eins []string
+	seen := make(map[string]struct{})
+
+	for i := 0; dec.More(); i++ {
+		var reportingPlan struct {
+			Type string `json:"plan_id_type"`
+			Id   string `json:"plan_id"`
+		}
+		path := fmt.Sprintf("/reporting_structure/%d/reporting_plans/%d", e.recordIndex, i)
+		if ok, err := e.decodeElement(dec, path, &reportingPlan); err != nil {
+			return nil, fmt.Errorf("decode reporting plan: %w", err)
+		} else if !ok {
+			continue
+		}
+
+		if _, dup := seen[reportingPlan.Id]; strings.ToLower(reportingPlan.Type) == "ein" && !dup {
+			seen[reportingPlan.Id] = struct{}{}
+			eins = append(eins, reportingPlan.Id)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("close reporting_plans element: %w", err)
+	}
+
+	return eins, nil
+}
@@ -0,0 +1,108 @@
+package extract_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+// go test ./pkg/extract -run '^$' -fuzz FuzzParseIndex -fuzztime 1m -fuzzminimizetime 5s
+// fuzzes the parser, shortening the minute spent minimizing every new input.
+
+func FuzzParseIndex(f *testing.F) {
+	small := synth.Options{Records: 3, FilesPerRecord: 2, Seed: 1}
+	f.Add(synth.Index(small))
+	for _, malformed := range synth.Malformations {
+		opts := small
+		opts.Malformed = malformed
+		f.Add(synth.Index(opts))
+	}
+	for _, seed := range []string{
+		``,
+		`{}`,
+		`[]`,
+		`[{"reporting_structure": []}]`,
+		`{"reporting_structure": [{"in_network_files": [{"description": "x", "location": "https://a/1_2_3_4.json"}]}]}`,
+		`{"reporting_structure": [{"reporting_plans": [{"plan_id": 1}], "in_network_files": {}}]}`,
+		`{"version": 1, "reporting_structure": [{`,
+		`{"table_of_contents": [{"location": "x"}], "reporting_structure": null}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	modes := []extract.Mode{extract.ModeHeuristics, extract.ModeUniquePlans, extract.ModeAnalysis, extract.ModeCoverage, extract.ModeStats}
+	f.Fuzz(func(t *testing.T, index []byte) {
+		for _, mode := range modes {
+			var quarantine bytes.Buffer
+			e := extract.New(extract.Options{Mode: mode, MaxDepth: 64, MaxStringLength: 1 << 16, Quarantine: &quarantine})
+			err := e.Parse(bytes.NewReader(index))
+			if err != nil {
+				continue
+			}
+			// a parse that succeeded read a whole document, whatever
+			// trailed it
+			dec := json.NewDecoder(bytes.NewReader(index))
+			var document json.RawMessage
+			if decodeErr := dec.Decode(&document); decodeErr != nil {
+				t.Fatalf("%s mode parsed invalid JSON without an error: %v", mode, decodeErr)
+			}
+			if stats := e.Stats(); stats.Records < 0 || stats.Matches < 0 {
+				t.Fatalf("%s mode stats %+v", mode, stats)
+			}
+		}
+	})
+}
+
+func FuzzExtractPlanCode(f *testing.F) {
+	for _, seed := range []string{
+		"https://cdn.example.com/2026-01_301_71A0_in-network-rates.json.gz",
+		"https://cdn.example.com/2026-01_301_71A0_in-network-rates_1_of_2.json.gz?X-Amz-Signature=abc",
+		"https://cdn.example.com/download?file=2026-01_254_39B0_in-network.json.gz",
+		"https://cdn.example.com/2026-01%255F301%255F71A0%255Frates.json",
+		"2026-01_800_72A0_rates.json",
+		"2026-01-01_UnitedHealthcare-of-New-York--Inc-_Insurer_Choice-Plus_CSP-8-A2_in-network-rates.json.gz",
+		"2026-01-01_cigna-health-life-insurance-company_national-oap_in-network-rates.json.gz",
+		"",
+		"_",
+		"%",
+		"https://a/__",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, location string) {
+		match, err := extract.MatchPlanCode(location)
+		code := match.Code
+		if err != nil {
+			if match != (extract.PlanCodeMatch{}) {
+				t.Fatalf("plan code %+v returned with error %v", match, err)
+			}
+			return
+		}
+		if code == "" || code != strings.ToLower(code) || strings.Contains(code, "/") {
+			t.Fatalf("plan code %q of %q is not a lowercase plan code", code, location)
+		}
+		if !slices.ContainsFunc(extract.PlanCodePatterns, func(p *extract.PlanCodePattern) bool { return p.Name == match.Pattern }) {
+			t.Fatalf("plan code %q matched unknown pattern %q", code, match.Pattern)
+		}
+		// the code comes from the location, possibly escaped twice
+		unescaped := strings.ToLower(location)
+		for range 3 {
+			if u, err := url.PathUnescape(unescaped); err == nil {
+				unescaped = u
+			}
+			if u, err := url.QueryUnescape(unescaped); err == nil {
+				unescaped = u
+			}
+		}
+		if !strings.Contains(unescaped, code) {
+			t.Fatalf("plan code %q is not in %q", code, location)
+		}
+	})
+}
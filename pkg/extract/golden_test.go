@@ -0,0 +1,191 @@
+package extract_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+// go test ./pkg/extract -run Golden -update rewrites the golden files after
+// an intended change of the results.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// goldenIndex is the synthetic index every mode is checked against.
+var goldenIndex = synth.Options{Records: 40, Seed: 1}
+
+// goldenRun is what a parse produced, as written to a golden file.
+type goldenRun struct {
+	Error        string                `json:"error,omitempty"`
+	Stats        extract.Stats         `json:"stats"`
+	Results      any                   `json:"results"`
+	Quarantine   []string              `json:"quarantine,omitempty"`
+	RecordErrors []extract.RecordError `json:"recordErrors,omitempty"`
+	DrugFiles    []extract.NetworkFile `json:"drugFiles,omitempty"`
+}
+
+func TestGolden(t *testing.T) {
+	index := synth.Index(goldenIndex)
+	modes := []extract.Mode{extract.ModeHeuristics, extract.ModeUniquePlans, extract.ModeAnalysis, extract.ModeCoverage, extract.ModeStats}
+	for _, mode := range modes {
+		golden := filepath.Join("testdata", "golden", string(mode)+".json")
+		// record workers must not change the results
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", mode, workers), func(t *testing.T) {
+				got := parseGolden(t, extract.Options{Mode: mode, Workers: workers}, index)
+				checkGolden(t, golden, got, workers == 1)
+			})
+		}
+	}
+}
+
+func TestGoldenMalformed(t *testing.T) {
+	for _, malformed := range synth.Malformations {
+		t.Run(string(malformed), func(t *testing.T) {
+			opts := goldenIndex
+			opts.Malformed = malformed
+			var quarantine bytes.Buffer
+			got := parseGolden(t, extract.Options{Mode: extract.ModeHeuristics, Quarantine: &quarantine}, synth.Index(opts))
+			for _, line := range strings.Split(strings.TrimSpace(quarantine.String()), "\n") {
+				if line != "" {
+					got.Quarantine = append(got.Quarantine, line)
+				}
+			}
+			if malformed != synth.WrongTypes && malformed != synth.MissingFields && got.Error == "" {
+				t.Errorf("parsing a %s index did not fail", malformed)
+			}
+			checkGolden(t, filepath.Join("testdata", "golden", "malformed-"+string(malformed)+".json"), got, true)
+		})
+	}
+}
+
+// TestGoldenKeepGoing checks that records with unexpected types are skipped
+// rather than failing the parse, in every mode and with record workers.
+func TestGoldenKeepGoing(t *testing.T) {
+	opts := goldenIndex
+	opts.Malformed = synth.WrongTypes
+	index := synth.Index(opts)
+	for _, mode := range []extract.Mode{extract.ModeHeuristics, extract.ModeAnalysis} {
+		golden := filepath.Join("testdata", "golden", "keep-going-"+string(mode)+".json")
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", mode, workers), func(t *testing.T) {
+				got := parseGolden(t, extract.Options{Mode: mode, Workers: workers, KeepGoing: true}, index)
+				if got.Error != "" {
+					t.Errorf("parse failed: %s", got.Error)
+				}
+				if got.Stats.Skipped == 0 || got.Stats.Skipped != len(got.RecordErrors) {
+					t.Errorf("skipped %d records with %d record errors", got.Stats.Skipped, len(got.RecordErrors))
+				}
+				checkGolden(t, golden, got, workers == 1)
+			})
+		}
+	}
+}
+
+// TestSynthDeterministic guards the golden files against the generator
+// changing under them.
+func TestSynthDeterministic(t *testing.T) {
+	if !bytes.Equal(synth.Index(goldenIndex), synth.Index(goldenIndex)) {
+		t.Fatal("the same options generated different indexes")
+	}
+	other := goldenIndex
+	other.Seed++
+	if bytes.Equal(synth.Index(goldenIndex), synth.Index(other)) {
+		t.Fatal("different seeds generated the same index")
+	}
+}
+
+// parseGolden parses index with opts and collects the results of its mode.
+func parseGolden(t *testing.T, opts extract.Options, index []byte) goldenRun {
+	t.Helper()
+	var matches []extract.Match
+	opts.OnMatch = func(m extract.Match) {
+		matches = append(matches, m)
+	}
+	e := extract.New(opts)
+
+	var run goldenRun
+	if err := e.Parse(bytes.NewReader(index)); err != nil {
+		run.Error = err.Error()
+	}
+	run.Stats = e.Stats()
+	run.RecordErrors = e.RecordErrors()
+	run.DrugFiles = e.DrugFiles()
+
+	switch opts.Mode {
+	case extract.ModeHeuristics:
+		locations := e.PpoPrices()
+		slices.Sort(locations)
+		run.Results = struct {
+			Locations []string          `json:"locations"`
+			FileSets  []extract.FileSet `json:"fileSets"`
+		}{Locations: locations, FileSets: e.FileSets()}
+	case extract.ModeUniquePlans:
+		run.Results = e.PlanSummaries()
+	case extract.ModeAnalysis:
+		run.Results = matches
+	case extract.ModeCoverage:
+		run.Results = e.Coverage()
+	case extract.ModeStats:
+		run.Results = e.IndexStats()
+	}
+	return run
+}
+
+// checkGolden compares got with the golden file, or rewrites it with -update
+// when write is set.
+func checkGolden(t *testing.T, golden string, got goldenRun, write bool) {
+	t.Helper()
+	content, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = append(content, '\n')
+
+	if *update && write {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	if !bytes.Equal(content, want) {
+		t.Errorf("results differ from %s, run with -update if the change is intended\n%s", golden, diffLines(string(want), string(content)))
+	}
+}
+
+// diffLines lists the first lines that differ, enough to see what changed.
+func diffLines(want string, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var diff strings.Builder
+	shown := 0
+	for i := 0; i < max(len(wantLines), len(gotLines)) && shown < 10; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&diff, "line %d\n- %s\n+ %s\n", i+1, w, g)
+			shown++
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,92 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+)
+
+// LimitError reports input that exceeds one of the structural guards.
+type LimitError struct {
+	Limit  string
+	Max    int
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("json %s limit of %d exceeded at byte offset %d", e.Limit, e.Max, e.Offset)
+}
+
+// guardReader scans the raw JSON bytes ahead of the decoder and fails as soon
+// as nesting depth or a single string grows past its limit, before the
+// decoder buffers the offending value. A limit of 0 disables that check.
+type guardReader struct {
+	r         io.Reader
+	maxDepth  int
+	maxString int
+
+	offset   int64
+	depth    int
+	inString bool
+	escaped  bool
+	strLen   int
+	// err is sticky, the decoder may call Read again after a failed read
+	err error
+}
+
+func newGuardReader(r io.Reader, maxDepth int, maxString int) io.Reader {
+	if maxDepth <= 0 && maxString <= 0 {
+		return r
+	}
+	return &guardReader{r: r, maxDepth: maxDepth, maxString: maxString}
+}
+
+func (g *guardReader) Read(p []byte) (int, error) {
+	if g.err != nil {
+		return 0, g.err
+	}
+
+	n, err := g.r.Read(p)
+	for i := 0; i < n; i++ {
+		if limitErr := g.scan(p[i]); limitErr != nil {
+			g.err = limitErr
+			return i, limitErr
+		}
+		g.offset++
+	}
+	return n, err
+}
+
+func (g *guardReader) scan(b byte) error {
+	if g.inString {
+		switch {
+		case g.escaped:
+			g.escaped = false
+		case b == '\\':
+			g.escaped = true
+		case b == '"':
+			g.inString = false
+			return nil
+		}
+
+		g.strLen++
+		if g.maxString > 0 && g.strLen > g.maxString {
+			return &LimitError{Limit: "string length", Max: g.maxString, Offset: g.offset}
+		}
+		return nil
+	}
+
+	switch b {
+	case '"':
+		g.inString = true
+		g.strLen = 0
+	case '{', '[':
+		g.depth++
+		if g.maxDepth > 0 && g.depth > g.maxDepth {
+			return &LimitError{Limit: "nesting depth", Max: g.maxDepth, Offset: g.offset}
+		}
+	case '}', ']':
+		g.depth--
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultPpoPlans is the lowercase allow-list of plan descriptions known to
+// be PPO plans, produced by the LLM pass described in the README.
+var DefaultPpoPlans = map[string]struct{}{
+	"regence bs idaho : par providers":                                                           struct{}{},
+	"bcbs kansas city : preferred care blue":                                                     struct{}{},
+	"bs california : blue high performance":                                                      struct{}{},
+	"bcbs tennessee, inc. : network c":                                                           struct{}{},
+	"hcsc: bcbs texas : blue high performance":                                                   struct{}{},
+	"arkansas bcbs : true blue ppo":                                                              struct{}{},
+	"bcbs massachusetts : blue care elect":                                                       struct{}{},
+	"carefirst bcbs : par network":                                                               struct{}{},
+	"bcbs michigan : par providers":                                                              struct{}{},
+	"bcbs south carolina : blue high performance":                                                struct{}{},
+	"bcbs louisiana : blue hpn":                                                                  struct{}{},
+	"premera bc : heritage prime":                                                                struct{}{},
+	"highmark bs : highmark bs network":                                                          struct{}{},
+	"bcbs north carolina : comprehensive major medical network (cmmn)":                           struct{}{},
+	"regence blueshield : regence-67e0":                                                          struct{}{},
+	"florida blue: bcbs florida : pps":                                                           struct{}{},
+	"hcsc: bcbs new mexico : new mexico bluecard ppo":                                            struct{}{},
+	"bcbs alabama : blue high performance":                                                       struct{}{},
+	"bcbs michigan : blue high performance":                                                      struct{}{},
+	"bcbs south carolina : preferred blue":                                                       struct{}{},
+	"highmark bs northeastern ny : highmark blue shield of northeastern new york - ppo":          struct{}{},
+	"highmark bcbs delaware : blue choice":                                                       struct{}{},
+	"premera bc : prudentbuyer washington":                                                       struct{}{},
+	"hcsc: bcbs illinois : blue high performance":                                                struct{}{},
+	"highmark bcbs : highmark bcbs network":                                                      struct{}{},
+	"bcbs kansas : blue choice":                                                                  struct{}{},
+	"independence bc : par network":                                                              struct{}{},
+	"hcsc: bcbs montana : par network":                                                           struct{}{},
+	"premera bc : heritage signature":                                                            struct{}{},
+	"bcbs massachusetts : blue high performance":                                                 struct{}{},
+	"carefirst bcbs : blue precision":                                                            struct{}{},
+	"bcbs mississippi : par network":                                                             struct{}{},
+	"florida blue: bcbs florida : networkblue":                                                   struct{}{},
+	"independence bc : personal choice":                                                          struct{}{},
+	"regence blueshield : bluecard ppo":                                                          struct{}{},
+	"bcbs arizona : blue preferred":                                                              struct{}{},
+	"bcbs alabama : par network":                                                                 struct{}{},
+	"bcbs vermont : new england health plans (nehp)":                                             struct{}{},
+	"bcbs hawaii : preferred provider network":                                                   struct{}{},
+	"florida blue: triple-s (pr) : bluecard ppo":                                                 struct{}{},
+	"bcbs kansas : traditional providers":                                                        struct{}{},
+	"horizon bcbs new jersey, inc. : select hospitals/par physicians":                            struct{}{},
+	"bcbs north carolina : blue high performance":                                                struct{}{},
+	"hcsc: bcbs illinois : participating provider option":                                        struct{}{},
+	"hcsc: bcbs montana : ppo network":                                                           struct{}{},
+	"highmark bs northeastern ny : highmark blue shield of northeastern new york traditional":    struct{}{},
+	"independence bc : personal choice limited":                                                  struct{}{},
+	"highmark bs : community blue premier":                                                       struct{}{},
+	"bcbs kansas city : participating network":                                                   struct{}{},
+	"premera bc : traditional":                                                                   struct{}{},
+	"regence bcbs utah : preferred blue option":                                                  struct{}{},
+	"health service coalition of nevada (hsc) rates":                                             struct{}{},
+	"bcbs wyoming : wyoming total choice":                                                        struct{}{},
+	"regence bs idaho : blue shield preferred providers":                                         struct{}{},
+	"regence bcbs oregon : oregon high performance":                                              struct{}{},
+	"capital bc : capital blue cross ppo":                                                        struct{}{},
+	"bcbs wyoming : par network":                                                                 struct{}{},
+	"arkansas bcbs : ppp network":                                                                struct{}{},
+	"excellus bcbs : blueppo":                                                                    struct{}{},
+	"bc idaho : participating provider network":                                                  struct{}{},
+	"highmark bcbs western ny : highmark blue cross blue shield of western new york - hpn":       struct{}{},
+	"bcbs kansas city : blueselect plus":                                                         struct{}{},
+	"hcsc: bcbs texas : participating providers":                                                 struct{}{},
+	"bcbs north carolina : preferred provider network (ppn)":                                     struct{}{},
+	"highmark bcbs wv : west virginia par providers":                                             struct{}{},
+	"bs california : ppo network":                                                                struct{}{},
+	"bcbs kansas city : blue high performance":                                                   struct{}{},
+	"bcbs vermont : bcbsvt par providers":                                                        struct{}{},
+	"carefirst bcbs : blueessential":                                                             struct{}{},
+	"capital bc : blue high performance":                                                         struct{}{},
+	"bcbs north carolina : blue value (lcst)":                                                    struct{}{},
+	"bc idaho : preferred blue":                                                                  struct{}{},
+	"highmark bcbs wv : super blue plus":                                                         struct{}{},
+	"premera bc : heritage":                                                                      struct{}{},
+	"bcbs tennessee, inc. : network p":                                                           struct{}{},
+	"bcbs alabama : preferred care":                                                              struct{}{},
+	"wellmark bcbs iowa : alliance select":                                                       struct{}{},
+	"highmark bcbs western ny : highmark blue cross blue shield of western new york-traditional": struct{}{},
+	"premera bc : blue high performance state-wide":                                              struct{}{},
+	"carefirst bcbs : alternate network":                                                         struct{}{},
+	"bcbs wyoming : blue select":                                                                 struct{}{},
+	"bcbs arizona : alliance":                                                                    struct{}{},
+	"hcsc: bcbs illinois : blue choice options":                                                  struct{}{},
+	"in-network negotiated rates files":                                                          struct{}{},
+	"hcsc: bcbs oklahoma : bluechoice ppo":                                                       struct{}{},
+	"hcsc: bcbs illinois : bcbs of illinois par providers":                                       struct{}{},
+	"bcbs north dakota : preferred blue ppo":                                                     struct{}{},
+	"bcbs louisiana : preferred care":                                                            struct{}{},
+	"hcsc: bcbs new mexico : new mexico par network":                                             struct{}{},
+	"florida blue: triple-s (pr) : participating providers":                                      struct{}{},
+	"regence blueshield : blue high performance":                                                 struct{}{},
+	"capital bc : capital blue cross traditional":                                                struct{}{},
+	"florida blue: bcbs florida : ppc / ppo network":                                             struct{}{},
+	"bcbs tennessee, inc. : network s":                                                           struct{}{},
+	"highmark bcbs : community blue":                                                             struct{}{},
+	"bcbs michigan : trust":                                                                      struct{}{},
+	"dental vision":                                                                              struct{}{},
+	"hcsc: bcbs oklahoma : blue traditional":                                                     struct{}{},
+	"bcbs hawaii : participating provider network":                                               struct{}{},
+	"bcbs massachusetts : par providers":                                                         struct{}{},
+	"bcbs minnesota : high value":                                                                struct{}{},
+	"highmark bs : pa national performance blue":                                                 struct{}{},
+	"bcbs nebraska : blueprint health":                                                           struct{}{},
+	"carefirst bcbs : select preferred provider":                                                 struct{}{},
+	"highmark bcbs western ny : highmark bluecross blueshield of western new york-ppo":           struct{}{},
+	"bcbs nebraska : network blue":                                                               struct{}{},
+	"bcbs minnesota : aware":                                                                     struct{}{},
+	"bcbs kansas city : preferred care ppo":                                                      struct{}{},
+	"florida blue: triple-s (vi) : usvi-62a0":                                                    struct{}{},
+	"highmark bcbs delaware : blue classic":                                                      struct{}{},
+	"hcsc: bcbs oklahoma : blue preferred":                                                       struct{}{},
+}
+
+// DefaultRegionCodes are the lowercase plan codes of New York pricing files.
+var DefaultRegionCodes = RegionSet(DefaultRegionsByState["NY"])
+
+// getPpoPricesByHeuristics returns the matching in_network_files, the
+// record decides whether they count once its reporting plans are read.
+func (e *Extractor) getPpoPricesByHeuristics(dec *json.Decoder) ([]NetworkFile, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read in_network_files value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, errors.New("in_network_files is not an array")
+	}
+
+	var matched []NetworkFile
+
+	for i := 0; dec.More(); i++ {
+		var inNetworkFile struct {
+			Description string `json:"description"`
+			Location    string `json:"location"`
+		}
+		source := e.elementSource(i, dec.InputOffset())
+		if ok, err := e.decodeElement(dec, source.Path, &inNetworkFile); err != nil {
+			return nil, fmt.Errorf("decode plan: %w", err)
+		} else if !ok {
+			continue
+		}
+		inNetworkFile.Description = e.cutDescription(inNetworkFile.Description)
+
+		if e.noteDrugFile(inNetworkFile.Description, inNetworkFile.Location) || e.noteTOCFile(inNetworkFile.Location) {
+			continue
+		}
+		e.shards.Add(inNetworkFile.Location)
+
+		lowerDesc := strings.ToLower(inNetworkFile.Description)
+		e.descriptions[lowerDesc] = struct{}{}
+		if e.isDenied(lowerDesc) {
+			continue
+		}
+
+		planMatch := false
+		regionCodeMatch := false
+
+		if e.isPpoPlan(lowerDesc) && e.passesPlanFilter(lowerDesc) {
+			planMatch = true
+		} else {
+			continue
+		}
+
+		if len(e.regionCodes) == 0 {
+			// states without region codes are told by name
+			regionCodeMatch = e.keywords.NamesState(lowerDesc)
+		} else if planCode, err := ExtractPlanCode(inNetworkFile.Location); err == nil {
+			if _, exists := e.regionCodes[strings.ToLower(planCode)]; exists {
+				regionCodeMatch = true
+			}
+		}
+
+		if planMatch && regionCodeMatch {
+			matched = append(matched, NetworkFile{Description: inNetworkFile.Description, Location: inNetworkFile.Location, source: source})
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("close reporting_plans array: %w", err)
+	}
+
+	return matched, nil
+}
+
+func (e *Extractor) getUniquePlans(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read in_network_files value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("in_network_files is not an array")
+	}
+
+	for i := 0; dec.More(); i++ {
+		var inNetworkFile struct {
+			Description string `json:"description"`
+			Location    string `json:"location"`
+		}
+		path := fmt.Sprintf("/reporting_structure/%d/in_network_files/%d", e.recordIndex, i)
+		if ok, err := e.decodeElement(dec, path, &inNetworkFile); err != nil {
+			return fmt.Errorf("decode plan: %w", err)
+		} else if !ok {
+			continue
+		}
+		inNetworkFile.Description = e.cutDescription(inNetworkFile.Description)
+
+		if e.noteDrugFile(inNetworkFile.Description, inNetworkFile.Location) || e.noteTOCFile(inNetworkFile.Location) {
+			continue
+		}
+
+		lowerDesc := strings.ToLower(inNetworkFile.Description)
+		e.descriptions[lowerDesc] = struct{}{}
+		if lowerDesc == "in-network negotiated rates files" || !e.passesPlanFilter(lowerDesc) || e.isDenied(lowerDesc) {
+			continue
+		}
+		e.addPlan(context.Background(), lowerDesc, inNetworkFile.Location)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close reporting_plans array: %w", err)
+	}
+
+	return nil
+}
+
+func (e *Extractor) passesPlanFilter(lowerDesc string) bool {
+	return e.planFilter == nil || e.planFilter.MatchString(lowerDesc)
+}
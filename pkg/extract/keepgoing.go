@@ -0,0 +1,35 @@
+package extract
+
+import "fmt"
+
+// maxRecordErrors bounds the RecordErrors kept of a parse, the records
+// skipped after them are only counted in Stats.
+const maxRecordErrors = 1000
+
+// RecordError is a reporting_structure record skipped with
+// Options.KeepGoing: its JSON pointer, where it starts in the decompressed
+// index file and why it could not be read or matched.
+type RecordError struct {
+	Path    string `json:"path"`
+	Offset  int64  `json:"offset"`
+	Message string `json:"message"`
+}
+
+// skipRecord notes the record at index as skipped, its results were
+// discarded.
+func (e *Extractor) skipRecord(index int, offset int64, err error) {
+	e.skipped++
+	if len(e.recordErrors) < maxRecordErrors {
+		e.recordErrors = append(e.recordErrors, RecordError{
+			Path:    fmt.Sprintf("/reporting_structure/%d", index),
+			Offset:  e.streamBase + offset,
+			Message: err.Error(),
+		})
+	}
+}
+
+// RecordErrors returns the first 1000 records skipped with
+// Options.KeepGoing, Stats counts all of them.
+func (e *Extractor) RecordErrors() []RecordError {
+	return e.recordErrors
+}
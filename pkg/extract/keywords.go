@@ -0,0 +1,207 @@
+package extract
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StateKeywords are how plan descriptions name a state: its name, an
+// abbreviation where it is not an ordinary word, and carrier brands only
+// selling there. Patterns are case-insensitive regular expressions, Exclude
+// patterns are removed from a description before matching, such as west
+// virginia for virginia.
+type StateKeywords struct {
+	Name     string
+	Patterns []string
+	Exclude  []string
+}
+
+// DefaultStateKeywords is the built-in registry of state keywords keyed by
+// uppercase state abbreviation.
+var DefaultStateKeywords = map[string]StateKeywords{
+	"AL": {Name: "Alabama", Patterns: []string{"alabama"}},
+	"AK": {Name: "Alaska", Patterns: []string{"alaska"}},
+	"AZ": {Name: "Arizona", Patterns: []string{"arizona", `\baz\b`}},
+	"AR": {Name: "Arkansas", Patterns: []string{"arkansas"}},
+	"CA": {Name: "California", Patterns: []string{"california", "bs california"}},
+	"CO": {Name: "Colorado", Patterns: []string{"colorado"}},
+	"CT": {Name: "Connecticut", Patterns: []string{"connecticut", `\bct\b`}},
+	"DE": {Name: "Delaware", Patterns: []string{"delaware"}},
+	"DC": {Name: "District of Columbia", Patterns: []string{"district of columbia", `washington,? d\.?c\b`, `\bdc\b`}},
+	"FL": {Name: "Florida", Patterns: []string{"florida", `\bfl\b`}},
+	"GA": {Name: "Georgia", Patterns: []string{"georgia"}},
+	"HI": {Name: "Hawaii", Patterns: []string{"hawaii"}},
+	"ID": {Name: "Idaho", Patterns: []string{"idaho"}},
+	"IL": {Name: "Illinois", Patterns: []string{"illinois"}},
+	"IN": {Name: "Indiana", Patterns: []string{"indiana"}},
+	"IA": {Name: "Iowa", Patterns: []string{"iowa"}},
+	"KS": {Name: "Kansas", Patterns: []string{`\bkansas\b`}, Exclude: []string{"kansas city"}},
+	"KY": {Name: "Kentucky", Patterns: []string{"kentucky", `\bky\b`}},
+	"LA": {Name: "Louisiana", Patterns: []string{"louisiana"}},
+	"ME": {Name: "Maine", Patterns: []string{`\bmaine\b`}},
+	"MD": {Name: "Maryland", Patterns: []string{"maryland"}},
+	"MA": {Name: "Massachusetts", Patterns: []string{"massachusetts", `\btufts\b`}},
+	"MI": {Name: "Michigan", Patterns: []string{"michigan", "priority health"}},
+	"MN": {Name: "Minnesota", Patterns: []string{"minnesota", `\bmn\b`}},
+	"MS": {Name: "Mississippi", Patterns: []string{"mississippi"}},
+	"MO": {Name: "Missouri", Patterns: []string{"missouri", "kansas city"}},
+	"MT": {Name: "Montana", Patterns: []string{"montana"}},
+	"NE": {Name: "Nebraska", Patterns: []string{"nebraska"}},
+	"NV": {Name: "Nevada", Patterns: []string{"nevada", `\bnv\b`}},
+	"NH": {Name: "New Hampshire", Patterns: []string{"new hampshire", `\bnh\b`}},
+	"NJ": {Name: "New Jersey", Patterns: []string{"new jersey", `\bnj\b`, `\bhorizon\b`}},
+	"NM": {Name: "New Mexico", Patterns: []string{"new mexico", `\bnm\b`}},
+	"NY": {Name: "New York", Patterns: []string{"new york", `\bnys?\b`, "excellus", "univera", `\bempire\b`, "emblemhealth", "healthfirst", "fidelis"}},
+	"NC": {Name: "North Carolina", Patterns: []string{"north carolina", `\bnc\b`}},
+	"ND": {Name: "North Dakota", Patterns: []string{"north dakota", `\bnd\b`}},
+	"OH": {Name: "Ohio", Patterns: []string{`\bohio\b`}},
+	"OK": {Name: "Oklahoma", Patterns: []string{"oklahoma"}},
+	"OR": {Name: "Oregon", Patterns: []string{"oregon"}},
+	"PA": {Name: "Pennsylvania", Patterns: []string{"pennsylvania", "independence", "capital blue", "capital bc", "geisinger"}},
+	"RI": {Name: "Rhode Island", Patterns: []string{"rhode island"}},
+	"SC": {Name: "South Carolina", Patterns: []string{"south carolina", `\bsc\b`}},
+	"SD": {Name: "South Dakota", Patterns: []string{"south dakota", `\bsd\b`}},
+	"TN": {Name: "Tennessee", Patterns: []string{"tennessee", `\btn\b`}},
+	"TX": {Name: "Texas", Patterns: []string{"texas", `\btx\b`}},
+	"UT": {Name: "Utah", Patterns: []string{`\butah\b`}},
+	"VT": {Name: "Vermont", Patterns: []string{"vermont", `\bvt\b`}},
+	"VA": {Name: "Virginia", Patterns: []string{`\bvirginia\b`}, Exclude: []string{"west virginia"}},
+	"WA": {Name: "Washington", Patterns: []string{`\bwashington\b`}, Exclude: []string{`washington,? d\.?c\b`}},
+	"WV": {Name: "West Virginia", Patterns: []string{"west virginia", `\bwv\b`}},
+	"WI": {Name: "Wisconsin", Patterns: []string{"wisconsin", `\bwi\b`}},
+	"WY": {Name: "Wyoming", Patterns: []string{"wyoming", `\bwy\b`}},
+}
+
+// PlanTypes are the plan types Options.Keywords accepts and descriptions are
+// classified as.
+var PlanTypes = []string{"PPO", "EPO", "HMO", "POS", "HDHP"}
+
+// DefaultPlanTypeKeywords are the case-insensitive patterns of descriptions
+// naming each plan type. PPO matches inside words, as in blueppo.
+var DefaultPlanTypeKeywords = map[string][]string{
+	"PPO":  {"ppo", "preferred"},
+	"EPO":  {"epo", "exclusive provider"},
+	"HMO":  {"hmo", "health maintenance"},
+	"POS":  {`\bpos\b`, "point[ -]of[ -]service"},
+	"HDHP": {"hdhp", "high[ -]deductible", `\bhsa\b`},
+}
+
+// KeywordRules decide the keyword signal of analysis mode, a description
+// naming the state and the plan type, and what the llm is asked. Heuristics
+// mode uses them in place of the allow-list for plan types other than PPO
+// and of region codes for states without any.
+type KeywordRules struct {
+	State     string
+	StateName string
+	PlanType  string
+
+	state    *regexp.Regexp
+	exclude  *regexp.Regexp
+	planType *regexp.Regexp
+}
+
+// DefaultKeywordRules are the rules of New York PPO plans.
+var DefaultKeywordRules = mustKeywordRules("NY", "PPO")
+
+// NewKeywordRules returns the rules of state, an abbreviation of
+// DefaultStateKeywords, and planType, one of PlanTypes.
+func NewKeywordRules(state string, planType string) (*KeywordRules, error) {
+	state = strings.ToUpper(strings.TrimSpace(state))
+	planType = strings.ToUpper(strings.TrimSpace(planType))
+	keywords, ok := DefaultStateKeywords[state]
+	if !ok {
+		return nil, fmt.Errorf("no keywords for state %q", state)
+	}
+	planTypeKeywords, ok := DefaultPlanTypeKeywords[planType]
+	if !ok {
+		return nil, fmt.Errorf("unknown plan type %q, expected one of %v", planType, PlanTypes)
+	}
+
+	r := &KeywordRules{State: state, StateName: keywords.Name, PlanType: planType}
+	var err error
+	if r.state, err = anyPattern(keywords.Patterns); err != nil {
+		return nil, fmt.Errorf("state %s keywords: %w", state, err)
+	}
+	if len(keywords.Exclude) > 0 {
+		if r.exclude, err = anyPattern(keywords.Exclude); err != nil {
+			return nil, fmt.Errorf("state %s exclusions: %w", state, err)
+		}
+	}
+	if r.planType, err = anyPattern(planTypeKeywords); err != nil {
+		return nil, fmt.Errorf("plan type %s keywords: %w", planType, err)
+	}
+	return r, nil
+}
+
+func mustKeywordRules(state string, planType string) *KeywordRules {
+	r, err := NewKeywordRules(state, planType)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func anyPattern(patterns []string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)(?:" + strings.Join(patterns, "|") + ")")
+}
+
+// KnownState reports whether DefaultStateKeywords has state.
+func KnownState(state string) bool {
+	_, ok := DefaultStateKeywords[strings.ToUpper(strings.TrimSpace(state))]
+	return ok
+}
+
+// States lists the abbreviations of DefaultStateKeywords.
+func States() []string {
+	states := make([]string, 0, len(DefaultStateKeywords))
+	for state := range DefaultStateKeywords {
+		states = append(states, state)
+	}
+	slices.Sort(states)
+	return states
+}
+
+// NamesState reports whether the description names the state.
+func (r *KeywordRules) NamesState(description string) bool {
+	if r.exclude != nil {
+		description = r.exclude.ReplaceAllString(description, " ")
+	}
+	return r.state.MatchString(description)
+}
+
+// NamesPlanType reports whether the description names the plan type.
+func (r *KeywordRules) NamesPlanType(description string) bool {
+	return r.planType.MatchString(description)
+}
+
+// Match reports whether the description names both.
+func (r *KeywordRules) Match(description string) bool {
+	return r.NamesState(description) && r.NamesPlanType(description)
+}
+
+// rule describes the rules in match evidence.
+func (r *KeywordRules) rule() string {
+	return fmt.Sprintf("description names %s and a %s", r.StateName, r.PlanType)
+}
+
+// stateQuestion and planTypeQuestion name the llm questions, and their
+// cached answers. New York and PPO keep the names of the original prompts.
+func (r *KeywordRules) stateQuestion() string {
+	if r.State == "NY" {
+		return "isNewYork"
+	}
+	return "inState:" + r.State
+}
+
+func (r *KeywordRules) planTypeQuestion() string {
+	return planTypeQuestion(r.PlanType)
+}
+
+func planTypeQuestion(planType string) string {
+	if planType == "PPO" {
+		return "isPpo"
+	}
+	return "isPlanType:" + planType
+}
@@ -0,0 +1,164 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Learned is a plan description or plan code a run or a review confirmed,
+// Comment recording which, written next to it in the config file.
+type Learned struct {
+	Value   string
+	Comment string
+}
+
+// AddLearnedPlans adds the descriptions of learned that the plan list at
+// filename neither names nor matches by pattern to its plans, lowercase and
+// each with its comment. The comments already in the file are kept, a bare
+// list stays a bare list and a missing file is created. It returns the
+// number of descriptions added.
+func AddLearnedPlans(filename string, learned []Learned) (int, error) {
+	return editYAML(filename, func(content []byte, root *yaml.Node) (int, error) {
+		var known *PlanList
+		if len(bytes.TrimSpace(content)) > 0 {
+			var err error
+			if known, err = ParsePlanList(filename, content); err != nil {
+				return 0, err
+			}
+		}
+		plans, err := sequence(root, "plans")
+		if err != nil {
+			return 0, fmt.Errorf("plan list %s: %w", filename, err)
+		}
+
+		added := 0
+		for _, l := range learned {
+			name := strings.ToLower(strings.TrimSpace(l.Value))
+			if name == "" || known != nil && known.Matches(name) || contains(plans, name) {
+				continue
+			}
+			plans.Content = append(plans.Content, learnedNode(name, l.Comment))
+			added++
+		}
+		return added, nil
+	})
+}
+
+// AddLearnedRegions adds the plan codes of learned that the regions file at
+// filename does not list yet to the codes of state, or to the codes of
+// every state when the file is a bare list. Like AddLearnedPlans the file's
+// comments are kept and a missing file is created, keyed by state.
+func AddLearnedRegions(filename string, state string, learned []Learned) (int, error) {
+	state = strings.ToUpper(strings.TrimSpace(state))
+	return editYAML(filename, func(content []byte, root *yaml.Node) (int, error) {
+		codes, err := sequence(root, state)
+		if err != nil {
+			return 0, fmt.Errorf("regions %s: %w", filename, err)
+		}
+
+		added := 0
+		for _, l := range learned {
+			code := strings.TrimSpace(l.Value)
+			if code == "" || contains(codes, code) {
+				continue
+			}
+			codes.Content = append(codes.Content, learnedNode(code, l.Comment))
+			added++
+		}
+		return added, nil
+	})
+}
+
+// Matches reports whether the lowercase description is named by the list or
+// matches one of its patterns.
+func (l *PlanList) Matches(lowerDesc string) bool {
+	if _, ok := l.Names[lowerDesc]; ok {
+		return true
+	}
+	for _, re := range l.Patterns {
+		if re.MatchString(lowerDesc) {
+			return true
+		}
+	}
+	return false
+}
+
+// editYAML lets edit change the document at filename and writes it back
+// when edit added anything, through a temporary file.
+func editYAML(filename string, edit func(content []byte, root *yaml.Node) (int, error)) (int, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return 0, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	added, err := edit(content, doc.Content[0])
+	if err != nil || added == 0 {
+		return 0, err
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return 0, err
+	}
+	if err := enc.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filename+".tmp", b.Bytes(), 0o644); err != nil {
+		return 0, err
+	}
+	return added, os.Rename(filename+".tmp", filename)
+}
+
+// sequence returns the list of root, root itself when it is one and the
+// list under key when it is a mapping, added when missing.
+func sequence(root *yaml.Node, key string) (*yaml.Node, error) {
+	if root.Kind == yaml.SequenceNode {
+		return root, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("neither a list nor an object")
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if strings.EqualFold(root.Content[i].Value, key) {
+			if root.Content[i+1].Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("%s is not a list", key)
+			}
+			return root.Content[i+1], nil
+		}
+	}
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, list)
+	return list, nil
+}
+
+func contains(list *yaml.Node, value string) bool {
+	for _, node := range list.Content {
+		if strings.EqualFold(strings.TrimSpace(node.Value), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func learnedNode(value string, comment string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value, Style: yaml.DoubleQuotedStyle}
+	if comment != "" {
+		node.LineComment = "# " + comment
+	}
+	return node
+}
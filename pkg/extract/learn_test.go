@@ -0,0 +1,67 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// TestAddLearned checks that learned entries are added once, with their
+// comment, next to the comments and layout the files already have.
+func TestAddLearned(t *testing.T) {
+	dir := t.TempDir()
+	plans := filepath.Join(dir, "plans.yaml")
+	if err := os.WriteFile(plans, []byte(`# curated by hand
+plans:
+  - "excellus bcbs : blueppo"
+patterns:
+  - "new york.*ppo"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	added, err := extract.AddLearnedPlans(plans, []extract.Learned{
+		{Value: "Empire PPO Choice", Comment: "learned from index.json"},
+		{Value: "EXCELLUS BCBS : BLUEPPO", Comment: "named already"},
+		{Value: "New York Select PPO", Comment: "matched by a pattern"},
+		{Value: "empire ppo choice", Comment: "added above"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Errorf("added %d plans, want 1", added)
+	}
+	content, _ := os.ReadFile(plans)
+	for _, want := range []string{"# curated by hand", `"empire ppo choice" # learned from index.json`, "new york.*ppo"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("plans file lacks %q:\n%s", want, content)
+		}
+	}
+	list, err := extract.LoadPlanList(plans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list.Names["empire ppo choice"]; !ok || len(list.Names) != 2 {
+		t.Errorf("got names %v", list.Names)
+	}
+
+	regions := filepath.Join(dir, "regions.yaml")
+	for _, state := range []string{"NY", "nj", "NY"} {
+		if _, err := extract.AddLearnedRegions(regions, state, []extract.Learned{{Value: "301_71A0", Comment: "learned"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry, err := extract.LoadRegions(regions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []string{"NY", "NJ"} {
+		codes, err := extract.RegionCodesForState(registry, state)
+		if _, ok := codes["301_71a0"]; err != nil || !ok || len(registry[state]) != 1 {
+			t.Errorf("%s region codes %v, %v, want 301_71A0 once", state, registry[state], err)
+		}
+	}
+}
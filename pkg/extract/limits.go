@@ -0,0 +1,52 @@
+package extract
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// cutDescription shortens description to Options.MaxDescriptionLength bytes
+// at a rune boundary. The cut is copied so a huge description read does not
+// stay referenced.
+func (e *Extractor) cutDescription(description string) string {
+	if e.maxDescription <= 0 || len(description) <= e.maxDescription {
+		return description
+	}
+	cut := e.maxDescription
+	for cut > 0 && !utf8.RuneStart(description[cut]) {
+		cut--
+	}
+	return strings.Clone(description[:cut])
+}
+
+// skipValue reads past the next value. With Options.MaxSkippedFieldSize it
+// is streamed token by token, so an enormous embedded array is never held in
+// memory, and fails once it grows past the limit.
+func (e *Extractor) skipValue(dec *json.Decoder) error {
+	if e.maxSkippedField <= 0 {
+		var discard json.RawMessage
+		return dec.Decode(&discard)
+	}
+
+	start := dec.InputOffset()
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if dec.InputOffset()-start > int64(e.maxSkippedField) {
+			return &LimitError{Limit: "skipped field size", Max: e.maxSkippedField, Offset: dec.InputOffset()}
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
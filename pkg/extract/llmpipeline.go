@@ -0,0 +1,110 @@
+package extract
+
+import (
+	"context"
+	"sync"
+)
+
+// batchesInFlight is how many batches per llm worker may be queued ahead of
+// the oldest batch whose matches are not reported yet.
+const batchesInFlight = 2
+
+// llmBatch is a flush of pending analysis files, with the descriptions to
+// classify in batched calls first.
+type llmBatch struct {
+	ctx          context.Context
+	seq          int
+	pending      []pendingFile
+	descriptions []string
+}
+
+type llmBatchResult struct {
+	seq     int
+	matches []Match
+}
+
+// llmPipeline classifies batches of pending files on llmWorkers goroutines
+// so reading the index is not held up by the llm. A batch takes a slot until
+// its matches are reported, bounding how far the reader runs ahead.
+type llmPipeline struct {
+	batches chan llmBatch
+	results chan llmBatchResult
+	slots   chan struct{}
+	workers sync.WaitGroup
+	done    chan struct{}
+	queued  int
+}
+
+func (e *Extractor) startLLMPipeline() *llmPipeline {
+	p := &llmPipeline{
+		batches: make(chan llmBatch),
+		results: make(chan llmBatchResult, e.llmWorkers),
+		slots:   make(chan struct{}, e.llmWorkers*batchesInFlight),
+		done:    make(chan struct{}),
+	}
+	for range e.llmWorkers {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for batch := range p.batches {
+				var matches []Match
+				// batches of an interrupted parse are dropped rather than
+				// reported without their llm answers
+				if batch.ctx.Err() == nil {
+					matches = e.classifyPending(batch.ctx, batch.pending, batch.descriptions)
+				}
+				p.results <- llmBatchResult{seq: batch.seq, matches: matches}
+			}
+		}()
+	}
+
+	// matches are reported from one goroutine in the order the batches were
+	// queued, as the merger and onMatch expect
+	go func() {
+		defer close(p.done)
+		pending := make(map[int][]Match)
+		next := 0
+		for res := range p.results {
+			pending[res.seq] = res.matches
+			for {
+				matches, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-p.slots
+				for _, m := range matches {
+					e.reportMatch(m)
+				}
+			}
+		}
+	}()
+	return p
+}
+
+// submit queues a batch, blocking while every slot is taken.
+func (p *llmPipeline) submit(batch llmBatch) {
+	p.slots <- struct{}{}
+	batch.seq = p.queued
+	p.queued++
+	p.batches <- batch
+}
+
+// wait reports the matches of every queued batch and stops the workers.
+func (p *llmPipeline) wait() {
+	close(p.batches)
+	p.workers.Wait()
+	close(p.results)
+	<-p.done
+}
+
+// waitAnalysis reports the matches of the batches queued to the llm
+// pipeline, the next flush starts a new one.
+func (e *Extractor) waitAnalysis() {
+	if e.llmPipeline == nil {
+		return
+	}
+	e.llmPipeline.wait()
+	e.llmPipeline = nil
+}
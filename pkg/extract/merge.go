@@ -0,0 +1,113 @@
+package extract
+
+import "slices"
+
+// matchMerger collects analysis mode matches by CanonicalLocation. Payers
+// list the same rate file under every reporting_structure record of a
+// network, each with the EINs of its own plans and often a url signed anew,
+// so merging turns those rows into one match per file.
+type matchMerger struct {
+	emit    func(Match)
+	matches []Match
+	// index holds the position of each location's match counted from the
+	// first match ever held, base of them were passed on early
+	index map[string]int
+	base  int
+	// max is Options.MaxMatches
+	max int
+}
+
+// mergeMatch folds m into the match of its location, or keeps it as the
+// first match of a new location.
+func (e *Extractor) mergeMatch(m Match) {
+	mm := e.merger
+	key := CanonicalLocation(m.Location)
+	i, seen := mm.index[key]
+	if !seen {
+		m.Records = 1
+		m.Descriptions = []string{m.Description}
+		m.Eins = slices.Clone(m.Eins)
+		m.Signals = slices.Clone(m.Signals)
+		mm.hold(key, m)
+		return
+	}
+	// matches are counted once per location
+	e.matchCount--
+	mergeInto(&mm.matches[i-mm.base], m)
+}
+
+// hold keeps m as the match of key, passing on the oldest match when more
+// than max are held.
+func (mm *matchMerger) hold(key string, m Match) {
+	mm.index[key] = mm.base + len(mm.matches)
+	mm.matches = append(mm.matches, m)
+	if mm.max <= 0 || len(mm.matches) <= mm.max {
+		return
+	}
+	oldest := mm.matches[0]
+	delete(mm.index, CanonicalLocation(oldest.Location))
+	// append copies the held matches to a new array once this one is full
+	mm.matches[0] = Match{}
+	mm.matches = mm.matches[1:]
+	mm.base++
+	mm.emit(oldest)
+}
+
+// mergeInto aggregates the EINs, descriptions and signals of m into merged.
+// Each signal keeps its strongest contribution, so the score is the one of
+// the best evidence any record gave for the file.
+func mergeInto(merged *Match, m Match) {
+	merged.Records += max(m.Records, 1)
+	for _, ein := range m.Eins {
+		if !slices.Contains(merged.Eins, ein) {
+			merged.Eins = append(merged.Eins, ein)
+		}
+	}
+	descriptions := m.Descriptions
+	if len(descriptions) == 0 {
+		descriptions = []string{m.Description}
+	}
+	for _, description := range descriptions {
+		if !slices.Contains(merged.Descriptions, description) {
+			merged.Descriptions = append(merged.Descriptions, description)
+		}
+	}
+	// the rationale of the most confident yes, or of any answer without one
+	if m.AIRationale != "" && (merged.AIRationale == "" || m.AIMatch && (!merged.AIMatch || m.AIConfidence > merged.AIConfidence)) {
+		merged.AIRationale = m.AIRationale
+	}
+	merged.AIMatch = merged.AIMatch || m.AIMatch
+	merged.AIConfidence = max(merged.AIConfidence, m.AIConfidence)
+	merged.HeuristicMatch = merged.HeuristicMatch || m.HeuristicMatch
+	merged.RegionCodeMatch = merged.RegionCodeMatch || m.RegionCodeMatch
+	if merged.PlanType == "" {
+		merged.PlanType = m.PlanType
+	}
+	if merged.Header == nil {
+		merged.Header = m.Header
+	}
+
+	merged.Score = 0
+	for i := range merged.Signals {
+		if i < len(m.Signals) && m.Signals[i].Score > merged.Signals[i].Score {
+			merged.Signals[i] = m.Signals[i]
+		}
+		merged.Score += merged.Signals[i].Score
+	}
+	merged.Score = roundScore(merged.Score)
+}
+
+// flushMerged passes the merged matches to Options.OnMatch in the order
+// their locations were first seen.
+func (e *Extractor) flushMerged() {
+	mm := e.merger
+	if mm == nil {
+		return
+	}
+	for _, m := range mm.matches {
+		mm.emit(m)
+	}
+	mm.matches = nil
+	mm.base = 0
+	clear(mm.index)
+}
@@ -0,0 +1,53 @@
+package extract
+
+import (
+	"io"
+	"time"
+)
+
+// Observer receives what a parse does as it happens, for metrics. pkg/metrics
+// exports them to Prometheus. Methods are called from the goroutines
+// matching records and must be safe for concurrent use.
+type Observer interface {
+	// RecordDone is called once per reporting_structure record with how
+	// long it took to match.
+	RecordDone(latency time.Duration)
+	// Matches adds n matches: rate file locations newly selected in
+	// heuristics and coverage mode, matches passed to Options.OnMatch in
+	// analysis mode.
+	Matches(n int)
+	// LLMCall is called for every question about a description, cached
+	// when the cache answered it without a call and err when the call failed.
+	LLMCall(cached bool, err error)
+	// Decompressed adds n bytes of index data after decompression.
+	Decompressed(n int)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) RecordDone(time.Duration) {}
+func (nopObserver) Matches(int)              {}
+func (nopObserver) LLMCall(bool, error)      {}
+func (nopObserver) Decompressed(int)         {}
+
+type observedReader struct {
+	r        io.Reader
+	observer Observer
+}
+
+func (o *observedReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.observer.Decompressed(n)
+	return n, err
+}
+
+// observeRecord reports a record that took latency and the locations it
+// added beyond before.
+func (e *Extractor) observeRecord(latency time.Duration, before int) {
+	if e.mode != ModeAnalysis {
+		if added := len(e.uniquePpoPrices) - before; added > 0 {
+			e.observer.Matches(added)
+		}
+	}
+	e.observer.RecordDone(latency)
+}
@@ -0,0 +1,61 @@
+package extract_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/extract"
+)
+
+// TestParseError checks that files which were read but are not an index are
+// told from files that could not be read or passed a limit.
+func TestParseError(t *testing.T) {
+	var corrupt bytes.Buffer
+	zw := gzip.NewWriter(&corrupt)
+	zw.Write([]byte(`{"reporting_structure":[]}`))
+	zw.Close()
+	gz := corrupt.Bytes()
+	// the first byte of the deflate stream, after the 10 byte header
+	gz[10] ^= 0xff
+
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		content []byte
+		opts    extract.Options
+		parse   bool
+	}{
+		{"truncated.json", []byte(`{"reporting_structure":[{"reporting_plans":[`), extract.Options{}, true},
+		{"text.json", []byte("not json"), extract.Options{}, true},
+		{"shape.json", []byte(`{"reporting_structure":{}}`), extract.Options{}, true},
+		{"corrupt.json.gz", gz, extract.Options{}, true},
+		{"limit.json", bytes.Repeat([]byte(" "), 2<<20), extract.Options{Limits: download.Limits{MaxBytes: 1 << 20}}, false},
+		{"depth.json", []byte(`{"reporting_entity_name":[[[[[[[[[]]]]]]]]]}`), extract.Options{MaxDepth: 4}, false},
+		{"missing.json", nil, extract.Options{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tc.name)
+			if tc.content != nil {
+				if err := os.WriteFile(filename, tc.content, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := extract.New(tc.opts).ParseFile(filename)
+			if err == nil {
+				t.Fatal("no error")
+			}
+			var parseErr *extract.ParseError
+			if errors.As(err, &parseErr) != tc.parse {
+				t.Errorf("%v is a ParseError: %v, want %v", err, !tc.parse, tc.parse)
+			}
+			if tc.name == "truncated.json" && (parseErr.Path != "/reporting_structure/0" || parseErr.Offset == 0) {
+				t.Errorf("truncated file failed at %q, offset %d", parseErr.Path, parseErr.Offset)
+			}
+		})
+	}
+}
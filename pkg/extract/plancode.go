@@ -0,0 +1,143 @@
+package extract
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PlanCodeError reports a location whose filename matches none of the
+// PlanCodePatterns.
+type PlanCodeError struct {
+	Filename string
+	Reason   string
+}
+
+func (e *PlanCodeError) Error() string {
+	return fmt.Sprintf("extract plan code from %q: %s", e.Filename, e.Reason)
+}
+
+// PlanCodePattern finds the plan code in the rate filenames of one carrier's
+// naming scheme. Pattern is matched against the filename and its code
+// subexpression is the plan code.
+type PlanCodePattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// NewPlanCodePattern compiles expr, which needs a (?P<code>...)
+// subexpression.
+func NewPlanCodePattern(name string, expr string) (*PlanCodePattern, error) {
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("plan code pattern %s: %w", name, err)
+	}
+	if pattern.SubexpIndex("code") < 0 {
+		return nil, fmt.Errorf("plan code pattern %s: no (?P<code>...) subexpression", name)
+	}
+	return &PlanCodePattern{Name: name, Pattern: pattern}, nil
+}
+
+func mustPlanCodePattern(name string, expr string) *PlanCodePattern {
+	p, err := NewPlanCodePattern(name, expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// PlanCodePatterns are the naming schemes ExtractPlanCode tries in order,
+// callers may add their own.
+var PlanCodePatterns = []*PlanCodePattern{
+	// Blue Cross Blue Shield plans file the region and plan after the month,
+	// 2026-01_301_71A0_in-network-rates.json.gz
+	mustPlanCodePattern("bcbs", `^[^_]*_(?P<code>\d+_[0-9A-Za-z]{4})_`),
+	// UnitedHealthcare names the entity, its type, the product and the
+	// network, 2026-01-01_UnitedHealthcare-of-New-York--Inc-_Insurer_Choice-Plus_CSP-8-A2_in-network-rates.json.gz
+	mustPlanCodePattern("uhc", `(?i)^\d{4}-\d{2}-\d{2}_[^_]+_[^_]+_[^_]+_(?P<code>[0-9a-z-]+)_in-network-rates`),
+	// Cigna names the legal entity and the network,
+	// 2026-01-01_cigna-health-life-insurance-company_national-oap_in-network-rates.json.gz
+	mustPlanCodePattern("cigna", `(?i)^\d{4}-\d{2}-\d{2}_cigna-[^_]+_(?P<code>[0-9a-z-]+)_in-network-rates`),
+}
+
+// PlanCodeMatch is a plan code and the name of the pattern finding it.
+type PlanCodeMatch struct {
+	Code    string
+	Pattern string
+}
+
+// ExtractPlanCode returns the lowercased region/plan code embedded in the
+// filename of rawURL, e.g. "301_71a0" for ".../2026-01_301_71A0_in-network-rates.json.gz".
+// The filename is taken from the URL path, or from the query string when the
+// path carries no filename (e.g. "download?file=...").
+func ExtractPlanCode(rawURL string) (string, error) {
+	match, err := MatchPlanCode(rawURL)
+	return match.Code, err
+}
+
+// MatchPlanCode is ExtractPlanCode also returning which of the
+// PlanCodePatterns matched.
+func MatchPlanCode(rawURL string) (PlanCodeMatch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return PlanCodeMatch{}, &PlanCodeError{Filename: rawURL, Reason: err.Error()}
+	}
+
+	var candidates []string
+	if filename := path.Base(u.Path); filename != "." && filename != "/" {
+		candidates = append(candidates, filename)
+	}
+
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			candidates = append(candidates, path.Base(v))
+		}
+	}
+
+	if len(candidates) == 0 {
+		return PlanCodeMatch{}, &PlanCodeError{Filename: rawURL, Reason: "no filename found in URL"}
+	}
+
+	var firstErr error
+	for _, filename := range candidates {
+		match, err := planCodeFromFilename(filename)
+		if err == nil {
+			return match, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return PlanCodeMatch{}, firstErr
+}
+
+func planCodeFromFilename(filename string) (PlanCodeMatch, error) {
+	// filenames are sometimes encoded twice, url.Parse only removes one layer
+	if strings.Contains(filename, "%") {
+		if unescaped, err := url.PathUnescape(filename); err == nil {
+			filename = unescaped
+		}
+	}
+
+	names := make([]string, 0, len(PlanCodePatterns))
+	for _, p := range PlanCodePatterns {
+		if submatch := p.Pattern.FindStringSubmatch(filename); submatch != nil {
+			if code := submatch[p.Pattern.SubexpIndex("code")]; code != "" {
+				return PlanCodeMatch{Code: strings.ToLower(code), Pattern: p.Name}, nil
+			}
+		}
+		names = append(names, p.Name)
+	}
+
+	return PlanCodeMatch{}, &PlanCodeError{Filename: filename, Reason: fmt.Sprintf("filename matches none of the plan code patterns %v", names)}
+}
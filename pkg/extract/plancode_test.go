@@ -0,0 +1,117 @@
+package extract_test
+
+import (
+	"errors"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+func TestMatchPlanCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     extract.PlanCodeMatch
+	}{
+		{
+			name:     "bcbs",
+			location: "https://cdn.example.com/2026-01_301_71A0_in-network-rates.json.gz",
+			want:     extract.PlanCodeMatch{Code: "301_71a0", Pattern: "bcbs"},
+		},
+		{
+			name:     "bcbs shard",
+			location: "https://cdn.example.com/2026-01_254_39B0_in-network-rates_2_of_3.json.gz?X-Amz-Signature=abc",
+			want:     extract.PlanCodeMatch{Code: "254_39b0", Pattern: "bcbs"},
+		},
+		{
+			name:     "bcbs in the query",
+			location: "https://cdn.example.com/download?file=2026-01_302_42B0_in-network-rates.json.gz",
+			want:     extract.PlanCodeMatch{Code: "302_42b0", Pattern: "bcbs"},
+		},
+		{
+			name:     "bcbs encoded twice",
+			location: "https://cdn.example.com/2026-01%255F301%255F71A0%255Frates.json",
+			want:     extract.PlanCodeMatch{Code: "301_71a0", Pattern: "bcbs"},
+		},
+		{
+			name:     "uhc",
+			location: "https://uhc.example.com/public-mrf/2026-01-01/2026-01-01_UnitedHealthcare-of-New-York--Inc-_Insurer_Choice-Plus_CSP-8-A2_in-network-rates.json.gz",
+			want:     extract.PlanCodeMatch{Code: "csp-8-a2", Pattern: "uhc"},
+		},
+		{
+			name:     "uhc third party administrator",
+			location: "https://uhc.example.com/2026-01-01_United-HealthCare-Services--Inc-_Third-Party-Administrator_Navigate_NAV-P3_in-network-rates.json.gz",
+			want:     extract.PlanCodeMatch{Code: "nav-p3", Pattern: "uhc"},
+		},
+		{
+			name:     "cigna",
+			location: "https://d25kgz5rikkq4n.cloudfront.net/cost_transparency/mrf/in-network-rates/reporting_month=2026-01/2026-01-01_cigna-health-life-insurance-company_national-oap_in-network-rates.json.gz?Expires=1767225600&Signature=abc",
+			want:     extract.PlanCodeMatch{Code: "national-oap", Pattern: "cigna"},
+		},
+		{
+			name:     "cigna local",
+			location: "https://cigna.example.com/2026-01-01_cigna-healthcare-of-new-york-inc_lan-ny-metro_in-network-rates.json.gz",
+			want:     extract.PlanCodeMatch{Code: "lan-ny-metro", Pattern: "cigna"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extract.MatchPlanCode(tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("MatchPlanCode(%q) = %+v, want %+v", tt.location, got, tt.want)
+			}
+			if code, _ := extract.ExtractPlanCode(tt.location); code != tt.want.Code {
+				t.Errorf("ExtractPlanCode(%q) = %q, want %q", tt.location, code, tt.want.Code)
+			}
+		})
+	}
+}
+
+func TestMatchPlanCodeNoMatch(t *testing.T) {
+	for _, location := range []string{
+		"",
+		"https://cdn.example.com/",
+		"https://cdn.example.com/in-network-rates.json.gz",
+		"https://cdn.example.com/2026-01_ab_71A0_in-network-rates.json.gz",
+		"https://cdn.example.com/2026-01_301_71A_rates.json",
+		"https://cdn.example.com/2026-01-01_aetna-life_in-network-rates.json.gz",
+	} {
+		match, err := extract.MatchPlanCode(location)
+		var planCodeErr *extract.PlanCodeError
+		if !errors.As(err, &planCodeErr) {
+			t.Errorf("MatchPlanCode(%q) = %+v, %v, want a PlanCodeError", location, match, err)
+		}
+		if match != (extract.PlanCodeMatch{}) {
+			t.Errorf("MatchPlanCode(%q) returned %+v with an error", location, match)
+		}
+	}
+}
+
+func TestPlanCodePatterns(t *testing.T) {
+	if _, err := extract.NewPlanCodePattern("plain", `^(\d+)_`); err == nil {
+		t.Error("a pattern without a code subexpression was accepted")
+	}
+	if _, err := extract.NewPlanCodePattern("broken", `(?P<code>`); err == nil {
+		t.Error("an invalid pattern was accepted")
+	}
+
+	custom, err := extract.NewPlanCodePattern("aetna", `(?i)^\d{4}-\d{2}-\d{2}_aetna-[^_]+_(?P<code>[a-z-]+)_in-network`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(patterns []*extract.PlanCodePattern) {
+		extract.PlanCodePatterns = patterns
+	}(extract.PlanCodePatterns)
+	extract.PlanCodePatterns = append(extract.PlanCodePatterns[:len(extract.PlanCodePatterns):len(extract.PlanCodePatterns)], custom)
+
+	got, err := extract.MatchPlanCode("https://aetna.example.com/2026-01-01_aetna-life_open-access_in-network-rates.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (extract.PlanCodeMatch{Code: "open-access", Pattern: "aetna"}); got != want {
+		t.Errorf("custom pattern matched %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"serif_interview/pkg/embed"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlanList is a plan description allow-list, or deny-list, loaded from a
+// config file.
+// Names match case-insensitively as a whole, Patterns are case-insensitive
+// regular expressions matching anywhere in the description.
+type PlanList struct {
+	Names    map[string]struct{}
+	Patterns []*regexp.Regexp
+}
+
+// planListFile is the config file layout, either a bare list of names or
+//
+//	plans:
+//	  - "excellus bcbs : blueppo"
+//	patterns:
+//	  - "new york.*ppo"
+//
+// JSON files use the same layout, YAML being a superset of JSON.
+type planListFile struct {
+	Plans    []string `yaml:"plans"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// LoadPlanList reads a YAML or JSON allow-list for Options.PpoPlans and
+// Options.PlanPatterns, or deny-list for Options.DenyPlans and
+// Options.DenyPatterns.
+func LoadPlanList(filename string) (*PlanList, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read plan list: %w", err)
+	}
+	return ParsePlanList(filename, content)
+}
+
+// ParsePlanList parses a plan list in the layout of LoadPlanList, filename
+// identifying it in errors.
+func ParsePlanList(filename string, content []byte) (*PlanList, error) {
+	var file planListFile
+	if err := yaml.Unmarshal(content, &file.Plans); err != nil {
+		file = planListFile{}
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return nil, fmt.Errorf("parse plan list %s: %w", filename, err)
+		}
+	}
+
+	list := &PlanList{Names: make(map[string]struct{})}
+	for _, name := range file.Plans {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			list.Names[name] = struct{}{}
+		}
+	}
+	for _, pattern := range file.Patterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("plan list %s: pattern %q: %w", filename, pattern, err)
+		}
+		list.Patterns = append(list.Patterns, re)
+	}
+	if len(list.Names) == 0 && len(list.Patterns) == 0 {
+		return nil, fmt.Errorf("plan list %s has no plans or patterns", filename)
+	}
+
+	return list, nil
+}
+
+// isPpoPlan reports whether the lowercase description is allow-listed, or
+// similar enough to an allow-listed name with Options.FuzzyThreshold or
+// Options.EmbeddingThreshold.
+// Without an allow-list for the plan type, descriptions naming it are.
+func (e *Extractor) isPpoPlan(lowerDesc string) bool {
+	if _, exists := e.ppoPlans[lowerDesc]; exists {
+		return true
+	}
+	if e.planTypeNames && e.keywords.NamesPlanType(lowerDesc) {
+		return true
+	}
+	for _, re := range e.planPatterns {
+		if re.MatchString(lowerDesc) {
+			return true
+		}
+	}
+	return e.isFuzzyPlan(lowerDesc) || e.isEmbeddedPlan(lowerDesc)
+}
+
+// isDenied reports whether the lowercase description is deny-listed,
+// counting the in_network_files elements it excludes.
+func (e *Extractor) isDenied(lowerDesc string) bool {
+	_, denied := e.denyPlans[lowerDesc]
+	if !denied {
+		denied = slices.ContainsFunc(e.denyPatterns, func(re *regexp.Regexp) bool { return re.MatchString(lowerDesc) })
+	}
+	if denied {
+		e.denied++
+	}
+	return denied
+}
+
+// FuzzyMatch is a description allow-listed for its similarity to Plan.
+type FuzzyMatch struct {
+	Description string  `json:"description"`
+	Plan        string  `json:"plan"`
+	Score       float64 `json:"score"`
+}
+
+func (e *Extractor) isFuzzyPlan(lowerDesc string) bool {
+	if e.fuzzyIndex == nil {
+		return false
+	}
+	// misses are cached with a score of 0
+	match, seen := e.fuzzyScores[lowerDesc]
+	if !seen {
+		match.Description = lowerDesc
+		match.Plan, match.Score = e.fuzzyIndex.Best(lowerDesc, e.fuzzyThreshold)
+		e.fuzzyScores[lowerDesc] = match
+	}
+	return match.Score >= e.fuzzyThreshold
+}
+
+// FuzzyMatches returns the descriptions allow-listed by similarity with the
+// name they resemble, ordered by description.
+func (e *Extractor) FuzzyMatches() []FuzzyMatch {
+	var result []FuzzyMatch
+	for _, match := range e.fuzzyScores {
+		if e.fuzzyIndex != nil && match.Score >= e.fuzzyThreshold {
+			result = append(result, match)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Description < result[j].Description })
+	return result
+}
+
+// planEmbeddings embeds the allow-listed names on first use.
+type planEmbeddings struct {
+	names []string
+
+	once    sync.Once
+	vectors [][]float32
+	err     error
+}
+
+func (p *planEmbeddings) load(store *embed.Store) ([][]float32, error) {
+	p.once.Do(func() {
+		p.vectors, p.err = store.Embed(context.Background(), p.names)
+	})
+	return p.vectors, p.err
+}
+
+// isEmbeddedPlan embeds the description unless stored and compares it with
+// the allow-listed names. A description the embedder fails on is a miss
+// that is tried again when seen next, like a failed llm question.
+func (e *Extractor) isEmbeddedPlan(lowerDesc string) bool {
+	if e.planEmbeddings == nil {
+		return false
+	}
+	match, seen := e.embeddingScores[lowerDesc]
+	if !seen {
+		names, err := e.planEmbeddings.load(e.embeddings)
+		if err != nil {
+			return false
+		}
+		vectors, err := e.embeddings.Embed(context.Background(), []string{lowerDesc})
+		if err != nil {
+			return false
+		}
+		match.Description = lowerDesc
+		for i, name := range names {
+			if score := embed.Cosine(vectors[0], name); score > match.Score {
+				match.Plan, match.Score = e.planEmbeddings.names[i], score
+			}
+		}
+		e.embeddingScores[lowerDesc] = match
+	}
+	return match.Score >= e.embeddingThreshold
+}
+
+// EmbeddingMatches returns the descriptions allow-listed by embedding
+// similarity with the name they are closest to, ordered by description.
+func (e *Extractor) EmbeddingMatches() []FuzzyMatch {
+	var result []FuzzyMatch
+	for _, match := range e.embeddingScores {
+		if e.planEmbeddings != nil && match.Score >= e.embeddingThreshold {
+			result = append(result, match)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Description < result[j].Description })
+	return result
+}
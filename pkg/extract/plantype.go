@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// planTypeOrder is the order descriptions naming several plan types are
+// classified in, the narrower first: a high deductible PPO is an HDHP and an
+// EPO or POS plan often says preferred.
+var planTypeOrder = []string{"HDHP", "EPO", "POS", "HMO", "PPO"}
+
+var planTypePatterns = func() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp, len(DefaultPlanTypeKeywords))
+	for planType, keywords := range DefaultPlanTypeKeywords {
+		patterns[planType] = regexp.MustCompile("(?i)(?:" + strings.Join(keywords, "|") + ")")
+	}
+	return patterns
+}()
+
+// ClassifyPlanType returns the plan type of PlanTypes the description names
+// by DefaultPlanTypeKeywords, or "" when it names none.
+func ClassifyPlanType(description string) string {
+	for _, planType := range planTypeOrder {
+		if planTypePatterns[planType].MatchString(description) {
+			return planType
+		}
+	}
+	return ""
+}
+
+// planTypeOf classifies the description by keywords and, with
+// Options.PlanTypeLLM, asks the llm about descriptions they leave
+// unclassified. The answer is cached as a yes or no for every plan type, so
+// the analysis questions about the plan type share it.
+func (e *Extractor) planTypeOf(ctx context.Context, description string) string {
+	if planType := ClassifyPlanType(description); planType != "" || !e.planTypeLLM || e.llm == nil {
+		return planType
+	}
+
+	cached := 0
+	for _, planType := range PlanTypes {
+		if answer, ok := e.llmCache.get(planTypeQuestion(planType), description); ok {
+			cached++
+			if answer.value {
+				e.observer.LLMCall(true, nil)
+				return planType
+			}
+		}
+	}
+	if cached == len(PlanTypes) {
+		e.observer.LLMCall(true, nil)
+		return ""
+	}
+
+	response, err := e.generate(ctx, e.prompts.planTypeClass, description)
+	e.observer.LLMCall(false, err)
+	if err != nil {
+		return ""
+	}
+	var answered string
+	confidence := 0.0
+	if fields, ok := jsonObject(response); ok {
+		if json.Unmarshal(fields["planType"], &answered) == nil {
+			answered = strings.ToUpper(strings.TrimSpace(answered))
+		}
+		confidence = decodeConfidence(fields["confidence"])
+	}
+	// an answer that cannot be read counts as no plan type, like doLlmQuery
+	for _, planType := range PlanTypes {
+		e.llmCache.put(planTypeQuestion(planType), description, llmAnswer{value: planType == answered, confidence: confidence})
+	}
+	if slices.Contains(PlanTypes, answered) {
+		return answered
+	}
+	return ""
+}
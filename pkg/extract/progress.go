@@ -0,0 +1,62 @@
+package extract
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Progress is how far the file being parsed has been read.
+type Progress struct {
+	File string
+	// BytesRead counts the bytes read from the file as stored, before
+	// decompression, and TotalBytes is its size, 0 when unknown.
+	BytesRead  int64
+	TotalBytes int64
+	// Records counts the reporting_structure records of all files so far.
+	Records int64
+}
+
+// progressCounter is updated by the parse and read by Progress, which may
+// run on another goroutine.
+type progressCounter struct {
+	file    atomic.Pointer[string]
+	read    atomic.Int64
+	total   atomic.Int64
+	records atomic.Int64
+}
+
+// Progress may be called while a parse runs on another goroutine.
+func (e *Extractor) Progress() Progress {
+	p := Progress{
+		BytesRead:  e.progress.read.Load(),
+		TotalBytes: e.progress.total.Load(),
+		Records:    e.progress.records.Load(),
+	}
+	if file := e.progress.file.Load(); file != nil {
+		p.File = *file
+	}
+	return p
+}
+
+// startProgress begins counting a new file of total bytes.
+func (e *Extractor) startProgress(filename string, total int64) {
+	e.progress.file.Store(&filename)
+	e.progress.read.Store(0)
+	e.progress.total.Store(max(total, 0))
+}
+
+type progressReader struct {
+	r    io.Reader
+	read *atomic.Int64
+	// err is the last read of the file itself that failed
+	err error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read.Add(int64(n))
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+	return n, err
+}
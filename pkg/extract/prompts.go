@@ -0,0 +1,147 @@
+package extract
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Prompt templates, each read from the file of its name with a .tmpl
+// extension.
+const (
+	// PromptState asks whether a description is of a plan in the state.
+	PromptState = "state"
+	// PromptPlanType asks whether a description is of the plan type.
+	PromptPlanType = "planType"
+	// PromptBatch asks both questions about a numbered list of descriptions.
+	PromptBatch = "batch"
+	// PromptPlanTypeClass asks which plan type a description is, see
+	// Options.PlanTypeLLM.
+	PromptPlanTypeClass = "planTypeClass"
+)
+
+// PromptNames lists the templates of Prompts.
+var PromptNames = []string{PromptState, PromptPlanType, PromptBatch, PromptPlanTypeClass}
+
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+// PromptData is what the templates are executed with, {{.State}} is the
+// state name such as New York.
+type PromptData struct {
+	State     string
+	StateCode string
+	PlanType  string
+}
+
+// Prompts are the text/template system prompts of the llm questions.
+type Prompts struct {
+	templates map[string]*template.Template
+}
+
+// DefaultPrompts are the built-in prompts.
+var DefaultPrompts = mustPrompts()
+
+func mustPrompts() *Prompts {
+	p, err := parsePrompts(defaultPromptFiles, "prompts", nil)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadPrompts reads the templates in dir, the state.tmpl, planType.tmpl,
+// batch.tmpl and planTypeClass.tmpl files it has replacing the built-in
+// ones. Other .tmpl files are refused as misnamed, and every template is
+// tried with every state and plan type so a bad one fails here rather than
+// mid-run.
+func LoadPrompts(dir string) (*Prompts, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no .tmpl prompt files in %s, expected any of %v", dir, PromptNames)
+	}
+	for _, name := range names {
+		if !slices.Contains(PromptNames, strings.TrimSuffix(filepath.Base(name), ".tmpl")) {
+			return nil, fmt.Errorf("unknown prompt %s, expected one of %v", filepath.Base(name), PromptNames)
+		}
+	}
+	return parsePrompts(os.DirFS(dir), ".", DefaultPrompts)
+}
+
+// parsePrompts reads the templates in dir of fsys, taking the missing ones
+// from defaults.
+func parsePrompts(fsys fs.FS, dir string, defaults *Prompts) (*Prompts, error) {
+	p := &Prompts{templates: make(map[string]*template.Template)}
+	for _, name := range PromptNames {
+		content, err := fs.ReadFile(fsys, path.Join(dir, name+".tmpl"))
+		if errors.Is(err, fs.ErrNotExist) && defaults != nil {
+			p.templates[name] = defaults.templates[name]
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", name, err)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", name, err)
+		}
+		p.templates[name] = tmpl
+	}
+	for _, state := range States() {
+		for _, planType := range PlanTypes {
+			data := PromptData{State: DefaultStateKeywords[state].Name, StateCode: state, PlanType: planType}
+			for _, name := range PromptNames {
+				if _, err := p.render(name, data); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *Prompts) render(name string, data PromptData) (string, error) {
+	var b bytes.Buffer
+	if err := p.templates[name].Execute(&b, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// renderedPrompts are the prompts of one Extractor's keyword rules.
+type renderedPrompts struct {
+	state         string
+	planType      string
+	batch         string
+	planTypeClass string
+}
+
+// renderPrompts executes p for the state and plan type of r. Templates are
+// tried with every state and plan type as they are parsed, a template
+// failing for rules made otherwise is replaced by the built-in one.
+func renderPrompts(p *Prompts, r *KeywordRules) renderedPrompts {
+	data := PromptData{State: r.StateName, StateCode: r.State, PlanType: r.PlanType}
+	var rendered [4]string
+	for i, name := range PromptNames {
+		text, err := p.render(name, data)
+		if err != nil {
+			text, _ = DefaultPrompts.render(name, data)
+		}
+		rendered[i] = text
+	}
+	return renderedPrompts{state: rendered[0], planType: rendered[1], batch: rendered[2], planTypeClass: rendered[3]}
+}
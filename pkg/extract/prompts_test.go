@@ -0,0 +1,78 @@
+package extract_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// promptRecorder answers yes to everything and keeps the system prompts.
+type promptRecorder struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (r *promptRecorder) Generate(ctx context.Context, system string, input string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prompts = append(r.prompts, system)
+	return `{"answer": true, "confidence": 1}`, nil
+}
+
+// TestLoadPrompts checks that templates of a prompts directory replace the
+// built-in ones for the state and plan type of the run, and that misnamed
+// or broken ones are refused.
+func TestLoadPrompts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("state.tmpl", "Is this plan sold in {{.State}} ({{.StateCode}})?")
+	prompts, err := extract.LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := filepath.Join(dir, "index.json")
+	write("index.json", `{"reporting_structure":[{"reporting_plans":[],"in_network_files":[{"description":"acme choice plan","location":"https://example.com/a.json.gz"}]}]}`)
+	keywords, err := extract.NewKeywordRules("NJ", "HMO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// record workers ask the llm with the same prompts
+	for _, workers := range []int{1, 4} {
+		client := &promptRecorder{}
+		e := extract.New(extract.Options{Mode: extract.ModeAnalysis, Keywords: keywords, LLM: client, Prompts: prompts, Workers: workers})
+		if err := e.ParseFile(index); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(client.prompts, "Is this plan sold in New Jersey (NJ)?") {
+			t.Errorf("workers=%d: state prompt not asked, got %q", workers, client.prompts)
+		}
+		if !slices.ContainsFunc(client.prompts, func(prompt string) bool { return strings.Contains(prompt, "considered a HMO plan") }) {
+			t.Errorf("workers=%d: built-in plan type prompt not asked, got %q", workers, client.prompts)
+		}
+		if slices.Contains(client.prompts, "") {
+			t.Errorf("workers=%d: asked without a system prompt, got %q", workers, client.prompts)
+		}
+	}
+
+	write("state.tmpl", "Is this plan sold in {{.Region}}?")
+	if _, err := extract.LoadPrompts(dir); err == nil {
+		t.Error("template of an unknown field loaded")
+	}
+	write("state.tmpl", "Is this plan sold in {{.State}}?")
+	write("stat.tmpl", "misnamed")
+	if _, err := extract.LoadPrompts(dir); err == nil {
+		t.Error("misnamed template loaded")
+	}
+}
@@ -0,0 +1,59 @@
+package extract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// QuarantineEntry is one line of the quarantine stream: a value whose shape
+// the parser does not understand, kept verbatim for writing new adapters,
+// with about where it starts in the decompressed index file.
+type QuarantineEntry struct {
+	Path   string          `json:"path"`
+	Offset int64           `json:"offset"`
+	Error  string          `json:"error"`
+	Raw    json.RawMessage `json:"raw"`
+}
+
+// decodeElement decodes the next array element into v. When the element is
+// well formed JSON but has an unexpected type somewhere inside it, and a
+// quarantine is configured, the raw element is written there and ok is
+// false with a nil error so the caller can skip it.
+func (e *Extractor) decodeElement(dec *json.Decoder, path string, v any) (ok bool, err error) {
+	_, ok, err = e.decodeRawElement(dec, path, v)
+	return ok, err
+}
+
+// decodeRawElement is decodeElement also returning the element as read.
+func (e *Extractor) decodeRawElement(dec *json.Decoder, path string, v any) (raw json.RawMessage, ok bool, err error) {
+	offset := e.streamBase + dec.InputOffset()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, false, err
+	}
+	adapted, err := e.adaptElement(raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = json.Unmarshal(adapted, v)
+	if err == nil {
+		return raw, true, nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if e.quarantine == nil || !errors.As(err, &typeErr) {
+		return nil, false, err
+	}
+
+	line, marshalErr := json.Marshal(QuarantineEntry{Path: path, Offset: offset, Error: err.Error(), Raw: raw})
+	if marshalErr != nil {
+		return nil, false, fmt.Errorf("serialize quarantine entry: %w", marshalErr)
+	}
+	if _, writeErr := e.quarantine.Write(append(line, '\n')); writeErr != nil {
+		return nil, false, fmt.Errorf("write quarantine: %w", writeErr)
+	}
+	e.quarantinedCount++
+
+	return nil, false, nil
+}
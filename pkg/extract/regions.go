@@ -0,0 +1,90 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRegionsByState is the built-in registry of plan codes whose pricing
+// files cover a state, keyed by uppercase state abbreviation. Only codes
+// verified against real index files are listed, other states are supplied
+// with LoadRegions.
+var DefaultRegionsByState = map[string][]string{
+	"NY": {"301_71A0", "302_42B0", "254_39B0", "800_72A0"},
+}
+
+// RegionSet returns codes as a lowercase set for Options.RegionCodes.
+func RegionSet(codes []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		if code = strings.ToLower(strings.TrimSpace(code)); code != "" {
+			set[code] = struct{}{}
+		}
+	}
+	return set
+}
+
+// LoadRegions reads a YAML or JSON region file, either a list of plan codes
+// used regardless of state, returned under the "" key, or an object of
+// state abbreviations to plan codes.
+func LoadRegions(filename string) (map[string][]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read regions: %w", err)
+	}
+	return ParseRegions(filename, content)
+}
+
+// ParseRegions parses regions in the layout of LoadRegions, filename
+// identifying them in errors.
+func ParseRegions(filename string, content []byte) (map[string][]string, error) {
+	var codes []string
+	if err := yaml.Unmarshal(content, &codes); err == nil {
+		if len(codes) == 0 {
+			return nil, fmt.Errorf("regions %s lists no plan codes", filename)
+		}
+		return map[string][]string{"": codes}, nil
+	}
+
+	var byState map[string][]string
+	if err := yaml.Unmarshal(content, &byState); err != nil {
+		return nil, fmt.Errorf("parse regions %s: %w", filename, err)
+	}
+	registry := make(map[string][]string, len(byState))
+	for state, codes := range byState {
+		registry[strings.ToUpper(state)] = codes
+	}
+	return registry, nil
+}
+
+// RegionCodesForState looks state up in registry, falling back to
+// DefaultRegionsByState. A registry with a "" entry applies to every state.
+func RegionCodesForState(registry map[string][]string, state string) (map[string]struct{}, error) {
+	if codes, exists := registry[""]; exists {
+		return RegionSet(codes), nil
+	}
+
+	state = strings.ToUpper(strings.TrimSpace(state))
+	if codes, exists := registry[state]; exists {
+		return RegionSet(codes), nil
+	}
+	if codes, exists := DefaultRegionsByState[state]; exists {
+		return RegionSet(codes), nil
+	}
+
+	known := make([]string, 0, len(registry)+len(DefaultRegionsByState))
+	for name := range DefaultRegionsByState {
+		known = append(known, name)
+	}
+	for name := range registry {
+		if _, builtIn := DefaultRegionsByState[name]; !builtIn {
+			known = append(known, name)
+		}
+	}
+	sort.Strings(known)
+	return nil, fmt.Errorf("no region codes for state %q, known states are %v, supply them with a regions file", state, known)
+}
@@ -0,0 +1,92 @@
+package extract
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Signal names one piece of evidence that a file holds the rates of interest.
+type Signal string
+
+const (
+	// SignalAllowList is the description being allow-listed.
+	SignalAllowList Signal = "allowList"
+	// SignalRegionCode is the plan code of the location being a region code
+	// of the state.
+	SignalRegionCode Signal = "regionCode"
+	// SignalKeyword is the description naming the state and plan type of
+	// Options.Keywords, New York and a PPO by default.
+	SignalKeyword Signal = "keyword"
+	// SignalLLM is the llm answering yes to both questions, valued at its
+	// confidence.
+	SignalLLM Signal = "llm"
+	// SignalEIN is the record listing a plan of one of Options.EINs.
+	SignalEIN Signal = "ein"
+)
+
+var Signals = []Signal{SignalAllowList, SignalRegionCode, SignalKeyword, SignalLLM, SignalEIN}
+
+// DefaultWeights add up to 1, a file with every signal scores 1.
+var DefaultWeights = map[Signal]float64{
+	SignalAllowList:  0.3,
+	SignalRegionCode: 0.25,
+	SignalKeyword:    0.15,
+	SignalLLM:        0.2,
+	SignalEIN:        0.1,
+}
+
+// SignalScore is what one signal contributed to a Match's score.
+type SignalScore struct {
+	Signal Signal  `json:"signal"`
+	Weight float64 `json:"weight"`
+	// Value is from 0 to 1, 1 for a hit and the llm's confidence for the llm
+	Value float64 `json:"value"`
+	Score float64 `json:"score"`
+}
+
+// score weighs the signal values of a file, in the order of Signals.
+func (e *Extractor) score(values map[Signal]float64) (float64, []SignalScore) {
+	total := 0.0
+	breakdown := make([]SignalScore, 0, len(Signals))
+	for _, signal := range Signals {
+		weight := e.weights[signal]
+		s := SignalScore{Signal: signal, Weight: weight, Value: values[signal], Score: weight * values[signal]}
+		total += s.Score
+		breakdown = append(breakdown, s)
+	}
+	return roundScore(total), breakdown
+}
+
+// roundScore drops the float noise of summing weights, 0.7 rather than
+// 0.7000000000000001.
+func roundScore(score float64) float64 {
+	return math.Round(score*1e6) / 1e6
+}
+
+// ParseWeights reads signal=weight pairs separated by commas, e.g.
+// "llm=0.5,keyword=0", over DefaultWeights.
+func ParseWeights(text string) (map[Signal]float64, error) {
+	weights := make(map[Signal]float64, len(DefaultWeights))
+	for signal, weight := range DefaultWeights {
+		weights[signal] = weight
+	}
+	for _, pair := range strings.Split(text, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		signal := Signal(strings.TrimSpace(name))
+		if !ok || !slices.Contains(Signals, signal) {
+			return nil, fmt.Errorf("invalid signal weight %q, expected one of %v=weight", pair, Signals)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight of %s %q", signal, value)
+		}
+		weights[signal] = weight
+	}
+	return weights, nil
+}
@@ -0,0 +1,76 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"serif_interview/pkg/blob"
+	"serif_interview/pkg/download"
+	"serif_interview/pkg/gzindex"
+)
+
+// resumePrefix stands in for the index before the first unfinished record
+// of a seeked resume: the root object, reporting_structure and one finished
+// record for skipResumed to discard.
+const resumePrefix = `{"reporting_structure":[{}`
+
+// openResumed opens a local gzip file at the first unfinished record of the
+// resumed checkpoint through its seek index, nil when the parse has to
+// start at the beginning of the file, and the reader of the file itself.
+func (e *Extractor) openResumed(ctx context.Context, filename string) (io.ReadCloser, *progressReader, error) {
+	if !e.gzipIndex || e.resumeOffset <= 0 || download.IsURL(filename) || blob.IsURI(filename) {
+		return nil, nil, nil
+	}
+	path := gzindex.Path(filename)
+	ix, err := gzindex.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("load gzip index %s: %w", path, err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open file stream: %s - %w", filename, err)
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = ix.Check(info.Size())
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("gzip index %s: %w, rebuild it", path, err)
+	}
+
+	p := ix.At(e.resumeOffset)
+	if _, err := f.Seek(p.In, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("seek %s: %w", filename, err)
+	}
+	e.startProgress(filename, info.Size())
+	e.progress.read.Store(p.In)
+	src := &progressReader{r: f, read: &e.progress.read}
+	compressed := &download.CountingReader{R: &contextReader{ctx: ctx, r: src}}
+	r, err := gzindex.NewReader(compressed, p)
+	if err == nil {
+		_, err = io.CopyN(io.Discard, r, e.resumeOffset-p.Out)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("seek %s to offset %d: %w", filename, e.resumeOffset, err)
+	}
+
+	// the prefix and its record take the place of the finished ones
+	e.streamBase = e.resumeOffset - int64(len(resumePrefix))
+	e.recordIndex = e.skipRecords - 2
+	e.progress.records.Add(int64(e.skipRecords - 1))
+	// the reporting_entity_name before the records is not read again
+	e.detectCarrier(e.header.ReportingEntityName)
+	return readCloser{Reader: io.MultiReader(strings.NewReader(resumePrefix), e.limits.Reader(r, compressed)), Closer: f}, src, nil
+}
@@ -0,0 +1,62 @@
+package extract_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+	"serif_interview/pkg/gzindex"
+)
+
+// TestResumeGzipIndex checks that a resume seeking through the gzip index
+// finds what a parse from the start does.
+func TestResumeGzipIndex(t *testing.T) {
+	var file bytes.Buffer
+	zw := gzip.NewWriter(&file)
+	zw.Write(synth.Index(synth.Options{Records: 2000, Seed: 2}))
+	zw.Close()
+	filename := filepath.Join(t.TempDir(), "index.json.gz")
+	if err := os.WriteFile(filename, file.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := gzindex.Build(bytes.NewReader(file.Bytes()), 64<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Save(gzindex.Path(filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		var checkpoints []extract.Checkpoint
+		full := extract.New(extract.Options{Workers: workers, OnCheckpoint: func(cp extract.Checkpoint) {
+			checkpoints = append(checkpoints, cp)
+		}})
+		if err := full.ParseFile(filename); err != nil {
+			t.Fatal(err)
+		}
+		want := full.PpoPrices()
+		slices.Sort(want)
+
+		for _, cp := range []extract.Checkpoint{checkpoints[0], checkpoints[len(checkpoints)/2], checkpoints[len(checkpoints)-1]} {
+			if cp.Offset == 0 {
+				t.Fatalf("checkpoint after %d records has no offset", cp.Records)
+			}
+			resumed := extract.New(extract.Options{Workers: workers, Resume: &cp, GzipIndex: true})
+			if err := resumed.ParseFile(filename); err != nil {
+				t.Fatalf("resume after %d records: %v", cp.Records, err)
+			}
+			got := resumed.PpoPrices()
+			slices.Sort(got)
+			if !slices.Equal(got, want) || resumed.Stats().Records != full.Stats().Records {
+				t.Errorf("resume after %d records with %d workers found %d locations in %d records, want %d in %d",
+					cp.Records, workers, len(got), resumed.Stats().Records, len(want), full.Stats().Records)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package extract
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shardPatterns recognise the numbering payers put on split in-network
+// files, e.g. "..._in-network-rates_3_of_57.json.gz" or "..._part03.json.gz".
+// The first submatch is the shard index, the optional second the total.
+var shardPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)[_-](\d+)[_-]of[_-](\d+)`),
+	regexp.MustCompile(`(?i)[_-]part[_-]?(\d+)`),
+}
+
+// maxShardTotal bounds the shard count a filename is believed to state,
+// Missing would list every number up to it.
+const maxShardTotal = 10000
+
+// ShardInfo describes where a single location sits in a sharded file set.
+type ShardInfo struct {
+	// Network is the location with the shard numbering and query string
+	// removed, identifying the logical file the shard belongs to.
+	Network string
+	Index   int
+	// Total is 0 when the filename does not state the shard count.
+	Total int
+}
+
+// DetectShard reports the shard numbering of rawURL, if any.
+func DetectShard(rawURL string) (ShardInfo, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ShardInfo{}, false
+	}
+
+	dir, filename := path.Split(u.Path)
+	for _, pattern := range shardPatterns {
+		m := pattern.FindStringSubmatchIndex(filename)
+		if m == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(filename[m[2]:m[3]])
+		if err != nil {
+			continue
+		}
+		total := 0
+		if len(m) > 4 && m[4] >= 0 {
+			total, err = strconv.Atoi(filename[m[4]:m[5]])
+			if err != nil {
+				continue
+			}
+		}
+
+		return ShardInfo{
+			Network: strings.ToLower(u.Host) + dir + filename[:m[0]] + filename[m[1]:],
+			Index:   index,
+			Total:   total,
+		}, true
+	}
+
+	return ShardInfo{}, false
+}
+
+// FileSet groups the locations that make up one logical network file.
+// Sources are where each of the Locations was found, with
+// Options.SourcePaths.
+type FileSet struct {
+	Network        string   `json:"network"`
+	PlanCode       string   `json:"planCode,omitempty"`
+	ShardCount     int      `json:"shardCount"`
+	ExpectedShards int      `json:"expectedShards,omitempty"`
+	MissingShards  []int    `json:"missingShards,omitempty"`
+	Locations      []string `json:"locations"`
+	Sources        []Source `json:"sources,omitempty"`
+}
+
+// GroupFileSets folds sharded locations into one FileSet per logical network.
+// Locations without shard numbering form a set of their own with a single shard.
+func GroupFileSets(locations []string) []FileSet {
+	type shard struct {
+		index    int
+		location string
+	}
+	shardsByNetwork := make(map[string][]shard)
+	sets := make(map[string]*FileSet)
+
+	for _, location := range locations {
+		info, ok := DetectShard(location)
+		if !ok {
+			u, err := url.Parse(location)
+			if err == nil {
+				info.Network = strings.ToLower(u.Host) + u.Path
+			} else {
+				info.Network = location
+			}
+		}
+
+		set, exists := sets[info.Network]
+		if !exists {
+			set = &FileSet{Network: info.Network}
+			if planCode, err := ExtractPlanCode(location); err == nil {
+				set.PlanCode = planCode
+			}
+			sets[info.Network] = set
+		}
+		if info.Total > set.ExpectedShards {
+			set.ExpectedShards = info.Total
+		}
+		shardsByNetwork[info.Network] = append(shardsByNetwork[info.Network], shard{index: info.Index, location: location})
+	}
+
+	result := make([]FileSet, 0, len(sets))
+	for network, set := range sets {
+		shards := shardsByNetwork[network]
+		sort.SliceStable(shards, func(i, j int) bool { return shards[i].index < shards[j].index })
+
+		distinct := make(map[int]struct{})
+		for _, s := range shards {
+			distinct[s.index] = struct{}{}
+			set.Locations = append(set.Locations, s.location)
+		}
+		set.ShardCount = len(distinct)
+
+		result = append(result, *set)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Network < result[j].Network })
+
+	return result
+}
+
+// ShardIndex records every shard seen in an index file, matched or not, so
+// matched file sets can be checked for shards the payer never published.
+type ShardIndex struct {
+	seen   map[string]map[int]struct{}
+	totals map[string]int
+}
+
+func NewShardIndex() *ShardIndex {
+	return &ShardIndex{
+		seen:   make(map[string]map[int]struct{}),
+		totals: make(map[string]int),
+	}
+}
+
+// Add records location if it carries shard numbering.
+func (s *ShardIndex) Add(location string) {
+	info, ok := DetectShard(location)
+	if !ok {
+		return
+	}
+
+	indexes, exists := s.seen[info.Network]
+	if !exists {
+		indexes = make(map[int]struct{})
+		s.seen[info.Network] = indexes
+	}
+	indexes[info.Index] = struct{}{}
+
+	if info.Total > s.totals[info.Network] {
+		s.totals[info.Network] = info.Total
+	}
+}
+
+// Merge adds the shards recorded in other.
+func (s *ShardIndex) Merge(other *ShardIndex) {
+	for network, indexes := range other.seen {
+		seen, exists := s.seen[network]
+		if !exists {
+			seen = make(map[int]struct{}, len(indexes))
+			s.seen[network] = seen
+		}
+		for index := range indexes {
+			seen[index] = struct{}{}
+		}
+	}
+	for network, total := range other.totals {
+		if total > s.totals[network] {
+			s.totals[network] = total
+		}
+	}
+}
+
+// Missing returns the shard numbers of network that never appeared in the
+// index. Shard numbering is detected as starting from 0 or 1 depending on
+// whether a shard 0 was seen. A total above maxShardTotal is not checked.
+func (s *ShardIndex) Missing(network string) []int {
+	total := s.totals[network]
+	indexes := s.seen[network]
+	if total == 0 || total > maxShardTotal || indexes == nil {
+		return nil
+	}
+
+	first := 1
+	if _, zeroBased := indexes[0]; zeroBased {
+		first = 0
+	}
+
+	var missing []int
+	for i := first; i < first+total; i++ {
+		if _, exists := indexes[i]; !exists {
+			missing = append(missing, i)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,42 @@
+package extract_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"serif_interview/pkg/extract"
+)
+
+func TestShardIndexMissing(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		locations []string
+		want      []int
+	}{
+		{"complete", []string{"https://example.com/rates_1_of_2.json.gz", "https://example.com/rates_2_of_2.json.gz"}, nil},
+		{"gap", []string{"https://example.com/rates_1_of_4.json.gz", "https://example.com/rates_3_of_4.json.gz"}, []int{2, 4}},
+		{"zero based", []string{"https://example.com/rates_0_of_3.json.gz", "https://example.com/rates_2_of_3.json.gz"}, []int{1}},
+		{"no total", []string{"https://example.com/rates_part1.json.gz", "https://example.com/rates_part3.json.gz"}, nil},
+		{"implausible total", []string{"https://example.com/rates_1_of_9999999999.json.gz"}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := extract.NewShardIndex()
+			for _, location := range tt.locations {
+				shards.Add(location)
+			}
+			info, _ := extract.DetectShard(tt.locations[0])
+
+			done := make(chan []int)
+			go func() { done <- shards.Missing(info.Network) }()
+			select {
+			case missing := <-done:
+				if !slices.Equal(missing, tt.want) {
+					t.Errorf("Missing(%q) = %v, want %v", info.Network, missing, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Missing(%q) did not return", info.Network)
+			}
+		})
+	}
+}
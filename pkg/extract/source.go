@@ -0,0 +1,35 @@
+package extract
+
+import "fmt"
+
+// Source locates an index element for debugging a payer's data: its JSON
+// pointer and about where it starts in the decompressed index file, the
+// offset being that of the whitespace or comma before it.
+type Source struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+// elementSource is the Source of the in_network_files element of the record
+// being scanned at index i, offset in the decoder's input.
+func (e *Extractor) elementSource(i int, offset int64) Source {
+	return Source{
+		Path:   fmt.Sprintf("/reporting_structure/%d/in_network_files/%d", e.recordIndex, i),
+		Offset: e.streamBase + offset,
+	}
+}
+
+// Source returns where the heuristics match of location was first found,
+// false without Options.SourcePaths.
+func (e *Extractor) Source(location string) (Source, bool) {
+	source, ok := e.ppoSources[CanonicalLocation(location)]
+	return source, ok
+}
+
+// failAt keeps the first place the parse failed for its ParseError, path
+// being the record it failed in, if any.
+func (e *Extractor) failAt(path string, offset int64) {
+	if e.failedAt == nil {
+		e.failedAt = &Source{Path: path, Offset: e.streamBase + offset}
+	}
+}
@@ -0,0 +1,59 @@
+package extract_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+// TestSourcePaths checks that the path and offset of every match lead to
+// the in_network_files element listing its location, with record workers
+// too.
+func TestSourcePaths(t *testing.T) {
+	index := synth.Index(goldenIndex)
+	// element is the index element starting about at offset
+	element := func(offset int64) string {
+		rest := strings.TrimLeft(string(index[offset:]), ", \n\t")
+		end := strings.IndexByte(rest, '}')
+		if !strings.HasPrefix(rest, "{") || end < 0 {
+			return ""
+		}
+		return rest[:end]
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			e := extract.New(extract.Options{Mode: extract.ModeHeuristics, Workers: workers, SourcePaths: true})
+			if err := e.Parse(bytes.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+			if len(e.PpoPrices()) == 0 {
+				t.Fatal("no heuristics matches")
+			}
+			for _, location := range e.PpoPrices() {
+				source, ok := e.Source(location)
+				if !ok || !strings.Contains(source.Path, "/in_network_files/") || !strings.Contains(element(source.Offset), location) {
+					t.Errorf("%s found at %+v", location, source)
+				}
+			}
+
+			var matches []extract.Match
+			e = extract.New(extract.Options{Mode: extract.ModeAnalysis, Workers: workers, SourcePaths: true, OnMatch: func(m extract.Match) { matches = append(matches, m) }})
+			if err := e.Parse(bytes.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) == 0 {
+				t.Fatal("no analysis matches")
+			}
+			for _, m := range matches {
+				if !strings.HasPrefix(m.Path, "/reporting_structure/") || !strings.Contains(element(m.Offset), m.Location) {
+					t.Errorf("%s found at %s, offset %d", m.Location, m.Path, m.Offset)
+				}
+			}
+		})
+	}
+}
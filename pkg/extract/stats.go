@@ -0,0 +1,136 @@
+package extract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// IndexStats sizes an index file in ModeStats. RecordsWithEIN counts the
+// reporting_structure records listing at least one EIN plan, EINCoverage is
+// their share of Records. BytesRead is the size of the file as stored.
+type IndexStats struct {
+	Records        int     `json:"records"`
+	InNetworkFiles int     `json:"inNetworkFiles"`
+	Descriptions   int     `json:"descriptions"`
+	PlanCodes      int     `json:"planCodes"`
+	ReportingPlans int     `json:"reportingPlans"`
+	EINs           int     `json:"eins"`
+	RecordsWithEIN int     `json:"recordsWithEin"`
+	EINCoverage    float64 `json:"einCoverage"`
+	BytesRead      int64   `json:"bytesRead"`
+}
+
+// indexCounts are the ModeStats counts beyond the descriptions.
+type indexCounts struct {
+	InNetworkFiles int      `json:"inNetworkFiles"`
+	ReportingPlans int      `json:"reportingPlans"`
+	RecordsWithEIN int      `json:"recordsWithEin"`
+	PlanCodes      []string `json:"planCodes,omitempty"`
+	EINs           []string `json:"eins,omitempty"`
+
+	planCodes map[string]struct{}
+	eins      map[string]struct{}
+}
+
+func newIndexCounts() *indexCounts {
+	return &indexCounts{planCodes: make(map[string]struct{}), eins: make(map[string]struct{})}
+}
+
+// countInNetworkFiles counts the elements of an in_network_files array, their
+// descriptions and plan codes.
+func (e *Extractor) countInNetworkFiles(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read in_network_files value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("in_network_files is not an array")
+	}
+
+	for i := 0; dec.More(); i++ {
+		var inNetworkFile NetworkFile
+		path := fmt.Sprintf("/reporting_structure/%d/in_network_files/%d", e.recordIndex, i)
+		if ok, err := e.decodeElement(dec, path, &inNetworkFile); err != nil {
+			return fmt.Errorf("decode in_network_files element: %w", err)
+		} else if !ok {
+			continue
+		}
+
+		e.counts.InNetworkFiles++
+		inNetworkFile.Description = e.cutDescription(inNetworkFile.Description)
+		e.descriptions[strings.ToLower(inNetworkFile.Description)] = struct{}{}
+		if code, err := ExtractPlanCode(inNetworkFile.Location); err == nil {
+			e.counts.planCodes[code] = struct{}{}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close in_network_files array: %w", err)
+	}
+	return nil
+}
+
+// countPlans counts the reporting plans of a record and their EINs.
+func (e *Extractor) countPlans(plans []ReportingPlan) {
+	e.counts.ReportingPlans += len(plans)
+	withEIN := false
+	for _, plan := range plans {
+		if strings.EqualFold(plan.IDType, "ein") {
+			e.counts.eins[NormalizeEIN(plan.ID)] = struct{}{}
+			withEIN = true
+		}
+	}
+	if withEIN {
+		e.counts.RecordsWithEIN++
+	}
+}
+
+// mergeCounts adds counts of another extractor or a checkpoint.
+func (e *Extractor) mergeCounts(c *indexCounts) {
+	e.counts.InNetworkFiles += c.InNetworkFiles
+	e.counts.ReportingPlans += c.ReportingPlans
+	e.counts.RecordsWithEIN += c.RecordsWithEIN
+	for code := range c.planCodes {
+		e.counts.planCodes[code] = struct{}{}
+	}
+	for _, code := range c.PlanCodes {
+		e.counts.planCodes[code] = struct{}{}
+	}
+	for ein := range c.eins {
+		e.counts.eins[ein] = struct{}{}
+	}
+	for _, ein := range c.EINs {
+		e.counts.eins[ein] = struct{}{}
+	}
+}
+
+// snapshot returns the counts with their sets listed, for checkpoints.
+func (c *indexCounts) snapshot() *indexCounts {
+	s := *c
+	s.PlanCodes = slices.Sorted(maps.Keys(c.planCodes))
+	s.EINs = slices.Sorted(maps.Keys(c.eins))
+	s.planCodes, s.eins = nil, nil
+	return &s
+}
+
+// IndexStats returns the ModeStats counts of the parses so far.
+func (e *Extractor) IndexStats() IndexStats {
+	stats := IndexStats{
+		Records:        e.recordIndex + 1,
+		InNetworkFiles: e.counts.InNetworkFiles,
+		Descriptions:   len(e.descriptions),
+		PlanCodes:      len(e.counts.planCodes),
+		ReportingPlans: e.counts.ReportingPlans,
+		EINs:           len(e.counts.eins),
+		RecordsWithEIN: e.counts.RecordsWithEIN,
+		BytesRead:      e.progress.read.Load(),
+	}
+	if stats.Records > 0 {
+		stats.EINCoverage = float64(stats.RecordsWithEIN) / float64(stats.Records)
+	}
+	return stats
+}
@@ -0,0 +1,102 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"serif_interview/pkg/blob"
+	"serif_interview/pkg/download"
+)
+
+// maxTOCDepth bounds how deep table of contents files may reference each
+// other.
+const maxTOCDepth = 4
+
+// tocKeys are the root keys payers list nested table of contents files
+// under, instead of a reporting_structure.
+var tocKeys = map[string]struct{}{
+	"table_of_contents":       {},
+	"table_of_contents_files": {},
+	"index_files":             {},
+	"toc_files":               {},
+}
+
+// tocFilePattern recognises table of contents files listed among the
+// in_network_files, which are not rate files.
+var tocFilePattern = regexp.MustCompile(`(?i)(table[-_ ]?of[-_ ]?contents|[-_]toc|[-_]index)\.json(\.gz|\.zst|\.bz2)?$`)
+
+// noteTOCFile records location when it is a nested table of contents file
+// and reports whether it did.
+func (e *Extractor) noteTOCFile(location string) bool {
+	if !tocFilePattern.MatchString(location) {
+		return false
+	}
+	e.tocFiles[location] = struct{}{}
+	return true
+}
+
+// takeTOCFiles returns the nested table of contents files found so far,
+// ordered, and forgets them.
+func (e *Extractor) takeTOCFiles() []string {
+	locations := make([]string, 0, len(e.tocFiles))
+	for location := range e.tocFiles {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	e.tocFiles = make(map[string]struct{})
+	return locations
+}
+
+// followTOCFiles parses the table of contents files filename references,
+// into the same results. Relative locations are resolved against filename.
+func (e *Extractor) followTOCFiles(ctx context.Context, filename string, visited map[string]struct{}, depth int) error {
+	nested := e.takeTOCFiles()
+	if len(nested) == 0 {
+		return nil
+	}
+	if depth >= maxTOCDepth {
+		return fmt.Errorf("table of contents files nested deeper than %d levels in %s", maxTOCDepth, filename)
+	}
+
+	// the header describes the file that was asked for
+	header := e.header
+	defer func() { e.header = header }()
+
+	for _, ref := range nested {
+		location, err := resolveLocation(filename, ref)
+		if err != nil {
+			return fmt.Errorf("table of contents %q: %w", ref, err)
+		}
+		if _, seen := visited[location]; seen {
+			continue
+		}
+		visited[location] = struct{}{}
+
+		if err := e.parseFile(ctx, location, visited, depth+1); err != nil {
+			return fmt.Errorf("table of contents %s: %w", location, err)
+		}
+	}
+	return nil
+}
+
+func resolveLocation(parent string, ref string) (string, error) {
+	if download.IsURL(ref) || blob.IsURI(ref) || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	if download.IsURL(parent) || blob.IsURI(parent) {
+		base, err := url.Parse(parent)
+		if err != nil {
+			return "", err
+		}
+		rel, err := url.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(rel).String(), nil
+	}
+	return filepath.Join(filepath.Dir(parent), ref), nil
+}
@@ -0,0 +1,137 @@
+package extract
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// maxPlanExamples is how many locations a PlanSummary keeps as examples.
+const maxPlanExamples = 3
+
+// PlanSummary is what unique plans mode saw of one lowercase description:
+// how many in_network_files elements carried it, its plan type, the
+// distinct plan codes of their locations and the first few locations.
+type PlanSummary struct {
+	Plan      string   `json:"plan"`
+	Count     int      `json:"count"`
+	PlanType  string   `json:"planType"`
+	PlanCodes []string `json:"planCodes"`
+	Examples  []string `json:"examples"`
+}
+
+type planStats struct {
+	count     int
+	planType  string
+	planCodes map[string]struct{}
+	examples  []string
+}
+
+// addPlan counts an element of the lowercase description at location,
+// classifying the description the first time it is seen.
+func (e *Extractor) addPlan(ctx context.Context, lowerDesc, location string) {
+	stats := e.planStats(lowerDesc)
+	if stats.count == 0 {
+		stats.planType = e.planTypeOf(ctx, lowerDesc)
+	}
+	stats.count++
+	if code, err := ExtractPlanCode(location); err == nil {
+		stats.planCodes[code] = struct{}{}
+	}
+	if len(stats.examples) < maxPlanExamples && location != "" && !slices.Contains(stats.examples, location) {
+		stats.examples = append(stats.examples, location)
+	}
+}
+
+func (e *Extractor) planStats(lowerDesc string) *planStats {
+	stats, ok := e.plansFound[lowerDesc]
+	if !ok {
+		stats = &planStats{planCodes: make(map[string]struct{})}
+		e.plansFound[lowerDesc] = stats
+	}
+	return stats
+}
+
+// mergePlan adds a summary of another extractor or a checkpoint.
+func (e *Extractor) mergePlan(summary PlanSummary) {
+	e.planStats(summary.Plan).merge(summary)
+}
+
+func (stats *planStats) merge(summary PlanSummary) {
+	stats.count += summary.Count
+	if stats.planType == "" {
+		stats.planType = summary.PlanType
+	}
+	for _, code := range summary.PlanCodes {
+		stats.planCodes[code] = struct{}{}
+	}
+	for _, location := range summary.Examples {
+		if len(stats.examples) < maxPlanExamples && !slices.Contains(stats.examples, location) {
+			stats.examples = append(stats.examples, location)
+		}
+	}
+}
+
+// PlanSummaries returns the unique plans mode descriptions, most frequent
+// first and then by description.
+func (e *Extractor) PlanSummaries() []PlanSummary {
+	return summarizePlans(e.plansFound)
+}
+
+func summarizePlans(plans map[string]*planStats) []PlanSummary {
+	result := make([]PlanSummary, 0, len(plans))
+	for plan, stats := range plans {
+		// empty lists rather than null for descriptions without plan codes
+		codes := slices.AppendSeq([]string{}, maps.Keys(stats.planCodes))
+		slices.Sort(codes)
+		result = append(result, PlanSummary{
+			Plan:      plan,
+			Count:     stats.count,
+			PlanType:  stats.planType,
+			PlanCodes: codes,
+			Examples:  slices.Clone(stats.examples),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Plan < result[j].Plan
+	})
+	return result
+}
+
+// PlanTally adds up the PlanSummaries of several extractors, such as those of
+// the index files of a manifest, so a description listed by more than one
+// file is counted over all of them. It is safe for concurrent use.
+type PlanTally struct {
+	mu    sync.Mutex
+	plans map[string]*planStats
+}
+
+func NewPlanTally() *PlanTally {
+	return &PlanTally{plans: make(map[string]*planStats)}
+}
+
+// Add merges summaries into the tally.
+func (t *PlanTally) Add(summaries []PlanSummary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, summary := range summaries {
+		stats, ok := t.plans[summary.Plan]
+		if !ok {
+			stats = &planStats{planCodes: make(map[string]struct{})}
+			t.plans[summary.Plan] = stats
+		}
+		stats.merge(summary)
+	}
+}
+
+// Summaries returns the merged descriptions ordered like PlanSummaries.
+func (t *PlanTally) Summaries() []PlanSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return summarizePlans(t.plans)
+}
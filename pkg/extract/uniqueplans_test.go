@@ -0,0 +1,59 @@
+package extract_test
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// TestPlanTally checks that a description listed by two index files is
+// counted over both, as in a manifest run reading them concurrently.
+func TestPlanTally(t *testing.T) {
+	indexes := []string{
+		`{"reporting_structure":[{"reporting_plans":[],"in_network_files":[
+			{"description":"Excellus BCBS : BluePPO","location":"https://example.com/2026-01_301_71A0_in-network-rates_1_of_2.json.gz"},
+			{"description":"empire bcbs : new york hmo","location":"https://example.com/2026-01_800_72A0_in-network-rates.json.gz"}
+		]}]}`,
+		`{"reporting_structure":[{"reporting_plans":[],"in_network_files":[
+			{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_301_71A0_in-network-rates_2_of_2.json.gz"},
+			{"description":"excellus bcbs : blueppo","location":"https://example.com/2026-01_302_42B0_in-network-rates.json.gz"}
+		]}]}`,
+	}
+
+	tally := extract.NewPlanTally()
+	var wg sync.WaitGroup
+	for _, index := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := extract.New(extract.Options{Mode: extract.ModeUniquePlans})
+			if err := e.Parse(strings.NewReader(index)); err != nil {
+				t.Error(err)
+				return
+			}
+			tally.Add(e.PlanSummaries())
+		}()
+	}
+	wg.Wait()
+
+	summaries := tally.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("got %d plans, want 2: %+v", len(summaries), summaries)
+	}
+	ppo := summaries[0]
+	if ppo.Plan != "excellus bcbs : blueppo" || ppo.Count != 3 {
+		t.Errorf("first plan %q seen %d times, want excellus bcbs : blueppo 3 times", ppo.Plan, ppo.Count)
+	}
+	if !slices.Equal(ppo.PlanCodes, []string{"301_71a0", "302_42b0"}) {
+		t.Errorf("plan codes %q, want those of both files", ppo.PlanCodes)
+	}
+	if len(ppo.Examples) != 3 {
+		t.Errorf("examples %q, want one of each location", ppo.Examples)
+	}
+	if hmo := summaries[1]; hmo.Plan != "empire bcbs : new york hmo" || hmo.Count != 1 {
+		t.Errorf("second plan %q seen %d times, want empire bcbs : new york hmo once", hmo.Plan, hmo.Count)
+	}
+}
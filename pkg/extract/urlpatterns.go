@@ -0,0 +1,167 @@
+package extract
+
+import (
+	"cmp"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxURLClusters bounds the clusters of a parse, the locations of further
+// patterns are counted in a cluster of their host with any path.
+const maxURLClusters = 1000
+
+// maxClusterExamples is how many locations a URLCluster lists.
+const maxClusterExamples = 3
+
+// URLPattern is the shape of a location: its host, the directories of its
+// path and its filename with the parts that change from file to file
+// replaced by placeholders. {code} is the plan code one of the
+// PlanCodePatterns reads, {date} a date, {id} a hash or uuid and {n} any
+// other number.
+type URLPattern struct {
+	Host     string `json:"host"`
+	Path     string `json:"path"`
+	Filename string `json:"filename"`
+}
+
+// URLCluster counts the in_network_files locations of one URLPattern.
+// Scheme is the name of the PlanCodePatterns entry reading their plan codes,
+// empty for the clusters a new pattern is wanted for. Examples are the
+// first few locations without their query strings, which are signatures.
+type URLCluster struct {
+	URLPattern
+	Scheme   string   `json:"scheme,omitempty"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+var (
+	urlDate   = regexp.MustCompile(`\d{4}-\d{2}(?:-\d{2})?`)
+	urlID     = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[0-9a-f]{12,}`)
+	urlNumber = regexp.MustCompile(`\d+`)
+)
+
+// URLPatternOf returns the pattern of rawURL and the name of the plan code
+// scheme matching its filename, "" when none does. The filename is taken
+// from the query string when the path does not end in one, like
+// ExtractPlanCode.
+func URLPatternOf(rawURL string) (URLPattern, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return URLPattern{Filename: templateSegment(rawURL)}, ""
+	}
+	dir, filename := path.Split(u.Path)
+	if !strings.Contains(filename, ".") {
+		// download?file=... serves the file of the query
+		query := u.Query()
+	keys:
+		for _, k := range slices.Sorted(maps.Keys(query)) {
+			for _, v := range query[k] {
+				if base := path.Base(v); strings.Contains(base, ".") {
+					dir, filename = dir+filename, base
+					break keys
+				}
+			}
+		}
+	}
+	if strings.Contains(filename, "%") {
+		if unescaped, err := url.PathUnescape(filename); err == nil {
+			filename = unescaped
+		}
+	}
+
+	var dirs []string
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment != "" {
+			dirs = append(dirs, templateSegment(segment))
+		}
+	}
+	pattern := URLPattern{Host: strings.ToLower(u.Host), Path: "/" + strings.Join(dirs, "/")}
+
+	scheme := ""
+	for _, p := range PlanCodePatterns {
+		m := p.Pattern.FindStringSubmatchIndex(filename)
+		code := 2 * p.Pattern.SubexpIndex("code")
+		if m == nil || m[code] < 0 || m[code] == m[code+1] {
+			continue
+		}
+		scheme = p.Name
+		pattern.Filename = templateSegment(filename[:m[code]]) + "{code}" + templateSegment(filename[m[code+1]:])
+		break
+	}
+	if scheme == "" {
+		pattern.Filename = templateSegment(filename)
+	}
+	return pattern, scheme
+}
+
+// templateSegment replaces the dates, ids and numbers of a path segment.
+func templateSegment(segment string) string {
+	segment = urlDate.ReplaceAllString(segment, "{date}")
+	segment = urlID.ReplaceAllStringFunc(segment, func(id string) string {
+		// words that happen to be hex digits are kept
+		if !strings.ContainsAny(id, "0123456789") {
+			return id
+		}
+		return "{id}"
+	})
+	return urlNumber.ReplaceAllString(segment, "{n}")
+}
+
+// addURLPattern counts location in the cluster of its pattern.
+func (e *Extractor) addURLPattern(location string) {
+	pattern, scheme := URLPatternOf(location)
+	e.addURLCluster(URLCluster{URLPattern: pattern, Scheme: scheme, Count: 1, Examples: []string{stripQuery(location)}})
+}
+
+// addURLCluster merges c into the clusters of e.
+func (e *Extractor) addURLCluster(c URLCluster) {
+	if e.urlClusters == nil {
+		e.urlClusters = make(map[URLPattern]*URLCluster)
+	}
+	cluster, seen := e.urlClusters[c.URLPattern]
+	if !seen && len(e.urlClusters) >= maxURLClusters {
+		c.URLPattern = URLPattern{Host: c.Host, Path: "*", Filename: "*"}
+		c.Scheme = ""
+		cluster, seen = e.urlClusters[c.URLPattern]
+	}
+	if !seen {
+		cluster = &URLCluster{URLPattern: c.URLPattern, Scheme: c.Scheme, Examples: []string{}}
+		e.urlClusters[c.URLPattern] = cluster
+	}
+	cluster.Count += c.Count
+	for _, example := range c.Examples {
+		if len(cluster.Examples) < maxClusterExamples && !slices.Contains(cluster.Examples, example) {
+			cluster.Examples = append(cluster.Examples, example)
+		}
+	}
+}
+
+// URLClusters returns the location clusters of the analysis mode parses so
+// far with Options.URLPatterns, the largest first.
+func (e *Extractor) URLClusters() []URLCluster {
+	clusters := make([]URLCluster, 0, len(e.urlClusters))
+	for _, cluster := range e.urlClusters {
+		clusters = append(clusters, *cluster)
+	}
+	slices.SortFunc(clusters, func(a, b URLCluster) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			cmp.Compare(a.Host, b.Host),
+			cmp.Compare(a.Path, b.Path),
+			cmp.Compare(a.Filename, b.Filename),
+		)
+	})
+	return clusters
+}
+
+// stripQuery drops the query string and fragment of location.
+func stripQuery(location string) string {
+	location, _, _ = strings.Cut(location, "#")
+	location, _, _ = strings.Cut(location, "?")
+	return location
+}
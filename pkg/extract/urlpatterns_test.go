@@ -0,0 +1,81 @@
+package extract_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+func TestURLPatternOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     extract.URLPattern
+		scheme   string
+	}{
+		{
+			name:     "bcbs shard",
+			location: "https://CDN.example.com/2026-01_254_39B0_in-network-rates_2_of_3.json.gz?X-Amz-Signature=abc",
+			want:     extract.URLPattern{Host: "cdn.example.com", Path: "/", Filename: "{date}_{code}_in-network-rates_{n}_of_{n}.json.gz"},
+			scheme:   "bcbs",
+		},
+		{
+			name:     "uhc",
+			location: "https://uhc.example.com/public-mrf/2026-01-01/2026-01-01_UnitedHealthcare-of-New-York--Inc-_Insurer_Choice-Plus_CSP-8-A2_in-network-rates.json.gz",
+			want:     extract.URLPattern{Host: "uhc.example.com", Path: "/public-mrf/{date}", Filename: "{date}_UnitedHealthcare-of-New-York--Inc-_Insurer_Choice-Plus_{code}_in-network-rates.json.gz"},
+			scheme:   "uhc",
+		},
+		{
+			name:     "ids and numbers",
+			location: "https://mrf.example.com/files/6f1c2e9a-3b4d-4e5f-8a9b-0c1d2e3f4a5b/v2/rates_9f86d081884c7d659a2f.json",
+			want:     extract.URLPattern{Host: "mrf.example.com", Path: "/files/{id}/v{n}", Filename: "rates_{id}.json"},
+		},
+		{
+			name:     "query filename",
+			location: "https://cdn.example.com/download?file=2026-01_302_42B0_in-network-rates.json.gz",
+			want:     extract.URLPattern{Host: "cdn.example.com", Path: "/download", Filename: "{date}_{code}_in-network-rates.json.gz"},
+			scheme:   "bcbs",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, scheme := extract.URLPatternOf(tc.location)
+			if got != tc.want || scheme != tc.scheme {
+				t.Errorf("URLPatternOf(%q) = %+v, %q, want %+v, %q", tc.location, got, scheme, tc.want, tc.scheme)
+			}
+		})
+	}
+}
+
+// TestURLClusters checks that every location analysis mode reads is counted
+// once, with record workers too.
+func TestURLClusters(t *testing.T) {
+	index := synth.Index(goldenIndex)
+	var want string
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			e := extract.New(extract.Options{Mode: extract.ModeAnalysis, Workers: workers, URLPatterns: true})
+			if err := e.Parse(bytes.NewReader(index)); err != nil {
+				t.Fatal(err)
+			}
+			clusters := e.URLClusters()
+			if len(clusters) == 0 {
+				t.Fatal("no url clusters")
+			}
+			for i, cluster := range clusters {
+				if cluster.Count == 0 || len(cluster.Examples) == 0 || i > 0 && cluster.Count > clusters[i-1].Count {
+					t.Errorf("cluster %d: %+v", i, cluster)
+				}
+			}
+			got := fmt.Sprint(clusters)
+			if want == "" {
+				want = got
+			} else if got != want {
+				t.Errorf("clusters differ from those without workers:\n%s\n%s", got, want)
+			}
+		})
+	}
+}
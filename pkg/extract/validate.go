@@ -0,0 +1,348 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"serif_interview/pkg/download"
+)
+
+// SchemaViolation is a place where an index file breaks the CMS
+// Transparency-in-Coverage table of contents schema. Path is the JSON pointer
+// of the offending value and Line the line the reporting_structure element
+// holding it starts on, or of the value itself outside of one.
+type SchemaViolation struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Rule string `json:"rule"`
+}
+
+// ValidationReport sums up a validated index file. Valid is false when any
+// violation was found, Rules counts them by rule.
+type ValidationReport struct {
+	File       string         `json:"file"`
+	Valid      bool           `json:"valid"`
+	Records    int            `json:"records"`
+	Violations int            `json:"violations"`
+	Rules      map[string]int `json:"rules"`
+}
+
+// ValidateOptions configures ValidateFile.
+type ValidateOptions struct {
+	Limits download.Limits
+	// OnViolation receives each violation as it is found.
+	OnViolation func(SchemaViolation)
+}
+
+var (
+	indexRootRequired = []string{"reporting_entity_name", "reporting_entity_type", "version", "reporting_structure"}
+	planRequired      = []string{"plan_name", "plan_id_type", "plan_id", "plan_market_type"}
+	fileRequired      = []string{"description", "location"}
+	planIDTypes       = []string{"ein", "hios"}
+	planMarketTypes   = []string{"group", "individual"}
+)
+
+// ValidateFile streams an index file, compressed or not, and checks it
+// against the schema. Malformed JSON ends the file as a violation at the
+// line it was found on, only failures to read the file are returned as
+// errors.
+func ValidateFile(ctx context.Context, filename string, opts ValidateOptions) (*ValidationReport, error) {
+	filestream, _, err := openStream(ctx, filename, opts.Limits)
+	if err != nil {
+		return nil, err
+	}
+	defer filestream.Close()
+
+	r, err := decompress(&contextReader{ctx: ctx, r: filestream}, opts.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+	defer r.Close()
+
+	report, err := Validate(r, opts.OnViolation)
+	if report != nil {
+		report.File = filename
+	}
+	return report, err
+}
+
+// Validate checks an uncompressed index document from r.
+func Validate(r io.Reader, onViolation func(SchemaViolation)) (*ValidationReport, error) {
+	lines := &lineReader{r: r}
+	v := &validator{
+		dec:         json.NewDecoder(lines),
+		lines:       lines,
+		onViolation: onViolation,
+		report:      &ValidationReport{Rules: make(map[string]int)},
+	}
+	err := v.root()
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		v.violate("", v.lines.line(syntaxErr.Offset), "invalid JSON: "+syntaxErr.Error())
+		err = nil
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		v.violate("", v.lines.line(v.dec.InputOffset()), "file ends mid document")
+		err = nil
+	}
+	v.report.Valid = v.report.Violations == 0
+	return v.report, err
+}
+
+type validator struct {
+	dec         *json.Decoder
+	lines       *lineReader
+	onViolation func(SchemaViolation)
+	report      *ValidationReport
+}
+
+func (v *validator) violate(path string, line int, rule string) {
+	v.report.Violations++
+	v.report.Rules[rule]++
+	if v.onViolation != nil {
+		v.onViolation(SchemaViolation{Path: path, Line: line, Rule: rule})
+	}
+}
+
+func (v *validator) root() error {
+	tok, err := v.dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		v.violate("", v.lines.line(v.dec.InputOffset()), "root is not an object")
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	for v.dec.More() {
+		keyTok, err := v.dec.Token()
+		if err != nil {
+			return err
+		}
+		key := keyTok.(string)
+		seen[key] = struct{}{}
+		path := "/" + key
+
+		if key == "reporting_structure" {
+			if err := v.reportingStructure(path); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, line, err := v.element()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "reporting_entity_name", "reporting_entity_type", "version":
+			if !isString(raw) {
+				v.violate(path, line, key+" is not a string")
+			}
+		}
+	}
+	end := v.lines.line(v.dec.InputOffset())
+	if _, err := v.dec.Token(); err != nil {
+		return err
+	}
+	for _, key := range indexRootRequired {
+		if _, ok := seen[key]; !ok {
+			v.violate("/"+key, end, "required key "+key+" is missing")
+		}
+	}
+	return nil
+}
+
+func (v *validator) reportingStructure(path string) error {
+	tok, err := v.dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		v.violate(path, v.lines.line(v.dec.InputOffset()), "reporting_structure is not an array")
+		if ok && d == '{' {
+			return v.skipRest()
+		}
+		return nil
+	}
+
+	for i := 0; v.dec.More(); i++ {
+		raw, line, err := v.element()
+		if err != nil {
+			return err
+		}
+		v.report.Records++
+		v.record(fmt.Sprintf("%s/%d", path, i), line, raw)
+	}
+	_, err = v.dec.Token()
+	return err
+}
+
+// skipRest reads up to the end of the object just opened.
+func (v *validator) skipRest() error {
+	for v.dec.More() {
+		// key and value
+		for range 2 {
+			var discard json.RawMessage
+			if err := v.dec.Decode(&discard); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := v.dec.Token()
+	return err
+}
+
+// element reads the next value and the line it starts on.
+func (v *validator) element() (json.RawMessage, int, error) {
+	var raw json.RawMessage
+	if err := v.dec.Decode(&raw); err != nil {
+		return nil, 0, err
+	}
+	start := v.dec.InputOffset() - int64(len(raw))
+	return raw, v.lines.line(start), nil
+}
+
+func (v *validator) record(path string, line int, raw json.RawMessage) {
+	var record map[string]json.RawMessage
+	if json.Unmarshal(raw, &record) != nil {
+		v.violate(path, line, "reporting_structure element is not an object")
+		return
+	}
+
+	if plans, ok := record["reporting_plans"]; ok {
+		v.array(path+"/reporting_plans", line, plans, "reporting_plans", true, v.plan)
+	} else {
+		v.violate(path+"/reporting_plans", line, "required key reporting_plans is missing")
+	}
+
+	networks, hasNetworks := record["in_network_files"]
+	allowed, hasAllowed := record["allowed_amount_file"]
+	if !hasNetworks && !hasAllowed {
+		v.violate(path, line, "reporting_structure element has neither in_network_files nor allowed_amount_file")
+	}
+	if hasNetworks {
+		v.array(path+"/in_network_files", line, networks, "in_network_files", false, v.file)
+	}
+	if hasAllowed {
+		v.file(path+"/allowed_amount_file", line, allowed)
+	}
+}
+
+// array checks each element of an array value with check.
+func (v *validator) array(path string, line int, raw json.RawMessage, name string, nonEmpty bool, check func(string, int, json.RawMessage)) {
+	var elements []json.RawMessage
+	if json.Unmarshal(raw, &elements) != nil {
+		v.violate(path, line, name+" is not an array")
+		return
+	}
+	if nonEmpty && len(elements) == 0 {
+		v.violate(path, line, name+" is empty")
+	}
+	for i, element := range elements {
+		check(fmt.Sprintf("%s/%d", path, i), line, element)
+	}
+}
+
+func (v *validator) plan(path string, line int, raw json.RawMessage) {
+	fields, ok := v.object(path, line, raw, "reporting_plans element", planRequired)
+	if !ok {
+		return
+	}
+	idType := strings.ToLower(fields["plan_id_type"])
+	if _, ok := fields["plan_id_type"]; ok && !slices.Contains(planIDTypes, idType) {
+		v.violate(path+"/plan_id_type", line, "plan_id_type is not EIN or HIOS")
+	}
+	if id, ok := fields["plan_id"]; ok && idType == "ein" && len(NormalizeEIN(id)) != 9 {
+		v.violate(path+"/plan_id", line, "EIN plan_id does not have 9 digits")
+	}
+	if market, ok := fields["plan_market_type"]; ok && !slices.Contains(planMarketTypes, strings.ToLower(market)) {
+		v.violate(path+"/plan_market_type", line, "plan_market_type is not group or individual")
+	}
+}
+
+func (v *validator) file(path string, line int, raw json.RawMessage) {
+	fields, ok := v.object(path, line, raw, "file", fileRequired)
+	if !ok {
+		return
+	}
+	if location, ok := fields["location"]; ok {
+		u, err := url.Parse(location)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.violate(path+"/location", line, "location is not an http or https url")
+		}
+	}
+}
+
+// object checks that raw is an object whose required keys are non-empty
+// strings, returning those that are.
+func (v *validator) object(path string, line int, raw json.RawMessage, name string, required []string) (map[string]string, bool) {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(raw, &object) != nil {
+		v.violate(path, line, name+" is not an object")
+		return nil, false
+	}
+	fields := make(map[string]string, len(required))
+	for _, key := range required {
+		value, ok := object[key]
+		if !ok {
+			v.violate(path+"/"+key, line, "required key "+key+" is missing")
+			continue
+		}
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			v.violate(path+"/"+key, line, key+" is not a string")
+			continue
+		}
+		if strings.TrimSpace(s) == "" {
+			v.violate(path+"/"+key, line, key+" is empty")
+			continue
+		}
+		fields[key] = s
+	}
+	return fields, true
+}
+
+func isString(raw json.RawMessage) bool {
+	var s string
+	return json.Unmarshal(raw, &s) == nil
+}
+
+// lineReader remembers where the lines of a stream start, so the offsets the
+// decoder reports can be turned into line numbers. Offsets are asked for in
+// increasing order and the newlines before them are forgotten.
+type lineReader struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	// passed counts the forgotten newlines
+	passed int
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			l.newlines = append(l.newlines, l.read+int64(i))
+		}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// line returns the 1-based line of offset.
+func (l *lineReader) line(offset int64) int {
+	i := 0
+	for i < len(l.newlines) && l.newlines[i] < offset {
+		i++
+	}
+	l.passed += i
+	l.newlines = l.newlines[i:]
+	return l.passed + 1
+}
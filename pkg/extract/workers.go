@@ -0,0 +1,248 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+)
+
+// recordsInFlight is how many records per worker may be decoded ahead of the
+// oldest record still being matched.
+const recordsInFlight = 4
+
+type recordJob struct {
+	index int
+	raw   json.RawMessage
+	// offset and end are where the record starts and ends in the decoder's
+	// input
+	offset int64
+	end    int64
+	// err fails a record that is not an object without matching it
+	err error
+}
+
+// recordResult is what matching a single record produced, collected by a
+// private Extractor so workers share no state.
+type recordResult struct {
+	index      int
+	offset     int64
+	end        int64
+	child      *Extractor
+	matches    []Match
+	quarantine bytes.Buffer
+	latency    time.Duration
+	err        error
+}
+
+// parseReportingStructureConcurrent is parseReportingStructure with the
+// records decoded as a whole and fanned out to e.workers goroutines. The
+// results are merged back in record order.
+func (e *Extractor) parseReportingStructureConcurrent(ctx context.Context, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read reporting_structure value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("reporting_structure is not an array")
+	}
+	if err := e.skipResumed(dec); err != nil {
+		return err
+	}
+
+	workers := max(e.workers, 1)
+	jobs := make(chan recordJob)
+	done := make(chan *recordResult, workers)
+	slots := make(chan struct{}, workers*recordsInFlight)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				done <- e.matchRecord(job)
+			}
+		}()
+	}
+
+	merged := make(chan error, 1)
+	next := e.recordIndex + 1
+	go func() {
+		pending := make(map[int]*recordResult)
+		var err error
+		for res := range done {
+			pending[res.index] = res
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-slots
+
+				if err != nil {
+					continue
+				}
+				if r.err != nil && e.keepGoing {
+					e.skipRecord(r.index, r.offset, r.err)
+					e.recordDone(r.index+1, r.end)
+					continue
+				}
+				if r.err != nil {
+					err = r.err
+					if r.child != nil {
+						e.failedAt = r.child.failedAt
+					}
+					close(stop)
+					continue
+				}
+				err = e.mergeRecord(r)
+				if err != nil {
+					close(stop)
+					continue
+				}
+				e.recordDone(r.index+1, r.end)
+			}
+		}
+		merged <- err
+	}()
+
+	var readErr error
+dispatch:
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			readErr = err
+			break
+		}
+		var raw json.RawMessage
+		start := dec.InputOffset()
+		if err := dec.Decode(&raw); err != nil {
+			readErr = fmt.Errorf("read reporting_structure element: %w", err)
+			e.failAt(fmt.Sprintf("/reporting_structure/%d", e.recordIndex+1), start)
+			break
+		}
+		job := recordJob{raw: raw, offset: dec.InputOffset() - int64(len(raw)), end: dec.InputOffset()}
+		if len(raw) == 0 || raw[0] != '{' {
+			if !e.keepGoing {
+				readErr = errors.New("expected object in reporting_structure array")
+				break
+			}
+			job.err = errors.New("expected object in reporting_structure array")
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-stop:
+			break dispatch
+		}
+		e.recordIndex++
+		e.progress.records.Add(1)
+		job.index = e.recordIndex
+		jobs <- job
+	}
+
+	close(jobs)
+	wg.Wait()
+	close(done)
+	if err := <-merged; err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close reporting_structure array: %w", err)
+	}
+
+	return nil
+}
+
+// matchRecord runs scanReportingRecord over one decoded record.
+func (e *Extractor) matchRecord(job recordJob) *recordResult {
+	res := &recordResult{index: job.index, offset: job.offset, end: job.end, err: job.err}
+	if job.err != nil {
+		return res
+	}
+	start := time.Now()
+	defer func() { res.latency = time.Since(start) }()
+
+	var quarantine io.Writer
+	if e.quarantine != nil {
+		quarantine = &res.quarantine
+	}
+	// the child asks the llm itself, the workers overlap the calls
+	s := e.settings
+	s.quarantine = quarantine
+	s.onMatch = func(m Match) { res.matches = append(res.matches, m) }
+	s.llmWorkers = 0
+	res.child = newExtractor(s)
+	res.child.recordIndex = job.index
+	res.child.streamBase = e.streamBase + job.offset
+
+	dec := json.NewDecoder(bytes.NewReader(job.raw))
+	if _, err := dec.Token(); err != nil {
+		res.err = fmt.Errorf("read reporting_structure element: %w", err)
+	} else {
+		res.err = res.child.scanReportingRecord(dec)
+	}
+	if res.err != nil {
+		res.child.failAt(fmt.Sprintf("/reporting_structure/%d", job.index), dec.InputOffset())
+	}
+	// batches do not span records here, the workers overlap the calls
+	res.child.flushAnalysis(context.Background())
+	return res
+}
+
+// mergeRecord folds the results of one record into e.
+func (e *Extractor) mergeRecord(r *recordResult) error {
+	if r.quarantine.Len() > 0 {
+		if _, err := e.quarantine.Write(r.quarantine.Bytes()); err != nil {
+			return fmt.Errorf("write quarantine: %w", err)
+		}
+	}
+
+	child := r.child
+	before := len(e.uniquePpoPrices)
+	e.quarantinedCount += child.quarantinedCount
+	e.denied += child.denied
+	for _, cluster := range child.urlClusters {
+		e.addURLCluster(*cluster)
+	}
+	e.matchCount += child.matchCount
+	for key, location := range child.uniquePpoPrices {
+		e.addPpoPrice(location, child.ppoSources[key])
+	}
+	for _, summary := range child.PlanSummaries() {
+		e.mergePlan(summary)
+	}
+	for k := range child.descriptions {
+		e.descriptions[k] = struct{}{}
+	}
+	for _, plan := range child.Coverage() {
+		e.addCoverage([]ReportingPlan{plan.Plan}, plan.Networks)
+	}
+	for location, description := range child.drugFiles {
+		e.drugFiles[location] = description
+	}
+	for location := range child.tocFiles {
+		e.tocFiles[location] = struct{}{}
+	}
+	maps.Copy(e.fuzzyScores, child.fuzzyScores)
+	maps.Copy(e.embeddingScores, child.embeddingScores)
+	e.mergeCounts(child.counts)
+	e.shards.Merge(child.shards)
+	for _, m := range r.matches {
+		e.onMatch(m)
+	}
+	e.observeRecord(r.latency, before)
+	return nil
+}
@@ -0,0 +1,75 @@
+package extract_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"serif_interview/internal/synth"
+	"serif_interview/pkg/extract"
+)
+
+// hashClient answers by a hash of the prompt and the input, so the answers
+// depend on which system prompt was asked but not on the order of the calls.
+type hashClient struct{}
+
+func (hashClient) Generate(ctx context.Context, system string, input string) (string, error) {
+	h := fnv.New32a()
+	h.Write([]byte(system))
+	h.Write([]byte(input))
+	return fmt.Sprintf(`{"answer": %t, "confidence": 0.9}`, h.Sum32()%2 == 0), nil
+}
+
+// TestWorkersMatchInline checks that record workers produce the results of
+// inline matching with every option that changes the matching set, so an
+// option the workers lose shows up here.
+func TestWorkersMatchInline(t *testing.T) {
+	index := synth.Index(goldenIndex)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "state.tmpl"), []byte("Is this plan sold in {{.State}}?"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := extract.LoadPrompts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := []extract.Mode{extract.ModeHeuristics, extract.ModeUniquePlans, extract.ModeAnalysis, extract.ModeCoverage, extract.ModeStats}
+	for _, mode := range modes {
+		t.Run(string(mode), func(t *testing.T) {
+			opts := func(workers int) extract.Options {
+				return extract.Options{
+					Mode:                 mode,
+					Workers:              workers,
+					DenyPatterns:         []*regexp.Regexp{regexp.MustCompile(`(?i)\bdental\b`)},
+					PlanFilter:           regexp.MustCompile(`(?i)[aeiou]`),
+					FuzzyThreshold:       0.8,
+					MinScore:             0.1,
+					LLM:                  hashClient{},
+					LLMBatchSize:         3,
+					PlanTypeLLM:          true,
+					Prompts:              prompts,
+					MaxDescriptionLength: 60,
+					SourcePaths:          true,
+					URLPatterns:          true,
+					DetectCarrier:        true,
+				}
+			}
+			inline, err := json.MarshalIndent(parseGolden(t, opts(1), index), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			concurrent, err := json.MarshalIndent(parseGolden(t, opts(4), index), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(inline) != string(concurrent) {
+				t.Errorf("workers=4 results differ from workers=1\n%s", diffLines(string(inline), string(concurrent)))
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package extractpb holds the protobuf messages and gRPC stubs of the
+// Extractor service defined in proto/extractor/v1/extractor.proto.
+package extractpb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=serif_interview --go-grpc_out=../.. --go-grpc_opt=module=serif_interview extractor/v1/extractor.proto
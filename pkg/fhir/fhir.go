@@ -0,0 +1,210 @@
+// Package fhir maps the plans and networks found in an index file onto
+// FHIR R4 resources shaped after the Da Vinci PDex Plan-Net profiles: the
+// reporting entity becomes a payer Organization, each network an
+// Organization of type ntwk with its rate files as Endpoints, and each
+// reporting plan an InsurancePlan referencing its networks. The resources
+// only carry what the index file states, they are not validated against the
+// profiles.
+package fhir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"serif_interview/pkg/extract"
+)
+
+const (
+	organizationTypeSystem = "http://terminology.hl7.org/CodeSystem/organization-type"
+	connectionTypeSystem   = "http://terminology.hl7.org/CodeSystem/endpoint-connection-type"
+	einSystem              = "urn:oid:2.16.840.1.113883.4.4"
+
+	planNetProfile = "http://hl7.org/fhir/us/davinci-pdex-plan-net/StructureDefinition/"
+)
+
+// Resource is any of the resources written by Resources.
+type Resource interface {
+	// Reference is the relative reference to the resource, e.g.
+	// Organization/1a2b.
+	Reference() string
+}
+
+type Meta struct {
+	Profile []string `json:"profile,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Identifier struct {
+	System string           `json:"system,omitempty"`
+	Type   *CodeableConcept `json:"type,omitempty"`
+	Value  string           `json:"value"`
+}
+
+type Reference struct {
+	Reference string `json:"reference"`
+	Display   string `json:"display,omitempty"`
+}
+
+type Organization struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id"`
+	Meta         *Meta             `json:"meta,omitempty"`
+	Active       bool              `json:"active"`
+	Type         []CodeableConcept `json:"type"`
+	Name         string            `json:"name"`
+	PartOf       *Reference        `json:"partOf,omitempty"`
+	Endpoint     []Reference       `json:"endpoint,omitempty"`
+}
+
+func (o Organization) Reference() string {
+	return "Organization/" + o.ID
+}
+
+type Endpoint struct {
+	ResourceType   string            `json:"resourceType"`
+	ID             string            `json:"id"`
+	Status         string            `json:"status"`
+	ConnectionType Coding            `json:"connectionType"`
+	Name           string            `json:"name,omitempty"`
+	PayloadType    []CodeableConcept `json:"payloadType"`
+	Address        string            `json:"address"`
+}
+
+func (e Endpoint) Reference() string {
+	return "Endpoint/" + e.ID
+}
+
+type InsurancePlan struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id"`
+	Meta         *Meta             `json:"meta,omitempty"`
+	Identifier   []Identifier      `json:"identifier,omitempty"`
+	Status       string            `json:"status"`
+	Type         []CodeableConcept `json:"type,omitempty"`
+	Name         string            `json:"name"`
+	OwnedBy      *Reference        `json:"ownedBy,omitempty"`
+	Network      []Reference       `json:"network,omitempty"`
+}
+
+func (p InsurancePlan) Reference() string {
+	return "InsurancePlan/" + p.ID
+}
+
+// Resources returns the payer, the rate file endpoints, the networks and
+// then the plans, so references always point backwards. Ids are derived from
+// the content, the same network or plan gets the same id in every file and
+// run.
+func Resources(header extract.IndexHeader, coverage []extract.PlanCoverage) []Resource {
+	var resources []Resource
+
+	var payer *Reference
+	if header.ReportingEntityName != "" {
+		org := Organization{
+			ResourceType: "Organization",
+			ID:           id("payer", header.ReportingEntityName),
+			Active:       true,
+			Type:         []CodeableConcept{{Coding: []Coding{{System: organizationTypeSystem, Code: "pay", Display: "Payer"}}}},
+			Name:         header.ReportingEntityName,
+		}
+		resources = append(resources, org)
+		payer = &Reference{Reference: org.Reference(), Display: org.Name}
+	}
+
+	var networks []*Organization
+	networksByName := make(map[string]*Organization)
+	endpoints := make(map[string]struct{})
+	var plans []Resource
+
+	for _, plan := range coverage {
+		var planNetworks []Reference
+		for _, file := range plan.Networks {
+			network, exists := networksByName[file.Description]
+			if !exists {
+				network = &Organization{
+					ResourceType: "Organization",
+					ID:           id("network", header.ReportingEntityName, file.Description),
+					Meta:         &Meta{Profile: []string{planNetProfile + "plannet-Network"}},
+					Active:       true,
+					Type:         []CodeableConcept{{Coding: []Coding{{System: organizationTypeSystem, Code: "ntwk", Display: "Network"}}}},
+					Name:         file.Description,
+					PartOf:       payer,
+				}
+				networksByName[file.Description] = network
+				networks = append(networks, network)
+			}
+
+			endpoint := Endpoint{
+				ResourceType:   "Endpoint",
+				ID:             id("endpoint", file.Location),
+				Status:         "active",
+				ConnectionType: Coding{System: connectionTypeSystem, Code: "rest-non-fhir"},
+				Name:           "in-network rates",
+				PayloadType:    []CodeableConcept{{Text: "CMS Transparency in Coverage in-network rate file"}},
+				Address:        file.Location,
+			}
+			if _, exists := endpoints[endpoint.ID]; !exists {
+				endpoints[endpoint.ID] = struct{}{}
+				resources = append(resources, endpoint)
+			}
+			network.Endpoint = appendReference(network.Endpoint, Reference{Reference: endpoint.Reference()})
+			planNetworks = appendReference(planNetworks, Reference{Reference: network.Reference(), Display: network.Name})
+		}
+
+		plans = append(plans, insurancePlan(plan.Plan, payer, planNetworks))
+	}
+
+	for _, network := range networks {
+		resources = append(resources, *network)
+	}
+	return append(resources, plans...)
+}
+
+func insurancePlan(plan extract.ReportingPlan, payer *Reference, networks []Reference) InsurancePlan {
+	result := InsurancePlan{
+		ResourceType: "InsurancePlan",
+		ID:           id("plan", plan.IDType, plan.ID, plan.Name),
+		Meta:         &Meta{Profile: []string{planNetProfile + "plannet-InsurancePlan"}},
+		Status:       "active",
+		Name:         plan.Name,
+		OwnedBy:      payer,
+		Network:      networks,
+	}
+	if plan.ID != "" {
+		identifier := Identifier{Value: plan.ID, Type: &CodeableConcept{Text: strings.ToUpper(plan.IDType)}}
+		if strings.EqualFold(plan.IDType, "ein") {
+			identifier.System = einSystem
+		}
+		result.Identifier = append(result.Identifier, identifier)
+	}
+	if plan.MarketType != "" {
+		result.Type = []CodeableConcept{{Text: plan.MarketType}}
+	}
+	return result
+}
+
+// appendReference appends ref unless refs already holds it.
+func appendReference(refs []Reference, ref Reference) []Reference {
+	for _, r := range refs {
+		if r.Reference == ref.Reference {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
+
+// id is a stable FHIR id, at most 64 characters of [A-Za-z0-9-.].
+func id(kind string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return kind + "-" + hex.EncodeToString(sum[:12])
+}
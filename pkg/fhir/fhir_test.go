@@ -0,0 +1,123 @@
+package fhir
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+var header = extract.IndexHeader{ReportingEntityName: "Anthem", ReportingEntityType: "health insurance issuer"}
+
+// coverage is two plans sharing the blue ppo network, one rate file of
+// which both list.
+var coverage = []extract.PlanCoverage{
+	{
+		Plan: extract.ReportingPlan{Name: "Acme Corp PPO", IDType: "ein", ID: "12-3456789", MarketType: "group"},
+		Networks: []extract.NetworkFile{
+			{Description: "Blue PPO", Location: "https://example.com/blue_ppo_1.json.gz"},
+			{Description: "Blue PPO", Location: "https://example.com/blue_ppo_2.json.gz"},
+		},
+	},
+	{
+		Plan: extract.ReportingPlan{Name: "Smith LLC PPO", IDType: "hios", ID: "12345NY0010001"},
+		Networks: []extract.NetworkFile{
+			{Description: "Blue PPO", Location: "https://example.com/blue_ppo_1.json.gz"},
+			{Description: "National PPO", Location: "https://example.com/national_ppo.json.gz"},
+		},
+	},
+}
+
+func TestResources(t *testing.T) {
+	resources := Resources(header, coverage)
+
+	var order []string
+	for _, resource := range resources {
+		order = append(order, strings.SplitN(resource.Reference(), "/", 2)[0])
+	}
+	want := "Organization Endpoint Endpoint Endpoint Organization Organization InsurancePlan InsurancePlan"
+	if strings.Join(order, " ") != want {
+		t.Fatalf("resources %s, want the payer, endpoints, networks and plans", strings.Join(order, " "))
+	}
+
+	payer := resources[0].(Organization)
+	if payer.Name != "Anthem" || payer.Type[0].Coding[0].Code != "pay" || payer.PartOf != nil {
+		t.Errorf("payer %+v", payer)
+	}
+	blue := resources[4].(Organization)
+	if blue.Name != "Blue PPO" || blue.Type[0].Coding[0].Code != "ntwk" || *blue.PartOf != (Reference{Reference: payer.Reference(), Display: "Anthem"}) {
+		t.Errorf("network %+v", blue)
+	}
+	if len(blue.Endpoint) != 2 || blue.Endpoint[0].Reference != resources[1].Reference() || blue.Endpoint[1].Reference != resources[2].Reference() {
+		t.Errorf("network endpoints %+v, want both blue ppo files once", blue.Endpoint)
+	}
+	if endpoint := resources[1].(Endpoint); endpoint.Address != "https://example.com/blue_ppo_1.json.gz" || endpoint.ConnectionType.Code != "rest-non-fhir" {
+		t.Errorf("endpoint %+v", endpoint)
+	}
+
+	acme, smith := resources[6].(InsurancePlan), resources[7].(InsurancePlan)
+	if len(acme.Network) != 1 || acme.Network[0].Reference != blue.Reference() || acme.OwnedBy.Reference != payer.Reference() {
+		t.Errorf("plan networks %+v owned by %+v, want blue ppo once", acme.Network, acme.OwnedBy)
+	}
+	if len(smith.Network) != 2 || smith.Network[1].Reference != resources[5].Reference() {
+		t.Errorf("plan networks %+v, want blue and national ppo", smith.Network)
+	}
+	if id := acme.Identifier[0]; id.System != einSystem || id.Value != "12-3456789" || id.Type.Text != "EIN" {
+		t.Errorf("ein identifier %+v", id)
+	}
+	if id := smith.Identifier[0]; id.System != "" || id.Type.Text != "HIOS" {
+		t.Errorf("hios identifier %+v, want no system", id)
+	}
+	if len(acme.Type) != 1 || acme.Type[0].Text != "group" || smith.Type != nil {
+		t.Errorf("plan types %+v and %+v, want only the stated market type", acme.Type, smith.Type)
+	}
+}
+
+func TestResourceIDs(t *testing.T) {
+	first, second := Resources(header, coverage), Resources(header, coverage[1:])
+	ids := make(map[string]bool)
+	valid := regexp.MustCompile(`^[A-Za-z0-9\-.]{1,64}$`)
+	for _, resource := range first {
+		ids[resource.Reference()] = true
+		if id := strings.SplitN(resource.Reference(), "/", 2)[1]; !valid.MatchString(id) {
+			t.Errorf("invalid fhir id %q", id)
+		}
+	}
+	if len(ids) != len(first) {
+		t.Errorf("%d distinct ids for %d resources", len(ids), len(first))
+	}
+	// the same payer, network and plan get the same ids in another file
+	for _, resource := range second {
+		if !ids[resource.Reference()] {
+			t.Errorf("%s has another id than in the first file", resource.Reference())
+		}
+	}
+	// a network is the payer's, another payer's network of the same name differs
+	other := Resources(extract.IndexHeader{ReportingEntityName: "Aetna"}, coverage[:1])
+	if other[2].Reference() == first[4].Reference() {
+		t.Errorf("networks of two payers share the id %s", other[2].Reference())
+	}
+}
+
+func TestResourcesWithoutPayer(t *testing.T) {
+	resources := Resources(extract.IndexHeader{}, coverage[:1])
+	if len(resources) != 4 {
+		t.Fatalf("%d resources, want two endpoints, a network and a plan", len(resources))
+	}
+	if network := resources[2].(Organization); network.PartOf != nil {
+		t.Errorf("network part of %+v without a payer", network.PartOf)
+	}
+
+	record, err := json.Marshal(resources[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(record), "ownedBy") || !strings.Contains(string(record), `"resourceType":"InsurancePlan"`) {
+		t.Errorf("plan %s", record)
+	}
+	if len(Resources(header, nil)) != 1 {
+		t.Error("index without plans returned more than the payer")
+	}
+}
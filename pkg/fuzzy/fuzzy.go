@@ -0,0 +1,307 @@
+// Package fuzzy scores how alike two plan descriptions are, so descriptions
+// that differ from an allow-listed name only by punctuation, abbreviations or
+// spacing still match.
+package fuzzy
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// abbreviations are expanded before comparing, payers write the same
+// network both ways. Plan types such as ppo stay abbreviated, they are often
+// run into the network name as in blueppo.
+var abbreviations = map[string]string{
+	"bc":   "blue cross",
+	"bs":   "blue shield",
+	"bcbs": "blue cross blue shield",
+	"ny":   "new york",
+	"nys":  "new york state",
+	"par":  "participating",
+	"natl": "national",
+	"intl": "international",
+	"ins":  "insurance",
+	"co":   "company",
+	"inc":  "incorporated",
+}
+
+// Normalize lowercases s, replaces punctuation with spaces, expands common
+// abbreviations and collapses the whitespace.
+func Normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+
+	words := strings.Fields(s)
+	for i, word := range words {
+		if expanded, ok := abbreviations[word]; ok {
+			words[i] = expanded
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Similarity scores normalized a and b from 0 to 1, the best of their
+// Jaro-Winkler similarity, their token set ratio and the Levenshtein ratio
+// of the two without spaces, so typos, reordered words and words run
+// together or split apart all score high.
+func Similarity(a, b string) float64 {
+	return similarity(Normalize(a), Normalize(b))
+}
+
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	compactA, compactB := strings.ReplaceAll(a, " ", ""), strings.ReplaceAll(b, " ", "")
+	return max(JaroWinkler(a, b), TokenSetRatio(a, b), LevenshteinRatio(compactA, compactB))
+}
+
+// TokenSetRatio compares the distinct words of a and b, ignoring their order
+// and repetitions. Each side is written as the shared words followed by its
+// own, sorted, and the two are compared by LevenshteinRatio, so reordered
+// words cost nothing and extra words cost their length.
+func TokenSetRatio(a, b string) float64 {
+	setA, setB := tokenSet(a), tokenSet(b)
+	var common, onlyA, onlyB []string
+	for token := range setA {
+		if _, ok := setB[token]; ok {
+			common = append(common, token)
+		} else {
+			onlyA = append(onlyA, token)
+		}
+	}
+	for token := range setB {
+		if _, ok := setA[token]; !ok {
+			onlyB = append(onlyB, token)
+		}
+	}
+	slices.Sort(common)
+	slices.Sort(onlyA)
+	slices.Sort(onlyB)
+
+	shared := strings.Join(common, " ")
+	withA := strings.TrimSpace(shared + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(shared + " " + strings.Join(onlyB, " "))
+	return LevenshteinRatio(withA, withB)
+}
+
+func tokenSet(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, token := range strings.Fields(s) {
+		set[token] = struct{}{}
+	}
+	return set
+}
+
+// Levenshtein is the number of single rune insertions, deletions and
+// substitutions turning a into b.
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// LevenshteinRatio is 1 minus the edit distance relative to the combined
+// length, 1 for equal strings.
+func LevenshteinRatio(a, b string) float64 {
+	total := len([]rune(a)) + len([]rune(b))
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(Levenshtein(a, b))/float64(total)
+}
+
+// JaroWinkler is the Jaro similarity of a and b boosted by the length of
+// their common prefix, up to 4 runes.
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	window = max(window, 0)
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// Index finds the most similar of a fixed list of names.
+type Index struct {
+	names    []string
+	profiles []profile
+}
+
+func NewIndex(names []string) *Index {
+	idx := &Index{}
+	for _, name := range slices.Sorted(slices.Values(names)) {
+		idx.names = append(idx.names, name)
+		idx.profiles = append(idx.profiles, newProfile(Normalize(name)))
+	}
+	return idx
+}
+
+// Best returns the name most similar to s if it scores at least threshold,
+// otherwise "" and 0. Names whose letters show they cannot reach the
+// threshold are skipped without scoring them.
+func (idx *Index) Best(s string, threshold float64) (string, float64) {
+	query := newProfile(Normalize(s))
+	best, bestScore := "", 0.0
+	for i, name := range idx.profiles {
+		if query.bound(name) < max(threshold, bestScore) {
+			continue
+		}
+		if score := similarity(query.text, name.text); score >= threshold && score > bestScore {
+			best, bestScore = idx.names[i], score
+		}
+	}
+	return best, bestScore
+}
+
+// profile holds the letter counts of the three forms of a normalized string
+// that similarity compares: as is, without spaces, and with repeated words
+// dropped as TokenSetRatio sees it.
+type profile struct {
+	text                  string
+	plain, compact, words histogram
+}
+
+// histogram counts the ascii runes of a string, other runes disable the
+// bound.
+type histogram struct {
+	counts [128]uint16
+	// runes lists the distinct runes counted
+	runes  []byte
+	length int
+	ascii  bool
+}
+
+func newProfile(text string) profile {
+	var seen []string
+	for _, word := range strings.Fields(text) {
+		if !slices.Contains(seen, word) {
+			seen = append(seen, word)
+		}
+	}
+	return profile{
+		text:    text,
+		plain:   newHistogram(text),
+		compact: newHistogram(strings.ReplaceAll(text, " ", "")),
+		words:   newHistogram(strings.Join(seen, " ")),
+	}
+}
+
+func newHistogram(s string) histogram {
+	h := histogram{ascii: true}
+	for _, r := range s {
+		if r >= 128 {
+			h.ascii = false
+			continue
+		}
+		if h.counts[r] == 0 {
+			h.runes = append(h.runes, byte(r))
+		}
+		h.counts[r]++
+		h.length++
+	}
+	return h
+}
+
+// common is how many runes a and b can have in common, an upper bound on
+// the matches of any alignment of the two.
+func (h histogram) common(other histogram) int {
+	if len(other.runes) < len(h.runes) {
+		h, other = other, h
+	}
+	n := 0
+	for _, r := range h.runes {
+		n += int(min(h.counts[r], other.counts[r]))
+	}
+	return n
+}
+
+// bound is an upper bound of similarity between p and other. Jaro matches
+// and Levenshtein alignments can only pair equal runes, so the runes the two
+// have in common limit every measure.
+func (p profile) bound(other profile) float64 {
+	if !p.plain.ascii || !other.plain.ascii {
+		return 1
+	}
+	return max(jaroWinklerBound(p.plain, other.plain), levenshteinRatioBound(p.compact, other.compact), levenshteinRatioBound(p.words, other.words))
+}
+
+func jaroWinklerBound(a, b histogram) float64 {
+	if a.length == 0 || b.length == 0 {
+		return 1
+	}
+	m := float64(a.common(b))
+	jaro := (m/float64(a.length) + m/float64(b.length) + 1) / 3
+	return jaro + 4*0.1*(1-jaro)
+}
+
+func levenshteinRatioBound(a, b histogram) float64 {
+	total := a.length + b.length
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(max(a.length, b.length)-a.common(b))/float64(total)
+}
@@ -0,0 +1,244 @@
+// Package gzindex builds seek indexes of gzip files, like gztool and zran:
+// the decompressor state at deflate block boundaries every span of output,
+// the bit position in the compressed file and the 32KB window before it, so
+// a later read can start decompressing near any offset instead of at the
+// beginning of the file.
+package gzindex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"serif_interview/pkg/download"
+)
+
+// DefaultSpan is the output between access points when Build is given 0.
+const DefaultSpan = 8 << 20
+
+// magic starts every index file, the last byte is the format version.
+var magic = [8]byte{'g', 'z', 'i', 'd', 'x', 0, 0, 1}
+
+// Point is a deflate block boundary to start decompressing at.
+type Point struct {
+	// Out is the offset of the decompressed file the block starts at
+	Out int64
+	// In is the offset of the compressed byte holding the block's first bit
+	In int64
+	// Bits is how many bits of that byte belong to the block before
+	Bits uint8
+	// Window is the output of the gzip member before Out the block may refer
+	// back to, at most 32KB
+	Window []byte
+}
+
+// Index lists the access points of a gzip file.
+type Index struct {
+	Span             int64
+	CompressedSize   int64
+	UncompressedSize int64
+	Points           []Point
+}
+
+// Path is where the index of filename is kept.
+func Path(filename string) string {
+	return filename + ".gzidx"
+}
+
+// Build decompresses the whole gzip file r, checking its checksums, and
+// records an access point after every span bytes of output.
+func Build(r io.Reader, span int64) (*Index, error) {
+	if span <= 0 {
+		span = DefaultSpan
+	}
+	compressed := &download.CountingReader{R: r}
+	f := newInflater(bufio.NewReader(compressed))
+	ix := &Index{Span: span}
+	last := int64(0)
+	f.onBlock = func() {
+		if f.total-last < span {
+			return
+		}
+		last = f.total
+		pos := f.br.pos()
+		ix.Points = append(ix.Points, Point{
+			Out:    f.total,
+			In:     pos / 8,
+			Bits:   uint8(pos % 8),
+			Window: append([]byte(nil), f.window()...),
+		})
+	}
+	if _, err := io.Copy(io.Discard, f); err != nil {
+		return nil, err
+	}
+	ix.CompressedSize = compressed.N
+	ix.UncompressedSize = f.total
+	return ix, nil
+}
+
+// Check reports an error unless the index was built from a file of size
+// compressed bytes.
+func (ix *Index) Check(size int64) error {
+	if size != ix.CompressedSize {
+		return fmt.Errorf("index is of a %d byte file, not %d bytes", ix.CompressedSize, size)
+	}
+	return nil
+}
+
+// At returns the last access point at or before offset, the zero Point, the
+// start of the file, when there is none.
+func (ix *Index) At(offset int64) Point {
+	i := sort.Search(len(ix.Points), func(i int) bool { return ix.Points[i].Out > offset })
+	if i == 0 {
+		return Point{}
+	}
+	return ix.Points[i-1]
+}
+
+// NewReader decompresses from p on, r reading the compressed file from p.In.
+func NewReader(r io.Reader, p Point) (io.Reader, error) {
+	f := newInflater(bufio.NewReader(r))
+	if p.Out == 0 && p.In == 0 {
+		return f, nil
+	}
+	if err := f.resume(p.Bits, p.Window); err != nil {
+		return nil, fmt.Errorf("resume at compressed offset %d: %w", p.In, err)
+	}
+	f.total = p.Out
+	return f, nil
+}
+
+// Open returns the decompressed file from offset on, ra reading the
+// compressed file.
+func (ix *Index) Open(ra io.ReaderAt, offset int64) (io.Reader, error) {
+	if offset < 0 || offset > ix.UncompressedSize {
+		return nil, fmt.Errorf("offset %d outside of the %d decompressed bytes", offset, ix.UncompressedSize)
+	}
+	p := ix.At(offset)
+	r, err := NewReader(io.NewSectionReader(ra, p.In, ix.CompressedSize-p.In), p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, r, offset-p.Out); err != nil {
+		return nil, fmt.Errorf("skip to offset %d: %w", offset, err)
+	}
+	return r, nil
+}
+
+type header struct {
+	Magic            [8]byte
+	Span             int64
+	CompressedSize   int64
+	UncompressedSize int64
+	Points           uint32
+}
+
+type pointHeader struct {
+	Out    int64
+	In     int64
+	Bits   uint8
+	Window uint32
+}
+
+// WriteTo writes the index, gzipped, to w.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	counted := &countingWriter{w: w}
+	zw := gzip.NewWriter(counted)
+	bw := bufio.NewWriter(zw)
+	h := header{Magic: magic, Span: ix.Span, CompressedSize: ix.CompressedSize, UncompressedSize: ix.UncompressedSize, Points: uint32(len(ix.Points))}
+	if err := binary.Write(bw, binary.LittleEndian, h); err != nil {
+		return counted.n, err
+	}
+	for _, p := range ix.Points {
+		ph := pointHeader{Out: p.Out, In: p.In, Bits: p.Bits, Window: uint32(len(p.Window))}
+		if err := binary.Write(bw, binary.LittleEndian, ph); err != nil {
+			return counted.n, err
+		}
+		if _, err := bw.Write(p.Window); err != nil {
+			return counted.n, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return counted.n, err
+	}
+	err := zw.Close()
+	return counted.n, err
+}
+
+// Read reads an index written by WriteTo.
+func Read(r io.Reader) (*Index, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	br := bufio.NewReader(zr)
+	var h header
+	if err := binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("read index header: %w", err)
+	}
+	if h.Magic != magic {
+		return nil, errors.New("not a gzip index or of another version")
+	}
+
+	ix := &Index{Span: h.Span, CompressedSize: h.CompressedSize, UncompressedSize: h.UncompressedSize}
+	for i := range h.Points {
+		var ph pointHeader
+		if err := binary.Read(br, binary.LittleEndian, &ph); err != nil {
+			return nil, fmt.Errorf("read access point %d: %w", i, err)
+		}
+		if ph.Window > windowSize || ph.Bits > 7 {
+			return nil, fmt.Errorf("access point %d is corrupt", i)
+		}
+		p := Point{Out: ph.Out, In: ph.In, Bits: ph.Bits, Window: make([]byte, ph.Window)}
+		if _, err := io.ReadFull(br, p.Window); err != nil {
+			return nil, fmt.Errorf("read access point %d: %w", i, err)
+		}
+		ix.Points = append(ix.Points, p)
+	}
+	return ix, nil
+}
+
+// Load reads the index at path.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Save writes the index to path, replacing it only once it is complete.
+func (ix *Index) Save(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := ix.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package gzindex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"serif_interview/internal/synth"
+)
+
+// testData is an index followed by text with long repeats and random bytes,
+// so the file has fixed, dynamic and stored blocks.
+func testData() []byte {
+	data := synth.Index(synth.Options{Records: 400, Seed: 3})
+	rng := rand.New(rand.NewPCG(3, 3))
+	random := make([]byte, 200_000)
+	for i := range random {
+		random[i] = byte(rng.IntN(256))
+	}
+	data = append(data, bytes.Repeat([]byte("in-network-rates "), 20_000)...)
+	return append(data, random...)
+}
+
+func compress(t *testing.T, data []byte, level int, members int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	size := len(data)/members + 1
+	for len(data) > 0 {
+		part := data[:min(size, len(data))]
+		data = data[len(part):]
+		zw, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw.Write(part)
+		zw.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestBuildAndOpen(t *testing.T) {
+	data := testData()
+	for _, tc := range []struct {
+		name    string
+		level   int
+		members int
+	}{
+		{"default", gzip.DefaultCompression, 1},
+		{"best", gzip.BestCompression, 1},
+		{"huffman", gzip.HuffmanOnly, 1},
+		{"stored", gzip.NoCompression, 1},
+		{"members", gzip.BestSpeed, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := compress(t, data, tc.level, tc.members)
+			ix, err := Build(bytes.NewReader(file), 64<<10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ix.UncompressedSize != int64(len(data)) || ix.CompressedSize != int64(len(file)) {
+				t.Fatalf("sizes %d and %d, want %d and %d", ix.UncompressedSize, ix.CompressedSize, len(data), len(file))
+			}
+			if len(ix.Points) < 3 {
+				t.Fatalf("only %d access points", len(ix.Points))
+			}
+
+			var saved bytes.Buffer
+			if _, err := ix.WriteTo(&saved); err != nil {
+				t.Fatal(err)
+			}
+			ix, err = Read(&saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, offset := range []int64{0, 1, ix.Points[0].Out, ix.Points[2].Out + 1000, int64(len(data)) - 10, int64(len(data))} {
+				r, err := ix.Open(bytes.NewReader(file), offset)
+				if err != nil {
+					t.Fatalf("open at %d: %v", offset, err)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("read from %d: %v", offset, err)
+				}
+				if !bytes.Equal(got, data[offset:]) {
+					t.Fatalf("read from %d differs from the file", offset)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildCorrupt(t *testing.T) {
+	file := compress(t, testData(), gzip.DefaultCompression, 1)
+	file[len(file)-6] ^= 0xff
+	if _, err := Build(bytes.NewReader(file), 0); err == nil {
+		t.Error("a wrong checksum was not reported")
+	}
+	if _, err := Build(bytes.NewReader(file[:len(file)/2]), 0); err == nil {
+		t.Error("a truncated file was not reported")
+	}
+	if _, err := Build(bytes.NewReader([]byte("{}")), 0); err == nil {
+		t.Error("plain JSON was indexed")
+	}
+}
@@ -0,0 +1,575 @@
+package gzindex
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// windowSize is the history deflate back-references reach into.
+const windowSize = 1 << 15
+
+// maxMatch is the longest back-reference, decoding stops when less room than
+// this is left in the buffer.
+const maxMatch = 258
+
+var errCorrupt = errors.New("gzindex: corrupt deflate stream")
+
+// bitReader reads the bits of a deflate stream least significant first and
+// knows the position of the next unread bit.
+type bitReader struct {
+	r io.ByteReader
+	// n is the number of bytes read from r
+	n  int64
+	b  uint64
+	nb uint
+}
+
+func (br *bitReader) need(n uint) error {
+	for br.nb < n {
+		c, err := br.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		br.b |= uint64(c) << br.nb
+		br.nb += 8
+		br.n++
+	}
+	return nil
+}
+
+func (br *bitReader) bits(n uint) (uint32, error) {
+	if err := br.need(n); err != nil {
+		return 0, err
+	}
+	v := uint32(br.b & (1<<n - 1))
+	br.b >>= n
+	br.nb -= n
+	return v, nil
+}
+
+// align drops the bits left of the current byte.
+func (br *bitReader) align() {
+	br.b >>= br.nb % 8
+	br.nb -= br.nb % 8
+}
+
+// pos is the position of the next unread bit relative to where r started.
+func (br *bitReader) pos() int64 {
+	return br.n*8 - int64(br.nb)
+}
+
+// huffman is a canonical Huffman code decoded through a table indexed by the
+// next maxLen bits, each entry the symbol shifted left by 4 and its length.
+type huffman struct {
+	table  []uint16
+	maxLen uint
+}
+
+func newHuffman(lengths []uint8) (*huffman, error) {
+	var count [16]int
+	maxLen := uint(0)
+	for _, l := range lengths {
+		count[l]++
+		if uint(l) > maxLen {
+			maxLen = uint(l)
+		}
+	}
+	if maxLen == 0 {
+		// a distance code without codes, only literals follow
+		return &huffman{table: make([]uint16, 1), maxLen: 0}, nil
+	}
+	count[0] = 0
+
+	var next [16]int
+	code, left := 0, 1
+	for l := 1; l < 16; l++ {
+		left = left<<1 - count[l]
+		if left < 0 {
+			return nil, errCorrupt
+		}
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+
+	h := &huffman{table: make([]uint16, 1<<maxLen), maxLen: maxLen}
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		reversed := 0
+		for i := uint8(0); i < l; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		for j := reversed; j < len(h.table); j += 1 << l {
+			h.table[j] = uint16(symbol)<<4 | uint16(l)
+		}
+	}
+	return h, nil
+}
+
+func (br *bitReader) decode(h *huffman) (int, error) {
+	if err := br.need(h.maxLen); err != nil {
+		// the last codes of a stream may be shorter than maxLen with nothing
+		// after them in a truncated file
+		if br.nb == 0 {
+			return 0, err
+		}
+	}
+	e := h.table[br.b&(1<<h.maxLen-1)]
+	l := uint(e & 15)
+	if l == 0 || l > br.nb {
+		return 0, errCorrupt
+	}
+	br.b >>= l
+	br.nb -= l
+	return int(e >> 4), nil
+}
+
+var lengthBase = [29]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+var lengthExtra = [29]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+var distBase = [30]uint16{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+var distExtra = [30]uint8{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+var codeLengthOrder = [19]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+var fixedLit, fixedDist = fixedCodes()
+
+func fixedCodes() (*huffman, *huffman) {
+	lengths := make([]uint8, 288)
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	lit, _ := newHuffman(lengths)
+	dist := make([]uint8, 30)
+	for i := range dist {
+		dist[i] = 5
+	}
+	d, _ := newHuffman(dist)
+	return lit, d
+}
+
+type state int
+
+const (
+	stateMember state = iota
+	stateBlock
+	stateStored
+	stateHuffman
+	stateTrailer
+	stateDone
+)
+
+// inflater decompresses a gzip file, or the rest of one from an access
+// point, one deflate block at a time so block boundaries can be indexed.
+type inflater struct {
+	br    *bitReader
+	state state
+	final bool
+
+	// buf holds the history and the unread output, buf[r:w] is unread
+	buf []byte
+	r   int
+	w   int
+
+	stored   int
+	lit      *huffman
+	dist     *huffman
+	lengths  [320]uint8
+	total    int64
+	member   int64
+	crc      hash.Hash32
+	verify   bool
+	memberNo int
+
+	// onBlock is called before each block header is read
+	onBlock func()
+	err     error
+}
+
+func newInflater(r io.ByteReader) *inflater {
+	return &inflater{br: &bitReader{r: r}, buf: make([]byte, 4*windowSize+maxMatch), crc: crc32.NewIEEE()}
+}
+
+// resume positions the inflater at a block boundary inside a member, bits
+// of the first byte already used and window the member's output before it.
+func (f *inflater) resume(bits uint8, window []byte) error {
+	if bits > 0 {
+		if err := f.br.need(8); err != nil {
+			return err
+		}
+		f.br.b >>= bits
+		f.br.nb -= uint(bits)
+	}
+	f.w = copy(f.buf, window)
+	f.r = f.w
+	f.member = int64(len(window))
+	f.state = stateBlock
+	// the checksum covers the whole member, which was not read
+	f.verify = false
+	f.memberNo = 1
+	return nil
+}
+
+// window is the member's output the next block may refer back to.
+func (f *inflater) window() []byte {
+	n := int(min(f.member, windowSize))
+	return f.buf[f.w-n : f.w]
+}
+
+func (f *inflater) Read(p []byte) (int, error) {
+	for f.r == f.w {
+		if f.err != nil {
+			return 0, f.err
+		}
+		f.err = f.step()
+	}
+	n := copy(p, f.buf[f.r:f.w])
+	f.r += n
+	return n, nil
+}
+
+// step decodes until some output is produced, the buffer is full or the
+// stream ends.
+func (f *inflater) step() error {
+	f.slide()
+	for f.r == f.w {
+		var err error
+		switch f.state {
+		case stateMember:
+			err = f.readHeader()
+		case stateBlock:
+			err = f.readBlockHeader()
+		case stateStored:
+			err = f.copyStored()
+		case stateHuffman:
+			err = f.decodeHuffman()
+		case stateTrailer:
+			err = f.readTrailer()
+		case stateDone:
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slide moves the last windowSize bytes of history to the front once the
+// output reached the end of buf.
+func (f *inflater) slide() {
+	if len(f.buf)-f.w >= maxMatch*2 {
+		return
+	}
+	start := max(f.w-windowSize, 0)
+	start = min(start, f.r)
+	n := copy(f.buf, f.buf[start:f.w])
+	f.r -= start
+	f.w = n
+}
+
+func (f *inflater) emit(b []byte) {
+	if f.verify {
+		f.crc.Write(b)
+	}
+	f.total += int64(len(b))
+	f.member += int64(len(b))
+}
+
+func (f *inflater) readByte() (byte, error) {
+	b, err := f.br.bits(8)
+	return byte(b), err
+}
+
+func (f *inflater) readHeader() error {
+	if f.memberNo > 0 {
+		// members after the first are optional
+		if err := f.br.need(8); err != nil {
+			f.state = stateDone
+			return nil
+		}
+	}
+	var header [10]byte
+	for i := range header {
+		b, err := f.readByte()
+		if err != nil {
+			return err
+		}
+		header[i] = b
+	}
+	if header[0] != 0x1f || header[1] != 0x8b || header[2] != 8 {
+		return errors.New("gzindex: not a gzip stream")
+	}
+	flags := header[3]
+	if flags&0x04 != 0 {
+		lo, _ := f.readByte()
+		hi, err := f.readByte()
+		if err != nil {
+			return err
+		}
+		for range int(lo) | int(hi)<<8 {
+			if _, err := f.readByte(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, flag := range []byte{0x08, 0x10} {
+		if flags&flag == 0 {
+			continue
+		}
+		for {
+			b, err := f.readByte()
+			if err != nil {
+				return err
+			}
+			if b == 0 {
+				break
+			}
+		}
+	}
+	if flags&0x02 != 0 {
+		if _, err := f.br.bits(16); err != nil {
+			return err
+		}
+	}
+
+	f.memberNo++
+	f.member = 0
+	f.verify = true
+	f.crc.Reset()
+	f.final = false
+	f.state = stateBlock
+	return nil
+}
+
+func (f *inflater) readBlockHeader() error {
+	if f.final {
+		f.state = stateTrailer
+		return nil
+	}
+	if f.onBlock != nil {
+		f.onBlock()
+	}
+	header, err := f.br.bits(3)
+	if err != nil {
+		return err
+	}
+	f.final = header&1 == 1
+	switch header >> 1 {
+	case 0:
+		f.br.align()
+		n, err := f.br.bits(16)
+		if err != nil {
+			return err
+		}
+		complement, err := f.br.bits(16)
+		if err != nil {
+			return err
+		}
+		if n != ^complement&0xffff {
+			return errCorrupt
+		}
+		f.stored = int(n)
+		f.state = stateStored
+	case 1:
+		f.lit, f.dist = fixedLit, fixedDist
+		f.state = stateHuffman
+	case 2:
+		if err := f.readDynamic(); err != nil {
+			return err
+		}
+		f.state = stateHuffman
+	default:
+		return errCorrupt
+	}
+	return nil
+}
+
+func (f *inflater) readDynamic() error {
+	hlit, err := f.br.bits(5)
+	if err != nil {
+		return err
+	}
+	hdist, err := f.br.bits(5)
+	if err != nil {
+		return err
+	}
+	hclen, err := f.br.bits(4)
+	if err != nil {
+		return err
+	}
+	nlit, ndist := int(hlit)+257, int(hdist)+1
+	if nlit > 286 || ndist > 30 {
+		return errCorrupt
+	}
+
+	var codeLengths [19]uint8
+	for i := range int(hclen) + 4 {
+		l, err := f.br.bits(3)
+		if err != nil {
+			return err
+		}
+		codeLengths[codeLengthOrder[i]] = uint8(l)
+	}
+	cl, err := newHuffman(codeLengths[:])
+	if err != nil {
+		return err
+	}
+
+	lengths := f.lengths[:nlit+ndist]
+	for i := 0; i < len(lengths); {
+		symbol, err := f.br.decode(cl)
+		if err != nil {
+			return err
+		}
+		if symbol < 16 {
+			lengths[i] = uint8(symbol)
+			i++
+			continue
+		}
+		var repeat uint32
+		var value uint8
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return errCorrupt
+			}
+			value = lengths[i-1]
+			repeat, err = f.br.bits(2)
+			repeat += 3
+		case 17:
+			repeat, err = f.br.bits(3)
+			repeat += 3
+		default:
+			repeat, err = f.br.bits(7)
+			repeat += 11
+		}
+		if err != nil {
+			return err
+		}
+		if i+int(repeat) > len(lengths) {
+			return errCorrupt
+		}
+		for range repeat {
+			lengths[i] = value
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		return errCorrupt
+	}
+
+	if f.lit, err = newHuffman(lengths[:nlit]); err != nil {
+		return err
+	}
+	f.dist, err = newHuffman(lengths[nlit:])
+	return err
+}
+
+func (f *inflater) copyStored() error {
+	if f.stored == 0 {
+		f.state = stateBlock
+		return nil
+	}
+	n := min(f.stored, len(f.buf)-f.w)
+	for i := range n {
+		b, err := f.readByte()
+		if err != nil {
+			return err
+		}
+		f.buf[f.w+i] = b
+	}
+	f.emit(f.buf[f.w : f.w+n])
+	f.w += n
+	f.stored -= n
+	return nil
+}
+
+func (f *inflater) decodeHuffman() error {
+	start := f.w
+	defer func() { f.emit(f.buf[start:f.w]) }()
+
+	for len(f.buf)-f.w >= maxMatch {
+		symbol, err := f.br.decode(f.lit)
+		if err != nil {
+			return err
+		}
+		switch {
+		case symbol < 256:
+			f.buf[f.w] = byte(symbol)
+			f.w++
+			continue
+		case symbol == 256:
+			f.state = stateBlock
+			return nil
+		case symbol > 285:
+			return errCorrupt
+		}
+
+		symbol -= 257
+		extra, err := f.br.bits(uint(lengthExtra[symbol]))
+		if err != nil {
+			return err
+		}
+		length := int(lengthBase[symbol]) + int(extra)
+
+		if f.dist.maxLen == 0 {
+			return errCorrupt
+		}
+		symbol, err = f.br.decode(f.dist)
+		if err != nil {
+			return err
+		}
+		if symbol >= 30 {
+			return errCorrupt
+		}
+		extra, err = f.br.bits(uint(distExtra[symbol]))
+		if err != nil {
+			return err
+		}
+		distance := int(distBase[symbol]) + int(extra)
+		if int64(distance) > f.member+int64(f.w-start) || distance > f.w {
+			return fmt.Errorf("%w: distance %d too far back", errCorrupt, distance)
+		}
+
+		from := f.w - distance
+		for i := range length {
+			f.buf[f.w+i] = f.buf[from+i]
+		}
+		f.w += length
+	}
+	return nil
+}
+
+func (f *inflater) readTrailer() error {
+	f.br.align()
+	sum, err := f.br.bits(32)
+	if err != nil {
+		return err
+	}
+	size, err := f.br.bits(32)
+	if err != nil {
+		return err
+	}
+	if f.verify && (sum != f.crc.Sum32() || size != uint32(f.member)) {
+		return errors.New("gzindex: gzip checksum or size mismatch")
+	}
+	f.state = stateMember
+	return nil
+}
@@ -0,0 +1,264 @@
+// Package hospital reads the standard charge files hospitals publish under
+// the CMS hospital price transparency rule, JSON template version 2, and
+// compares their charges with the negotiated rates payers publish for the
+// same hospital.
+package hospital
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"serif_interview/pkg/rates"
+)
+
+// Code is an element of code_information.
+type Code struct {
+	Code string `json:"code"`
+	Type string `json:"type"`
+}
+
+// PayerCharge is an element of payers_information, the hospital's view of
+// what a payer's plan pays.
+type PayerCharge struct {
+	PayerName       string   `json:"payer_name"`
+	PlanName        string   `json:"plan_name"`
+	Dollar          *float64 `json:"standard_charge_dollar,omitempty"`
+	Percentage      *float64 `json:"standard_charge_percentage,omitempty"`
+	Algorithm       string   `json:"standard_charge_algorithm,omitempty"`
+	EstimatedAmount *float64 `json:"estimated_amount,omitempty"`
+	Methodology     string   `json:"methodology,omitempty"`
+	Notes           string   `json:"additional_payer_notes,omitempty"`
+}
+
+// StandardCharge is an element of standard_charges.
+type StandardCharge struct {
+	Setting        string        `json:"setting"`
+	GrossCharge    *float64      `json:"gross_charge,omitempty"`
+	DiscountedCash *float64      `json:"discounted_cash,omitempty"`
+	Minimum        *float64      `json:"minimum,omitempty"`
+	Maximum        *float64      `json:"maximum,omitempty"`
+	Modifiers      []string      `json:"modifiers,omitempty"`
+	Payers         []PayerCharge `json:"payers_information,omitempty"`
+}
+
+// ChargeItem is an element of standard_charge_information.
+type ChargeItem struct {
+	Description string           `json:"description"`
+	Codes       []Code           `json:"code_information"`
+	Charges     []StandardCharge `json:"standard_charges"`
+}
+
+// File is a parsed standard charge file, holding only the items with the
+// requested codes.
+type File struct {
+	Name         string
+	HospitalName string
+	Locations    []string
+	// NPIs are the type 2 NPIs of the hospital, from type_2_npi or given
+	// explicitly for files published before template 2.2.
+	NPIs []string
+
+	items map[string][]ChargeItem
+}
+
+// Load parses a standard charge file, gzip compressed when its name ends in
+// .gz. Only items with one of codes are kept, all items when codes is empty.
+func Load(filename string, codes []string) (*File, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open hospital file: %s - %w", filename, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(filename), ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip stream: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	file, err := Parse(r, codes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	file.Name = filename
+	return file, nil
+}
+
+// Parse streams a standard charge document from r, see Load.
+func Parse(r io.Reader, codes []string) (*File, error) {
+	var wanted map[string]struct{}
+	if len(codes) > 0 {
+		wanted = make(map[string]struct{})
+		for _, code := range codes {
+			wanted[strings.ToUpper(strings.TrimSpace(code))] = struct{}{}
+		}
+	}
+
+	file := &File{items: make(map[string][]ChargeItem)}
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("read root token: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, errors.New("expected root object")
+	}
+
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("read root key: %w", err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return nil, errors.New("unexpected non-string key at root")
+		}
+
+		switch key {
+		case "hospital_name":
+			err = dec.Decode(&file.HospitalName)
+		case "hospital_location":
+			err = dec.Decode(&file.Locations)
+		case "type_2_npi":
+			err = dec.Decode(&file.NPIs)
+		case "standard_charge_information":
+			err = file.parseItems(dec, wanted)
+		default:
+			var discard json.RawMessage
+			err = dec.Decode(&discard)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", key, err)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("close root object: %w", err)
+	}
+
+	return file, nil
+}
+
+func (f *File) parseItems(dec *json.Decoder, wanted map[string]struct{}) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("standard_charge_information is not an array")
+	}
+
+	for dec.More() {
+		var item ChargeItem
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		for _, code := range item.Codes {
+			upper := strings.ToUpper(code.Code)
+			if wanted != nil {
+				if _, ok := wanted[upper]; !ok {
+					continue
+				}
+			}
+			key := codeKey(code.Type, upper)
+			f.items[key] = append(f.items[key], item)
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// Items returns the charge items listing the code.
+func (f *File) Items(codeType string, code string) []ChargeItem {
+	return f.items[codeKey(codeType, strings.ToUpper(code))]
+}
+
+func codeKey(codeType string, code string) string {
+	return strings.ToUpper(codeType) + "\x00" + code
+}
+
+// Comparison puts a payer's negotiated price next to the charges the
+// hospital lists for the same code.
+type Comparison struct {
+	BillingCodeType string  `json:"billingCodeType"`
+	BillingCode     string  `json:"billingCode"`
+	Service         string  `json:"service"`
+	NPI             string  `json:"npi"`
+	TIN             string  `json:"tin"`
+	PayerSetting    string  `json:"payerSetting"`
+	PayerPriceType  string  `json:"payerPriceType"`
+	PayerPrice      float64 `json:"payerPrice"`
+
+	Hospital            string        `json:"hospital"`
+	HospitalFile        string        `json:"hospitalFile"`
+	HospitalDescription string        `json:"hospitalDescription"`
+	HospitalSetting     string        `json:"hospitalSetting"`
+	GrossCharge         *float64      `json:"grossCharge,omitempty"`
+	DiscountedCash      *float64      `json:"discountedCash,omitempty"`
+	Minimum             *float64      `json:"minimum,omitempty"`
+	Maximum             *float64      `json:"maximum,omitempty"`
+	HospitalPayers      []PayerCharge `json:"hospitalPayers,omitempty"`
+}
+
+// Compare joins a payer price with the hospital's charges for its code when
+// one of the price's providers is the hospital, matched by NPI.
+func (f *File) Compare(price rates.ConsumerPrice) []Comparison {
+	items := f.Items(price.BillingCodeType, price.BillingCode)
+	if len(items) == 0 {
+		return nil
+	}
+
+	var comparisons []Comparison
+	for _, provider := range price.Providers {
+		npi, ok := f.sharedNPI(provider.NPIs)
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			for _, charge := range item.Charges {
+				comparisons = append(comparisons, Comparison{
+					BillingCodeType:     price.BillingCodeType,
+					BillingCode:         price.BillingCode,
+					Service:             price.Service,
+					NPI:                 npi,
+					TIN:                 provider.TIN,
+					PayerSetting:        price.Setting,
+					PayerPriceType:      price.PriceType,
+					PayerPrice:          price.Price,
+					Hospital:            f.HospitalName,
+					HospitalFile:        f.Name,
+					HospitalDescription: item.Description,
+					HospitalSetting:     charge.Setting,
+					GrossCharge:         charge.GrossCharge,
+					DiscountedCash:      charge.DiscountedCash,
+					Minimum:             charge.Minimum,
+					Maximum:             charge.Maximum,
+					HospitalPayers:      charge.Payers,
+				})
+			}
+		}
+	}
+	return comparisons
+}
+
+func (f *File) sharedNPI(npis []string) (string, bool) {
+	for _, npi := range npis {
+		for _, own := range f.NPIs {
+			if npi == own {
+				return npi, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,199 @@
+package hospital_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/hospital"
+	"serif_interview/pkg/rates"
+)
+
+// chargeFile is a template 2.2 standard charge file with an office visit
+// charged in both settings and a C-section listed by its CPT and MS-DRG codes.
+const chargeFile = `{
+  "hospital_name": "Albany Medical Center",
+  "last_updated_on": "2026-01-01",
+  "version": "2.2.0",
+  "hospital_location": ["Albany Medical Center Hospital"],
+  "hospital_address": ["43 New Scotland Ave, Albany, NY 12208"],
+  "license_information": {"license_number": "0001", "state": "NY"},
+  "type_2_npi": ["1487654321", "1306849450"],
+  "standard_charge_information": [
+    {
+      "description": "Office visit, established patient",
+      "code_information": [{"code": "99213", "type": "CPT"}],
+      "standard_charges": [
+        {
+          "setting": "outpatient",
+          "gross_charge": 250,
+          "discounted_cash": 175,
+          "minimum": 90,
+          "maximum": 160,
+          "payers_information": [
+            {"payer_name": "Anthem", "plan_name": "Blue PPO", "standard_charge_dollar": 110.5, "methodology": "fee schedule"}
+          ]
+        },
+        {"setting": "inpatient", "gross_charge": 300}
+      ]
+    },
+    {
+      "description": "Cesarean delivery",
+      "code_information": [{"code": "59510", "type": "CPT"}, {"code": "788", "type": "MS-DRG"}],
+      "standard_charges": [
+        {
+          "setting": "inpatient",
+          "gross_charge": 30000,
+          "payers_information": [
+            {"payer_name": "Anthem", "plan_name": "Blue PPO", "standard_charge_percentage": 40, "standard_charge_algorithm": "percent of gross charges", "estimated_amount": 12000, "methodology": "percent of total billed charges"}
+          ]
+        }
+      ]
+    },
+    {
+      "description": "Chest x-ray",
+      "code_information": [{"code": "71046", "type": "CPT"}],
+      "standard_charges": [{"setting": "both", "gross_charge": 400}]
+    }
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	file, err := hospital.Parse(strings.NewReader(chargeFile), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.HospitalName != "Albany Medical Center" || len(file.Locations) != 1 || len(file.NPIs) != 2 {
+		t.Errorf("hospital %q at %v with npis %v", file.HospitalName, file.Locations, file.NPIs)
+	}
+
+	visits := file.Items("cpt", "99213")
+	if len(visits) != 1 || len(visits[0].Charges) != 2 {
+		t.Fatalf("office visits %+v, want one item charged in two settings", visits)
+	}
+	outpatient := visits[0].Charges[0]
+	if *outpatient.GrossCharge != 250 || *outpatient.DiscountedCash != 175 || *outpatient.Minimum != 90 || *outpatient.Maximum != 160 {
+		t.Errorf("outpatient charge %+v", outpatient)
+	}
+	if payer := outpatient.Payers[0]; payer.PlanName != "Blue PPO" || *payer.Dollar != 110.5 || payer.Percentage != nil {
+		t.Errorf("payer charge %+v", payer)
+	}
+	if inpatient := visits[0].Charges[1]; inpatient.DiscountedCash != nil || inpatient.Payers != nil {
+		t.Errorf("inpatient charge %+v, want only the gross charge", inpatient)
+	}
+
+	// an item is found by each of its codes
+	for _, code := range []hospital.Code{{Code: "59510", Type: "CPT"}, {Code: "788", Type: "ms-drg"}} {
+		if items := file.Items(code.Type, code.Code); len(items) != 1 || items[0].Description != "Cesarean delivery" {
+			t.Errorf("%s %s items %+v", code.Type, code.Code, items)
+		}
+	}
+	if items := file.Items("HCPCS", "99213"); items != nil {
+		t.Errorf("items of another code type %+v", items)
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	file, err := hospital.Parse(strings.NewReader(chargeFile), []string{" 788 ", "71046"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := file.Items("CPT", "99213"); items != nil {
+		t.Errorf("kept the office visit %+v", items)
+	}
+	if len(file.Items("MS-DRG", "788")) != 1 || len(file.Items("CPT", "71046")) != 1 {
+		t.Error("dropped a wanted code")
+	}
+	// only the wanted code of an item is indexed
+	if items := file.Items("CPT", "59510"); items != nil {
+		t.Errorf("kept the c-section by an unwanted code %+v", items)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(chargeFile))
+	zw.Close()
+	for name, data := range map[string][]byte{"standardcharges.json": []byte(chargeFile), "standardcharges.json.gz": gz.Bytes()} {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := hospital.Load(filename, []string{"99213"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if file.Name != filename || len(file.Items("CPT", "99213")) != 1 {
+			t.Errorf("%s loaded as %q with %d office visits", name, file.Name, len(file.Items("CPT", "99213")))
+		}
+	}
+	if _, err := hospital.Load(filepath.Join(dir, "missing.json"), nil); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, file := range []string{
+		`[]`,
+		`{"hospital_name": 1}`,
+		`{"standard_charge_information": {}}`,
+		`{"standard_charge_information": [{"code_information": "99213"}]}`,
+		`{"hospital_name": "Albany Medical Center"`,
+	} {
+		if _, err := hospital.Parse(strings.NewReader(file), nil); err == nil {
+			t.Errorf("parsed %s", file)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	file, err := hospital.Parse(strings.NewReader(chargeFile), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Name = "standardcharges.json"
+	price := rates.ConsumerPrice{
+		BillingCodeType: "CPT",
+		BillingCode:     "99213",
+		Service:         "Office visit",
+		Setting:         "professional",
+		PriceType:       "negotiated",
+		Price:           110.5,
+		Providers: []rates.ConsumerProvider{
+			{TIN: "98-7654321", NPIs: []string{"1999999984"}},
+			{TIN: "12-3456789", NPIs: []string{"1111111111", "1306849450"}},
+		},
+	}
+
+	comparisons := file.Compare(price)
+	if len(comparisons) != 2 {
+		t.Fatalf("%d comparisons, want one per hospital setting of the hospital's provider", len(comparisons))
+	}
+	first := comparisons[0]
+	if first.NPI != "1306849450" || first.TIN != "12-3456789" || first.PayerPrice != 110.5 || first.PayerSetting != "professional" {
+		t.Errorf("payer side %+v", first)
+	}
+	if first.Hospital != "Albany Medical Center" || first.HospitalFile != "standardcharges.json" || first.HospitalSetting != "outpatient" || *first.GrossCharge != 250 || len(first.HospitalPayers) != 1 {
+		t.Errorf("hospital side %+v", first)
+	}
+	if comparisons[1].HospitalSetting != "inpatient" || comparisons[1].DiscountedCash != nil {
+		t.Errorf("second comparison %+v", comparisons[1])
+	}
+
+	// prices of other providers or codes are not compared
+	other := price
+	other.Providers = price.Providers[:1]
+	if comparisons := file.Compare(other); comparisons != nil {
+		t.Errorf("compared another provider's price %+v", comparisons)
+	}
+	other = price
+	other.BillingCode = "99214"
+	if comparisons := file.Compare(other); comparisons != nil {
+		t.Errorf("compared a code the hospital does not list %+v", comparisons)
+	}
+}
@@ -0,0 +1,191 @@
+// Package leader elects a single active replica through a Kubernetes
+// coordination.k8s.io Lease, with the client-go leader election and the
+// pod's service account.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Options configures an Elector.
+type Options struct {
+	// Name of the Lease object, shared by all replicas.
+	Name string
+	// Namespace defaults to the namespace of the pod's service account.
+	Namespace string
+	// Identity defaults to the hostname, the pod name in Kubernetes.
+	Identity string
+	// LeaseDuration is how long a lease is valid without renewal, default 15s.
+	// A standby takes over a lease it has not seen renewed for this long.
+	LeaseDuration time.Duration
+	// RenewInterval is how often the leader renews and standbys retry, with
+	// jitter, default a third of LeaseDuration. The leader gives up the lease
+	// when it could not renew it for two thirds of LeaseDuration.
+	RenewInterval time.Duration
+}
+
+// Elector acquires and keeps a Lease.
+type Elector struct {
+	name     string
+	identity string
+	lock     *resourcelock.LeaseLock
+	duration time.Duration
+	renew    time.Duration
+}
+
+// NewInCluster returns an Elector using the service account mounted into the
+// pod and the API server address Kubernetes injects into the environment.
+// The service account token is read again as the kubelet rotates it.
+func NewInCluster(opts Options) (*Elector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("leader election requires running in a kubernetes pod: %w", err)
+	}
+	if opts.Namespace == "" {
+		namespace, err := os.ReadFile(serviceAccountNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("read service account namespace: %w", err)
+		}
+		opts.Namespace = strings.TrimSpace(string(namespace))
+	}
+	config.Timeout = 10 * time.Second
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return New(opts, client)
+}
+
+// New returns an Elector keeping its Lease through client.
+func New(opts Options, client kubernetes.Interface) (*Elector, error) {
+	if opts.Name == "" {
+		return nil, errors.New("lease name is required")
+	}
+	if opts.Namespace == "" {
+		return nil, errors.New("lease namespace is required")
+	}
+
+	e := &Elector{
+		name:     opts.Name,
+		identity: opts.Identity,
+		duration: opts.LeaseDuration,
+		renew:    opts.RenewInterval,
+	}
+	if e.identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("leader identity: %w", err)
+		}
+		e.identity = hostname
+	}
+	if e.duration <= 0 {
+		e.duration = 15 * time.Second
+	}
+	if e.renew <= 0 {
+		e.renew = e.duration / 3
+	}
+	e.lock = &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+	}
+	return e, nil
+}
+
+// Identity is the holder identity this replica writes into the Lease.
+func (e *Elector) Identity() string {
+	return e.identity
+}
+
+// Run blocks until this replica holds the lease, then calls lead with a
+// context that is cancelled when the lease is lost or ctx ends. The lease is
+// released when lead returns so a standby takes over without waiting for it
+// to expire.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context) error) error {
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	lock := &observedLock{Interface: e.lock}
+	var leadErr error
+	var lost bool
+	led := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            e.name,
+		LeaseDuration:   e.duration,
+		RenewDeadline:   e.duration * 2 / 3,
+		RetryPeriod:     e.renew,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leadCtx context.Context) {
+				defer close(led)
+				leadErr = lead(leadCtx)
+				// leadCtx alone is cancelled when renewing failed
+				lost = leadCtx.Err() != nil && runCtx.Err() == nil
+				// ends the renewal, which releases the lease
+				stop()
+			},
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("lease %s: %w", e.name, err)
+	}
+	elector.Run(runCtx)
+
+	if !lock.acquired.Load() {
+		// ctx ended before the lease was acquired
+		return ctx.Err()
+	}
+	<-led
+	if lost {
+		return errors.Join(leadErr, fmt.Errorf("lost lease %s/%s", e.lock.LeaseMeta.Namespace, e.name))
+	}
+	return leadErr
+}
+
+// observedLock notes whether the elector ever acquired the lease, it starts
+// leading in a goroutine of its own.
+type observedLock struct {
+	resourcelock.Interface
+	acquired atomic.Bool
+}
+
+func (l *observedLock) Create(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Create(ctx, record)
+	if err == nil && record.HolderIdentity == l.Identity() {
+		l.acquired.Store(true)
+	}
+	return err
+}
+
+func (l *observedLock) Update(ctx context.Context, record resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Update(ctx, record)
+	if apierrors.IsConflict(err) && record.HolderIdentity == "" {
+		// a renewal cancelled in flight may still have been written, so the
+		// release is retried on the lease as it is now while it is ours
+		current, _, getErr := l.Get(ctx)
+		if getErr == nil && current.HolderIdentity == l.Identity() {
+			err = l.Interface.Update(ctx, record)
+		}
+	}
+	if err == nil && record.HolderIdentity == l.Identity() {
+		l.acquired.Store(true)
+	}
+	return err
+}
@@ -0,0 +1,334 @@
+package leader_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	"serif_interview/pkg/leader"
+)
+
+const leasePath = "/apis/coordination.k8s.io/v1/namespaces/jobs/leases"
+
+// fakeAPIServer keeps a single Lease the way the Kubernetes API server does,
+// refusing writes of a stale resourceVersion with 409 Conflict.
+type fakeAPIServer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	lease   *coordinationv1.Lease
+	version int
+	// fail answers every request with 500 while set
+	fail bool
+	// conflicts refuses this many more updates with 409 Conflict
+	conflicts int
+	tokens    map[string]bool
+}
+
+func newFakeAPIServer(t *testing.T) (*fakeAPIServer, *httptest.Server) {
+	api := &fakeAPIServer{t: t, tokens: make(map[string]bool)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+func (api *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.tokens[r.Header.Get("Authorization")] = true
+	if api.fail {
+		api.status(w, http.StatusInternalServerError, metav1.StatusReasonInternalError)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == leasePath+"/extract":
+		if api.lease == nil {
+			api.status(w, http.StatusNotFound, metav1.StatusReasonNotFound)
+			return
+		}
+		api.write(w, http.StatusOK)
+	case r.Method == http.MethodPost && r.URL.Path == leasePath:
+		if api.lease != nil {
+			api.status(w, http.StatusConflict, metav1.StatusReasonAlreadyExists)
+			return
+		}
+		api.store(w, r, http.StatusCreated)
+	case r.Method == http.MethodPut && r.URL.Path == leasePath+"/extract":
+		if api.conflicts > 0 {
+			api.conflicts--
+			api.status(w, http.StatusConflict, metav1.StatusReasonConflict)
+			return
+		}
+		api.store(w, r, http.StatusOK)
+	default:
+		api.t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		api.status(w, http.StatusNotImplemented, metav1.StatusReasonMethodNotAllowed)
+	}
+}
+
+// store writes the lease of the request unless its resourceVersion is stale.
+func (api *fakeAPIServer) store(w http.ResponseWriter, r *http.Request, code int) {
+	// client-go sends protobuf unless told otherwise
+	var lease coordinationv1.Lease
+	body, _ := io.ReadAll(r.Body)
+	if _, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, &lease); err != nil {
+		api.t.Errorf("decode lease: %v", err)
+		api.status(w, http.StatusBadRequest, metav1.StatusReasonBadRequest)
+		return
+	}
+	if api.lease != nil && lease.ResourceVersion != api.lease.ResourceVersion {
+		api.status(w, http.StatusConflict, metav1.StatusReasonConflict)
+		return
+	}
+	api.version++
+	lease.ResourceVersion = strconv.Itoa(api.version)
+	api.lease = &lease
+	api.write(w, code)
+}
+
+func (api *fakeAPIServer) write(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(api.lease)
+}
+
+func (api *fakeAPIServer) status(w http.ResponseWriter, code int, reason metav1.StatusReason) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  string(reason),
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+// holder is the holder identity and transitions of the lease.
+func (api *fakeAPIServer) holder() (string, int32) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.lease == nil || api.lease.Spec.HolderIdentity == nil {
+		return "", 0
+	}
+	var transitions int32
+	if api.lease.Spec.LeaseTransitions != nil {
+		transitions = *api.lease.Spec.LeaseTransitions
+	}
+	return *api.lease.Spec.HolderIdentity, transitions
+}
+
+func (api *fakeAPIServer) set(change func(api *fakeAPIServer)) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	change(api)
+}
+
+// newElector is an Elector of replica identity with a short lease, talking
+// to server with the token in a file like a service account.
+func newElector(t *testing.T, server *httptest.Server, identity string) *leader.Elector {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token-of-"+identity), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL, BearerTokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := leader.New(leader.Options{
+		Name:          "extract",
+		Namespace:     "jobs",
+		Identity:      identity,
+		LeaseDuration: time.Second,
+		RenewInterval: 100 * time.Millisecond,
+	}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// within fails the test unless done is closed within d.
+func within(t *testing.T, d time.Duration, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("%s did not happen within %s", what, d)
+	}
+}
+
+func TestLeadAndRelease(t *testing.T) {
+	api, server := newFakeAPIServer(t)
+	e := newElector(t, server, "a")
+
+	err := e.Run(context.Background(), func(ctx context.Context) error {
+		if holder, _ := api.holder(); holder != "a" {
+			t.Errorf("leading while the lease is held by %q", holder)
+		}
+		return errors.New("lead failed")
+	})
+	if err == nil || err.Error() != "lead failed" {
+		t.Errorf("Run returned %v, want the error of lead", err)
+	}
+	if holder, _ := api.holder(); holder != "" {
+		t.Errorf("lease still held by %q after lead returned", holder)
+	}
+	if !api.tokens["Bearer token-of-a"] {
+		t.Errorf("requests not authorized with the token file, got %v", api.tokens)
+	}
+}
+
+func TestStandbyTakesOverAfterRelease(t *testing.T) {
+	api, server := newFakeAPIServer(t)
+	a, b := newElector(t, server, "a"), newElector(t, server, "b")
+
+	aLeading, aStop, aDone := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(aDone)
+		a.Run(context.Background(), func(ctx context.Context) error {
+			close(aLeading)
+			<-aStop
+			return nil
+		})
+	}()
+	within(t, 2*time.Second, aLeading, "a leading")
+
+	bLeading, bDone := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(bDone)
+		b.Run(context.Background(), func(ctx context.Context) error {
+			close(bLeading)
+			return nil
+		})
+	}()
+	// well past the lease duration, a keeps renewing
+	select {
+	case <-bLeading:
+		t.Fatal("b leads while a holds the lease")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	close(aStop)
+	within(t, 2*time.Second, aDone, "a returning")
+	// a released the lease, b does not wait for it to expire
+	within(t, 500*time.Millisecond, bLeading, "b leading after the release")
+	within(t, 2*time.Second, bDone, "b returning")
+	if _, transitions := api.holder(); transitions != 1 {
+		t.Errorf("%d lease transitions, want 1", transitions)
+	}
+}
+
+func TestTakeoverOfExpiredLease(t *testing.T) {
+	api, server := newFakeAPIServer(t)
+	gone, duration, transitions := "gone", int32(1), int32(3)
+	renewed := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	api.set(func(api *fakeAPIServer) {
+		api.version = 1
+		api.lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "extract", Namespace: "jobs", ResourceVersion: "1"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &gone,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &renewed,
+				RenewTime:            &renewed,
+				LeaseTransitions:     &transitions,
+			},
+		}
+		// the first takeover loses a race and is retried
+		api.conflicts = 1
+	})
+	e := newElector(t, server, "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := e.Run(ctx, func(ctx context.Context) error {
+		if holder, transitions := api.holder(); holder != "a" || transitions != 4 {
+			t.Errorf("leading with the lease held by %q after %d transitions, want a after 4", holder, transitions)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func TestLostLease(t *testing.T) {
+	for _, how := range []string{"api server failing", "taken over"} {
+		t.Run(how, func(t *testing.T) {
+			api, server := newFakeAPIServer(t)
+			e := newElector(t, server, "a")
+
+			leading := make(chan struct{})
+			go func() {
+				<-leading
+				api.set(func(api *fakeAPIServer) {
+					if how == "api server failing" {
+						api.fail = true
+						return
+					}
+					other := "b"
+					api.lease.Spec.HolderIdentity = &other
+					api.version++
+					api.lease.ResourceVersion = strconv.Itoa(api.version)
+				})
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := e.Run(ctx, func(leadCtx context.Context) error {
+				close(leading)
+				<-leadCtx.Done()
+				if ctx.Err() != nil {
+					t.Error("lead not stopped before the test timed out")
+				}
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), "lost lease jobs/extract") {
+				t.Errorf("Run returned %v, want a lost lease", err)
+			}
+		})
+	}
+}
+
+func TestCancelledWhileStandby(t *testing.T) {
+	_, server := newFakeAPIServer(t)
+	a, b := newElector(t, server, "a"), newElector(t, server, "b")
+
+	aLeading := make(chan struct{})
+	aCtx, aCancel := context.WithCancel(context.Background())
+	defer aCancel()
+	go a.Run(aCtx, func(ctx context.Context) error {
+		close(aLeading)
+		<-ctx.Done()
+		return nil
+	})
+	within(t, 2*time.Second, aLeading, "a leading")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := b.Run(ctx, func(ctx context.Context) error {
+		t.Error("b leads while a holds the lease")
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run returned %v, want the context's error", err)
+	}
+}
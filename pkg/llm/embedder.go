@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"fmt"
+
+	"serif_interview/pkg/embed"
+
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// DefaultEmbeddingModels are used when NewEmbedder is given no model.
+var DefaultEmbeddingModels = map[string]string{
+	BackendOllama: "nomic-embed-text",
+	BackendOpenAI: "text-embedding-3-small",
+}
+
+// NewEmbedder returns the embedder of backend, configured like New from the
+// Model and OllamaURL of o. The none backend has no embeddings.
+func NewEmbedder(backend string, o Options) (embed.Embedder, error) {
+	if err := o.Check(); err != nil {
+		return nil, err
+	}
+	model := o.Model
+	if model == "" {
+		model = DefaultEmbeddingModels[backend]
+	}
+
+	switch backend {
+	case BackendOllama:
+		ollamaOptions := []ollama.Option{ollama.WithModel(model)}
+		if o.OllamaURL != "" {
+			ollamaOptions = append(ollamaOptions, ollama.WithServerURL(o.OllamaURL))
+		}
+		client, err := ollama.New(ollamaOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("open ollama: %w", err)
+		}
+		return client, nil
+	case BackendOpenAI:
+		client, err := openai.New(openai.WithEmbeddingModel(model))
+		if err != nil {
+			return nil, fmt.Errorf("open openai: %w", err)
+		}
+		return client, nil
+	case BackendNone:
+		return nil, fmt.Errorf("the %s llm has no embeddings", backend)
+	default:
+		return nil, fmt.Errorf("unknown llm %q, expected one of %v", backend, Backends)
+	}
+}
@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// embeddingServer answers Ollama's /api/embed, one text per call, and
+// OpenAI's /v1/embeddings, one batch per call, embedding a text as its
+// length, recording the model and texts asked.
+type embeddingServer struct {
+	mu     sync.Mutex
+	models []string
+	texts  []string
+}
+
+func (s *embeddingServer) start(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+			Input any    `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode embedding request: %v", err)
+		}
+		var texts []string
+		switch input := req.Input.(type) {
+		case string:
+			texts = []string{input}
+		case []any:
+			for _, text := range input {
+				texts = append(texts, text.(string))
+			}
+		}
+		s.mu.Lock()
+		s.models = append(s.models, req.Model)
+		s.texts = append(s.texts, texts...)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/embed":
+			json.NewEncoder(w).Encode(map[string]any{"model": req.Model, "embeddings": [][]float32{{float32(len(texts[0])), 1}}})
+		case "/v1/embeddings":
+			var data []map[string]any
+			for i, text := range texts {
+				data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": []float32{float32(len(text)), 1}})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"object": "list", "model": req.Model, "data": data})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	var embeddings embeddingServer
+	embedder, err := NewEmbedder(BackendOllama, Options{OllamaURL: embeddings.start(t).URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := embedder.CreateEmbedding(context.Background(), []string{"blue ppo", "empire plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 8 || vectors[1][0] != 11 {
+		t.Errorf("vectors %v, want one per text in order", vectors)
+	}
+	if len(embeddings.models) != 2 || embeddings.models[0] != DefaultEmbeddingModels[BackendOllama] {
+		t.Errorf("asked models %v, want the default embedding model per text", embeddings.models)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	var embeddings embeddingServer
+	t.Setenv("OPENAI_BASE_URL", embeddings.start(t).URL+"/v1")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	embedder, err := NewEmbedder(BackendOpenAI, Options{Model: "text-embedding-3-large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := embedder.CreateEmbedding(context.Background(), []string{"blue ppo", "empire plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 8 || vectors[1][0] != 11 {
+		t.Errorf("vectors %v, want one per text in order", vectors)
+	}
+	if len(embeddings.models) != 1 || embeddings.models[0] != "text-embedding-3-large" || len(embeddings.texts) != 2 {
+		t.Errorf("asked models %v for %q, want both texts of the model in one call", embeddings.models, embeddings.texts)
+	}
+}
+
+func TestNewEmbedderBackends(t *testing.T) {
+	for _, backend := range []string{BackendNone, "claude"} {
+		if _, err := NewEmbedder(backend, Options{}); err == nil {
+			t.Errorf("embedder of the %s backend opened", backend)
+		}
+	}
+	if _, err := NewEmbedder(BackendOllama, Options{OllamaURL: "localhost:11434"}); err == nil {
+		t.Error("ollama url without a scheme accepted")
+	}
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"sync"
+
+	"serif_interview/pkg/extract"
+)
+
+// NewLazy is New with the client of backend only built by its first call,
+// so runs that never ask the llm never open it. The backend and options are
+// checked at once, the none backend is returned as is.
+func NewLazy(backend string, o Options) (extract.LLMClient, error) {
+	if backend == BackendNone {
+		return Disabled{}, nil
+	}
+	if _, ok := DefaultModels[backend]; !ok {
+		return New(backend, o)
+	}
+	if err := o.Check(); err != nil {
+		return nil, err
+	}
+	return &lazy{open: sync.OnceValues(func() (extract.LLMClient, error) {
+		return New(backend, o)
+	})}, nil
+}
+
+type lazy struct {
+	open func() (extract.LLMClient, error)
+}
+
+func (l *lazy) Generate(ctx context.Context, system string, input string) (string, error) {
+	client, err := l.open()
+	if err != nil {
+		return "", err
+	}
+	return client.Generate(ctx, system, input)
+}
+
+func (l *lazy) GenerateJSON(ctx context.Context, system string, input string) (string, error) {
+	client, err := l.open()
+	if err != nil {
+		return "", err
+	}
+	if client, ok := client.(extract.JSONClient); ok {
+		return client.GenerateJSON(ctx, system, input)
+	}
+	return client.Generate(ctx, system, input)
+}
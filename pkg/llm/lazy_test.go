@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+func TestNewLazy(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		ollamaAnswer(w, `{"ppo":true}`)
+	}))
+	defer server.Close()
+
+	client, err := NewLazy(BackendOllama, Options{OllamaURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("%d requests before the first call", calls.Load())
+	}
+	answer, err := client.(extract.JSONClient).GenerateJSON(context.Background(), "answer in json", "anthem blue ppo")
+	if err != nil || answer != `{"ppo":true}` {
+		t.Errorf("GenerateJSON returned %q, %v", answer, err)
+	}
+	if _, err := client.Generate(context.Background(), "system", "input"); err != nil {
+		t.Error(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("%d requests for two calls", calls.Load())
+	}
+}
+
+func TestNewLazyChecks(t *testing.T) {
+	if _, err := NewLazy(BackendOllama, Options{OllamaURL: "localhost:11434"}); err == nil {
+		t.Error("bad ollama url accepted before the first call")
+	}
+	if _, err := NewLazy("claude", Options{}); err == nil {
+		t.Error("unknown backend accepted")
+	}
+	client, err := NewLazy(BackendNone, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Generate(context.Background(), "system", "input"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("none backend returned %v, want ErrDisabled", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package llm builds the LLMClient analysis mode asks about plan
+// descriptions, backed by a local Ollama server, any OpenAI compatible
+// endpoint, or nothing.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"serif_interview/pkg/extract"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+const (
+	BackendOllama = "ollama"
+	BackendOpenAI = "openai"
+	BackendNone   = "none"
+)
+
+// Backends lists the values New accepts.
+var Backends = []string{BackendOllama, BackendOpenAI, BackendNone}
+
+// DefaultModels are used when New is given no model.
+var DefaultModels = map[string]string{
+	BackendOllama: "llama3",
+	BackendOpenAI: "gpt-4o-mini",
+}
+
+// ErrDisabled is returned by every call of the none backend.
+var ErrDisabled = errors.New("llm disabled")
+
+// Options configure the client New returns, the zero value asks the
+// default model of the backend with its own generation parameters.
+type Options struct {
+	// Model defaults to DefaultModels of the backend.
+	Model string
+	// OllamaURL is the Ollama server, OLLAMA_HOST when empty.
+	OllamaURL string
+	// Temperature of the sampling, the model's own when negative.
+	Temperature float64
+	// NumCtx is the context window of an Ollama model in tokens, the
+	// model's own when 0.
+	NumCtx int
+}
+
+// Check refuses what langchaingo would only fail on, the ollama options
+// exit the program on a bad url.
+func (o Options) Check() error {
+	if o.OllamaURL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.OllamaURL)
+	if err == nil && (u.Scheme != "http" && u.Scheme != "https" || u.Host == "") {
+		err = errors.New("expected an http or https url")
+	}
+	if err != nil {
+		return fmt.Errorf("ollama url %q: %w", o.OllamaURL, err)
+	}
+	return nil
+}
+
+// New returns the client of backend. The Ollama server is taken from
+// OLLAMA_HOST unless o names one, the OpenAI endpoint and key from
+// OPENAI_BASE_URL and OPENAI_API_KEY.
+func New(backend string, o Options) (extract.LLMClient, error) {
+	if err := o.Check(); err != nil {
+		return nil, err
+	}
+	model := o.Model
+	if model == "" {
+		model = DefaultModels[backend]
+	}
+	var options []llms.CallOption
+	if o.Temperature >= 0 {
+		options = append(options, llms.WithTemperature(o.Temperature))
+	}
+
+	switch backend {
+	case BackendOllama:
+		ollamaOptions := []ollama.Option{ollama.WithModel(model)}
+		if o.OllamaURL != "" {
+			ollamaOptions = append(ollamaOptions, ollama.WithServerURL(o.OllamaURL))
+		}
+		if o.NumCtx > 0 {
+			ollamaOptions = append(ollamaOptions, ollama.WithRunnerNumCtx(o.NumCtx))
+		}
+		client, err := ollama.New(ollamaOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("open ollama: %w", err)
+		}
+		return &langchain{model: client, options: options}, nil
+	case BackendOpenAI:
+		client, err := openai.New(openai.WithModel(model))
+		if err != nil {
+			return nil, fmt.Errorf("open openai: %w", err)
+		}
+		return &langchain{model: client, options: options}, nil
+	case BackendNone:
+		return Disabled{}, nil
+	default:
+		return nil, fmt.Errorf("unknown llm %q, expected one of %v", backend, Backends)
+	}
+}
+
+// langchain adapts a langchaingo chat model.
+type langchain struct {
+	model llms.Model
+	// options are passed with every call
+	options []llms.CallOption
+}
+
+func (l *langchain) Generate(ctx context.Context, system string, input string) (string, error) {
+	return l.generate(ctx, system, input)
+}
+
+// GenerateJSON sets ollama's format=json or the OpenAI json_object response
+// format.
+func (l *langchain) GenerateJSON(ctx context.Context, system string, input string) (string, error) {
+	return l.generate(ctx, system, input, llms.WithJSONMode())
+}
+
+func (l *langchain) generate(ctx context.Context, system string, input string, options ...llms.CallOption) (string, error) {
+	messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, system)}
+	if input != "" {
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, input))
+	}
+
+	resp, err := l.model.GenerateContent(ctx, messages, append(slices.Clip(l.options), options...)...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("llm returned no choices")
+	}
+	return resp.Choices[0].Content, nil
+}
+
+// Disabled is the none backend, analysis mode then relies on the heuristic
+// and region code signals only.
+type Disabled struct{}
+
+func (Disabled) Generate(context.Context, string, string) (string, error) {
+	return "", ErrDisabled
+}
@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"serif_interview/pkg/extract"
+)
+
+// chatRequest holds the fields of an Ollama or OpenAI chat request the
+// backends set.
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	// Format is ollama's, ResponseFormat OpenAI's json mode
+	Format         string `json:"format"`
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Options struct {
+		NumCtx      int     `json:"num_ctx"`
+		Temperature float64 `json:"temperature"`
+	} `json:"options"`
+	Temperature float64 `json:"temperature"`
+}
+
+// chatServer records the chat requests it gets and answers each with
+// "answer to" its last message, written by respond in the backend's format.
+type chatServer struct {
+	mu       sync.Mutex
+	requests []chatRequest
+	headers  []http.Header
+}
+
+func (s *chatServer) last(t *testing.T) (chatRequest, http.Header) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no chat request made")
+	}
+	return s.requests[len(s.requests)-1], s.headers[len(s.headers)-1]
+}
+
+func (s *chatServer) handle(t *testing.T, path string, respond func(w http.ResponseWriter, answer string)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != path {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode chat request: %v", err)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		respond(w, "answer to "+req.Messages[len(req.Messages)-1].Content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func ollamaAnswer(w http.ResponseWriter, answer string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	json.NewEncoder(w).Encode(map[string]any{
+		"model":   "qwen2",
+		"message": map[string]string{"role": "assistant", "content": answer},
+		"done":    true,
+	})
+}
+
+func openAIAnswer(w http.ResponseWriter, answer string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":     "chatcmpl-1",
+		"object": "chat.completion",
+		"model":  "gpt-4o-mini",
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": answer},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+func TestOllama(t *testing.T) {
+	var chats chatServer
+	server := chats.handle(t, "/api/chat", ollamaAnswer)
+	client, err := New(BackendOllama, Options{Model: "qwen2", OllamaURL: server.URL, Temperature: 0.2, NumCtx: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := client.Generate(context.Background(), "is this a ppo plan?", "anthem blue ppo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "answer to anthem blue ppo" {
+		t.Errorf("answer %q", answer)
+	}
+	req, _ := chats.last(t)
+	if req.Model != "qwen2" || req.Format != "" || req.Options.NumCtx != 4096 || req.Options.Temperature != 0.2 {
+		t.Errorf("request for model %q format %q num_ctx %d temperature %v", req.Model, req.Format, req.Options.NumCtx, req.Options.Temperature)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[0].Content != "is this a ppo plan?" || req.Messages[1].Role != "user" {
+		t.Errorf("messages %+v, want the system prompt then the input", req.Messages)
+	}
+
+	if _, err := client.(extract.JSONClient).GenerateJSON(context.Background(), "answer in json", ""); err != nil {
+		t.Fatal(err)
+	}
+	req, _ = chats.last(t)
+	if req.Format != "json" || len(req.Messages) != 1 {
+		t.Errorf("json request with format %q and %d messages, want json and only the system prompt", req.Format, len(req.Messages))
+	}
+}
+
+func TestOllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"model \"qwen2\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+	client, err := New(BackendOllama, Options{Model: "qwen2", OllamaURL: server.URL, Temperature: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Generate(context.Background(), "system", "input"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Generate failed with %v, want the server's error", err)
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	var chats chatServer
+	server := chats.handle(t, "/v1/chat/completions", openAIAnswer)
+	t.Setenv("OPENAI_BASE_URL", server.URL+"/v1")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	client, err := New(BackendOpenAI, Options{Temperature: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := client.(extract.JSONClient).GenerateJSON(context.Background(), "answer in json", "anthem blue ppo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "answer to anthem blue ppo" {
+		t.Errorf("answer %q", answer)
+	}
+	req, header := chats.last(t)
+	if req.Model != DefaultModels[BackendOpenAI] || req.Temperature != 0.5 {
+		t.Errorf("request for model %q temperature %v, want the default model at 0.5", req.Model, req.Temperature)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		t.Errorf("response format %+v, want json_object", req.ResponseFormat)
+	}
+	if header.Get("Authorization") != "Bearer test-key" {
+		t.Errorf("authorization %q, want the OPENAI_API_KEY", header.Get("Authorization"))
+	}
+}
+
+func TestNewBackends(t *testing.T) {
+	client, err := New(BackendNone, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Generate(context.Background(), "system", "input"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("none backend returned %v, want ErrDisabled", err)
+	}
+	if _, err := New("claude", Options{}); err == nil {
+		t.Error("unknown backend accepted")
+	}
+	for _, url := range []string{"localhost:11434", "ftp://localhost", "http://"} {
+		if _, err := New(BackendOllama, Options{OllamaURL: url}); err == nil {
+			t.Errorf("ollama url %q accepted", url)
+		}
+	}
+}
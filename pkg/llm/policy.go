@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"serif_interview/pkg/extract"
+)
+
+// ErrCircuitOpen is returned without calling the llm while the circuit
+// breaker of a Policy is open.
+var ErrCircuitOpen = errors.New("llm circuit open after repeated failures")
+
+// maxBackoff caps the doubling delay between retries.
+const maxBackoff = 30 * time.Second
+
+// Policy bounds the calls of a client so a hung or failing llm cannot stall
+// a run. Analysis mode treats a failed call as no llm answer, so with the
+// circuit open it carries on with the other signals.
+type Policy struct {
+	// Timeout bounds each attempt, 0 leaves attempts unbounded.
+	Timeout time.Duration
+	// Retries is the number of additional attempts after a failed one.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each
+	// following one, a second when 0.
+	Backoff time.Duration
+	// MaxFailures consecutive failed calls open the circuit, 0 never opens
+	// it.
+	MaxFailures int
+	// Cooldown is how long the circuit stays open before a single call
+	// probes whether the llm answers again, five minutes when 0.
+	Cooldown time.Duration
+	// OnOpen is called with the last error when the circuit opens.
+	OnOpen func(err error)
+	// OnClose is called when a probe succeeds and the circuit closes.
+	OnClose func()
+}
+
+// WithPolicy returns client with its calls timed out, retried and guarded by
+// a circuit breaker as p says. The none backend is returned as is.
+func WithPolicy(client extract.LLMClient, p Policy) extract.LLMClient {
+	if _, ok := client.(Disabled); ok {
+		return client
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = time.Second
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 5 * time.Minute
+	}
+	return &policyClient{client: client, policy: p}
+}
+
+type policyClient struct {
+	client extract.LLMClient
+	policy Policy
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (c *policyClient) Generate(ctx context.Context, system string, input string) (string, error) {
+	return c.call(ctx, func(ctx context.Context) (string, error) {
+		return c.client.Generate(ctx, system, input)
+	})
+}
+
+func (c *policyClient) GenerateJSON(ctx context.Context, system string, input string) (string, error) {
+	client, ok := c.client.(extract.JSONClient)
+	if !ok {
+		return c.Generate(ctx, system, input)
+	}
+	return c.call(ctx, func(ctx context.Context) (string, error) {
+		return client.GenerateJSON(ctx, system, input)
+	})
+}
+
+func (c *policyClient) call(ctx context.Context, generate func(context.Context) (string, error)) (string, error) {
+	allowed, probe := c.allow()
+	if !allowed {
+		return "", ErrCircuitOpen
+	}
+	response, err := c.retry(ctx, generate)
+	c.record(ctx, err, probe)
+	return response, err
+}
+
+// retry makes up to 1+Retries attempts, giving up early once ctx is done.
+func (c *policyClient) retry(ctx context.Context, generate func(context.Context) (string, error)) (string, error) {
+	backoff := c.policy.Backoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.attempt(ctx, generate)
+		if err == nil || attempt >= c.policy.Retries || ctx.Err() != nil {
+			return response, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", err
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *policyClient) attempt(ctx context.Context, generate func(context.Context) (string, error)) (string, error) {
+	if c.policy.Timeout <= 0 {
+		return generate(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.policy.Timeout)
+	defer cancel()
+	return generate(ctx)
+}
+
+// allow is false while the circuit is open, and lets one probe through once
+// the cooldown passed.
+func (c *policyClient) allow() (allowed bool, probe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.policy.MaxFailures <= 0 || c.failures < c.policy.MaxFailures {
+		return true, false
+	}
+	if c.probing || time.Now().Before(c.openUntil) {
+		return false, false
+	}
+	c.probing = true
+	return true, true
+}
+
+// record counts the outcome of a call towards opening or closing the
+// circuit.
+func (c *policyClient) record(ctx context.Context, err error, probe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if probe {
+		c.probing = false
+	}
+
+	switch {
+	case err == nil:
+		opened := c.policy.MaxFailures > 0 && c.failures >= c.policy.MaxFailures
+		c.failures = 0
+		if opened && c.policy.OnClose != nil {
+			c.policy.OnClose()
+		}
+	case ctx.Err() != nil:
+		// the caller gave up, which says nothing about the llm
+	default:
+		c.failures++
+		if c.policy.MaxFailures <= 0 || c.failures < c.policy.MaxFailures {
+			return
+		}
+		c.openUntil = time.Now().Add(c.policy.Cooldown)
+		if !probe && c.failures == c.policy.MaxFailures && c.policy.OnOpen != nil {
+			c.policy.OnOpen(err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer is an Ollama server failing with 500 while failing is set and
+// answering otherwise, or hanging until the request is cancelled while
+// hanging is set.
+type flakyServer struct {
+	calls   atomic.Int32
+	failing atomic.Bool
+	hanging atomic.Bool
+}
+
+func (s *flakyServer) start(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		// the server notices the client hanging up once the body is read
+		io.Copy(io.Discard, r.Body)
+		switch {
+		case s.hanging.Load():
+			<-r.Context().Done()
+		case s.failing.Load():
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"llm runner crashed"}`))
+		default:
+			ollamaAnswer(w, "yes")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *flakyServer) client(t *testing.T, p Policy) *policyClient {
+	t.Helper()
+	client, err := New(BackendOllama, Options{OllamaURL: s.start(t).URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WithPolicy(client, p).(*policyClient)
+}
+
+func TestPolicyRetries(t *testing.T) {
+	var server flakyServer
+	server.failing.Store(true)
+	client := server.client(t, Policy{Retries: 2, Backoff: 10 * time.Millisecond})
+
+	if _, err := client.Generate(context.Background(), "system", "input"); err == nil {
+		t.Error("Generate succeeded against a failing llm")
+	}
+	if calls := server.calls.Load(); calls != 3 {
+		t.Errorf("%d attempts, want 3", calls)
+	}
+
+	// the llm recovers during the backoff
+	server.calls.Store(0)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		server.failing.Store(false)
+	}()
+	answer, err := client.Generate(context.Background(), "system", "input")
+	if err != nil || answer != "yes" {
+		t.Errorf("Generate returned %q, %v after the llm recovered", answer, err)
+	}
+}
+
+func TestPolicyTimeout(t *testing.T) {
+	var server flakyServer
+	server.hanging.Store(true)
+	client := server.client(t, Policy{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+
+	start := time.Now()
+	if _, err := client.Generate(context.Background(), "system", "input"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Generate returned %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("two timed out attempts took %s", elapsed)
+	}
+	if calls := server.calls.Load(); calls != 2 {
+		t.Errorf("%d attempts, want 2", calls)
+	}
+}
+
+func TestPolicyCircuit(t *testing.T) {
+	var server flakyServer
+	server.failing.Store(true)
+	var opened, closed atomic.Int32
+	client := server.client(t, Policy{
+		MaxFailures: 2,
+		Cooldown:    50 * time.Millisecond,
+		OnOpen:      func(error) { opened.Add(1) },
+		OnClose:     func() { closed.Add(1) },
+	})
+	generate := func() error {
+		_, err := client.Generate(context.Background(), "system", "input")
+		return err
+	}
+
+	generate()
+	generate()
+	if err := generate(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("third call returned %v, want ErrCircuitOpen", err)
+	}
+	if calls := server.calls.Load(); calls != 2 || opened.Load() != 1 {
+		t.Errorf("%d calls and %d opens, want the llm left alone after opening once", calls, opened.Load())
+	}
+
+	// a failed probe after the cooldown keeps it open without a second OnOpen
+	time.Sleep(60 * time.Millisecond)
+	if err := generate(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("probe returned %v, want the llm's error", err)
+	}
+	if err := generate(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("call after a failed probe returned %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	server.failing.Store(false)
+	if err := generate(); err != nil {
+		t.Errorf("probe returned %v", err)
+	}
+	if err := generate(); err != nil {
+		t.Errorf("call after the circuit closed returned %v", err)
+	}
+	if opened.Load() != 1 || closed.Load() != 1 {
+		t.Errorf("%d opens and %d closes, want 1 each", opened.Load(), closed.Load())
+	}
+}
+
+func TestPolicyCallerCancel(t *testing.T) {
+	var server flakyServer
+	server.hanging.Store(true)
+	client := server.client(t, Policy{MaxFailures: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	client.Generate(ctx, "system", "input")
+	// giving up says nothing about the llm
+	server.hanging.Store(false)
+	if _, err := client.Generate(context.Background(), "system", "input"); err != nil {
+		t.Errorf("call after a cancelled one returned %v, want the circuit closed", err)
+	}
+}
+
+func TestPolicyDisabled(t *testing.T) {
+	if _, ok := WithPolicy(Disabled{}, Policy{Retries: 3}).(Disabled); !ok {
+		t.Error("the none backend was wrapped")
+	}
+}
@@ -0,0 +1,67 @@
+// Package logging sets up the log/slog logger the commands write their
+// diagnostics with. Logs always go to stderr so stdout carries nothing but
+// results.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var Formats = []string{FormatText, FormatJSON}
+
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// Options selects the lowest level logged and the format of the lines.
+type Options struct {
+	// Level is one of Levels, info when empty.
+	Level string
+	// Verbose lowers Level to debug, for the -v flag.
+	Verbose bool
+	// Format is one of Formats, text when empty.
+	Format string
+}
+
+// Setup makes a logger writing to w the default slog logger.
+func Setup(w io.Writer, opts Options) error {
+	logger, err := New(w, opts)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// New returns a logger writing to w.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if opts.Level != "" {
+		if !slices.Contains(Levels, strings.ToLower(opts.Level)) {
+			return nil, fmt.Errorf("unknown log level %q, expected one of %v", opts.Level, Levels)
+		}
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Verbose {
+		level = min(level, slog.LevelDebug)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch opts.Format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected one of %v", opts.Format, Formats)
+	}
+}
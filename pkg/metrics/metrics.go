@@ -0,0 +1,103 @@
+// Package metrics exports what extractions do to Prometheus. A Metrics is an
+// extract.Observer, passed as Options.Observer to every extractor of a
+// process, and Handler serves its registry on /metrics.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"serif_interview/pkg/llm"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the extraction counters. It is safe for concurrent use.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	records       prometheus.Counter
+	recordLatency prometheus.Histogram
+	matches       prometheus.Counter
+	llmCalls      *prometheus.CounterVec
+	decompressed  prometheus.Counter
+}
+
+// New registers the extraction metrics, and the Go runtime and process
+// metrics, on a registry of its own.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		records: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "extract_records_total",
+			Help: "reporting_structure records matched",
+		}),
+		recordLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "extract_record_duration_seconds",
+			Help: "time taken to match one reporting_structure record, llm calls included",
+			// records take microseconds without an llm and seconds with one
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 11),
+		}),
+		matches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "extract_matches_total",
+			Help: "rate file locations selected in heuristics and coverage mode, matches reported in analysis mode",
+		}),
+		llmCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "extract_llm_calls_total",
+			Help: "llm questions by result, made for answered calls, cached for answers from the cache and failed for calls that returned an error",
+		}, []string{"result"}),
+		decompressed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "extract_decompressed_bytes_total",
+			Help: "bytes of index files read after decompression",
+		}),
+	}
+	// the results are known up front, so dashboards show them at 0
+	for _, result := range []string{"made", "cached", "failed"} {
+		m.llmCalls.WithLabelValues(result)
+	}
+	m.registry.MustRegister(
+		m.records, m.recordLatency, m.matches, m.llmCalls, m.decompressed,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Register adds collectors of the caller, e.g. job gauges of a server.
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) RecordDone(latency time.Duration) {
+	m.records.Inc()
+	m.recordLatency.Observe(latency.Seconds())
+}
+
+func (m *Metrics) Matches(n int) {
+	m.matches.Add(float64(n))
+}
+
+func (m *Metrics) LLMCall(cached bool, err error) {
+	switch {
+	case errors.Is(err, llm.ErrDisabled), errors.Is(err, llm.ErrCircuitOpen):
+		// -llm=none and an open circuit ask nothing
+	case cached:
+		m.llmCalls.WithLabelValues("cached").Inc()
+	case err != nil:
+		m.llmCalls.WithLabelValues("failed").Inc()
+	default:
+		m.llmCalls.WithLabelValues("made").Inc()
+	}
+}
+
+func (m *Metrics) Decompressed(n int) {
+	m.decompressed.Add(float64(n))
+}
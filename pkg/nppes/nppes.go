@@ -0,0 +1,338 @@
+// Package nppes looks providers up by NPI in a local snapshot of the CMS
+// National Plan and Provider Enumeration System, the npidata_pfile csv of
+// the monthly NPPES download or a sqlite table imported from it with the
+// same column names, e.g. with sqlite3's .import --csv.
+package nppes
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Provider is the NPPES record of an NPI. Taxonomy is the code of the
+// primary taxonomy, the first one listed when none is marked primary, and
+// Address the practice location.
+type Provider struct {
+	NPI        string  `json:"npi"`
+	EntityType string  `json:"entityType"`
+	Name       string  `json:"name"`
+	Credential string  `json:"credential,omitempty"`
+	Taxonomy   string  `json:"taxonomy,omitempty"`
+	Address    Address `json:"address"`
+}
+
+// Address is a practice location.
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postalCode"`
+}
+
+// Entity types of a Provider.
+const (
+	EntityIndividual   = "individual"
+	EntityOrganization = "organization"
+)
+
+// taxonomies is how many taxonomy codes an NPPES record lists.
+const taxonomies = 15
+
+// columns are the NPPES columns a Provider is read from.
+var columns = []string{
+	"NPI",
+	"Entity Type Code",
+	"Provider Organization Name (Legal Business Name)",
+	"Provider Last Name (Legal Name)",
+	"Provider First Name",
+	"Provider Middle Name",
+	"Provider Name Suffix Text",
+	"Provider Credential Text",
+	"Provider First Line Business Practice Location Address",
+	"Provider Second Line Business Practice Location Address",
+	"Provider Business Practice Location Address City Name",
+	"Provider Business Practice Location Address State Name",
+	"Provider Business Practice Location Address Postal Code",
+}
+
+func init() {
+	for i := 1; i <= taxonomies; i++ {
+		columns = append(columns,
+			"Healthcare Provider Taxonomy Code_"+strconv.Itoa(i),
+			"Healthcare Provider Primary Taxonomy Switch_"+strconv.Itoa(i))
+	}
+}
+
+// Registry looks providers up in a csv snapshot read into memory, or one
+// row at a time in a sqlite table. It is not safe for concurrent use.
+type Registry struct {
+	// providers are the records of a csv snapshot
+	providers map[string]Provider
+
+	// query selects the present columns of a sqlite table by NPI, and
+	// selected are their positions in columns
+	query    *sql.Stmt
+	selected []int
+	// looked up are the sqlite answers so far, nil for unknown NPIs
+	lookedUp map[string]*Provider
+}
+
+// LoadCSV reads the npidata_pfile csv at filename, gzip compressed when its
+// name ends in .gz. The national file is several gigabytes, a sqlite
+// snapshot suits it better than holding its providers in memory.
+func LoadCSV(filename string) (*Registry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open nppes file: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(filename), ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("nppes file %s: %w", filename, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	registry, err := ReadCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("nppes file %s: %w", filename, err)
+	}
+	return registry, nil
+}
+
+// ReadCSV reads an npidata_pfile csv, a header row naming the NPPES columns
+// followed by a row per NPI. Only the NPI column is required.
+func ReadCSV(r io.Reader) (*Registry, error) {
+	// a byte order mark before the quoted header is a bare quote to csv
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	positions, err := columnPositions(header)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &Registry{providers: make(map[string]Provider)}
+	values := make([]string, len(columns))
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for i, position := range positions {
+			values[i] = ""
+			// the fields of a record share its line, which a kept field
+			// would hold on to
+			if position >= 0 && position < len(record) {
+				values[i] = strings.Clone(record[position])
+			}
+		}
+		if p := newProvider(values); p.NPI != "" {
+			registry.providers[p.NPI] = p
+		}
+	}
+	return registry, nil
+}
+
+// NewSQLite looks providers up in table of db, or in the table or view with
+// an NPI column when table is empty. The caller registers the driver and
+// closes db. Lookups are by the NPI column, which wants an index on the
+// national file.
+func NewSQLite(db *sql.DB, table string) (*Registry, error) {
+	if table == "" {
+		var err error
+		if table, err = findTable(db); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := db.Query("SELECT * FROM " + quote(table) + " LIMIT 0")
+	if err != nil {
+		return nil, fmt.Errorf("nppes table %s: %w", table, err)
+	}
+	header, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("nppes table %s: %w", table, err)
+	}
+	positions, err := columnPositions(header)
+	if err != nil {
+		return nil, fmt.Errorf("nppes table %s: %w", table, err)
+	}
+
+	registry := &Registry{lookedUp: make(map[string]*Provider)}
+	var selected []string
+	for i, position := range positions {
+		if position >= 0 {
+			registry.selected = append(registry.selected, i)
+			selected = append(selected, quote(header[position]))
+		}
+	}
+	registry.query, err = db.Prepare("SELECT " + strings.Join(selected, ", ") + " FROM " + quote(table) + " WHERE " + quote(header[positions[0]]) + " = ? LIMIT 1")
+	if err != nil {
+		return nil, fmt.Errorf("nppes table %s: %w", table, err)
+	}
+	return registry, nil
+}
+
+// findTable returns the first table or view of db with an NPI column.
+func findTable(db *sql.DB) (string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type IN ('table', 'view') ORDER BY name")
+	if err != nil {
+		return "", fmt.Errorf("list nppes tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return "", err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	for _, table := range tables {
+		rows, err := db.Query("SELECT * FROM " + quote(table) + " LIMIT 0")
+		if err != nil {
+			continue
+		}
+		header, _ := rows.Columns()
+		rows.Close()
+		for _, name := range header {
+			if strings.EqualFold(name, "NPI") {
+				return table, nil
+			}
+		}
+	}
+	return "", errors.New("no nppes table with an NPI column")
+}
+
+// Lookup returns the provider of npi, false when the snapshot has none.
+func (r *Registry) Lookup(npi string) (Provider, bool, error) {
+	npi = strings.TrimSpace(npi)
+	if r.providers != nil {
+		p, ok := r.providers[npi]
+		return p, ok, nil
+	}
+	if p, ok := r.lookedUp[npi]; ok {
+		if p == nil {
+			return Provider{}, false, nil
+		}
+		return *p, true, nil
+	}
+
+	scanned := make([]sql.NullString, len(r.selected))
+	dest := make([]any, len(scanned))
+	for i := range scanned {
+		dest[i] = &scanned[i]
+	}
+	err := r.query.QueryRow(npi).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.lookedUp[npi] = nil
+		return Provider{}, false, nil
+	} else if err != nil {
+		return Provider{}, false, fmt.Errorf("look up npi %s: %w", npi, err)
+	}
+	values := make([]string, len(columns))
+	for i, column := range r.selected {
+		values[column] = scanned[i].String
+	}
+	p := newProvider(values)
+	r.lookedUp[npi] = &p
+	return p, true, nil
+}
+
+// Close releases the prepared query of a sqlite snapshot.
+func (r *Registry) Close() error {
+	if r.query != nil {
+		return r.query.Close()
+	}
+	return nil
+}
+
+// columnPositions maps each of columns to its position in header, -1 when
+// missing, comparing names case-insensitively.
+func columnPositions(header []string) ([]int, error) {
+	byName := make(map[string]int, len(header))
+	for i, name := range header {
+		byName[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	positions := make([]int, len(columns))
+	for i, name := range columns {
+		position, ok := byName[strings.ToLower(name)]
+		if !ok {
+			position = -1
+		}
+		positions[i] = position
+	}
+	if positions[0] < 0 {
+		return nil, errors.New("no NPI column")
+	}
+	return positions, nil
+}
+
+// newProvider builds a Provider from the values of columns.
+func newProvider(values []string) Provider {
+	v := func(i int) string { return strings.TrimSpace(values[i]) }
+	p := Provider{
+		NPI:        v(0),
+		Credential: v(7),
+		Address: Address{
+			Line1:      v(8),
+			Line2:      v(9),
+			City:       v(10),
+			State:      v(11),
+			PostalCode: v(12),
+		},
+	}
+	switch v(1) {
+	case "1":
+		p.EntityType = EntityIndividual
+	case "2":
+		p.EntityType = EntityOrganization
+	}
+	if p.EntityType == EntityOrganization || v(3) == "" {
+		p.Name = v(2)
+	} else {
+		p.Name = strings.Join(strings.Fields(strings.Join([]string{v(4), v(5), v(3), v(6)}, " ")), " ")
+	}
+	for i := range taxonomies {
+		code, primary := v(13+2*i), v(14+2*i)
+		if code == "" {
+			continue
+		}
+		if p.Taxonomy == "" || primary == "Y" {
+			p.Taxonomy = code
+		}
+		if primary == "Y" {
+			break
+		}
+	}
+	return p
+}
+
+// quote quotes a sqlite identifier.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,155 @@
+package nppes_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/nppes"
+
+	_ "modernc.org/sqlite"
+)
+
+// snapshot is an npidata_pfile excerpt with an individual whose second
+// taxonomy is the primary one, an organization and a deactivated NPI, with
+// the columns in another order than the NPPES download and a BOM.
+const snapshot = "\ufeff\"NPI\",\"Entity Type Code\",\"Provider Last Name (Legal Name)\",\"Provider First Name\",\"Provider Middle Name\",\"Provider Name Suffix Text\",\"Provider Credential Text\",\"Provider Organization Name (Legal Business Name)\",\"Provider First Line Business Practice Location Address\",\"Provider Second Line Business Practice Location Address\",\"Provider Business Practice Location Address City Name\",\"Provider Business Practice Location Address State Name\",\"Provider Business Practice Location Address Postal Code\",\"Healthcare Provider Taxonomy Code_1\",\"Healthcare Provider Primary Taxonomy Switch_1\",\"Healthcare Provider Taxonomy Code_2\",\"Healthcare Provider Primary Taxonomy Switch_2\"\n" +
+	`"1306849450","1","SMITH","JANE","A","JR","MD","","100 MAIN ST","SUITE 2","ALBANY","NY","122070001","207R00000X","N","207RC0000X","Y"` + "\n" +
+	`"1487654321","2","","","","","","ALBANY MEDICAL CENTER","43 NEW SCOTLAND AVE","","ALBANY","NY","12208","282N00000X","","",""` + "\n" +
+	`"1999999984","","","","","","","","","","","","","","","",""` + "\n"
+
+func checkSnapshot(t *testing.T, registry *nppes.Registry) {
+	t.Helper()
+	jane, ok, err := registry.Lookup(" 1306849450 ")
+	if err != nil || !ok {
+		t.Fatalf("lookup returned %v, %v", ok, err)
+	}
+	want := nppes.Provider{
+		NPI:        "1306849450",
+		EntityType: nppes.EntityIndividual,
+		Name:       "JANE A SMITH JR",
+		Credential: "MD",
+		Taxonomy:   "207RC0000X",
+		Address:    nppes.Address{Line1: "100 MAIN ST", Line2: "SUITE 2", City: "ALBANY", State: "NY", PostalCode: "122070001"},
+	}
+	if jane != want {
+		t.Errorf("individual %+v, want %+v", jane, want)
+	}
+
+	hospital, _, _ := registry.Lookup("1487654321")
+	if hospital.EntityType != nppes.EntityOrganization || hospital.Name != "ALBANY MEDICAL CENTER" || hospital.Taxonomy != "282N00000X" {
+		t.Errorf("organization %+v", hospital)
+	}
+	// deactivated NPIs are listed without their details
+	if deactivated, ok, _ := registry.Lookup("1999999984"); !ok || deactivated.EntityType != "" || deactivated.Name != "" {
+		t.Errorf("deactivated npi %+v, %v", deactivated, ok)
+	}
+	for range 2 {
+		if _, ok, err := registry.Lookup("1111111111"); ok || err != nil {
+			t.Errorf("unknown npi returned %v, %v", ok, err)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	registry, err := nppes.ReadCSV(strings.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshot(t, registry)
+	if err := registry.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// only the NPI column is required
+	registry, err = nppes.ReadCSV(strings.NewReader("npi,Provider Organization Name (Legal Business Name)\n1487654321,ALBANY MEDICAL CENTER\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok, _ := registry.Lookup("1487654321"); !ok || p.Name != "ALBANY MEDICAL CENTER" {
+		t.Errorf("provider %+v, %v", p, ok)
+	}
+
+	for _, file := range []string{"", "Provider First Name\nJANE\n", "NPI\n\"1306849450\n"} {
+		if _, err := nppes.ReadCSV(strings.NewReader(file)); err == nil {
+			t.Errorf("read %q", file)
+		}
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(snapshot))
+	zw.Close()
+	files := map[string][]byte{"npidata_pfile.csv": []byte(snapshot), "npidata_pfile.csv.gz": gz.Bytes()}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		registry, err := nppes.LoadCSV(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkSnapshot(t, registry)
+	}
+	if _, err := nppes.LoadCSV(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "nppes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	header := strings.TrimPrefix(strings.SplitN(snapshot, "\n", 2)[0], "\ufeff")
+	names := strings.Split(header, ",")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	for _, statement := range []string{
+		"CREATE TABLE imports (started text)",
+		"CREATE TABLE npidata (" + header + ", \"NPI Deactivation Date\")",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(snapshot), "\n")[1:] {
+		var values []any
+		for _, value := range strings.Split(line, ",") {
+			values = append(values, strings.Trim(value, `"`))
+		}
+		if _, err := db.Exec("INSERT INTO npidata ("+header+") VALUES ("+placeholders+")", values...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the table is found by its NPI column
+	for _, table := range []string{"npidata", ""} {
+		registry, err := nppes.NewSQLite(db, table)
+		if err != nil {
+			t.Fatalf("table %q: %v", table, err)
+		}
+		checkSnapshot(t, registry)
+		if err := registry.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := nppes.NewSQLite(db, "imports"); err == nil {
+		t.Error("table without an NPI column accepted")
+	}
+	if _, err := nppes.NewSQLite(db, "missing"); err == nil {
+		t.Error("missing table accepted")
+	}
+	db.Exec("DROP TABLE npidata")
+	if _, err := nppes.NewSQLite(db, ""); err == nil {
+		t.Error("database without an nppes table accepted")
+	}
+}
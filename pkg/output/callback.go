@@ -0,0 +1,86 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CallbackBatch is the body of a Callback request with matches.
+type CallbackBatch struct {
+	Type     string            `json:"type"`
+	Run      string            `json:"run"`
+	Sequence int               `json:"sequence"`
+	Matches  []json.RawMessage `json:"matches"`
+}
+
+// CallbackSummary is the body of the last Callback request.
+type CallbackSummary struct {
+	Type     string `json:"type"`
+	Run      string `json:"run"`
+	Sequence int    `json:"sequence"`
+	// Matches counts the matches of every batch
+	Matches int `json:"matches"`
+	Summary any `json:"summary"`
+}
+
+// Callback is a sink posting the match records to an ingestion endpoint as
+// JSON, {"type": "matches", ...} CallbackBatch bodies of opts.Batch matches,
+// and on Close the rest followed by a {"type": "summary", ...}
+// CallbackSummary. Meta and error records are not posted.
+type Callback struct {
+	poster  *poster
+	run     string
+	pending []json.RawMessage
+	matches int
+	summary any
+}
+
+// NewCallback returns a Callback posting to url, run naming the run in every
+// body and, followed by the sequence number, in DeliveryHeader.
+func NewCallback(url string, run string, opts WebhookOptions) *Callback {
+	if opts.Delivery == "" {
+		opts.Delivery = run + "/"
+	}
+	return &Callback{poster: newPoster(url, opts), run: run}
+}
+
+// SetSummary is what the summary request carries, it is posted on Close.
+func (c *Callback) SetSummary(summary any) {
+	c.summary = summary
+}
+
+func (c *Callback) WriteMeta(string, json.RawMessage) error { return nil }
+func (c *Callback) WriteError(json.RawMessage) error        { return nil }
+
+func (c *Callback) WriteMatch(record json.RawMessage) error {
+	c.pending = append(c.pending, record)
+	c.matches++
+	if len(c.pending) < c.poster.opts.Batch {
+		return nil
+	}
+	return c.flush()
+}
+
+func (c *Callback) flush() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	batch := CallbackBatch{Type: "matches", Run: c.run, Sequence: c.poster.sent + 1, Matches: c.pending}
+	c.pending = nil
+	return c.postJSON(batch)
+}
+
+func (c *Callback) postJSON(body any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal webhook body: %w", err)
+	}
+	return c.poster.post("application/json", raw)
+}
+
+func (c *Callback) Close() error {
+	if err := c.flush(); err != nil {
+		return err
+	}
+	return c.postJSON(CallbackSummary{Type: "summary", Run: c.run, Sequence: c.poster.sent + 1, Matches: c.matches, Summary: c.summary})
+}
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+)
+
+func TestCallback(t *testing.T) {
+	var rcv webhookReceiver
+	server := rcv.start(t)
+	callback := NewCallback(server.URL, "run-7", WebhookOptions{Batch: 1, Secret: "s3cret"})
+	callback.SetSummary(map[string]int{"files": 1})
+	w, err := NewWriter(io.Discard, Options{Sinks: []Sink{callback}, SinkFailed: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, w)
+
+	// only the matches are posted, then the summary
+	if len(rcv.bodies) != 3 {
+		t.Fatalf("%d requests, want two batches and the summary: %q", len(rcv.bodies), rcv.bodies)
+	}
+	for i, name := range []string{`{"name":"blue ppo","url":"https://example.com/a.json.gz"}`, `"gold ppo"`} {
+		var batch CallbackBatch
+		if err := json.Unmarshal([]byte(rcv.bodies[i]), &batch); err != nil {
+			t.Fatal(err)
+		}
+		if batch.Type != "matches" || batch.Run != "run-7" || batch.Sequence != i+1 || len(batch.Matches) != 1 || string(batch.Matches[0]) != name {
+			t.Errorf("batch %d is %s", i, rcv.bodies[i])
+		}
+	}
+	var summary struct {
+		CallbackSummary
+		Summary map[string]int `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(rcv.bodies[2]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Type != "summary" || summary.Sequence != 3 || summary.Matches != 2 || summary.Summary["files"] != 1 {
+		t.Errorf("summary is %s", rcv.bodies[2])
+	}
+
+	for i, header := range rcv.headers {
+		if header.Get("Content-Type") != "application/json" || header.Get(SignatureHeader) != Sign("s3cret", []byte(rcv.bodies[i])) {
+			t.Errorf("request %d is not signed json", i)
+		}
+		if want := "run-7/" + strconv.Itoa(i+1); header.Get(DeliveryHeader) != want {
+			t.Errorf("request %d delivery %q, want %q", i, header.Get(DeliveryHeader), want)
+		}
+	}
+}
+
+func TestCallbackBatches(t *testing.T) {
+	var rcv webhookReceiver
+	server := rcv.start(t)
+	callback := NewCallback(server.URL, "run-8", WebhookOptions{})
+	for range 3 {
+		callback.WriteMatch(json.RawMessage(`"blue ppo"`))
+	}
+	if len(rcv.bodies) != 0 {
+		t.Errorf("posted %d requests before a batch was full", len(rcv.bodies))
+	}
+	if err := callback.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var batch CallbackBatch
+	json.Unmarshal([]byte(rcv.bodies[0]), &batch)
+	if len(rcv.bodies) != 2 || len(batch.Matches) != 3 {
+		t.Errorf("%d requests with %d matches first, want the rest and the summary on Close", len(rcv.bodies), len(batch.Matches))
+	}
+}
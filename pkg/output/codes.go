@@ -0,0 +1,98 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Code identifies a kind of warning or error record. Codes never change
+// meaning once released, so automation can route or suppress records by
+// code instead of matching their messages. Warnings start with W, errors
+// that failed a file or the run with E.
+type Code string
+
+const (
+	// index file content
+	CodeIndexDrift      Code = "W001"
+	CodeShardGap        Code = "W002"
+	CodeDrugFiles       Code = "W003"
+	CodeQuarantine      Code = "W004"
+	CodeSchemaViolation Code = "W005"
+	CodeStaleIndex      Code = "W006"
+	CodeSkippedRecord   Code = "W007"
+	CodeDeadLink        Code = "W008"
+
+	// external services
+	CodeWebhook        Code = "W010"
+	CodeAdminAPI       Code = "W011"
+	CodeRetention      Code = "W012"
+	CodeResultIndex    Code = "W013"
+	CodeLLMUnavailable Code = "W014"
+	CodeCheckpoint     Code = "W015"
+	CodeProviderFile   Code = "W016"
+	CodeMetrics        Code = "W017"
+	CodeLLMCircuitOpen Code = "W018"
+	CodeSinkFailed     Code = "W019"
+
+	// listener
+	CodeMessageRetry Code = "W020"
+
+	// CodeSerialize is a record that could not be written.
+	CodeSerialize Code = "W090"
+
+	CodeRunFailed      Code = "E001"
+	CodeSizeLimit      Code = "E002"
+	CodeFileFailed     Code = "E003"
+	CodeDownloadFailed Code = "E004"
+	CodeOutputFailed   Code = "E005"
+	CodeUsage          Code = "E006"
+	CodeInterrupted    Code = "E007"
+)
+
+// IsError is true for codes of records that failed a file or the run.
+func (c Code) IsError() bool {
+	return strings.HasPrefix(string(c), "E")
+}
+
+// Logf logs a warning, or an error for E codes, with its code to the default
+// slog logger, for diagnostics that are not result records.
+func Logf(code Code, format string, args ...any) {
+	level := slog.LevelWarn
+	if code.IsError() {
+		level = slog.LevelError
+	}
+	slog.Log(context.Background(), level, fmt.Sprintf(format, args...), "code", code)
+}
+
+// Codes describes every code.
+var Codes = map[Code]string{
+	CodeIndexDrift:      "index version or reporting entity changed since the previous run",
+	CodeShardGap:        "sharded file set is incomplete in the index",
+	CodeDrugFiles:       "index lists prescription drug files, which are not rate files",
+	CodeQuarantine:      "values with unexpected types were written to the quarantine file",
+	CodeSchemaViolation: "index file violates the CMS table of contents schema",
+	CodeStaleIndex:      "index file was last updated longer ago than -max-index-age",
+	CodeSkippedRecord:   "reporting_structure record could not be read and was skipped with -keep-going",
+	CodeDeadLink:        "matched rate file location answered -probe with an error or not at all",
+	CodeWebhook:         "drift, slack or teams webhook could not be delivered",
+	CodeAdminAPI:        "admin api stopped serving",
+	CodeRetention:       "expired results could not be removed",
+	CodeResultIndex:     "completed results could not be recorded for reuse",
+	CodeLLMUnavailable:  "llm backend is not answering, analysis continues without it",
+	CodeCheckpoint:      "checkpoint could not be written or removed, a resume would start further back",
+	CodeProviderFile:    "remote provider reference file could not be read, its providers stay unresolved",
+	CodeMetrics:         "metrics endpoint stopped serving",
+	CodeLLMCircuitOpen:  "llm calls failed repeatedly, analysis continues without it and retries it every few minutes",
+	CodeSinkFailed:      "-webhook, -out-webhook or -out-records-sqlite failed and was dropped, the other outputs are complete",
+	CodeMessageRetry:    "queue message failed and will be retried",
+	CodeSerialize:       "record could not be serialized",
+	CodeRunFailed:       "run failed",
+	CodeSizeLimit:       "file passed the decompressed size or expansion ratio limit",
+	CodeFileFailed:      "index file from the queue failed",
+	CodeDownloadFailed:  "rate file downloads failed",
+	CodeOutputFailed:    "output could not be written",
+	CodeUsage:           "invalid command line",
+	CodeInterrupted:     "run was interrupted by SIGINT or SIGTERM, results are partial",
+}
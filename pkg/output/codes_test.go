@@ -0,0 +1,82 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCodes checks every Code constant is described and well formed, so a
+// new code cannot ship without its line in the README table.
+func TestCodes(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "codes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[WE][0-9]{3}$`)
+	seen := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			name := value.Names[0].Name
+			code, _ := strconv.Unquote(value.Values[0].(*ast.BasicLit).Value)
+			if !format.MatchString(code) {
+				t.Errorf("%s is %q, want W or E and three digits", name, code)
+			}
+			if other, dup := seen[code]; dup {
+				t.Errorf("%s and %s are both %s", name, other, code)
+			}
+			seen[code] = name
+			if Codes[Code(code)] == "" {
+				t.Errorf("%s (%s) has no description in Codes", name, code)
+			}
+			if !strings.Contains(string(readme), "\n| "+code+" | ") {
+				t.Errorf("%s (%s) is missing from the README table", name, code)
+			}
+		}
+	}
+	if len(seen) != len(Codes) {
+		t.Errorf("%d code constants, %d described", len(seen), len(Codes))
+	}
+	if !CodeRunFailed.IsError() || CodeIndexDrift.IsError() {
+		t.Error("IsError does not follow the E prefix")
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	Logf(CodeWebhook, "post %s: %v", "https://example.com", "timeout")
+	Logf(CodeRunFailed, "run failed")
+	dec := json.NewDecoder(&buf)
+	for _, want := range []struct{ level, msg, code string }{
+		{"WARN", "post https://example.com: timeout", "W010"},
+		{"ERROR", "run failed", "E001"},
+	} {
+		var line struct{ Level, Msg, Code string }
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		if line.Level != want.level || line.Msg != want.msg || line.Code != want.code {
+			t.Errorf("logged %+v, want %+v", line, want)
+		}
+	}
+}
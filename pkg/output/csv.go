@@ -0,0 +1,90 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// csvRow picks columns out of a marshalled record. Records with none of the
+// columns, such as the file headers of manifest runs, have no row.
+func csvRow(raw json.RawMessage, columns []string) ([]string, bool) {
+	values, found := pickColumns(raw, columns)
+	if !found {
+		return nil, false
+	}
+	row := make([]string, len(columns))
+	for i, value := range values {
+		if value != nil {
+			row[i] = csvCell(value)
+		}
+	}
+	return row, true
+}
+
+// pickColumns returns the values of columns in a marshalled record, nil for
+// those it lacks. A record that is not an object fills the first column.
+func pickColumns(raw json.RawMessage, columns []string) ([]json.RawMessage, bool) {
+	values := make([]json.RawMessage, len(columns))
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		values[0] = raw
+		return values, true
+	}
+
+	found := false
+	for i, column := range columns {
+		if value, ok := lookup(fields, column); ok {
+			values[i] = value
+			found = true
+		}
+	}
+	return values, found
+}
+
+// lookup resolves a dotted column name against nested objects.
+func lookup(fields map[string]json.RawMessage, column string) (json.RawMessage, bool) {
+	name, rest, nested := strings.Cut(column, ".")
+	value, ok := fields[name]
+	if !ok || !nested {
+		return value, ok
+	}
+
+	var inner map[string]json.RawMessage
+	if err := json.Unmarshal(value, &inner); err != nil {
+		return nil, false
+	}
+	return lookup(inner, rest)
+}
+
+// csvCell renders strings without quotes, arrays of scalars joined by ";"
+// and anything else as JSON.
+func csvCell(raw json.RawMessage) string {
+	var scalar any
+	if err := json.Unmarshal(raw, &scalar); err != nil {
+		return string(raw)
+	}
+
+	switch v := scalar.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, element := range v {
+			switch e := element.(type) {
+			case map[string]any, []any:
+				return string(raw)
+			case string:
+				parts = append(parts, e)
+			default:
+				b, _ := json.Marshal(e)
+				parts = append(parts, string(b))
+			}
+		}
+		return strings.Join(parts, ";")
+	default:
+		return string(raw)
+	}
+}
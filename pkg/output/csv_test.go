@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	var out, errs bytes.Buffer
+	w, err := NewWriter(&out, Options{
+		Format:  FormatCSV,
+		Columns: []string{"name", "rate.billing_code", "rate.negotiated_rate", "plans", "providers"},
+		Errors:  &errs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []any{
+		map[string]any{"file": "anthem_index.json"},
+		map[string]any{
+			"name":      "blue ppo, national",
+			"rate":      map[string]any{"billing_code": "99213", "negotiated_rate": 110.5},
+			"plans":     []any{"ppo", 42},
+			"providers": []any{map[string]any{"npi": 1}},
+		},
+		"gold ppo",
+	} {
+		if err := w.Match(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Meta("input", "anthem_index.json")
+	w.Error(map[string]string{"code": "W001"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `name,rate.billing_code,rate.negotiated_rate,plans,providers
+"blue ppo, national",99213,110.5,ppo;42,"[{""npi"":1}]"
+gold ppo,,,,
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	if errs.String() != "{\"code\":\"W001\"}\n" {
+		t.Errorf("errors %q", errs.String())
+	}
+}
+
+func TestCSVNoHeader(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Options{Format: FormatCSV, Columns: []string{"name"}, NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Match(map[string]string{"name": "blue ppo"})
+	w.Close()
+	if out.String() != "blue ppo\n" {
+		t.Errorf("got %q", out.String())
+	}
+	if _, err := NewWriter(&out, Options{Format: FormatCSV}); err == nil {
+		t.Error("csv without columns accepted")
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	for raw, want := range map[string]string{
+		`null`:             "",
+		`"blue ppo"`:       "blue ppo",
+		`12.50`:            "12.50",
+		`true`:             "true",
+		`["a","b",3]`:      "a;b;3",
+		`[["a"]]`:          `[["a"]]`,
+		`{"code":"99213"}`: `{"code":"99213"}`,
+	} {
+		if got := csvCell(json.RawMessage(raw)); got != want {
+			t.Errorf("csvCell(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
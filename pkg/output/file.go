@@ -0,0 +1,93 @@
+package output
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"serif_interview/pkg/blob"
+)
+
+// File writes results to a temporary file next to its path and only renames
+// it into place on Commit, so a killed run never leaves a truncated results
+// file behind. Paths ending in .gz are gzip compressed. An s3://, gs:// or
+// az:// path is spooled to a temporary file and uploaded on Commit.
+type File struct {
+	path string
+	tmp  *os.File
+	gz   *gzip.Writer
+	w    io.Writer
+}
+
+func CreateFile(path string) (*File, error) {
+	dir := filepath.Dir(path)
+	if blob.IsURI(path) {
+		if _, err := blob.Parse(path); err != nil {
+			return nil, fmt.Errorf("create output file: %w", err)
+		}
+		dir = ""
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("create output file: %s - %w", path, err)
+	}
+	f := &File{path: path, tmp: tmp, w: tmp}
+	if strings.HasSuffix(path, ".gz") {
+		f.gz = gzip.NewWriter(tmp)
+		f.w = f.gz
+	}
+	return f, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+// Commit finishes the file and replaces path with it.
+func (f *File) Commit() error {
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.Abort()
+			return fmt.Errorf("compress output file: %s - %w", f.path, err)
+		}
+	}
+	// CreateTemp files are private, results are not
+	if err := f.tmp.Chmod(0o644); err != nil {
+		f.Abort()
+		return fmt.Errorf("chmod output file: %s - %w", f.path, err)
+	}
+	if blob.IsURI(f.path) {
+		return f.upload()
+	}
+	if err := f.tmp.Close(); err != nil {
+		os.Remove(f.tmp.Name())
+		return fmt.Errorf("close output file: %s - %w", f.path, err)
+	}
+	if err := os.Rename(f.tmp.Name(), f.path); err != nil {
+		os.Remove(f.tmp.Name())
+		return fmt.Errorf("replace output file: %s - %w", f.path, err)
+	}
+	return nil
+}
+
+func (f *File) upload() error {
+	defer f.Abort()
+	size, err := f.tmp.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = f.tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("read output file: %s - %w", f.path, err)
+	}
+	return blob.Upload(context.Background(), f.path, f.tmp, size)
+}
+
+// Abort discards the temporary file, leaving path as it was.
+func (f *File) Abort() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
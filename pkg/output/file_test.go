@@ -0,0 +1,99 @@
+package output
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte("previous run"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "[]\n")
+	// a killed run leaves the previous results in place
+	if content, _ := os.ReadFile(path); string(content) != "previous run" {
+		t.Errorf("results replaced before Commit: %q", content)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "[]\n" {
+		t.Errorf("committed results %q", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Errorf("committed results have mode %v, want 0644", info.Mode().Perm())
+	}
+	assertOnly(t, filepath.Dir(path), "results.json")
+}
+
+func TestFileAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	os.WriteFile(path, []byte("previous run"), 0o644)
+
+	f, err := CreateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "[\n{\"name\":")
+	f.Abort()
+	if content, _ := os.ReadFile(path); string(content) != "previous run" {
+		t.Errorf("aborted run changed the results to %q", content)
+	}
+	assertOnly(t, filepath.Dir(path), "results.json")
+}
+
+func TestFileGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json.gz")
+	f, err := CreateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, "[]\n")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compressed.Close()
+	r, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatalf("results not gzip compressed: %v", err)
+	}
+	if content, _ := io.ReadAll(r); string(content) != "[]\n" {
+		t.Errorf("decompressed results %q", content)
+	}
+}
+
+func TestFileMissingDir(t *testing.T) {
+	if _, err := CreateFile(filepath.Join(t.TempDir(), "missing", "results.json")); err == nil {
+		t.Error("created a file in a missing directory")
+	}
+}
+
+// assertOnly fails unless dir holds just name, no temporary files.
+func assertOnly(t *testing.T, dir string, name string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v, want only %s", names, name)
+	}
+}
@@ -0,0 +1,157 @@
+// Package output writes command results as syntactically valid JSON, either
+// streamed as an array of records or as JSON lines, or buffered into one
+// object with meta, matches and errors sections, or as CSV or parquet rows of
+// selected columns. Further Sinks, such as a webhook or a sqlite table, can
+// receive the same records.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+type Format string
+
+const (
+	// FormatJSON streams one array element per record as soon as it is
+	// written, meta records are {"<key>": <value>} objects.
+	FormatJSON Format = "json"
+	// FormatObject buffers records and writes
+	// {"meta": {...}, "matches": [...], "errors": [...]} on Close.
+	FormatObject Format = "object"
+	// FormatNDJSON streams one JSON object per line, bare values such as plan
+	// names are wrapped as {"match": <value>} or {"error": <value>}.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV writes one row of Options.Columns per match, meta records
+	// are dropped and errors go to Options.Errors.
+	FormatCSV Format = "csv"
+	// FormatParquet writes Options.Columns of the matches as a parquet file
+	// for DuckDB or Spark, typed after the first rows and written in row
+	// groups. Meta records are dropped and errors go to Options.Errors like
+	// csv.
+	FormatParquet Format = "parquet"
+)
+
+var Formats = []Format{FormatJSON, FormatObject, FormatNDJSON, FormatCSV, FormatParquet}
+
+// Options configures a Writer.
+type Options struct {
+	Format Format
+	// Columns are the csv columns, json field names of the matches with
+	// nested fields separated by dots, e.g. rate.billing_code. A match that
+	// is not an object fills the first column.
+	Columns []string
+	// NoHeader omits the csv header row.
+	NoHeader bool
+	// Errors receives the error records of csv and parquet output as JSON
+	// lines, discarded when nil.
+	Errors io.Writer
+	// DataOnly drops meta records and sends error records to Errors in
+	// every format, so the output holds nothing but matches. Sinks still get
+	// every record.
+	DataOnly bool
+	// Observe, when set, is called with every match and error record as
+	// JSON, whatever the format, kind is "match" or "error". It is called
+	// while the Writer is locked so records arrive in order.
+	Observe func(kind string, record json.RawMessage)
+	// Sinks also receive every record after the format's own. A sink that
+	// fails is dropped and its error passed to SinkFailed, the output of the
+	// format is not affected. SinkFailed is called while the Writer is
+	// locked and must not write records.
+	Sinks      []Sink
+	SinkFailed func(err error)
+}
+
+// Writer is safe for concurrent use, each record is written atomically.
+type Writer struct {
+	mu   sync.Mutex
+	sink Sink
+
+	sinks      []Sink
+	sinkFailed func(err error)
+	observe    func(kind string, record json.RawMessage)
+}
+
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	sink, err := NewSink(w, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{sink: sink, sinks: opts.Sinks, sinkFailed: opts.SinkFailed, observe: opts.Observe}, nil
+}
+
+// Meta records run information such as start time or input file. In object
+// format a later value for the same key replaces the earlier one.
+func (w *Writer) Meta(key string, value any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", key, err)
+	}
+	return w.each(func(s Sink) error { return s.WriteMeta(key, raw) })
+}
+
+// Match records one result of the run.
+func (w *Writer) Match(value any) error {
+	return w.record("match", value, Sink.WriteMatch)
+}
+
+// Error records a warning or error that did not stop the run.
+func (w *Writer) Error(value any) error {
+	return w.record("error", value, Sink.WriteError)
+}
+
+func (w *Writer) record(kind string, value any, write func(Sink, json.RawMessage) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+	if w.observe != nil {
+		w.observe(kind, raw)
+	}
+	return w.each(func(s Sink) error { return write(s, raw) })
+}
+
+// each writes to the format sink and then the others, dropping those that
+// fail.
+func (w *Writer) each(write func(Sink) error) error {
+	err := write(w.sink)
+	w.sinks = slices.DeleteFunc(w.sinks, func(s Sink) bool {
+		err := write(s)
+		w.failed(s, err)
+		return err != nil
+	})
+	return err
+}
+
+func (w *Writer) failed(s Sink, err error) {
+	if err == nil {
+		return
+	}
+	// releases what it holds, its error is the same failure
+	s.Close()
+	if w.sinkFailed != nil {
+		w.sinkFailed(err)
+	}
+}
+
+// Close terminates the array, or writes the buffered object, and closes the
+// other sinks. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range w.sinks {
+		w.failed(s, s.Close())
+	}
+	w.sinks = nil
+	return w.sink.Close()
+}
@@ -0,0 +1,174 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type plan struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// writeRun records what a run of the extract command does, meta around
+// matches that are objects or bare names and an error.
+func writeRun(t *testing.T, w *Writer) {
+	t.Helper()
+	for _, err := range []error{
+		w.Meta("input", "anthem_index.json"),
+		w.Match(plan{Name: "blue ppo", URL: "https://example.com/a.json.gz"}),
+		w.Match("gold ppo"),
+		w.Error(map[string]string{"code": "W001", "message": "index drift"}),
+		w.Meta("records", 2),
+		w.Close(),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatJSON, `[
+{"input":"anthem_index.json"},
+{"name":"blue ppo","url":"https://example.com/a.json.gz"},
+"gold ppo",
+{"code":"W001","message":"index drift"},
+{"records":2}
+]
+`},
+		{FormatObject, `{
+"meta": {
+  "input": "anthem_index.json",
+  "records": 2
+},
+"matches": [
+  {"name":"blue ppo","url":"https://example.com/a.json.gz"},
+  "gold ppo"
+],
+"errors": [
+  {"code":"W001","message":"index drift"}
+]
+}
+`},
+		{FormatNDJSON, `{"input":"anthem_index.json"}
+{"name":"blue ppo","url":"https://example.com/a.json.gz"}
+{"match":"gold ppo"}
+{"code":"W001","message":"index drift"}
+{"records":2}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, Options{Format: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeRun(t, w)
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+			if tt.format != FormatNDJSON && !json.Valid(buf.Bytes()) {
+				t.Error("output is not valid json")
+			}
+		})
+	}
+}
+
+func TestEmptyOutputIsValid(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatObject} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, Options{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("%s output of no records is not valid json: %s", format, buf.String())
+		}
+	}
+}
+
+func TestConcurrentRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Options{Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				w.Match(plan{Name: strings.Repeat("p", i+1)})
+			}
+		}()
+	}
+	wg.Wait()
+	w.Close()
+
+	var records []plan
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("concurrent output is not valid json: %v", err)
+	}
+	if len(records) != 1000 {
+		t.Errorf("%d records, want 1000", len(records))
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Format: "xml"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestDataOnly(t *testing.T) {
+	var out, errs bytes.Buffer
+	sink := &recordSink{}
+	w, err := NewWriter(&out, Options{Format: FormatJSON, DataOnly: true, Errors: &errs, Sinks: []Sink{sink}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, w)
+	want := "[\n{\"name\":\"blue ppo\",\"url\":\"https://example.com/a.json.gz\"},\n\"gold ppo\"\n]\n"
+	if out.String() != want {
+		t.Errorf("output\n%s\nwant only the matches", out.String())
+	}
+	if errs.String() != "{\"code\":\"W001\",\"message\":\"index drift\"}\n" {
+		t.Errorf("errors %q", errs.String())
+	}
+	if len(sink.records) != len(runRecords) {
+		t.Errorf("sink got %d records, want all %d", len(sink.records), len(runRecords))
+	}
+}
+
+func TestObserve(t *testing.T) {
+	var observed []string
+	w, err := NewWriter(&bytes.Buffer{}, Options{
+		Format:  FormatObject,
+		Observe: func(kind string, record json.RawMessage) { observed = append(observed, kind+" "+string(record)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, w)
+	want := []string{
+		`match {"name":"blue ppo","url":"https://example.com/a.json.gz"}`,
+		`match "gold ppo"`,
+		`error {"code":"W001","message":"index drift"}`,
+	}
+	if strings.Join(observed, "\n") != strings.Join(want, "\n") {
+		t.Errorf("observed\n%s\nwant the matches and errors as they are written", strings.Join(observed, "\n"))
+	}
+}
@@ -0,0 +1,208 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetSchemaRows is the number of rows buffered to pick the column types
+// from, parquet needs its schema before the first row.
+const parquetSchemaRows = 1000
+
+// parquetRowGroupRows bounds the rows held in memory before a row group is
+// written.
+const parquetRowGroupRows = 100000
+
+type parquetType int
+
+const (
+	parquetString parquetType = iota
+	parquetDouble
+	parquetBool
+)
+
+// parquetWriter writes Options.Columns of the matches as optional parquet
+// columns. A column whose values in the first rows are all numbers or all
+// booleans is a double or boolean column, numbers are doubles as in JSON
+// since a price of 110 may be followed by one of 110.5. Any other column is a string holding strings
+// as they are and other values as JSON. Later values that do not fit the
+// type are written as null.
+type parquetWriter struct {
+	out     io.Writer
+	columns []string
+
+	// pending are the rows read before the schema is known
+	pending [][]json.RawMessage
+	types   []parquetType
+	// leaves are the parquet column indexes of columns
+	leaves []int
+	w      *parquet.Writer
+}
+
+func newParquetWriter(out io.Writer, columns []string) *parquetWriter {
+	return &parquetWriter{out: out, columns: columns}
+}
+
+func (p *parquetWriter) write(row []json.RawMessage) error {
+	if p.w == nil {
+		p.pending = append(p.pending, row)
+		if len(p.pending) < parquetSchemaRows {
+			return nil
+		}
+		return p.start()
+	}
+	_, err := p.w.WriteRows([]parquet.Row{p.row(row)})
+	return err
+}
+
+// start picks the schema from the pending rows and writes them.
+func (p *parquetWriter) start() error {
+	p.types = make([]parquetType, len(p.columns))
+	group := make(parquet.Group, len(p.columns))
+	names := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		p.types[i] = p.columnType(i)
+		names[i] = parquetName(column)
+		switch p.types[i] {
+		case parquetDouble:
+			group[names[i]] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		case parquetBool:
+			group[names[i]] = parquet.Optional(parquet.Leaf(parquet.BooleanType))
+		default:
+			group[names[i]] = parquet.Optional(parquet.String())
+		}
+	}
+
+	schema := parquet.NewSchema("results", orderedGroup{Group: group, names: names})
+	p.leaves = make([]int, len(p.columns))
+	for leaf, path := range schema.Columns() {
+		p.leaves[slices.Index(names, path[0])] = leaf
+	}
+	p.w = parquet.NewWriter(p.out, schema, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(parquetRowGroupRows))
+
+	rows := make([]parquet.Row, len(p.pending))
+	for i, row := range p.pending {
+		rows[i] = p.row(row)
+	}
+	p.pending = nil
+	_, err := p.w.WriteRows(rows)
+	return err
+}
+
+// columnType is the narrowest type fitting the pending values of column i.
+func (p *parquetWriter) columnType(i int) parquetType {
+	typ, seen := parquetString, false
+	for _, row := range p.pending {
+		value, ok := parseValue(row[i])
+		if !ok {
+			continue
+		}
+		var t parquetType
+		switch value.(type) {
+		case bool:
+			t = parquetBool
+		case json.Number:
+			t = parquetDouble
+		default:
+			return parquetString
+		}
+		if seen && typ != t {
+			return parquetString
+		}
+		typ, seen = t, true
+	}
+	return typ
+}
+
+func (p *parquetWriter) row(values []json.RawMessage) parquet.Row {
+	row := make(parquet.Row, len(p.columns))
+	for i, raw := range values {
+		value, definition := p.value(i, raw), 1
+		if value.IsNull() {
+			definition = 0
+		}
+		row[p.leaves[i]] = value.Level(0, definition, p.leaves[i])
+	}
+	return row
+}
+
+// value converts raw to the type of column i, a null Value when it is
+// missing or does not fit.
+func (p *parquetWriter) value(i int, raw json.RawMessage) parquet.Value {
+	value, ok := parseValue(raw)
+	if !ok {
+		return parquet.NullValue()
+	}
+	switch p.types[i] {
+	case parquetDouble:
+		if n, ok := value.(json.Number); ok {
+			if v, err := n.Float64(); err == nil {
+				return parquet.DoubleValue(v)
+			}
+		}
+	case parquetBool:
+		if v, ok := value.(bool); ok {
+			return parquet.BooleanValue(v)
+		}
+	default:
+		if s, ok := value.(string); ok {
+			return parquet.ByteArrayValue([]byte(s))
+		}
+		return parquet.ByteArrayValue(raw)
+	}
+	return parquet.NullValue()
+}
+
+func (p *parquetWriter) close() error {
+	if p.w == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+	if err := p.w.Close(); err != nil {
+		return fmt.Errorf("write parquet footer: %w", err)
+	}
+	return nil
+}
+
+// parseValue decodes raw keeping numbers exact, false for missing and null
+// values.
+func parseValue(raw json.RawMessage) (any, bool) {
+	if len(raw) == 0 {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil || value == nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// parquetName turns a dotted column into a top level parquet column name,
+// rate.billing_code becomes rate_billing_code, as Spark reads dots as
+// nesting.
+func parquetName(column string) string {
+	return strings.ReplaceAll(column, ".", "_")
+}
+
+// orderedGroup keeps the columns in the order they were given instead of the
+// alphabetical order of parquet.Group.
+type orderedGroup struct {
+	parquet.Group
+	names []string
+}
+
+func (g orderedGroup) Fields() []parquet.Field {
+	fields := g.Group.Fields()
+	slices.SortFunc(fields, func(a, b parquet.Field) int {
+		return slices.Index(g.names, a.Name()) - slices.Index(g.names, b.Name())
+	})
+	return fields
+}
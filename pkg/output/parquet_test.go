@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquet(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Options{
+		Format:  FormatParquet,
+		Columns: []string{"name", "rate.negotiated_rate", "in_network", "codes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []any{
+		map[string]any{"file": "anthem_index.json"},
+		map[string]any{"name": "blue ppo", "rate": map[string]any{"negotiated_rate": 110}, "in_network": true, "codes": []string{"99213"}},
+		map[string]any{"name": "gold ppo", "rate": map[string]any{"negotiated_rate": 110.5}, "in_network": false},
+		map[string]any{"name": "silver ppo"},
+	} {
+		if err := w.Match(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, field := range file.Schema().Fields() {
+		names = append(names, field.Name()+" "+field.Type().String())
+	}
+	wantNames := []string{"name STRING", "rate_negotiated_rate DOUBLE", "in_network BOOLEAN", "codes STRING"}
+	if len(names) != len(wantNames) {
+		t.Fatalf("columns %v, want %v", names, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Errorf("column %d is %q, want %q", i, names[i], wantNames[i])
+		}
+	}
+
+	rows := make([]parquet.Row, 10)
+	n, _ := file.RowGroups()[0].Rows().ReadRows(rows)
+	want := [][]string{
+		{"blue ppo", "110", "true", `["99213"]`},
+		{"gold ppo", "110.5", "false", "null"},
+		{"silver ppo", "null", "null", "null"},
+	}
+	if n != len(want) {
+		t.Fatalf("%d rows, want %d", n, len(want))
+	}
+	for i, row := range rows[:n] {
+		for j, value := range row {
+			got := value.String()
+			if value.IsNull() {
+				got = "null"
+			}
+			if got != want[i][j] {
+				t.Errorf("row %d column %d is %s, want %s", i, j, got, want[i][j])
+			}
+		}
+	}
+}
+
+func TestParquetLateValues(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Options{Format: FormatParquet, Columns: []string{"rate"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the column is typed after the first rows, a later value that does not
+	// fit is null
+	for range parquetSchemaRows {
+		w.Match(map[string]any{"rate": 12.5})
+	}
+	w.Match(map[string]any{"rate": "n/a"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := file.Schema().Fields()[0].Type().String(); typ != "DOUBLE" {
+		t.Errorf("rate column is %s, want DOUBLE", typ)
+	}
+	rows := make([]parquet.Row, parquetSchemaRows+10)
+	n, _ := file.RowGroups()[0].Rows().ReadRows(rows)
+	if n != parquetSchemaRows+1 {
+		t.Fatalf("%d rows, want %d", n, parquetSchemaRows+1)
+	}
+	if last := rows[n-1][0]; !last.IsNull() {
+		t.Errorf("late rate is %s, want null", last)
+	}
+}
+
+func TestParquetColumns(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Format: FormatParquet}); err == nil {
+		t.Error("parquet without columns accepted")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Format: FormatParquet, Columns: []string{"rate.code", "rate_code"}}); err == nil {
+		t.Error("columns with the same parquet name accepted")
+	}
+}
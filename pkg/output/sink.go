@@ -0,0 +1,301 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sink is where a Writer's records go, marshaled to JSON. A Writer calls it
+// from one goroutine at a time, in record order.
+type Sink interface {
+	// WriteMeta records run information under key.
+	WriteMeta(key string, value json.RawMessage) error
+	// WriteMatch records one result.
+	WriteMatch(record json.RawMessage) error
+	// WriteError records a warning or error that did not stop the run.
+	WriteError(record json.RawMessage) error
+	// Close writes what is buffered, no record follows.
+	Close() error
+}
+
+// NewSink returns the sink writing opts.Format to w.
+func NewSink(w io.Writer, opts Options) (Sink, error) {
+	s, err := newFormatSink(w, opts)
+	if err != nil || !opts.DataOnly {
+		return s, err
+	}
+	return &dataOnlySink{Sink: s, errOutput: errorOutput(opts)}, nil
+}
+
+func newFormatSink(w io.Writer, opts Options) (Sink, error) {
+	switch opts.Format {
+	case "", FormatJSON:
+		s := &jsonSink{w: w}
+		s.write([]byte("[\n"))
+		return s, s.err
+	case FormatObject:
+		return &objectSink{w: w, meta: make(map[string]json.RawMessage)}, nil
+	case FormatNDJSON:
+		return &ndjsonSink{w: w}, nil
+	case FormatCSV:
+		if len(opts.Columns) == 0 {
+			return nil, errors.New("csv output requires at least one column")
+		}
+		s := &csvSink{csv: csv.NewWriter(w), columns: opts.Columns, errOutput: errorOutput(opts)}
+		if !opts.NoHeader {
+			s.writeRow(opts.Columns)
+		}
+		return s, s.err
+	case FormatParquet:
+		if len(opts.Columns) == 0 {
+			return nil, errors.New("parquet output requires at least one column")
+		}
+		names := make(map[string]struct{}, len(opts.Columns))
+		for _, column := range opts.Columns {
+			if _, dup := names[parquetName(column)]; dup {
+				return nil, fmt.Errorf("parquet column %s is given twice", parquetName(column))
+			}
+			names[parquetName(column)] = struct{}{}
+		}
+		return &parquetSink{parquet: newParquetWriter(w, opts.Columns), columns: opts.Columns, errOutput: errorOutput(opts)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of %v", opts.Format, Formats)
+	}
+}
+
+func errorOutput(opts Options) io.Writer {
+	if opts.Errors == nil {
+		return io.Discard
+	}
+	return opts.Errors
+}
+
+// dataOnlySink passes only the matches to the format's sink.
+type dataOnlySink struct {
+	Sink
+	errOutput io.Writer
+}
+
+func (s *dataOnlySink) WriteMeta(string, json.RawMessage) error { return nil }
+
+func (s *dataOnlySink) WriteError(record json.RawMessage) error {
+	_, err := s.errOutput.Write(append(record, '\n'))
+	return err
+}
+
+// jsonSink streams an array of records, meta records as {"<key>": <value>}.
+type jsonSink struct {
+	w     io.Writer
+	count int
+	err   error
+}
+
+func (s *jsonSink) WriteMeta(key string, value json.RawMessage) error {
+	record, err := json.Marshal(map[string]json.RawMessage{key: value})
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", key, err)
+	}
+	return s.element(record)
+}
+
+func (s *jsonSink) WriteMatch(record json.RawMessage) error { return s.element(record) }
+func (s *jsonSink) WriteError(record json.RawMessage) error { return s.element(record) }
+
+func (s *jsonSink) element(raw []byte) error {
+	if s.count > 0 {
+		s.write([]byte(",\n"))
+	}
+	s.write(raw)
+	s.count++
+	return s.err
+}
+
+func (s *jsonSink) write(p []byte) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.w.Write(p)
+}
+
+func (s *jsonSink) Close() error {
+	s.write([]byte("\n]\n"))
+	return s.err
+}
+
+// ndjsonSink streams a JSON object per line, bare values wrapped as
+// {"match": <value>} or {"error": <value>}.
+type ndjsonSink struct {
+	w   io.Writer
+	err error
+}
+
+func (s *ndjsonSink) WriteMeta(key string, value json.RawMessage) error {
+	return s.line(key, value)
+}
+
+func (s *ndjsonSink) WriteMatch(record json.RawMessage) error { return s.record("match", record) }
+func (s *ndjsonSink) WriteError(record json.RawMessage) error { return s.record("error", record) }
+
+func (s *ndjsonSink) record(kind string, raw json.RawMessage) error {
+	if raw[0] != '{' {
+		return s.line(kind, raw)
+	}
+	return s.write(raw)
+}
+
+func (s *ndjsonSink) line(key string, value json.RawMessage) error {
+	record, err := json.Marshal(map[string]json.RawMessage{key: value})
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", key, err)
+	}
+	return s.write(record)
+}
+
+func (s *ndjsonSink) write(record []byte) error {
+	if s.err == nil {
+		_, s.err = s.w.Write(append(record, '\n'))
+	}
+	return s.err
+}
+
+func (s *ndjsonSink) Close() error { return s.err }
+
+// objectSink buffers the records into one object written on Close.
+type objectSink struct {
+	w        io.Writer
+	metaKeys []string
+	meta     map[string]json.RawMessage
+	matches  []json.RawMessage
+	errors   []json.RawMessage
+}
+
+func (s *objectSink) WriteMeta(key string, value json.RawMessage) error {
+	if _, exists := s.meta[key]; !exists {
+		s.metaKeys = append(s.metaKeys, key)
+	}
+	s.meta[key] = value
+	return nil
+}
+
+func (s *objectSink) WriteMatch(record json.RawMessage) error {
+	s.matches = append(s.matches, record)
+	return nil
+}
+
+func (s *objectSink) WriteError(record json.RawMessage) error {
+	s.errors = append(s.errors, record)
+	return nil
+}
+
+func (s *objectSink) Close() error {
+	var buf bytes.Buffer
+	buf.WriteString("{\n\"meta\": {")
+	for i, key := range s.metaKeys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, _ := json.Marshal(key)
+		buf.WriteString("\n  ")
+		buf.Write(name)
+		buf.WriteString(": ")
+		buf.Write(s.meta[key])
+	}
+	buf.WriteString("\n},\n\"matches\": ")
+	writeArray(&buf, s.matches)
+	buf.WriteString(",\n\"errors\": ")
+	writeArray(&buf, s.errors)
+	buf.WriteString("\n}\n")
+
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func writeArray(buf *bytes.Buffer, elements []json.RawMessage) {
+	if len(elements) == 0 {
+		buf.WriteString("[]")
+		return
+	}
+	buf.WriteString("[")
+	for i, element := range elements {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		buf.Write(element)
+	}
+	buf.WriteString("\n]")
+}
+
+// csvSink writes a row of the columns per match, meta records are dropped
+// and errors go to errOutput as JSON lines.
+type csvSink struct {
+	csv       *csv.Writer
+	columns   []string
+	errOutput io.Writer
+	err       error
+}
+
+func (s *csvSink) WriteMeta(string, json.RawMessage) error { return nil }
+
+func (s *csvSink) WriteMatch(record json.RawMessage) error {
+	row, ok := csvRow(record, s.columns)
+	if !ok {
+		return nil
+	}
+	s.writeRow(row)
+	return s.err
+}
+
+func (s *csvSink) WriteError(record json.RawMessage) error {
+	_, err := s.errOutput.Write(append(record, '\n'))
+	return err
+}
+
+func (s *csvSink) writeRow(row []string) {
+	if s.err != nil {
+		return
+	}
+	// flushed per row so csv streams like the json formats
+	s.csv.Write(row)
+	s.csv.Flush()
+	s.err = s.csv.Error()
+}
+
+func (s *csvSink) Close() error { return s.err }
+
+// parquetSink writes the columns of the matches in row groups, meta records
+// are dropped and errors go to errOutput as JSON lines.
+type parquetSink struct {
+	parquet   *parquetWriter
+	columns   []string
+	errOutput io.Writer
+	err       error
+}
+
+func (s *parquetSink) WriteMeta(string, json.RawMessage) error { return nil }
+
+func (s *parquetSink) WriteMatch(record json.RawMessage) error {
+	values, ok := pickColumns(record, s.columns)
+	if !ok || s.err != nil {
+		return s.err
+	}
+	s.err = s.parquet.write(values)
+	return s.err
+}
+
+func (s *parquetSink) WriteError(record json.RawMessage) error {
+	_, err := s.errOutput.Write(append(record, '\n'))
+	return err
+}
+
+func (s *parquetSink) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.err = s.parquet.close()
+	return s.err
+}
@@ -0,0 +1,78 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recordSink keeps the records it gets, failing the write of failAt.
+type recordSink struct {
+	records []string
+	failAt  int
+	closed  bool
+}
+
+func (s *recordSink) add(record string) error {
+	s.records = append(s.records, record)
+	if len(s.records) == s.failAt {
+		return errors.New("sink full")
+	}
+	return nil
+}
+
+func (s *recordSink) WriteMeta(key string, value json.RawMessage) error {
+	return s.add("meta " + key + " " + string(value))
+}
+func (s *recordSink) WriteMatch(record json.RawMessage) error {
+	return s.add("match " + string(record))
+}
+func (s *recordSink) WriteError(record json.RawMessage) error {
+	return s.add("error " + string(record))
+}
+func (s *recordSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// runRecords are the records of writeRun as a recordSink has them.
+var runRecords = []string{
+	`meta input "anthem_index.json"`,
+	`match {"name":"blue ppo","url":"https://example.com/a.json.gz"}`,
+	`match "gold ppo"`,
+	`error {"code":"W001","message":"index drift"}`,
+	`meta records 2`,
+}
+
+func TestSinks(t *testing.T) {
+	all, failing := &recordSink{}, &recordSink{failAt: 2}
+	var failures []error
+	var out bytes.Buffer
+	w, err := NewWriter(&out, Options{
+		Format:     FormatCSV,
+		Columns:    []string{"name"},
+		Sinks:      []Sink{all, failing},
+		SinkFailed: func(err error) { failures = append(failures, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, w)
+
+	// sinks get every record whatever the format drops
+	if strings.Join(all.records, "\n") != strings.Join(runRecords, "\n") || !all.closed {
+		t.Errorf("sink got\n%s\nwant\n%s", strings.Join(all.records, "\n"), strings.Join(runRecords, "\n"))
+	}
+	if len(failing.records) != 2 || !failing.closed {
+		t.Errorf("failed sink got %d records, closed %v, want it dropped and closed after 2", len(failing.records), failing.closed)
+	}
+	if len(failures) != 1 || failures[0].Error() != "sink full" {
+		t.Errorf("sink failures %v", failures)
+	}
+	// the format's output is complete
+	if out.String() != "name\nblue ppo\ngold ppo\n" {
+		t.Errorf("csv output %q", out.String())
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// sqliteCommitEvery is how many records a sqlite sink inserts per
+// transaction.
+const sqliteCommitEvery = 1000
+
+const recordsSchema = `
+CREATE TABLE IF NOT EXISTS records (
+	id INTEGER PRIMARY KEY,
+	run TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	key TEXT,
+	record TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS records_run ON records(run);
+`
+
+// sqliteSink appends the records to the records table, one row per record
+// with its kind, match, error or meta, and the meta key.
+type sqliteSink struct {
+	db    *sql.DB
+	run   string
+	tx    *sql.Tx
+	count int
+}
+
+// NewSQLiteSink returns a sink appending the records of run to the records
+// table of db, created when missing. The caller registers the driver, the
+// sink closes db on Close.
+func NewSQLiteSink(db *sql.DB, run string) (Sink, error) {
+	if _, err := db.Exec(recordsSchema); err != nil {
+		return nil, fmt.Errorf("create records table: %w", err)
+	}
+	return &sqliteSink{db: db, run: run}, nil
+}
+
+func (s *sqliteSink) WriteMeta(key string, value json.RawMessage) error {
+	return s.insert("meta", key, value)
+}
+
+func (s *sqliteSink) WriteMatch(record json.RawMessage) error {
+	return s.insert("match", "", record)
+}
+
+func (s *sqliteSink) WriteError(record json.RawMessage) error {
+	return s.insert("error", "", record)
+}
+
+func (s *sqliteSink) insert(kind string, key string, record json.RawMessage) error {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin records transaction: %w", err)
+		}
+		s.tx = tx
+	}
+	var nullableKey sql.NullString
+	if key != "" {
+		nullableKey = sql.NullString{String: key, Valid: true}
+	}
+	if _, err := s.tx.Exec(`INSERT INTO records (run, kind, key, record) VALUES (?, ?, ?, ?)`, s.run, kind, nullableKey, string(record)); err != nil {
+		return fmt.Errorf("insert record: %w", err)
+	}
+	s.count++
+	if s.count < sqliteCommitEvery {
+		return nil
+	}
+	return s.commit()
+}
+
+func (s *sqliteSink) commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Commit()
+	s.tx, s.count = nil, 0
+	if err != nil {
+		return fmt.Errorf("commit records: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	err := s.commit()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
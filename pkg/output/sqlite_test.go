@@ -0,0 +1,65 @@
+package output
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	// two runs accumulate in the table
+	for _, run := range []string{"2026-01-01T00:00:00Z", "2026-02-01T00:00:00Z"} {
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink, err := NewSQLiteSink(db, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWriter(io.Discard, Options{Sinks: []Sink{sink}, SinkFailed: func(err error) { t.Error(err) }})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeRun(t, w)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT kind, coalesce(key, ''), record FROM records WHERE run = ? ORDER BY id`, "2026-02-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var kind, key, record string
+		if err := rows.Scan(&kind, &key, &record); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSpace(kind+" "+key+" "+record))
+	}
+	want := []string{
+		`meta input "anthem_index.json"`,
+		`match  {"name":"blue ppo","url":"https://example.com/a.json.gz"}`,
+		`match  "gold ppo"`,
+		`error  {"code":"W001","message":"index drift"}`,
+		`meta records 2`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	var count int
+	db.QueryRow(`SELECT count(*) FROM records`).Scan(&count)
+	if count != 10 {
+		t.Errorf("%d records over two runs, want 10", count)
+	}
+}
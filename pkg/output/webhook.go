@@ -0,0 +1,166 @@
+package output
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWebhookBatch is how many records a webhook request carries when
+// WebhookOptions.Batch is 0.
+const DefaultWebhookBatch = 500
+
+// SignatureHeader carries the hex HMAC-SHA256 of a request body keyed with
+// WebhookOptions.Secret, prefixed with sha256=.
+const SignatureHeader = "X-Signature-256"
+
+// DeliveryHeader names a request, the same on its retries so the receiver
+// can drop duplicates.
+const DeliveryHeader = "X-Webhook-Delivery"
+
+// WebhookOptions configure the requests of NewWebhookSink and NewCallback.
+type WebhookOptions struct {
+	// Batch is how many records a request carries.
+	Batch int
+	// Secret signs each request body in SignatureHeader, unsigned when
+	// empty.
+	Secret string
+	// Retries are the additional attempts after a request fails with a
+	// network error, a 429 or a 5xx, a second apart and twice as long each
+	// time. Other responses fail the sink at once.
+	Retries int
+	// Delivery prefixes the DeliveryHeader of each request, followed by
+	// its sequence number.
+	Delivery string
+}
+
+// poster posts signed request bodies with retries.
+type poster struct {
+	url    string
+	client http.Client
+	opts   WebhookOptions
+	// sent numbers the requests
+	sent int
+	// err is the first failed request, nothing is posted after it
+	err error
+}
+
+func newPoster(url string, opts WebhookOptions) *poster {
+	if opts.Batch <= 0 {
+		opts.Batch = DefaultWebhookBatch
+	}
+	return &poster{url: url, client: http.Client{Timeout: 30 * time.Second}, opts: opts}
+}
+
+func (p *poster) post(contentType string, body []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.sent++
+	delay := time.Second
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = p.attempt(contentType, body)
+		if err == nil || !retry || attempt >= p.opts.Retries {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		p.err = fmt.Errorf("post to webhook: %w", err)
+	}
+	return p.err
+}
+
+func (p *poster) attempt(contentType string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(DeliveryHeader, fmt.Sprintf("%s%d", p.opts.Delivery, p.sent))
+	if p.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(p.opts.Secret, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign is the SignatureHeader value of body, for receivers to compare with
+// hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSink posts the records as JSON lines, like FormatNDJSON, a batch
+// of records per request.
+type webhookSink struct {
+	poster *poster
+	buf    bytes.Buffer
+	lines  ndjsonSink
+	count  int
+}
+
+// NewWebhookSink returns a sink posting every record to url as
+// application/x-ndjson, opts.Batch records per request and the rest on
+// Close.
+func NewWebhookSink(url string, opts WebhookOptions) Sink {
+	s := &webhookSink{poster: newPoster(url, opts)}
+	s.lines.w = &s.buf
+	return s
+}
+
+func (s *webhookSink) WriteMeta(key string, value json.RawMessage) error {
+	return s.added(s.lines.WriteMeta(key, value))
+}
+
+func (s *webhookSink) WriteMatch(record json.RawMessage) error {
+	return s.added(s.lines.WriteMatch(record))
+}
+
+func (s *webhookSink) WriteError(record json.RawMessage) error {
+	return s.added(s.lines.WriteError(record))
+}
+
+func (s *webhookSink) added(err error) error {
+	if err != nil {
+		return err
+	}
+	s.count++
+	if s.count < s.poster.opts.Batch {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *webhookSink) flush() error {
+	if s.count == 0 {
+		return nil
+	}
+	err := s.poster.post("application/x-ndjson", s.buf.Bytes())
+	s.buf.Reset()
+	s.count = 0
+	return err
+}
+
+func (s *webhookSink) Close() error {
+	return s.flush()
+}
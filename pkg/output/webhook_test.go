@@ -0,0 +1,111 @@
+package output
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookReceiver records the requests it gets, answering each with the next
+// of statuses and 200 once they run out.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (rcv *webhookReceiver) start(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.bodies = append(rcv.bodies, string(body))
+		rcv.headers = append(rcv.headers, r.Header.Clone())
+		if len(rcv.statuses) > 0 {
+			w.WriteHeader(rcv.statuses[0])
+			rcv.statuses = rcv.statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookSink(t *testing.T) {
+	var rcv webhookReceiver
+	server := rcv.start(t)
+	var failures []error
+	w, err := NewWriter(io.Discard, Options{
+		Sinks:      []Sink{NewWebhookSink(server.URL, WebhookOptions{Batch: 2, Secret: "s3cret", Delivery: "run-1/"})},
+		SinkFailed: func(err error) { failures = append(failures, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, w)
+
+	want := []string{
+		"{\"input\":\"anthem_index.json\"}\n{\"name\":\"blue ppo\",\"url\":\"https://example.com/a.json.gz\"}\n",
+		"{\"match\":\"gold ppo\"}\n{\"code\":\"W001\",\"message\":\"index drift\"}\n",
+		"{\"records\":2}\n",
+	}
+	if len(failures) > 0 {
+		t.Fatalf("sink failed: %v", failures)
+	}
+	if len(rcv.bodies) != len(want) {
+		t.Fatalf("%d requests, want %d: %q", len(rcv.bodies), len(want), rcv.bodies)
+	}
+	for i, body := range rcv.bodies {
+		if body != want[i] {
+			t.Errorf("request %d body %q, want %q", i, body, want[i])
+		}
+		header := rcv.headers[i]
+		if header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("request %d content type %q", i, header.Get("Content-Type"))
+		}
+		if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign("s3cret", []byte(body)))) {
+			t.Errorf("request %d signature %q does not match its body", i, header.Get(SignatureHeader))
+		}
+		if delivery := header.Get(DeliveryHeader); delivery != "run-1/"+strconv.Itoa(i+1) {
+			t.Errorf("request %d delivery %q", i, delivery)
+		}
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	rcv := webhookReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := rcv.start(t)
+	sink := NewWebhookSink(server.URL, WebhookOptions{Batch: 1, Retries: 1})
+	if err := sink.WriteMatch(json.RawMessage(`"blue ppo"`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(rcv.bodies) != 2 || rcv.headers[0].Get(DeliveryHeader) != rcv.headers[1].Get(DeliveryHeader) {
+		t.Errorf("%d requests, want the batch retried once under the same delivery", len(rcv.bodies))
+	}
+	if rcv.headers[0].Get(SignatureHeader) != "" {
+		t.Error("request signed without a secret")
+	}
+}
+
+func TestWebhookRejected(t *testing.T) {
+	rcv := webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	server := rcv.start(t)
+	sink := NewWebhookSink(server.URL, WebhookOptions{Batch: 1, Retries: 3})
+	err := sink.WriteMatch(json.RawMessage(`"blue ppo"`))
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("rejected batch failed with %v, want the status", err)
+	}
+	// nothing is posted after a failed request
+	if err := sink.WriteMatch(json.RawMessage(`"gold ppo"`)); err == nil {
+		t.Error("write after a failed request succeeded")
+	}
+	if len(rcv.bodies) != 1 {
+		t.Errorf("%d requests, want the rejected one only", len(rcv.bodies))
+	}
+}
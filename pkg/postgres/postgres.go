@@ -0,0 +1,251 @@
+// Package postgres bulk loads matches and negotiated rates into a central
+// PostgreSQL price transparency warehouse. Rows are buffered and copied in
+// batches into session staging tables with COPY, then upserted into the
+// warehouse tables, so rerunning a file refreshes its rows instead of
+// duplicating them.
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"serif_interview/pkg/rates"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultBatchSize is the number of rows copied per batch when
+// Options.BatchSize is not set.
+const DefaultBatchSize = 10000
+
+const schema = `
+CREATE TABLE IF NOT EXISTS ppo_matches (
+	payer text NOT NULL,
+	location text NOT NULL,
+	file text NOT NULL,
+	mode text NOT NULL,
+	description text,
+	network text,
+	plan_code text,
+	eins text[],
+	ai_match boolean,
+	heuristic_match boolean,
+	region_code_match boolean,
+	score double precision,
+	first_seen timestamptz NOT NULL DEFAULT now(),
+	last_seen timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (payer, location)
+);
+CREATE TABLE IF NOT EXISTS negotiated_rates (
+	id text PRIMARY KEY,
+	file text NOT NULL,
+	billing_code_type text NOT NULL,
+	billing_code text NOT NULL,
+	negotiated_type text,
+	negotiated_rate double precision,
+	billing_class text,
+	expiration_date text,
+	provider_references jsonb,
+	provider_groups jsonb,
+	first_seen timestamptz NOT NULL DEFAULT now(),
+	last_seen timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS negotiated_rates_code ON negotiated_rates (billing_code_type, billing_code);
+CREATE TEMP TABLE IF NOT EXISTS ppo_matches_staging (LIKE ppo_matches INCLUDING DEFAULTS) ON COMMIT DELETE ROWS;
+CREATE TEMP TABLE IF NOT EXISTS negotiated_rates_staging (LIKE negotiated_rates INCLUDING DEFAULTS) ON COMMIT DELETE ROWS;
+`
+
+var matchColumns = []string{"payer", "location", "file", "mode", "description", "network", "plan_code", "eins", "ai_match", "heuristic_match", "region_code_match", "score"}
+
+var rateColumns = []string{"id", "file", "billing_code_type", "billing_code", "negotiated_type", "negotiated_rate", "billing_class", "expiration_date", "provider_references", "provider_groups"}
+
+// Match is a rate file location a run selected. A payer lists a location
+// once, a later run replaces the columns of its earlier row and keeps when
+// it was first seen.
+type Match struct {
+	Payer           string
+	Location        string
+	File            string
+	Mode            string
+	Description     string
+	Network         string
+	PlanCode        string
+	EINs            []string
+	AIMatch         bool
+	HeuristicMatch  bool
+	RegionCodeMatch bool
+	Score           float64
+}
+
+// Options configures a Sink.
+type Options struct {
+	// BatchSize is the number of rows buffered per table before they are
+	// copied, DefaultBatchSize when 0.
+	BatchSize int
+}
+
+// Sink is safe for concurrent use, rows are copied over one connection.
+type Sink struct {
+	mu        sync.Mutex
+	conn      *pgx.Conn
+	batchSize int
+
+	matches [][]any
+	rates   [][]any
+}
+
+// Open connects to the database at url, a postgres:// url or key=value
+// connection string, and creates the tables when they are missing.
+func Open(ctx context.Context, url string, opts Options) (*Sink, error) {
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	if _, err := conn.Exec(ctx, schema); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("create postgres tables: %w", err)
+	}
+
+	s := &Sink{conn: conn, batchSize: opts.BatchSize}
+	if s.batchSize <= 0 {
+		s.batchSize = DefaultBatchSize
+	}
+	return s, nil
+}
+
+// AddMatch buffers a match, copying the batch once it is full.
+func (s *Sink) AddMatch(ctx context.Context, m Match) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.matches = append(s.matches, []any{
+		m.Payer, m.Location, m.File, m.Mode, nullable(m.Description), nullable(m.Network), nullable(m.PlanCode),
+		m.EINs, m.AIMatch, m.HeuristicMatch, m.RegionCodeMatch, m.Score,
+	})
+	if len(s.matches) < s.batchSize {
+		return nil
+	}
+	return s.flushMatches(ctx)
+}
+
+// AddRate buffers a negotiated price of the rate file filename. Its id
+// hashes the file's base name with the price, so the same price of the same
+// file is one row however often it is loaded.
+func (s *Sink) AddRate(ctx context.Context, filename string, price rates.FlatPrice) error {
+	record, err := json.Marshal(price)
+	if err != nil {
+		return fmt.Errorf("marshal negotiated price: %w", err)
+	}
+	file := filepath.Base(filename)
+	sum := sha256.Sum256([]byte(file + "\x00" + string(record)))
+
+	var references, groups []byte
+	if len(price.ProviderReferences) > 0 {
+		references, _ = json.Marshal(price.ProviderReferences)
+	}
+	if len(price.ProviderGroups) > 0 {
+		groups, _ = json.Marshal(price.ProviderGroups)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rates = append(s.rates, []any{
+		hex.EncodeToString(sum[:]), file, price.BillingCodeType, price.BillingCode,
+		nullable(price.NegotiatedType), price.NegotiatedRate, nullable(price.BillingClass), nullable(price.ExpirationDate),
+		references, groups,
+	})
+	if len(s.rates) < s.batchSize {
+		return nil
+	}
+	return s.flushRates(ctx)
+}
+
+// Flush copies the buffered rows.
+func (s *Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.flushMatches(ctx); err != nil {
+		return err
+	}
+	return s.flushRates(ctx)
+}
+
+// Close flushes the buffered rows and closes the connection.
+func (s *Sink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	if closeErr := s.conn.Close(ctx); err == nil && closeErr != nil {
+		err = fmt.Errorf("close postgres connection: %w", closeErr)
+	}
+	return err
+}
+
+func (s *Sink) flushMatches(ctx context.Context) error {
+	if len(s.matches) == 0 {
+		return nil
+	}
+	err := s.upsert(ctx, "ppo_matches", matchColumns, []string{"payer", "location"}, s.matches)
+	s.matches = s.matches[:0]
+	return err
+}
+
+func (s *Sink) flushRates(ctx context.Context) error {
+	if len(s.rates) == 0 {
+		return nil
+	}
+	err := s.upsert(ctx, "negotiated_rates", rateColumns, []string{"id"}, s.rates)
+	s.rates = s.rates[:0]
+	return err
+}
+
+// upsert copies rows into the staging table of table and merges them in
+// one transaction. Rows of the batch sharing a key are merged first, ON
+// CONFLICT may only update a row once per statement.
+func (s *Sink) upsert(ctx context.Context, table string, columns []string, key []string, rows [][]any) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin %s batch: %w", table, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{table + "_staging"}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("copy %d rows into %s: %w", len(rows), table, err)
+	}
+
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !slices.Contains(key, column) {
+			updates = append(updates, column+" = EXCLUDED."+column)
+		}
+	}
+	updates = append(updates, "last_seen = now()")
+	keyList := strings.Join(key, ", ")
+	columnList := strings.Join(columns, ", ")
+	query := fmt.Sprintf(`INSERT INTO %[1]s (%[2]s)
+SELECT DISTINCT ON (%[3]s) %[2]s FROM %[1]s_staging ORDER BY %[3]s
+ON CONFLICT (%[3]s) DO UPDATE SET %[4]s`, table, columnList, keyList, strings.Join(updates, ", "))
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return fmt.Errorf("upsert %s: %w", table, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit %s batch: %w", table, err)
+	}
+	return nil
+}
+
+// nullable stores empty strings as NULL.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,367 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"serif_interview/pkg/postgres"
+	"serif_interview/pkg/rates"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// columnTypes are the types of the columns the sink copies.
+var columnTypes = map[string]uint32{
+	"payer": pgtype.TextOID, "location": pgtype.TextOID, "file": pgtype.TextOID, "mode": pgtype.TextOID,
+	"description": pgtype.TextOID, "network": pgtype.TextOID, "plan_code": pgtype.TextOID,
+	"eins": pgtype.TextArrayOID, "ai_match": pgtype.BoolOID, "heuristic_match": pgtype.BoolOID,
+	"region_code_match": pgtype.BoolOID, "score": pgtype.Float8OID,
+	"id": pgtype.TextOID, "billing_code_type": pgtype.TextOID, "billing_code": pgtype.TextOID,
+	"negotiated_type": pgtype.TextOID, "negotiated_rate": pgtype.Float8OID, "billing_class": pgtype.TextOID,
+	"expiration_date": pgtype.TextOID, "provider_references": pgtype.JSONBOID, "provider_groups": pgtype.JSONBOID,
+}
+
+// fakePostgres speaks enough of the postgres protocol for the sink. It
+// records the statements it runs, a COPY as "copy <table>", and decodes the
+// rows copied into each table. Statements starting with fail are rejected.
+type fakePostgres struct {
+	mu         sync.Mutex
+	statements []string
+	copied     map[string][]map[string]any
+	fail       string
+}
+
+func (f *fakePostgres) start(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f.copied = map[string][]map[string]any{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return fmt.Sprintf("postgres://loader@%s/warehouse?sslmode=disable", ln.Addr())
+}
+
+func (f *fakePostgres) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.4"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return
+	}
+
+	txStatus := byte('I')
+	var described []string
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Parse:
+			// pgx describes "select <columns> from <table>" before a COPY
+			described = strings.Split(strings.TrimPrefix(strings.SplitN(msg.Query, " from ", 2)[0], "select "), ", ")
+			backend.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			row := &pgproto3.RowDescription{}
+			for _, column := range described {
+				column = strings.Trim(column, `"`)
+				row.Fields = append(row.Fields, pgproto3.FieldDescription{Name: []byte(column), DataTypeOID: columnTypes[column], Format: pgtype.BinaryFormatCode})
+			}
+			backend.Send(&pgproto3.ParameterDescription{})
+			backend.Send(row)
+		case *pgproto3.Sync:
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+		case *pgproto3.Query:
+			statement := msg.String
+			var tag string
+			var err error
+			if strings.HasPrefix(statement, "copy ") {
+				table := strings.Trim(strings.Fields(statement)[1], `"`)
+				statement = "copy " + table
+				tag, err = f.copyIn(backend, table, described)
+			}
+			f.mu.Lock()
+			f.statements = append(f.statements, statement)
+			failed := f.fail != "" && strings.HasPrefix(statement, f.fail)
+			f.mu.Unlock()
+
+			switch {
+			case failed || err != nil:
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "XX000", Message: "rejected " + statement})
+				if txStatus == 'T' {
+					txStatus = 'E'
+				}
+			case statement == "begin":
+				tag, txStatus = "BEGIN", 'T'
+			case statement == "commit":
+				tag, txStatus = "COMMIT", 'I'
+			case statement == "rollback":
+				tag, txStatus = "ROLLBACK", 'I'
+			case strings.HasPrefix(statement, "INSERT"):
+				tag = "INSERT 0 1"
+			case tag == "":
+				tag = "CREATE TABLE"
+			}
+			if tag != "" && !failed && err == nil {
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+			}
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+		case *pgproto3.Terminate:
+			return
+		}
+		if backend.Flush() != nil {
+			return
+		}
+	}
+}
+
+// copyIn reads the binary COPY of columns into table.
+func (f *fakePostgres) copyIn(backend *pgproto3.Backend, table string, columns []string) (string, error) {
+	formats := make([]uint16, len(columns))
+	for i := range formats {
+		formats[i] = pgtype.BinaryFormatCode
+	}
+	backend.Send(&pgproto3.CopyInResponse{OverallFormat: 1, ColumnFormatCodes: formats})
+	if err := backend.Flush(); err != nil {
+		return "", err
+	}
+	var data []byte
+	for done := false; !done; {
+		msg, err := backend.Receive()
+		if err != nil {
+			return "", err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			data = append(data, msg.Data...)
+		case *pgproto3.CopyDone:
+			done = true
+		case *pgproto3.CopyFail:
+			return "", errors.New(msg.Message)
+		}
+	}
+
+	// the 11 byte signature, the flags and the header extension length
+	data = data[19:]
+	types := pgtype.NewMap()
+	var rows []map[string]any
+	// pgx ends the copy without the -1 trailer
+	for len(data) > 0 {
+		count := int16(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if count == -1 {
+			break
+		}
+		row := map[string]any{}
+		for _, column := range columns {
+			column = strings.Trim(column, `"`)
+			size := int32(binary.BigEndian.Uint32(data))
+			data = data[4:]
+			var value any
+			if size >= 0 {
+				oid := columnTypes[column]
+				t, _ := types.TypeForOID(oid)
+				decoded, err := t.Codec.DecodeValue(types, oid, pgtype.BinaryFormatCode, data[:size])
+				if err != nil {
+					return "", err
+				}
+				value, data = decoded, data[size:]
+			}
+			row[column] = value
+		}
+		rows = append(rows, row)
+	}
+
+	f.mu.Lock()
+	f.copied[table] = append(f.copied[table], rows...)
+	f.mu.Unlock()
+	return fmt.Sprintf("COPY %d", len(rows)), nil
+}
+
+// run returns the statements run since the last call.
+func (f *fakePostgres) run() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	statements := f.statements
+	f.statements = nil
+	return statements
+}
+
+func (f *fakePostgres) rows(table string) []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.copied[table]
+}
+
+func TestSinkMatches(t *testing.T) {
+	var pg fakePostgres
+	ctx := context.Background()
+	sink, err := postgres.Open(ctx, pg.start(t), postgres.Options{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statements := pg.run(); len(statements) != 1 || !strings.Contains(statements[0], "CREATE TABLE IF NOT EXISTS ppo_matches") {
+		t.Errorf("open ran %q, want the schema", statements)
+	}
+
+	matches := []postgres.Match{
+		{Payer: "anthem", Location: "https://example.com/a.json.gz", File: "a.json.gz", Mode: "ppo", Description: "blue ppo", EINs: []string{"12-3456789"}, AIMatch: true, Score: 0.9},
+		{Payer: "anthem", Location: "https://example.com/b.json.gz", File: "b.json.gz", Mode: "ppo", HeuristicMatch: true},
+		{Payer: "aetna", Location: "https://example.com/c.json.gz", File: "c.json.gz", Mode: "ppo"},
+	}
+	for _, m := range matches[:1] {
+		if err := sink.AddMatch(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if statements := pg.run(); len(statements) != 0 {
+		t.Errorf("ran %q before the batch was full", statements)
+	}
+
+	// a full batch is copied and merged in a transaction
+	if err := sink.AddMatch(ctx, matches[1]); err != nil {
+		t.Fatal(err)
+	}
+	statements := pg.run()
+	if len(statements) != 4 || statements[0] != "begin" || statements[1] != "copy ppo_matches_staging" || statements[3] != "commit" {
+		t.Fatalf("batch ran %q, want a copy and upsert in a transaction", statements)
+	}
+	upsert := statements[2]
+	for _, want := range []string{"INSERT INTO ppo_matches (payer, location,", "DISTINCT ON (payer, location)", "ON CONFLICT (payer, location) DO UPDATE SET file = EXCLUDED.file", "last_seen = now()"} {
+		if !strings.Contains(upsert, want) {
+			t.Errorf("upsert %q lacks %q", upsert, want)
+		}
+	}
+	if strings.Contains(upsert, "payer = EXCLUDED") {
+		t.Errorf("upsert %q updates its key", upsert)
+	}
+
+	// the rest is copied on close
+	if err := sink.AddMatch(ctx, matches[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if statements := pg.run(); len(statements) != 4 {
+		t.Errorf("close ran %q, want the last batch", statements)
+	}
+
+	rows := pg.rows("ppo_matches_staging")
+	if len(rows) != 3 {
+		t.Fatalf("%d rows copied, want 3", len(rows))
+	}
+	first := rows[0]
+	if first["description"] != "blue ppo" || first["ai_match"] != true || first["score"] != 0.9 || fmt.Sprint(first["eins"]) != "[12-3456789]" {
+		t.Errorf("first row %v", first)
+	}
+	// empty strings are stored as NULL
+	if rows[1]["description"] != nil || rows[1]["network"] != nil || rows[1]["heuristic_match"] != true {
+		t.Errorf("second row %v", rows[1])
+	}
+}
+
+func TestSinkRates(t *testing.T) {
+	var pg fakePostgres
+	ctx := context.Background()
+	sink, err := postgres.Open(ctx, pg.start(t), postgres.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := rates.FlatPrice{BillingCode: "99213", BillingCodeType: "CPT", NegotiatedRate: 110.5, NegotiatedType: "negotiated", ExpirationDate: "9999-12-31", ProviderReferences: []json.Number{"1", "2"}}
+	inline := price
+	inline.ProviderReferences = nil
+	inline.ProviderGroups = []rates.ProviderGroup{{NPI: []rates.NPI{"1306849450"}, TIN: rates.TIN{Type: "ein", Value: "12-3456789"}}}
+	for _, add := range []struct {
+		file  string
+		price rates.FlatPrice
+	}{
+		{"/data/2026-01_anthem_rates.json.gz", price},
+		// the same price of the same file loaded from elsewhere
+		{"https://example.com/2026-01_anthem_rates.json.gz", price},
+		{"/data/2026-01_anthem_rates.json.gz", inline},
+	} {
+		if err := sink.AddRate(ctx, add.file, add.price); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := pg.rows("negotiated_rates_staging")
+	if len(rows) != 3 {
+		t.Fatalf("%d rows copied, want 3", len(rows))
+	}
+	if rows[0]["id"] != rows[1]["id"] || rows[0]["id"] == rows[2]["id"] {
+		t.Errorf("ids %v %v %v, want one per price of a file", rows[0]["id"], rows[1]["id"], rows[2]["id"])
+	}
+	if rows[0]["file"] != "2026-01_anthem_rates.json.gz" || rows[0]["negotiated_rate"] != 110.5 || rows[0]["billing_class"] != nil {
+		t.Errorf("row %v", rows[0])
+	}
+	if fmt.Sprint(rows[0]["provider_references"]) != "[1 2]" || rows[0]["provider_groups"] != nil {
+		t.Errorf("referenced row with references %v and groups %v", rows[0]["provider_references"], rows[0]["provider_groups"])
+	}
+	if rows[2]["provider_references"] != nil || !strings.Contains(fmt.Sprint(rows[2]["provider_groups"]), "12-3456789") {
+		t.Errorf("inline row with references %v and groups %v", rows[2]["provider_references"], rows[2]["provider_groups"])
+	}
+}
+
+func TestSinkErrors(t *testing.T) {
+	ctx := context.Background()
+	pg := fakePostgres{fail: "\nCREATE TABLE"}
+	if _, err := postgres.Open(ctx, pg.start(t), postgres.Options{}); err == nil || !strings.Contains(err.Error(), "create postgres tables") {
+		t.Errorf("open with a failing schema returned %v", err)
+	}
+
+	for _, fail := range []string{"copy", "INSERT"} {
+		t.Run(fail, func(t *testing.T) {
+			pg := fakePostgres{fail: fail}
+			sink, err := postgres.Open(ctx, pg.start(t), postgres.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink.AddMatch(ctx, postgres.Match{Payer: "anthem", Location: "a.json"})
+			if err := sink.Flush(ctx); err == nil {
+				t.Fatal("flush succeeded")
+			}
+			if statements := pg.run(); statements[len(statements)-1] != "rollback" {
+				t.Errorf("failed batch ran %q, want it rolled back", statements)
+			}
+			// the failed batch is dropped
+			if err := sink.Close(ctx); err != nil {
+				t.Errorf("close after a failed batch returned %v", err)
+			}
+		})
+	}
+
+	if _, err := postgres.Open(ctx, "postgres://loader@127.0.0.1:1/warehouse?sslmode=disable&connect_timeout=1", postgres.Options{}); err == nil {
+		t.Error("connected to a closed port")
+	}
+}
@@ -0,0 +1,98 @@
+package rates
+
+// ConsumerPrice is a simplified, member facing view of one negotiated price:
+// what service it is for, in which setting, how much, and which providers
+// charge it. It flattens the in_network element, its negotiated_rates and
+// negotiated_prices, and resolves provider_references into the providers.
+type ConsumerPrice struct {
+	BillingCodeType string `json:"billingCodeType"`
+	BillingCode     string `json:"billingCode"`
+	// Service is the plain name of the item or service, Description the
+	// payer's longer text.
+	Service     string `json:"service"`
+	Description string `json:"description,omitempty"`
+	// Arrangement is ffs, bundle or capitation.
+	Arrangement string `json:"arrangement"`
+	// Setting is professional or institutional.
+	Setting string `json:"setting"`
+	// PriceType is negotiated, derived, fee schedule, percentage or per diem,
+	// a percentage Price is not an amount in dollars.
+	PriceType      string   `json:"priceType"`
+	Price          float64  `json:"price"`
+	ExpirationDate string   `json:"expirationDate"`
+	PlacesOfCare   []string `json:"placesOfCare,omitempty"`
+	Modifiers      []string `json:"modifiers,omitempty"`
+	Notes          string   `json:"notes,omitempty"`
+
+	Providers []ConsumerProvider `json:"providers"`
+	// ProviderFiles are remote provider reference files the providers of
+	// this price are published in instead.
+	ProviderFiles []string `json:"providerFiles,omitempty"`
+}
+
+// ConsumerProvider is a provider group charging a price.
+type ConsumerProvider struct {
+	TIN     string   `json:"tin"`
+	TINType string   `json:"tinType"`
+	NPIs    []string `json:"npis"`
+}
+
+// ConsumerPrices flattens rate into one ConsumerPrice per negotiated price.
+// Provider references are resolved against the last parsed file, so call it
+// once the file has been parsed completely.
+func (p *Parser) ConsumerPrices(rate Rate) []ConsumerPrice {
+	var prices []ConsumerPrice
+	for _, negotiatedRate := range rate.NegotiatedRates {
+		providers, files := p.resolveProviders(negotiatedRate)
+
+		for _, price := range negotiatedRate.NegotiatedPrices {
+			prices = append(prices, ConsumerPrice{
+				BillingCodeType: rate.BillingCodeType,
+				BillingCode:     rate.BillingCode,
+				Service:         rate.Name,
+				Description:     rate.Description,
+				Arrangement:     rate.NegotiationArrangement,
+				Setting:         price.BillingClass,
+				PriceType:       price.NegotiatedType,
+				Price:           price.NegotiatedRate,
+				ExpirationDate:  price.ExpirationDate,
+				PlacesOfCare:    price.ServiceCode,
+				Modifiers:       price.BillingCodeModifier,
+				Notes:           price.AdditionalInformation,
+				Providers:       providers,
+				ProviderFiles:   files,
+			})
+		}
+	}
+	return prices
+}
+
+func (p *Parser) resolveProviders(negotiatedRate NegotiatedRate) ([]ConsumerProvider, []string) {
+	providers := make([]ConsumerProvider, 0, len(negotiatedRate.ProviderGroups))
+	var files []string
+
+	groups := append([]ProviderGroup(nil), negotiatedRate.ProviderGroups...)
+	for _, id := range negotiatedRate.ProviderReferences {
+		ref, exists := p.providerReferences[id.String()]
+		if !exists {
+			continue
+		}
+		if ref.Location != "" {
+			files = append(files, ref.Location)
+		}
+		groups = append(groups, ref.ProviderGroups...)
+	}
+
+	for _, group := range groups {
+		provider := ConsumerProvider{
+			TIN:     group.TIN.Value,
+			TINType: group.TIN.Type,
+			NPIs:    make([]string, len(group.NPI)),
+		}
+		for i, npi := range group.NPI {
+			provider.NPIs[i] = string(npi)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, files
+}
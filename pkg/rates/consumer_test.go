@@ -0,0 +1,125 @@
+package rates_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+func TestConsumerPrices(t *testing.T) {
+	var parsed []rates.Rate
+	p := rates.New(rates.Options{OnRate: func(r rates.Rate) { parsed = append(parsed, r) }})
+	if err := p.Parse(strings.NewReader(rateFile("https://example.com/providers/2.json"))); err != nil {
+		t.Fatal(err)
+	}
+	// references are resolved once the whole file is parsed
+	var prices []rates.ConsumerPrice
+	for _, rate := range parsed {
+		prices = append(prices, p.ConsumerPrices(rate)...)
+	}
+
+	got, _ := json.MarshalIndent(prices[:3], "", "  ")
+	want := `[
+  {
+    "billingCodeType": "CPT",
+    "billingCode": "99213",
+    "service": "Office visit",
+    "description": "Established patient office visit",
+    "arrangement": "ffs",
+    "setting": "professional",
+    "priceType": "negotiated",
+    "price": 110.5,
+    "expirationDate": "9999-12-31",
+    "placesOfCare": [
+      "11"
+    ],
+    "providers": [
+      {
+        "tin": "98-7654321",
+        "tinType": "ein",
+        "npis": [
+          "1487654321",
+          "1234567893"
+        ]
+      }
+    ],
+    "providerFiles": [
+      "https://example.com/providers/2.json"
+    ]
+  },
+  {
+    "billingCodeType": "CPT",
+    "billingCode": "99213",
+    "service": "Office visit",
+    "description": "Established patient office visit",
+    "arrangement": "ffs",
+    "setting": "professional",
+    "priceType": "fee schedule",
+    "price": 95,
+    "expirationDate": "9999-12-31",
+    "placesOfCare": [
+      "11",
+      "22"
+    ],
+    "modifiers": [
+      "25"
+    ],
+    "providers": [
+      {
+        "tin": "12-3456789",
+        "tinType": "ein",
+        "npis": [
+          "1306849450"
+        ]
+      }
+    ]
+  },
+  {
+    "billingCodeType": "CPT",
+    "billingCode": "99213",
+    "service": "Office visit",
+    "description": "Established patient office visit",
+    "arrangement": "ffs",
+    "setting": "institutional",
+    "priceType": "negotiated",
+    "price": 140,
+    "expirationDate": "9999-12-31",
+    "notes": "hospital outpatient",
+    "providers": [
+      {
+        "tin": "12-3456789",
+        "tinType": "ein",
+        "npis": [
+          "1306849450"
+        ]
+      }
+    ]
+  }
+]`
+	if string(got) != want {
+		t.Errorf("office visit prices\n%s\nwant\n%s", got, want)
+	}
+	if len(prices) != 4 || prices[3].BillingCode != "788" || prices[3].Providers[0].TINType != "npi" {
+		t.Errorf("case rate prices %+v", prices[3:])
+	}
+}
+
+func TestFlatPrices(t *testing.T) {
+	var parsed []rates.Rate
+	p := rates.New(rates.Options{Codes: []string{"99213"}, OnRate: func(r rates.Rate) { parsed = append(parsed, r) }})
+	if err := p.Parse(strings.NewReader(rateFile(""))); err != nil {
+		t.Fatal(err)
+	}
+	prices := rates.FlatPrices(parsed[0])
+	got, _ := json.Marshal(prices)
+	want := `[` +
+		`{"billing_code":"99213","billing_code_type":"CPT","negotiated_rate":110.5,"negotiated_type":"negotiated","billing_class":"professional","expiration_date":"9999-12-31","provider_references":[1,2]},` +
+		`{"billing_code":"99213","billing_code_type":"CPT","negotiated_rate":95,"negotiated_type":"fee schedule","billing_class":"professional","expiration_date":"9999-12-31","provider_groups":[{"npi":["1306849450"],"tin":{"type":"ein","value":"12-3456789"}}]},` +
+		`{"billing_code":"99213","billing_code_type":"CPT","negotiated_rate":140,"negotiated_type":"negotiated","billing_class":"institutional","expiration_date":"9999-12-31","provider_groups":[{"npi":["1306849450"],"tin":{"type":"ein","value":"12-3456789"}}]}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("flat prices\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package rates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Drug is an element of the prescription_drugs array of a prescription drug
+// file. The drug file schema was never finalised, so the element is kept as
+// published and only the name and national drug codes are read from it.
+type Drug struct {
+	Name string `json:"drugName"`
+	// NDCs are the national drug codes of the drug, without hyphens.
+	NDCs   []string        `json:"ndcs"`
+	Record json.RawMessage `json:"record"`
+}
+
+// ndcKeys are the field names payers use for the national drug codes.
+var ndcKeys = []string{"ndc", "ndcs", "national_drug_code", "national_drug_codes"}
+
+// normalizeNDC drops the hyphens, the same code is published with and
+// without them.
+func normalizeNDC(ndc string) string {
+	return strings.ReplaceAll(strings.TrimSpace(ndc), "-", "")
+}
+
+func parseDrug(record json.RawMessage) (Drug, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return Drug{}, err
+	}
+
+	drug := Drug{Record: record}
+	if name, ok := fields["drug_name"]; ok {
+		json.Unmarshal(name, &drug.Name)
+	}
+	for _, key := range ndcKeys {
+		value, ok := fields[key]
+		if !ok {
+			continue
+		}
+		// like npis, codes are published as strings or numbers
+		var ndcs []NPI
+		if err := json.Unmarshal(value, &ndcs); err != nil {
+			var ndc NPI
+			if err := json.Unmarshal(value, &ndc); err != nil {
+				return Drug{}, fmt.Errorf("%s: %w", key, err)
+			}
+			ndcs = []NPI{ndc}
+		}
+		for _, ndc := range ndcs {
+			drug.NDCs = append(drug.NDCs, normalizeNDC(string(ndc)))
+		}
+	}
+	return drug, nil
+}
+
+func (p *Parser) parsePrescriptionDrugs(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read prescription_drugs value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("prescription_drugs is not an array")
+	}
+
+	for dec.More() {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("decode prescription_drugs element: %w", err)
+		}
+		drug, err := parseDrug(record)
+		if err != nil {
+			return fmt.Errorf("decode prescription_drugs element: %w", err)
+		}
+
+		if p.codes != nil && !p.wantsDrug(drug) {
+			continue
+		}
+		p.drugCount++
+		p.onDrug(drug)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close prescription_drugs array: %w", err)
+	}
+
+	return nil
+}
+
+// wantsDrug reports whether one of the drug's codes is among the -codes.
+func (p *Parser) wantsDrug(drug Drug) bool {
+	for _, ndc := range drug.NDCs {
+		if _, wanted := p.codes[ndc]; wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// DrugCount returns how many prescription_drugs elements were extracted.
+func (p *Parser) DrugCount() int {
+	return p.drugCount
+}
@@ -0,0 +1,63 @@
+package rates_test
+
+import (
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+const drugFile = `{
+  "reporting_entity_name": "Anthem",
+  "last_updated_on": "2026-01-05",
+  "prescription_drugs": [
+    {"drug_name": "Atorvastatin 20mg", "ndc": "0071-0156-23", "price": 4.12},
+    {"drug_name": "Metformin 500mg", "ndcs": ["00093-1048-01", 68180033701], "price": 2.05},
+    {"drug_name": "Insulin glargine", "national_drug_code": 88222033, "price": 310}
+  ]
+}`
+
+func TestDrugs(t *testing.T) {
+	var drugs []rates.Drug
+	p := rates.New(rates.Options{OnDrug: func(d rates.Drug) { drugs = append(drugs, d) }})
+	if err := p.Parse(strings.NewReader(drugFile)); err != nil {
+		t.Fatal(err)
+	}
+	if p.DrugCount() != 3 || p.RateCount() != 0 {
+		t.Fatalf("%d drugs and %d rates, want 3 drugs", p.DrugCount(), p.RateCount())
+	}
+
+	// codes are kept without hyphens whether published as strings or numbers
+	want := map[string]string{
+		"Atorvastatin 20mg": "0071015623",
+		"Metformin 500mg":   "00093104801,68180033701",
+		"Insulin glargine":  "88222033",
+	}
+	for _, drug := range drugs {
+		if got := strings.Join(drug.NDCs, ","); got != want[drug.Name] {
+			t.Errorf("%s has codes %s, want %s", drug.Name, got, want[drug.Name])
+		}
+		if !strings.Contains(string(drug.Record), `"price"`) {
+			t.Errorf("%s record %s not kept as published", drug.Name, drug.Record)
+		}
+	}
+}
+
+func TestDrugCodes(t *testing.T) {
+	var names []string
+	p := rates.New(rates.Options{
+		// a hyphenated code matches the same code published without them
+		Codes:  []string{"00093-1048-01", "99213"},
+		OnDrug: func(d rates.Drug) { names = append(names, d.Name) },
+	})
+	if err := p.Parse(strings.NewReader(drugFile)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "Metformin 500mg" {
+		t.Errorf("extracted %v, want the drug with the code", names)
+	}
+
+	if err := rates.New(rates.Options{}).Parse(strings.NewReader(`{"prescription_drugs": [{"ndc": {"code": 1}}]}`)); err == nil {
+		t.Error("parsed a drug with an object for its code")
+	}
+}
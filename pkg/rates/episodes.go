@@ -0,0 +1,132 @@
+package rates
+
+import (
+	"sort"
+	"strings"
+)
+
+// Episode is the price of a whole episode of care, e.g. a C-section case
+// rate, summarised over every provider that negotiated it. Line item prices
+// and different arrangements, settings or price types are never averaged
+// together, a percentage of billed charges is no dollar amount.
+type Episode struct {
+	BillingCodeType string `json:"billingCodeType"`
+	BillingCode     string `json:"billingCode"`
+	Service         string `json:"service"`
+	Arrangement     string `json:"arrangement"`
+	Setting         string `json:"setting"`
+	PriceType       string `json:"priceType"`
+	// Components are the bundled codes the episode price covers, as
+	// type:code.
+	Components []string `json:"components,omitempty"`
+
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+
+	prices []float64
+}
+
+// IsEpisode reports whether rate prices an episode rather than a line item,
+// a bundle arrangement or a DRG case rate.
+func IsEpisode(rate Rate) bool {
+	return rate.NegotiationArrangement == "bundle" ||
+		strings.HasSuffix(strings.ToUpper(rate.BillingCodeType), "DRG")
+}
+
+// Episodes aggregates the episode rates of any number of files.
+type Episodes struct {
+	episodes  map[string]*Episode
+	lineItems int
+}
+
+func NewEpisodes() *Episodes {
+	return &Episodes{episodes: make(map[string]*Episode)}
+}
+
+// Add records the prices of rate when it is an episode and counts it as a
+// skipped line item otherwise.
+func (a *Episodes) Add(rate Rate) {
+	if !IsEpisode(rate) {
+		a.lineItems++
+		return
+	}
+
+	var components []string
+	for _, bundled := range rate.BundledCodes {
+		components = append(components, bundled.BillingCodeType+":"+bundled.BillingCode)
+	}
+	sort.Strings(components)
+
+	for _, negotiatedRate := range rate.NegotiatedRates {
+		for _, price := range negotiatedRate.NegotiatedPrices {
+			key := strings.Join([]string{rate.BillingCodeType, rate.BillingCode, rate.NegotiationArrangement,
+				price.BillingClass, price.NegotiatedType, strings.Join(components, ",")}, "\x00")
+			episode, exists := a.episodes[key]
+			if !exists {
+				episode = &Episode{
+					BillingCodeType: rate.BillingCodeType,
+					BillingCode:     rate.BillingCode,
+					Service:         rate.Name,
+					Arrangement:     rate.NegotiationArrangement,
+					Setting:         price.BillingClass,
+					PriceType:       price.NegotiatedType,
+					Components:      components,
+				}
+				a.episodes[key] = episode
+			}
+			episode.prices = append(episode.prices, price.NegotiatedRate)
+		}
+	}
+}
+
+// LineItems returns how many rates were skipped as line items.
+func (a *Episodes) LineItems() int {
+	return a.lineItems
+}
+
+// Episodes returns the summarised episodes ordered by code, arrangement,
+// setting and price type.
+func (a *Episodes) Episodes() []Episode {
+	result := make([]Episode, 0, len(a.episodes))
+	for _, episode := range a.episodes {
+		prices := append([]float64(nil), episode.prices...)
+		sort.Float64s(prices)
+
+		summary := *episode
+		summary.prices = nil
+		summary.Count = len(prices)
+		summary.Min = prices[0]
+		summary.Max = prices[len(prices)-1]
+		summary.Median = prices[len(prices)/2]
+		if len(prices)%2 == 0 {
+			summary.Median = (prices[len(prices)/2-1] + prices[len(prices)/2]) / 2
+		}
+		var sum float64
+		for _, price := range prices {
+			sum += price
+		}
+		summary.Mean = sum / float64(len(prices))
+		result = append(result, summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		for _, pair := range [][2]string{
+			{a.BillingCodeType, b.BillingCodeType},
+			{a.BillingCode, b.BillingCode},
+			{a.Arrangement, b.Arrangement},
+			{a.Setting, b.Setting},
+			{a.PriceType, b.PriceType},
+			{strings.Join(a.Components, ","), strings.Join(b.Components, ",")},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return false
+	})
+	return result
+}
@@ -0,0 +1,79 @@
+package rates_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+// caseRate is a C-section DRG case rate of one hospital.
+func caseRate(arrangement string, prices ...rates.NegotiatedPrice) rates.Rate {
+	return rates.Rate{
+		NegotiationArrangement: arrangement,
+		Name:                   "Cesarean delivery",
+		BillingCodeType:        "MS-DRG",
+		BillingCode:            "788",
+		BundledCodes: []rates.BundledCode{
+			{BillingCodeType: "CPT", BillingCode: "59510"},
+			{BillingCodeType: "CPT", BillingCode: "01961"},
+		},
+		NegotiatedRates: []rates.NegotiatedRate{{NegotiatedPrices: prices}},
+	}
+}
+
+func price(typ string, amount float64) rates.NegotiatedPrice {
+	return rates.NegotiatedPrice{NegotiatedType: typ, NegotiatedRate: amount, BillingClass: "institutional"}
+}
+
+func TestEpisodes(t *testing.T) {
+	episodes := rates.NewEpisodes()
+	episodes.Add(caseRate("ffs", price("negotiated", 12000), price("percentage", 60)))
+	episodes.Add(caseRate("ffs", price("negotiated", 9000)))
+	episodes.Add(caseRate("ffs", price("negotiated", 15000), price("negotiated", 10000)))
+	episodes.Add(rates.Rate{NegotiationArrangement: "ffs", BillingCodeType: "CPT", BillingCode: "99213"})
+
+	if episodes.LineItems() != 1 {
+		t.Errorf("%d line items skipped, want the office visit", episodes.LineItems())
+	}
+	got, _ := json.Marshal(episodes.Episodes())
+	// a percentage of billed charges is never averaged with dollar amounts
+	want := `[` +
+		`{"billingCodeType":"MS-DRG","billingCode":"788","service":"Cesarean delivery","arrangement":"ffs","setting":"institutional","priceType":"negotiated","components":["CPT:01961","CPT:59510"],"count":4,"min":9000,"median":11000,"mean":11500,"max":15000},` +
+		`{"billingCodeType":"MS-DRG","billingCode":"788","service":"Cesarean delivery","arrangement":"ffs","setting":"institutional","priceType":"percentage","components":["CPT:01961","CPT:59510"],"count":1,"min":60,"median":60,"mean":60,"max":60}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("episodes\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIsEpisode(t *testing.T) {
+	for _, tt := range []struct {
+		arrangement, codeType string
+		want                  bool
+	}{
+		{"bundle", "CPT", true},
+		{"ffs", "MS-DRG", true},
+		{"ffs", "APR-DRG", true},
+		{"ffs", "CPT", false},
+		{"capitation", "HCPCS", false},
+	} {
+		rate := rates.Rate{NegotiationArrangement: tt.arrangement, BillingCodeType: tt.codeType}
+		if got := rates.IsEpisode(rate); got != tt.want {
+			t.Errorf("IsEpisode(%s %s) = %v", tt.arrangement, tt.codeType, got)
+		}
+	}
+}
+
+func TestEpisodesOfFile(t *testing.T) {
+	episodes := rates.NewEpisodes()
+	p := rates.New(rates.Options{OnRate: episodes.Add})
+	if err := p.Parse(strings.NewReader(rateFile(""))); err != nil {
+		t.Fatal(err)
+	}
+	list := episodes.Episodes()
+	if len(list) != 1 || list[0].Arrangement != "bundle" || list[0].Count != 1 || list[0].Median != 12000 {
+		t.Errorf("episodes %+v, want the bundled case rate", list)
+	}
+}
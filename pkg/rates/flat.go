@@ -0,0 +1,38 @@
+package rates
+
+import "encoding/json"
+
+// FlatPrice is one negotiated price of an in_network element with the CMS
+// field names, for billing code lookups that want one row per price. The
+// provider references are the ids as published, resolve them with
+// ProviderReferences.
+type FlatPrice struct {
+	BillingCode        string          `json:"billing_code"`
+	BillingCodeType    string          `json:"billing_code_type"`
+	NegotiatedRate     float64         `json:"negotiated_rate"`
+	NegotiatedType     string          `json:"negotiated_type"`
+	BillingClass       string          `json:"billing_class"`
+	ExpirationDate     string          `json:"expiration_date"`
+	ProviderReferences []json.Number   `json:"provider_references,omitempty"`
+	ProviderGroups     []ProviderGroup `json:"provider_groups,omitempty"`
+}
+
+// FlatPrices returns one FlatPrice per negotiated price of rate.
+func FlatPrices(rate Rate) []FlatPrice {
+	var prices []FlatPrice
+	for _, negotiatedRate := range rate.NegotiatedRates {
+		for _, price := range negotiatedRate.NegotiatedPrices {
+			prices = append(prices, FlatPrice{
+				BillingCode:        rate.BillingCode,
+				BillingCodeType:    rate.BillingCodeType,
+				NegotiatedRate:     price.NegotiatedRate,
+				NegotiatedType:     price.NegotiatedType,
+				BillingClass:       price.BillingClass,
+				ExpirationDate:     price.ExpirationDate,
+				ProviderReferences: negotiatedRate.ProviderReferences,
+				ProviderGroups:     negotiatedRate.ProviderGroups,
+			})
+		}
+	}
+	return prices
+}
@@ -0,0 +1,128 @@
+package rates
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PriceSpan is one distinct (provider, code, price, arrangement) tuple and
+// the months it was published in, across shards and monthly files.
+type PriceSpan struct {
+	BillingCodeType string           `json:"billingCodeType"`
+	BillingCode     string           `json:"billingCode"`
+	Arrangement     string           `json:"arrangement"`
+	Setting         string           `json:"setting"`
+	PriceType       string           `json:"priceType"`
+	Price           float64          `json:"price"`
+	Provider        ConsumerProvider `json:"provider"`
+	// From and To are the first and last month as YYYY-MM, Months every
+	// month in between that published the price.
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Months []string `json:"months"`
+}
+
+// Longitudinal deduplicates the prices of many rate files into spans.
+type Longitudinal struct {
+	spans  map[string]*PriceSpan
+	months map[string]map[string]struct{}
+	prices int
+}
+
+func NewLongitudinal() *Longitudinal {
+	return &Longitudinal{
+		spans:  make(map[string]*PriceSpan),
+		months: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add records the prices of one file published in month. Prices whose
+// providers are only published in remote reference files are kept under an
+// empty provider.
+func (l *Longitudinal) Add(month string, prices []ConsumerPrice) {
+	for _, price := range prices {
+		providers := price.Providers
+		if len(providers) == 0 {
+			providers = []ConsumerProvider{{NPIs: []string{}}}
+		}
+		for _, provider := range providers {
+			l.prices++
+			npis := append([]string{}, provider.NPIs...)
+			sort.Strings(npis)
+			provider.NPIs = npis
+
+			key := strings.Join([]string{price.BillingCodeType, price.BillingCode, price.Arrangement,
+				price.Setting, price.PriceType, strconv.FormatFloat(price.Price, 'f', -1, 64),
+				provider.TINType, provider.TIN, strings.Join(npis, ",")}, "\x00")
+			if _, exists := l.spans[key]; !exists {
+				l.spans[key] = &PriceSpan{
+					BillingCodeType: price.BillingCodeType,
+					BillingCode:     price.BillingCode,
+					Arrangement:     price.Arrangement,
+					Setting:         price.Setting,
+					PriceType:       price.PriceType,
+					Price:           price.Price,
+					Provider:        provider,
+				}
+				l.months[key] = make(map[string]struct{})
+			}
+			l.months[key][month] = struct{}{}
+		}
+	}
+}
+
+// Prices returns how many provider prices were added, before deduplication.
+func (l *Longitudinal) Prices() int {
+	return l.prices
+}
+
+// Spans returns the distinct prices ordered by code, provider and first
+// month.
+func (l *Longitudinal) Spans() []PriceSpan {
+	result := make([]PriceSpan, 0, len(l.spans))
+	for key, span := range l.spans {
+		months := make([]string, 0, len(l.months[key]))
+		for month := range l.months[key] {
+			months = append(months, month)
+		}
+		sort.Strings(months)
+
+		s := *span
+		s.Months = months
+		s.From = months[0]
+		s.To = months[len(months)-1]
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.BillingCode != b.BillingCode {
+			return a.BillingCode < b.BillingCode
+		}
+		if a.Provider.TIN != b.Provider.TIN {
+			return a.Provider.TIN < b.Provider.TIN
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.Price < b.Price
+	})
+	return result
+}
+
+var monthPattern = regexp.MustCompile(`^(\d{4})-(\d{2})`)
+
+// FileMonth is the YYYY-MM a rate file was published in, from its
+// last_updated_on or else the date prefix of its name, empty when neither
+// has one.
+func FileMonth(filename string, lastUpdatedOn string) string {
+	for _, s := range []string{lastUpdatedOn, filepath.Base(filename)} {
+		if m := monthPattern.FindStringSubmatch(s); m != nil {
+			return m[1] + "-" + m[2]
+		}
+	}
+	return ""
+}
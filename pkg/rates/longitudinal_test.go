@@ -0,0 +1,61 @@
+package rates_test
+
+import (
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+func TestLongitudinal(t *testing.T) {
+	visit := func(amount float64, npis ...string) rates.ConsumerPrice {
+		return rates.ConsumerPrice{
+			BillingCodeType: "CPT",
+			BillingCode:     "99213",
+			Arrangement:     "ffs",
+			Setting:         "professional",
+			PriceType:       "negotiated",
+			Price:           amount,
+			Providers:       []rates.ConsumerProvider{{TIN: "98-7654321", TINType: "ein", NPIs: npis}},
+		}
+	}
+
+	l := rates.NewLongitudinal()
+	// two shards of january list the price, the npis in either order
+	l.Add("2026-01", []rates.ConsumerPrice{visit(110.5, "1487654321", "1234567893")})
+	l.Add("2026-01", []rates.ConsumerPrice{visit(110.5, "1234567893", "1487654321")})
+	l.Add("2026-03", []rates.ConsumerPrice{visit(110.5, "1487654321", "1234567893"), visit(115, "1487654321", "1234567893")})
+	// providers only published in a remote reference file
+	l.Add("2026-02", []rates.ConsumerPrice{{BillingCode: "99214", Price: 150}})
+
+	if l.Prices() != 5 {
+		t.Errorf("%d prices added, want 5", l.Prices())
+	}
+	spans := l.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("%d spans, want 3: %+v", len(spans), spans)
+	}
+	if s := spans[0]; s.Price != 110.5 || s.From != "2026-01" || s.To != "2026-03" || strings.Join(s.Months, ",") != "2026-01,2026-03" ||
+		strings.Join(s.Provider.NPIs, ",") != "1234567893,1487654321" {
+		t.Errorf("first span %+v, want 110.5 in january and march", s)
+	}
+	if s := spans[1]; s.Price != 115 || s.From != "2026-03" || s.To != "2026-03" {
+		t.Errorf("second span %+v, want the new price of march", s)
+	}
+	if s := spans[2]; s.BillingCode != "99214" || s.Provider.TIN != "" || s.Provider.NPIs == nil {
+		t.Errorf("span without providers %+v", s)
+	}
+}
+
+func TestFileMonth(t *testing.T) {
+	for _, tt := range []struct{ filename, lastUpdatedOn, want string }{
+		{"2026-01-01_anthem_in-network-rates.json.gz", "2026-02-15", "2026-02"},
+		{"/data/2025-12_anthem_rates_part01.json.gz", "", "2025-12"},
+		{"https://cdn.example.com/2026-03-01_rates.json.gz", "", "2026-03"},
+		{"anthem_rates.json.gz", "", ""},
+	} {
+		if got := rates.FileMonth(tt.filename, tt.lastUpdatedOn); got != tt.want {
+			t.Errorf("FileMonth(%q, %q) = %q, want %q", tt.filename, tt.lastUpdatedOn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package rates
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"serif_interview/pkg/download"
+)
+
+// providerFile is the result of reading a remote provider reference file,
+// kept for the files of later parses that point to the same location.
+type providerFile struct {
+	groups []ProviderGroup
+	err    error
+}
+
+// openFile opens a local file or url, gunzipping it when its name or url path
+// ends in .gz.
+func (p *Parser) openFile(location string) (io.ReadCloser, error) {
+	var stream io.ReadCloser
+	name := location
+	if download.IsURL(location) {
+		s, err := download.New(download.Options{Limits: p.limits}).Stream(context.Background(), location)
+		if err != nil {
+			return nil, fmt.Errorf("open url stream: %s - %w", location, err)
+		}
+		stream = s
+		if u, err := url.Parse(location); err == nil {
+			name = u.Path
+		}
+	} else {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("open file stream: %s - %w", location, err)
+		}
+		stream = f
+	}
+
+	if !strings.HasSuffix(strings.ToLower(name), ".gz") {
+		return readCloser{Reader: p.limits.Reader(stream, nil), closers: []io.Closer{stream}}, nil
+	}
+	compressed := &download.CountingReader{R: stream}
+	gr, err := gzip.NewReader(compressed)
+	if err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("open gzip stream: %w", err)
+	}
+	return readCloser{Reader: p.limits.Reader(gr, compressed), closers: []io.Closer{gr, stream}}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// resolveRemote fills in the provider groups of the references with the ids
+// of rates that are published in remote provider reference files.
+func (p *Parser) resolveRemote(rates []Rate) {
+	ids := make(map[string]struct{}, len(p.referenced))
+	for id := range p.referenced {
+		ids[id] = struct{}{}
+	}
+	for _, rate := range rates {
+		for _, negotiatedRate := range rate.NegotiatedRates {
+			for _, id := range negotiatedRate.ProviderReferences {
+				ids[id.String()] = struct{}{}
+			}
+		}
+	}
+
+	for id := range ids {
+		ref, exists := p.providerReferences[id]
+		if !exists || ref.Location == "" || len(ref.ProviderGroups) > 0 {
+			continue
+		}
+		file, read := p.providerFiles[ref.Location]
+		if !read {
+			file.groups, file.err = p.readProviderFile(ref.Location)
+			p.providerFiles[ref.Location] = file
+			if file.err != nil {
+				p.onUnresolved(ref.Location, file.err)
+			}
+		}
+		ref.ProviderGroups = file.groups
+		p.providerReferences[id] = ref
+	}
+}
+
+func (p *Parser) readProviderFile(location string) ([]ProviderGroup, error) {
+	r, err := p.openFile(location)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var file struct {
+		ProviderGroups []ProviderGroup `json:"provider_groups"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode provider reference file: %w", err)
+	}
+	return file.ProviderGroups, nil
+}
+
+// keepProviders drops the negotiated rates of rate whose provider groups,
+// inline or referenced, have none of the wanted NPIs or TINs, and reports
+// whether any are left.
+func (p *Parser) keepProviders(rate Rate) (Rate, bool) {
+	var kept []NegotiatedRate
+	for _, negotiatedRate := range rate.NegotiatedRates {
+		groups := negotiatedRate.ProviderGroups
+		for _, id := range negotiatedRate.ProviderReferences {
+			groups = append(groups[:len(groups):len(groups)], p.providerReferences[id.String()].ProviderGroups...)
+		}
+		if p.wantsProvider(groups) {
+			kept = append(kept, negotiatedRate)
+		}
+	}
+	rate.NegotiatedRates = kept
+	return rate, len(kept) > 0
+}
+
+func (p *Parser) wantsProvider(groups []ProviderGroup) bool {
+	for _, group := range groups {
+		if _, ok := p.providers[digits(group.TIN.Value)]; ok {
+			return true
+		}
+		for _, npi := range group.NPI {
+			if _, ok := p.providers[digits(string(npi))]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// digits drops everything but the digits, TINs are published with and
+// without their hyphen.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, s)
+}
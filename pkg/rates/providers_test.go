@@ -0,0 +1,112 @@
+package rates_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+// providerServer serves the remote provider reference file of rateFile's
+// reference 2 at /providers/2.json, counting the requests.
+func providerServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/providers/2.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"provider_groups": [{"npi": [1770000001], "tin": {"type": "ein", "value": "55-5555555"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolveRemote(t *testing.T) {
+	var requests atomic.Int32
+	server := providerServer(t, &requests)
+	var parsed []rates.Rate
+	p := rates.New(rates.Options{ResolveRemote: true, OnRate: func(r rates.Rate) { parsed = append(parsed, r) }})
+	// the reference file is read once for every file pointing to it
+	for range 2 {
+		parsed = nil
+		if err := p.Parse(strings.NewReader(rateFile(server.URL + "/providers/2.json"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("%d requests for the provider reference file, want 1", requests.Load())
+	}
+
+	providers := p.ConsumerPrices(parsed[0])[0].Providers
+	if len(providers) != 2 || providers[1].TIN != "55-5555555" || providers[1].NPIs[0] != "1770000001" {
+		t.Errorf("providers %+v, want those of reference 1 and of the remote file", providers)
+	}
+}
+
+func TestProviderFilter(t *testing.T) {
+	var requests atomic.Int32
+	server := providerServer(t, &requests)
+	file := rateFile(server.URL + "/providers/2.json")
+
+	for _, tt := range []struct {
+		name      string
+		providers []string
+		// want are the billing codes and prices kept
+		want string
+	}{
+		{"tin without hyphen", []string{"123456789"}, "99213 95,140"},
+		{"tin of a referenced group", []string{"98-7654321"}, "99213 110.5"},
+		{"npi in a remote file", []string{"1770000001"}, "99213 110.5"},
+		{"npi of the case rate", []string{"1999999984"}, "788 12000"},
+		{"unused reference", []string{"1111111111"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var kept []string
+			p := rates.New(rates.Options{
+				Providers: tt.providers,
+				OnRate: func(r rates.Rate) {
+					var prices []string
+					for _, negotiatedRate := range r.NegotiatedRates {
+						for _, price := range negotiatedRate.NegotiatedPrices {
+							prices = append(prices, strconv.FormatFloat(price.NegotiatedRate, 'f', -1, 64))
+						}
+					}
+					kept = append(kept, r.BillingCode+" "+strings.Join(prices, ","))
+				},
+			})
+			if err := p.Parse(strings.NewReader(file)); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(kept, "; "); got != tt.want {
+				t.Errorf("kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnresolvedProviderFile(t *testing.T) {
+	var requests atomic.Int32
+	server := providerServer(t, &requests)
+	var unresolved []string
+	p := rates.New(rates.Options{
+		Providers:    []string{"98-7654321"},
+		OnUnresolved: func(location string, err error) { unresolved = append(unresolved, location) },
+	})
+	for range 2 {
+		if err := p.Parse(strings.NewReader(rateFile(server.URL + "/providers/missing.json"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the rates of the other groups are still kept
+	if p.RateCount() != 2 {
+		t.Errorf("%d rates kept, want the office visit of both files", p.RateCount())
+	}
+	if len(unresolved) != 1 || unresolved[0] != server.URL+"/providers/missing.json" {
+		t.Errorf("unresolved %v, want the missing file once", unresolved)
+	}
+}
@@ -0,0 +1,397 @@
+// Package rates streams CMS in-network rate files, the files the index
+// locations point to, and extracts the negotiated rates of selected billing
+// codes together with the provider references they use. Prescription drug
+// files are recognised by their prescription_drugs array.
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"serif_interview/pkg/download"
+)
+
+// NPI accepts provider numbers published either as JSON numbers or strings.
+type NPI string
+
+func (n *NPI) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*n = NPI(s)
+		return nil
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*n = NPI(num.String())
+	return nil
+}
+
+type TIN struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type ProviderGroup struct {
+	NPI []NPI `json:"npi"`
+	TIN TIN   `json:"tin"`
+}
+
+// ProviderReference is an element of the top level provider_references array.
+type ProviderReference struct {
+	ProviderGroupID json.Number     `json:"provider_group_id"`
+	ProviderGroups  []ProviderGroup `json:"provider_groups,omitempty"`
+	// Location points at a remote provider reference file instead of
+	// listing ProviderGroups inline.
+	Location string `json:"location,omitempty"`
+}
+
+type NegotiatedPrice struct {
+	NegotiatedType        string   `json:"negotiated_type"`
+	NegotiatedRate        float64  `json:"negotiated_rate"`
+	ExpirationDate        string   `json:"expiration_date"`
+	ServiceCode           []string `json:"service_code,omitempty"`
+	BillingClass          string   `json:"billing_class"`
+	BillingCodeModifier   []string `json:"billing_code_modifier,omitempty"`
+	AdditionalInformation string   `json:"additional_information,omitempty"`
+}
+
+type NegotiatedRate struct {
+	ProviderReferences []json.Number     `json:"provider_references,omitempty"`
+	ProviderGroups     []ProviderGroup   `json:"provider_groups,omitempty"`
+	NegotiatedPrices   []NegotiatedPrice `json:"negotiated_prices"`
+}
+
+// BundledCode is a service covered by a bundle arrangement's single price.
+type BundledCode struct {
+	BillingCodeType        string `json:"billing_code_type"`
+	BillingCodeTypeVersion string `json:"billing_code_type_version"`
+	BillingCode            string `json:"billing_code"`
+	Description            string `json:"description,omitempty"`
+}
+
+// Rate is an element of the in_network array.
+type Rate struct {
+	NegotiationArrangement string           `json:"negotiation_arrangement"`
+	Name                   string           `json:"name"`
+	BillingCodeType        string           `json:"billing_code_type"`
+	BillingCodeTypeVersion string           `json:"billing_code_type_version"`
+	BillingCode            string           `json:"billing_code"`
+	Description            string           `json:"description"`
+	NegotiatedRates        []NegotiatedRate `json:"negotiated_rates"`
+	BundledCodes           []BundledCode    `json:"bundled_codes,omitempty"`
+}
+
+// Options configures a Parser.
+type Options struct {
+	// Codes restricts extraction to these billing codes, or national drug
+	// codes in drug files, empty extracts all.
+	Codes []string
+	// OnRate receives matching in_network elements as they are parsed.
+	OnRate func(Rate)
+	// OnDrug receives matching prescription_drugs elements.
+	OnDrug func(Drug)
+	// Limits bound the decompressed size and expansion ratio of .gz files.
+	Limits download.Limits
+	// Providers keeps only the negotiated rates of provider groups with one
+	// of these NPIs or TINs, empty keeps all. The rates of a file are then
+	// passed to OnRate once it is parsed, provider_references may follow
+	// in_network.
+	Providers []string
+	// ResolveRemote reads the provider reference files that provider
+	// references point to by location, so their groups are resolved like
+	// inline ones. Providers implies it.
+	ResolveRemote bool
+	// OnUnresolved receives the remote provider reference files that could
+	// not be read, each location once.
+	OnUnresolved func(location string, err error)
+}
+
+// Parser holds the settings and accumulated provider references of one or
+// more rate file parses. It is not safe for concurrent use.
+type Parser struct {
+	codes  map[string]struct{}
+	onRate func(Rate)
+	onDrug func(Drug)
+	limits download.Limits
+
+	providers     map[string]struct{}
+	remote        bool
+	onUnresolved  func(string, error)
+	providerFiles map[string]providerFile
+	// held are the rates of the file waiting for the provider filter
+	held []Rate
+
+	providerReferences map[string]ProviderReference
+	referenced         map[string]struct{}
+	rateCount          int
+	drugCount          int
+	lastUpdatedOn      string
+}
+
+func New(opts Options) *Parser {
+	p := &Parser{
+		onRate:             opts.OnRate,
+		onDrug:             opts.OnDrug,
+		limits:             opts.Limits,
+		remote:             opts.ResolveRemote,
+		onUnresolved:       opts.OnUnresolved,
+		providerFiles:      make(map[string]providerFile),
+		providerReferences: make(map[string]ProviderReference),
+		referenced:         make(map[string]struct{}),
+	}
+	for _, provider := range opts.Providers {
+		if provider = digits(provider); provider != "" {
+			if p.providers == nil {
+				p.providers = make(map[string]struct{})
+			}
+			p.providers[provider] = struct{}{}
+		}
+	}
+	if p.providers != nil {
+		p.remote = true
+	}
+	if len(opts.Codes) > 0 {
+		p.codes = make(map[string]struct{})
+		for _, code := range opts.Codes {
+			p.codes[strings.ToUpper(strings.TrimSpace(code))] = struct{}{}
+			p.codes[normalizeNDC(code)] = struct{}{}
+		}
+	}
+	if p.onRate == nil {
+		p.onRate = func(Rate) {}
+	}
+	if p.onDrug == nil {
+		p.onDrug = func(Drug) {}
+	}
+	if p.onUnresolved == nil {
+		p.onUnresolved = func(string, error) {}
+	}
+	return p
+}
+
+// ParseFile parses a local or https rate file, gzip compressed when its name
+// ends in .gz.
+func (p *Parser) ParseFile(filename string) error {
+	return p.ParseFileContext(context.Background(), filename)
+}
+
+// ParseFileContext is ParseFile stopping with ctx's error once ctx is done.
+func (p *Parser) ParseFileContext(ctx context.Context, filename string) error {
+	r, err := p.openFile(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return p.Parse(&contextReader{ctx: ctx, r: r})
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// Parse parses an uncompressed rate file document from r. Provider
+// reference ids are local to a file, so those of earlier parses are dropped.
+func (p *Parser) Parse(r io.Reader) error {
+	p.providerReferences = make(map[string]ProviderReference)
+	p.referenced = make(map[string]struct{})
+	p.lastUpdatedOn = ""
+	p.held = nil
+
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read root token: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return errors.New("expected root object")
+	}
+
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read root key: %w", err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return errors.New("unexpected non-string key at root")
+		}
+
+		switch key {
+		case "in_network":
+			err = p.parseInNetwork(dec)
+		case "provider_references":
+			err = p.parseProviderReferences(dec)
+		case "prescription_drugs":
+			err = p.parsePrescriptionDrugs(dec)
+		case "last_updated_on":
+			if err := dec.Decode(&p.lastUpdatedOn); err != nil {
+				return fmt.Errorf("decode last_updated_on: %w", err)
+			}
+		default:
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return fmt.Errorf("skip field %q: %w", key, err)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close root object: %w", err)
+	}
+
+	if p.remote {
+		p.resolveRemote(p.held)
+	}
+	for _, rate := range p.held {
+		if rate, ok := p.keepProviders(rate); ok {
+			p.accept(rate)
+		}
+	}
+	p.held = nil
+
+	return nil
+}
+
+func (p *Parser) parseInNetwork(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read in_network value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("in_network is not an array")
+	}
+
+	for dec.More() {
+		var rate Rate
+		if p.codes == nil {
+			if err := dec.Decode(&rate); err != nil {
+				return fmt.Errorf("decode in_network element: %w", err)
+			}
+		} else if wanted, err := p.decodeWanted(dec, &rate); err != nil {
+			return err
+		} else if !wanted {
+			continue
+		}
+
+		if p.providers != nil {
+			p.held = append(p.held, rate)
+			continue
+		}
+		p.accept(rate)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close in_network array: %w", err)
+	}
+
+	return nil
+}
+
+// decodeWanted decodes the next in_network element into rate when its billing
+// code is one of the codes. The negotiated rates of the other codes, most of
+// a file, are only scanned past rather than decoded.
+func (p *Parser) decodeWanted(dec *json.Decoder, rate *Rate) (bool, error) {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return false, fmt.Errorf("decode in_network element: %w", err)
+	}
+	var code struct {
+		BillingCode string `json:"billing_code"`
+	}
+	if err := json.Unmarshal(raw, &code); err != nil {
+		return false, fmt.Errorf("decode in_network element: %w", err)
+	}
+	if _, wanted := p.codes[strings.ToUpper(code.BillingCode)]; !wanted {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, rate); err != nil {
+		return false, fmt.Errorf("decode in_network element: %w", err)
+	}
+	return true, nil
+}
+
+// accept passes an extracted rate on.
+func (p *Parser) accept(rate Rate) {
+	for _, negotiatedRate := range rate.NegotiatedRates {
+		for _, ref := range negotiatedRate.ProviderReferences {
+			p.referenced[ref.String()] = struct{}{}
+		}
+	}
+	p.rateCount++
+	p.onRate(rate)
+}
+
+func (p *Parser) parseProviderReferences(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read provider_references value: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("provider_references is not an array")
+	}
+
+	for dec.More() {
+		var ref ProviderReference
+		if err := dec.Decode(&ref); err != nil {
+			return fmt.Errorf("decode provider reference: %w", err)
+		}
+		p.providerReferences[ref.ProviderGroupID.String()] = ref
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close provider_references array: %w", err)
+	}
+
+	return nil
+}
+
+// ProviderReferences returns the provider references used by the rates
+// extracted from the last parsed file, ordered by id. The top level array may
+// come before or after in_network, so this is only complete once the whole
+// file has been parsed.
+func (p *Parser) ProviderReferences() []ProviderReference {
+	result := make([]ProviderReference, 0, len(p.referenced))
+	for id := range p.referenced {
+		if ref, exists := p.providerReferences[id]; exists {
+			result = append(result, ref)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProviderGroupID.String() < result[j].ProviderGroupID.String()
+	})
+	return result
+}
+
+// LastUpdatedOn returns the last_updated_on date of the last parsed file.
+func (p *Parser) LastUpdatedOn() string {
+	return p.lastUpdatedOn
+}
+
+// RateCount returns how many in_network elements were extracted.
+func (p *Parser) RateCount() int {
+	return p.rateCount
+}
@@ -0,0 +1,197 @@
+package rates_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+// rateFile is an in-network rate file with an office visit priced for
+// referenced and inline provider groups and a C-section case rate, the
+// provider references following in_network. Reference 2 points to the
+// provider reference file at providerURL.
+func rateFile(providerURL string) string {
+	return `{
+  "reporting_entity_name": "Anthem",
+  "reporting_entity_type": "health insurance issuer",
+  "last_updated_on": "2026-01-05",
+  "version": "1.3.1",
+  "in_network": [
+    {
+      "negotiation_arrangement": "ffs",
+      "name": "Office visit",
+      "billing_code_type": "CPT",
+      "billing_code_type_version": "2026",
+      "billing_code": "99213",
+      "description": "Established patient office visit",
+      "negotiated_rates": [
+        {
+          "provider_references": [1, 2],
+          "negotiated_prices": [
+            {"negotiated_type": "negotiated", "negotiated_rate": 110.5, "expiration_date": "9999-12-31", "service_code": ["11"], "billing_class": "professional"}
+          ]
+        },
+        {
+          "provider_groups": [{"npi": ["1306849450"], "tin": {"type": "ein", "value": "12-3456789"}}],
+          "negotiated_prices": [
+            {"negotiated_type": "fee schedule", "negotiated_rate": 95, "expiration_date": "9999-12-31", "service_code": ["11", "22"], "billing_class": "professional", "billing_code_modifier": ["25"]},
+            {"negotiated_type": "negotiated", "negotiated_rate": 140, "expiration_date": "9999-12-31", "billing_class": "institutional", "additional_information": "hospital outpatient"}
+          ]
+        }
+      ]
+    },
+    {
+      "negotiation_arrangement": "bundle",
+      "name": "Cesarean delivery",
+      "billing_code_type": "MS-DRG",
+      "billing_code_type_version": "2026",
+      "billing_code": "788",
+      "description": "Cesarean section without sterilization",
+      "bundled_codes": [
+        {"billing_code_type": "CPT", "billing_code_type_version": "2026", "billing_code": "59510"},
+        {"billing_code_type": "CPT", "billing_code_type_version": "2026", "billing_code": "01961"}
+      ],
+      "negotiated_rates": [
+        {
+          "provider_references": [3],
+          "negotiated_prices": [
+            {"negotiated_type": "negotiated", "negotiated_rate": 12000, "expiration_date": "9999-12-31", "billing_class": "institutional"}
+          ]
+        }
+      ]
+    }
+  ],
+  "provider_references": [
+    {"provider_group_id": 1, "provider_groups": [{"npi": [1487654321, "1234567893"], "tin": {"type": "ein", "value": "98-7654321"}}]},
+    {"provider_group_id": 2, "location": "` + providerURL + `"},
+    {"provider_group_id": 3, "provider_groups": [{"npi": [1999999984], "tin": {"type": "npi", "value": "1999999984"}}]},
+    {"provider_group_id": 4, "provider_groups": [{"npi": [1111111111], "tin": {"type": "ein", "value": "11-1111111"}}]}
+  ]
+}`
+}
+
+func TestParse(t *testing.T) {
+	var got []rates.Rate
+	p := rates.New(rates.Options{OnRate: func(r rates.Rate) { got = append(got, r) }})
+	if err := p.Parse(strings.NewReader(rateFile("https://example.com/providers/2.json"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].BillingCode != "99213" || got[1].BillingCode != "788" {
+		t.Fatalf("parsed %+v, want the office visit and the case rate", got)
+	}
+	if prices := got[0].NegotiatedRates[1].NegotiatedPrices; len(prices) != 2 || prices[0].NegotiatedRate != 95 || prices[0].BillingCodeModifier[0] != "25" {
+		t.Errorf("inline provider prices %+v", prices)
+	}
+	if len(got[1].BundledCodes) != 2 || got[1].BundledCodes[0].BillingCode != "59510" {
+		t.Errorf("bundled codes %+v", got[1].BundledCodes)
+	}
+	if p.RateCount() != 2 || p.LastUpdatedOn() != "2026-01-05" {
+		t.Errorf("%d rates last updated on %q", p.RateCount(), p.LastUpdatedOn())
+	}
+
+	// only the references the rates use, npis as numbers or strings
+	refs := p.ProviderReferences()
+	var ids []string
+	for _, ref := range refs {
+		ids = append(ids, ref.ProviderGroupID.String())
+	}
+	if strings.Join(ids, ",") != "1,2,3" {
+		t.Errorf("provider references %v, want 1,2,3", ids)
+	}
+	if npis := refs[0].ProviderGroups[0].NPI; len(npis) != 2 || npis[0] != "1487654321" || npis[1] != "1234567893" {
+		t.Errorf("npis %v", npis)
+	}
+	if refs[1].Location != "https://example.com/providers/2.json" || len(refs[1].ProviderGroups) != 0 {
+		t.Errorf("remote reference %+v left as published", refs[1])
+	}
+}
+
+func TestParseCodes(t *testing.T) {
+	var got []rates.Rate
+	p := rates.New(rates.Options{Codes: []string{" 788 "}, OnRate: func(r rates.Rate) { got = append(got, r) }})
+	if err := p.Parse(strings.NewReader(rateFile(""))); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].BillingCode != "788" {
+		t.Fatalf("parsed %+v, want only the case rate", got)
+	}
+	if refs := p.ProviderReferences(); len(refs) != 1 || refs[0].ProviderGroupID.String() != "3" {
+		t.Errorf("provider references %+v, want those of the case rate", refs)
+	}
+
+	// provider reference ids are local to a file
+	if err := p.Parse(strings.NewReader(`{"in_network": []}`)); err != nil {
+		t.Fatal(err)
+	}
+	if refs := p.ProviderReferences(); len(refs) != 0 {
+		t.Errorf("references of the previous file kept: %+v", refs)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(rateFile("")))
+	zw.Close()
+
+	local := filepath.Join(t.TempDir(), "2026-01_anthem_rates.json.gz")
+	if err := os.WriteFile(local, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/in-network/anthem_rates.json.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(gz.Bytes())
+	}))
+	defer server.Close()
+
+	for _, location := range []string{local, server.URL + "/in-network/anthem_rates.json.gz?sig=abc"} {
+		p := rates.New(rates.Options{})
+		if err := p.ParseFile(location); err != nil {
+			t.Errorf("ParseFile(%s): %v", location, err)
+			continue
+		}
+		if p.RateCount() != 2 {
+			t.Errorf("%s: %d rates, want 2", location, p.RateCount())
+		}
+	}
+	if err := rates.New(rates.Options{}).ParseFile(server.URL + "/in-network/missing.json"); err == nil {
+		t.Error("parsed a missing url")
+	}
+}
+
+func TestParseFileContext(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "rates.json")
+	os.WriteFile(local, []byte(rateFile("")), 0o644)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rates.New(rates.Options{}).ParseFileContext(ctx, local); !errors.Is(err, context.Canceled) {
+		t.Errorf("parse of a cancelled context returned %v", err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, file := range []string{
+		`[]`,
+		`{"in_network": {}}`,
+		`{"in_network": [{"billing_code": 99213}]}`,
+		`{"provider_references": [{"provider_group_id": "one"}]}`,
+		`{"in_network": [`,
+	} {
+		if err := rates.New(rates.Options{}).Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("parsed %s", file)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package rates
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SampleOptions bounds how much of a rate file Sample reads.
+type SampleOptions struct {
+	// MaxBytes stops reading after this many bytes of the file as stored,
+	// compressed or not, 0 reads it all.
+	MaxBytes int64
+	// Records checks a uniform random sample of this many in_network
+	// elements, 0 checks every element read.
+	Records int
+	// Seed makes the sample reproducible.
+	Seed int64
+}
+
+// SampleReport is the schema conformance and basic statistics of the part of
+// a rate file that was read.
+type SampleReport struct {
+	BytesRead int64 `json:"bytesRead"`
+	// Truncated is set when the file did not end within MaxBytes.
+	Truncated bool `json:"truncated"`
+	// Records is how many in_network elements were read, Checked how many
+	// of them were checked.
+	Records            int            `json:"records"`
+	Checked            int            `json:"checked"`
+	ProviderReferences int            `json:"providerReferences"`
+	Violations         []Violation    `json:"violations"`
+	BillingCodeTypes   map[string]int `json:"billingCodeTypes"`
+	Arrangements       map[string]int `json:"arrangements"`
+	PriceTypes         map[string]int `json:"priceTypes"`
+	Prices             int            `json:"prices"`
+	MinPrice           float64        `json:"minPrice"`
+	MaxPrice           float64        `json:"maxPrice"`
+}
+
+// Violation is a schema rule broken by Count of the checked elements.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+var (
+	rootRequired       = []string{"reporting_entity_name", "reporting_entity_type", "last_updated_on", "version", "in_network"}
+	rateRequired       = []string{"negotiation_arrangement", "name", "billing_code_type", "billing_code_type_version", "billing_code", "description", "negotiated_rates"}
+	arrangements       = []string{"ffs", "bundle", "capitation"}
+	negotiatedTypes    = []string{"negotiated", "derived", "fee schedule", "percentage", "per diem"}
+	billingClasses     = []string{"professional", "institutional"}
+	errSampleTruncated = errors.New("sample truncated")
+)
+
+// SampleFile is Sample of a file, gzip compressed when its name ends in .gz.
+func SampleFile(filename string, opts SampleOptions) (*SampleReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open file stream: %s - %w", filename, err)
+	}
+	defer f.Close()
+
+	counter := &countingReader{r: f, limit: opts.MaxBytes}
+	var r io.Reader = counter
+	if strings.HasSuffix(strings.ToLower(filename), ".gz") {
+		gr, err := gzip.NewReader(counter)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip stream: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	report, err := Sample(r, opts)
+	if report != nil {
+		report.BytesRead = counter.n
+		report.Truncated = counter.limited
+	}
+	if err != nil && counter.limited {
+		// running out of the byte budget mid document is expected
+		err = nil
+	}
+	return report, err
+}
+
+// Sample reads an uncompressed rate file document from r, checking its
+// in_network elements against the CMS in-network schema. The report covers
+// whatever was read before r ended, also when it ended mid document.
+func Sample(r io.Reader, opts SampleOptions) (*SampleReport, error) {
+	s := &sampler{
+		report: &SampleReport{
+			BillingCodeTypes: make(map[string]int),
+			Arrangements:     make(map[string]int),
+			PriceTypes:       make(map[string]int),
+		},
+		opts:       opts,
+		rng:        rand.New(rand.NewSource(opts.Seed)),
+		violations: make(map[string]*Violation),
+	}
+	err := s.parse(json.NewDecoder(r))
+	s.finish()
+	return s.report, err
+}
+
+type sampler struct {
+	report     *SampleReport
+	opts       SampleOptions
+	rng        *rand.Rand
+	reservoir  []json.RawMessage
+	violations map[string]*Violation
+	rootKeys   map[string]struct{}
+}
+
+func (s *sampler) parse(dec *json.Decoder) error {
+	s.rootKeys = make(map[string]struct{})
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read root token: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return errors.New("expected root object")
+	}
+
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("read root key: %w", err)
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return errors.New("unexpected non-string key at root")
+		}
+		s.rootKeys[key] = struct{}{}
+
+		switch key {
+		case "in_network":
+			err = s.parseInNetwork(dec)
+		case "provider_references":
+			err = s.parseProviderReferences(dec)
+		default:
+			var discard json.RawMessage
+			err = dec.Decode(&discard)
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", key, err)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("close root object: %w", err)
+	}
+
+	for _, key := range rootRequired {
+		if _, ok := s.rootKeys[key]; !ok {
+			s.violate("root: "+key+" is required", "")
+		}
+	}
+	return nil
+}
+
+func (s *sampler) parseInNetwork(dec *json.Decoder) error {
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if d, ok := tok.(json.Delim); !ok || d != '[' {
+		s.violate("root: in_network must be an array", "")
+		return errors.New("in_network is not an array")
+	}
+
+	for dec.More() {
+		var element json.RawMessage
+		if err := dec.Decode(&element); err != nil {
+			return err
+		}
+		s.report.Records++
+
+		switch {
+		case s.opts.Records <= 0:
+			s.check(element)
+		case len(s.reservoir) < s.opts.Records:
+			s.reservoir = append(s.reservoir, element)
+		default:
+			if i := s.rng.Intn(s.report.Records); i < s.opts.Records {
+				s.reservoir[i] = element
+			}
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+func (s *sampler) parseProviderReferences(dec *json.Decoder) error {
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if d, ok := tok.(json.Delim); !ok || d != '[' {
+		s.violate("root: provider_references must be an array", "")
+		return errors.New("provider_references is not an array")
+	}
+
+	for dec.More() {
+		var ref json.RawMessage
+		if err := dec.Decode(&ref); err != nil {
+			return err
+		}
+		s.report.ProviderReferences++
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+// finish checks the sampled elements once the reservoir is final.
+func (s *sampler) finish() {
+	for _, element := range s.reservoir {
+		s.check(element)
+	}
+
+	report := s.report
+	for _, v := range s.violations {
+		report.Violations = append(report.Violations, *v)
+	}
+	sort.Slice(report.Violations, func(i, j int) bool {
+		if report.Violations[i].Count != report.Violations[j].Count {
+			return report.Violations[i].Count > report.Violations[j].Count
+		}
+		return report.Violations[i].Rule < report.Violations[j].Rule
+	})
+	if report.Violations == nil {
+		report.Violations = []Violation{}
+	}
+}
+
+// check validates one in_network element and adds it to the statistics.
+func (s *sampler) check(element json.RawMessage) {
+	s.report.Checked++
+
+	var rate map[string]any
+	if err := json.Unmarshal(element, &rate); err != nil {
+		s.violate("in_network: element must be an object", string(element))
+		return
+	}
+	code, _ := rate["billing_code"].(string)
+
+	for _, key := range rateRequired {
+		if _, ok := rate[key]; !ok {
+			s.violate("in_network: "+key+" is required", code)
+		}
+	}
+	if codeType, ok := rate["billing_code_type"].(string); ok {
+		s.report.BillingCodeTypes[codeType]++
+	}
+	if arrangement, ok := rate["negotiation_arrangement"].(string); ok {
+		s.report.Arrangements[arrangement]++
+		if !slices.Contains(arrangements, arrangement) {
+			s.violate("in_network: negotiation_arrangement must be one of "+strings.Join(arrangements, ", "), code)
+		}
+	}
+	if arrangement := rate["negotiation_arrangement"]; arrangement == "bundle" {
+		if _, ok := rate["bundled_codes"]; !ok {
+			s.violate("in_network: bundled_codes is required for bundle arrangements", code)
+		}
+	}
+
+	negotiatedRates, ok := rate["negotiated_rates"].([]any)
+	if _, present := rate["negotiated_rates"]; present && (!ok || len(negotiatedRates) == 0) {
+		s.violate("in_network: negotiated_rates must be a non-empty array", code)
+	}
+	for _, nr := range negotiatedRates {
+		negotiatedRate, ok := nr.(map[string]any)
+		if !ok {
+			s.violate("negotiated_rates: element must be an object", code)
+			continue
+		}
+		_, hasRefs := negotiatedRate["provider_references"]
+		_, hasGroups := negotiatedRate["provider_groups"]
+		if !hasRefs && !hasGroups {
+			s.violate("negotiated_rates: provider_references or provider_groups is required", code)
+		}
+		prices, ok := negotiatedRate["negotiated_prices"].([]any)
+		if !ok || len(prices) == 0 {
+			s.violate("negotiated_rates: negotiated_prices must be a non-empty array", code)
+		}
+		for _, p := range prices {
+			price, ok := p.(map[string]any)
+			if !ok {
+				s.violate("negotiated_prices: element must be an object", code)
+				continue
+			}
+			s.checkPrice(price, code)
+		}
+	}
+}
+
+func (s *sampler) checkPrice(price map[string]any, code string) {
+	negotiatedType, _ := price["negotiated_type"].(string)
+	if !slices.Contains(negotiatedTypes, negotiatedType) {
+		s.violate("negotiated_prices: negotiated_type must be one of "+strings.Join(negotiatedTypes, ", "), code)
+	} else {
+		s.report.PriceTypes[negotiatedType]++
+	}
+
+	if amount, ok := price["negotiated_rate"].(float64); !ok {
+		s.violate("negotiated_prices: negotiated_rate must be a number", code)
+	} else if negotiatedType != "percentage" {
+		if s.report.Prices == 0 || amount < s.report.MinPrice {
+			s.report.MinPrice = amount
+		}
+		if s.report.Prices == 0 || amount > s.report.MaxPrice {
+			s.report.MaxPrice = amount
+		}
+		s.report.Prices++
+	}
+
+	if _, ok := price["expiration_date"].(string); !ok {
+		s.violate("negotiated_prices: expiration_date is required", code)
+	}
+	billingClass, _ := price["billing_class"].(string)
+	if !slices.Contains(billingClasses, billingClass) {
+		s.violate("negotiated_prices: billing_class must be professional or institutional", code)
+	}
+	if _, ok := price["service_code"]; !ok && billingClass == "professional" {
+		s.violate("negotiated_prices: service_code is required for professional prices", code)
+	}
+}
+
+func (s *sampler) violate(rule string, example string) {
+	v, exists := s.violations[rule]
+	if !exists {
+		v = &Violation{Rule: rule, Example: example}
+		s.violations[rule] = v
+	}
+	v.Count++
+}
+
+// countingReader counts the bytes read and ends the stream after limit
+// bytes, unless it is 0.
+type countingReader struct {
+	r       io.Reader
+	n       int64
+	limit   int64
+	limited bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.limit > 0 {
+		if c.n >= c.limit {
+			// a file of exactly limit bytes is complete
+			var probe [1]byte
+			if n, err := c.r.Read(probe[:]); n == 0 && err != nil {
+				return 0, err
+			}
+			c.limited = true
+			return 0, errSampleTruncated
+		}
+		if remaining := c.limit - c.n; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
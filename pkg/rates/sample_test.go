@@ -0,0 +1,137 @@
+package rates_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serif_interview/pkg/rates"
+)
+
+func TestSample(t *testing.T) {
+	report, err := rates.Sample(strings.NewReader(rateFile("https://example.com/providers/2.json")), rates.SampleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Records != 2 || report.Checked != 2 || report.ProviderReferences != 4 {
+		t.Errorf("%d records, %d checked, %d provider references", report.Records, report.Checked, report.ProviderReferences)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("violations in a valid file: %+v", report.Violations)
+	}
+	if report.Prices != 4 || report.MinPrice != 95 || report.MaxPrice != 12000 {
+		t.Errorf("%d prices from %v to %v", report.Prices, report.MinPrice, report.MaxPrice)
+	}
+	if report.BillingCodeTypes["MS-DRG"] != 1 || report.Arrangements["bundle"] != 1 || report.PriceTypes["fee schedule"] != 1 {
+		t.Errorf("statistics %v %v %v", report.BillingCodeTypes, report.Arrangements, report.PriceTypes)
+	}
+}
+
+func TestSampleViolations(t *testing.T) {
+	file := `{
+  "reporting_entity_name": "Anthem",
+  "in_network": [
+    {"negotiation_arrangement": "fee", "billing_code": "99213", "negotiated_rates": [
+      {"negotiated_prices": [{"negotiated_type": "negotiated", "negotiated_rate": "110", "billing_class": "professional"}]}
+    ]},
+    {"negotiation_arrangement": "bundle", "name": "Cesarean delivery", "billing_code_type": "MS-DRG", "billing_code_type_version": "2026",
+     "billing_code": "788", "description": "Cesarean section", "negotiated_rates": []}
+  ]
+}`
+	report, err := rates.Sample(strings.NewReader(file), rates.SampleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, v := range report.Violations {
+		got[v.Rule] = fmt.Sprintf("%d %s", v.Count, v.Example)
+	}
+	for rule, want := range map[string]string{
+		"root: version is required":    "1 ",
+		"in_network: name is required": "1 99213",
+		"in_network: negotiation_arrangement must be one of ffs, bundle, capitation": "1 99213",
+		"in_network: bundled_codes is required for bundle arrangements":              "1 788",
+		"in_network: negotiated_rates must be a non-empty array":                     "1 788",
+		"negotiated_rates: provider_references or provider_groups is required":       "1 99213",
+		"negotiated_prices: negotiated_rate must be a number":                        "1 99213",
+		"negotiated_prices: expiration_date is required":                             "1 99213",
+		"negotiated_prices: service_code is required for professional prices":        "1 99213",
+	} {
+		if got[rule] != want {
+			t.Errorf("%s: got %q, want %q", rule, got[rule], want)
+		}
+	}
+	// the most frequent first
+	for i := 1; i < len(report.Violations); i++ {
+		if report.Violations[i].Count > report.Violations[i-1].Count {
+			t.Errorf("violations not ordered by count: %+v", report.Violations)
+		}
+	}
+}
+
+func TestSampleRecords(t *testing.T) {
+	var file strings.Builder
+	file.WriteString(`{"in_network": [`)
+	for i := range 500 {
+		if i > 0 {
+			file.WriteString(",")
+		}
+		fmt.Fprintf(&file, `{"billing_code": "%d", "negotiation_arrangement": "ffs"}`, i)
+	}
+	file.WriteString(`]}`)
+
+	sample := func(seed int64) *rates.SampleReport {
+		report, err := rates.Sample(strings.NewReader(file.String()), rates.SampleOptions{Records: 20, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	report := sample(7)
+	if report.Records != 500 || report.Checked != 20 {
+		t.Errorf("%d records read and %d checked, want 500 and 20", report.Records, report.Checked)
+	}
+	example := func(r *rates.SampleReport) string {
+		for _, v := range r.Violations {
+			if v.Rule == "in_network: name is required" {
+				return v.Example
+			}
+		}
+		return ""
+	}
+	if example(report) != example(sample(7)) {
+		t.Error("the same seed picked a different sample")
+	}
+}
+
+func TestSampleFileTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw, _ := gzip.NewWriterLevel(f, gzip.NoCompression)
+	zw.Write([]byte(rateFile("")))
+	zw.Close()
+	f.Close()
+	info, _ := os.Stat(path)
+
+	report, err := rates.SampleFile(path, rates.SampleOptions{MaxBytes: 1500})
+	if err != nil {
+		t.Fatalf("a truncated sample failed: %v", err)
+	}
+	if !report.Truncated || report.BytesRead != 1500 || report.Records != 1 {
+		t.Errorf("truncated %v after %d bytes with %d records, want 1500 bytes and the first record", report.Truncated, report.BytesRead, report.Records)
+	}
+
+	report, err = rates.SampleFile(path, rates.SampleOptions{MaxBytes: info.Size()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Truncated || report.Records != 2 {
+		t.Errorf("file of exactly MaxBytes truncated %v with %d records", report.Truncated, report.Records)
+	}
+}